@@ -1,14 +1,26 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/abhaybhargav/firecracker-orchestrator/internal/config"
 	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
 	"github.com/abhaybhargav/firecracker-orchestrator/pkg/api"
 	"github.com/abhaybhargav/firecracker-orchestrator/pkg/firecracker"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/ipam"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/jobs"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/policy"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/replication"
 	"github.com/gin-gonic/gin"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/sirupsen/logrus"
@@ -27,6 +39,15 @@ func main() {
 	logger.SetLevel(level)
 	logger.SetFormatter(&logrus.JSONFormatter{})
 
+	if cfg.LogFile != "" {
+		logFile, err := os.OpenFile(cfg.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+		if err != nil {
+			logger.Fatalf("Failed to open log file: %v", err)
+		}
+		defer logFile.Close()
+		logger.SetOutput(io.MultiWriter(os.Stderr, logFile))
+	}
+
 	logger.Info("Starting Firecracker Orchestrator")
 
 	// Initialize database
@@ -45,12 +66,133 @@ func main() {
 	}
 	defer db.Close()
 
+	keys := cfg.EncryptionKeys
+	if cfg.EncryptionKeyFile != "" {
+		data, err := os.ReadFile(cfg.EncryptionKeyFile)
+		if err != nil {
+			logger.Fatalf("Failed to read encryption key file: %v", err)
+		}
+		keys = strings.TrimSpace(string(data))
+	}
+	if keys != "" {
+		keyring, err := database.ParseKeyring(keys)
+		if err != nil {
+			logger.Fatalf("Invalid encryption keys: %v", err)
+		}
+		db.SetKeyring(keyring)
+		logger.Infof("Encrypting sensitive columns with key %s", keyring.Primary())
+	} else if cfg.EncryptVMFiles {
+		logger.Fatalf("ENCRYPT_VM_FILES needs ENCRYPTION_KEYS or ENCRYPTION_KEY_FILE")
+	}
+
 	logger.Info("Database initialized successfully")
 
 	// Initialize Firecracker manager
 	vmManager := firecracker.NewManager(cfg, db, logger)
 	logger.Info("Firecracker manager initialized")
 
+	// Report host problems up front rather than on the first VM start
+	for _, check := range vmManager.Preflight() {
+		if !check.OK {
+			logger.Errorf("Preflight check %s failed: %s", check.Name, check.Message)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if cfg.MetricsSampleSeconds > 0 {
+		go vmManager.RunNetworkSampler(ctx, time.Duration(cfg.MetricsSampleSeconds)*time.Second)
+	}
+	if cfg.DriftCheckSeconds > 0 {
+		go vmManager.RunDriftDetector(ctx, time.Duration(cfg.DriftCheckSeconds)*time.Second)
+	}
+	var replicator *replication.Replicator
+	if cfg.ReplicaURL != "" && cfg.ReplicaSeconds > 0 {
+		store, err := replication.NewStore(cfg)
+		if err != nil {
+			logger.Fatalf("Failed to set up database replication: %v", err)
+		}
+		replicator = replication.NewReplicator(db, store, filepath.Join(filepath.Dir(cfg.DatabasePath), ".replica"), logger)
+		go replicator.Run(ctx, time.Duration(cfg.ReplicaSeconds)*time.Second)
+	}
+	if cfg.ReapIntervalSeconds > 0 {
+		go vmManager.RunZombieReaper(ctx, time.Duration(cfg.ReapIntervalSeconds)*time.Second)
+	}
+
+	// Settle VMs a previous run left half-created before workers pick them up
+	if err := jobs.RecoverVMs(db, logger); err != nil {
+		logger.Errorf("Failed to recover interrupted VMs: %v", err)
+	}
+
+	if _, err := ipam.New(nil, cfg.VMSubnet, cfg.VMIPPool); err != nil {
+		logger.Fatalf("Invalid guest address configuration: %v", err)
+	}
+	if !firecracker.ValidMACPrefix(cfg.MACPrefix) {
+		logger.Fatalf("MAC_PREFIX must be three unicast octets like 02:fc:00, not %q", cfg.MACPrefix)
+	}
+
+	if err := vmManager.EnsureBridge(); err != nil {
+		logger.Fatalf("Failed to set up VM bridge: %v", err)
+	}
+	if err := vmManager.EnsureNAT(); err != nil {
+		logger.Fatalf("Failed to set up NAT for guests: %v", err)
+	}
+	dhcpServer, err := vmManager.DHCPServer()
+	if err != nil {
+		logger.Fatalf("Invalid DHCP configuration: %v", err)
+	}
+	if dhcpServer != nil {
+		go func() {
+			if err := dhcpServer.Run(ctx); err != nil {
+				logger.Errorf("DHCP server stopped: %v", err)
+			}
+		}()
+	}
+
+	// Adopt VMs still running from the previous run and clean up after the rest
+	if err := vmManager.Reconcile(); err != nil {
+		logger.Errorf("Failed to reconcile VMs: %v", err)
+	}
+
+	if !firecracker.ValidOutputMode(cfg.VMOutputMode) {
+		logger.Fatalf("VM_OUTPUT_MODE must be discard, file or buffer, not %q", cfg.VMOutputMode)
+	}
+	if !firecracker.ValidRootfsMode(cfg.RootfsMode) {
+		logger.Fatalf("ROOTFS_MODE must be copy or overlay, not %q", cfg.RootfsMode)
+	}
+	if !firecracker.ValidRestartPolicy(cfg.RestartPolicy) {
+		logger.Fatalf("RESTART_POLICY must be never, on-failure or always, not %q", cfg.RestartPolicy)
+	}
+	if !firecracker.ValidShutdownPolicy(cfg.ShutdownVMPolicy) {
+		logger.Fatalf("SHUTDOWN_VM_POLICY must be stop or detach, not %q", cfg.ShutdownVMPolicy)
+	}
+	if cfg.JobHeartbeatSeconds < 1 {
+		logger.Fatalf("JOB_HEARTBEAT_TIMEOUT must be at least 1 second, not %d", cfg.JobHeartbeatSeconds)
+	}
+	if cfg.JobWorkers < 1 {
+		logger.Fatalf("JOB_WORKERS must be at least 1, not %d", cfg.JobWorkers)
+	}
+
+	if cfg.IdleAfterHours > 0 && cfg.IdleCheckSeconds > 0 {
+		if !firecracker.ValidIdleAction(cfg.IdleAction) {
+			logger.Fatalf("IDLE_ACTION must be none, notify, stop or delete, not %q", cfg.IdleAction)
+		}
+		idlePolicy := jobs.IdlePolicy{After: time.Duration(cfg.IdleAfterHours) * time.Hour, Action: cfg.IdleAction}
+		go jobs.RunIdleReaper(ctx, db, vmManager, logger, idlePolicy, time.Duration(cfg.IdleCheckSeconds)*time.Second)
+	}
+
+	// Start job workers
+	queue := jobs.NewQueue(db, logger,
+		time.Duration(cfg.JobHeartbeatSeconds)*time.Second,
+		time.Duration(cfg.JobRetentionDays)*24*time.Hour)
+	jobs.RegisterVMHandlers(queue, vmManager, db)
+	queueDone := make(chan struct{})
+	go func() {
+		queue.Run(ctx, cfg.JobWorkers)
+		close(queueDone)
+	}()
+
 	// Setup Gin router
 	if cfg.LogLevel != "debug" {
 		gin.SetMode(gin.ReleaseMode)
@@ -74,15 +216,57 @@ func main() {
 		c.Next()
 	})
 
+	// Load resource policies; a broken rule stops startup rather than admitting everything
+	var policies *policy.Engine
+	if cfg.PolicyFile != "" {
+		if policies, err = policy.Load(cfg.PolicyFile); err != nil {
+			logger.Fatalf("Failed to load policies: %v", err)
+		}
+		logger.Infof("Loaded %d policy rules from %s", policies.Len(), cfg.PolicyFile)
+	}
+
 	// Initialize API server
-	apiServer := api.NewServer(vmManager, db, logger)
+	apiServer := api.NewServer(cfg, vmManager, db, policies, logger)
+	auth, err := api.NewAuthenticator(cfg, db)
+	if err != nil {
+		logger.Fatalf("Failed to set up authentication: %v", err)
+	}
+	if auth != nil {
+		apiServer.SetAuthenticator(auth)
+		logger.Infof("API requests are authenticated by %s", cfg.AuthProvider)
+	}
+
+	// SIGHUP rereads the authenticator's configuration, e.g. the token file
+	if reloader, ok := auth.(api.Reloader); ok {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if err := reloader.Reload(); err != nil {
+					logger.Errorf("Failed to reload %s authenticator, keeping the previous configuration: %v", cfg.AuthProvider, err)
+					continue
+				}
+				logger.Infof("Reloaded %s authenticator", cfg.AuthProvider)
+			}
+		}()
+	}
 	apiServer.SetupRoutes(r)
 
+	// Request contexts are cancelled as soon as shutdown begins, which ends
+	// followed logs and TCP tunnels; other requests are left to finish
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	srv := &http.Server{
+		Addr:        cfg.Address(),
+		Handler:     r,
+		BaseContext: func(net.Listener) context.Context { return requestCtx },
+	}
+	srv.RegisterOnShutdown(cancelRequests)
+
 	logger.Infof("Server starting on %s", cfg.Address())
 
 	// Start server in a goroutine
 	go func() {
-		if err := r.Run(cfg.Address()); err != nil {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.Fatalf("Failed to start server: %v", err)
 		}
 	}()
@@ -94,10 +278,40 @@ func main() {
 	<-c
 	logger.Info("Shutting down server...")
 
-	// TODO: Implement graceful shutdown
-	// - Stop all running VMs
-	// - Close database connections
-	// - Clean up resources
+	// Stop accepting connections and wait for in-flight requests, then for
+	// running jobs, within the shutdown timeout
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), time.Duration(cfg.DrainTimeoutSeconds)*time.Second)
+	defer cancelShutdown()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Warnf("Requests still running after %ds were cut off: %v", cfg.DrainTimeoutSeconds, err)
+		srv.Close()
+	}
+
+	cancel()
+	select {
+	case <-queueDone:
+	case <-shutdownCtx.Done():
+		logger.Warn("Running jobs did not finish in time; they will be retried on the next start")
+	}
+
+	// Stop or leave the VMs once nothing else can start one, with a drain
+	// timeout of their own
+	vmCtx, cancelVMs := context.WithTimeout(context.Background(), time.Duration(cfg.DrainTimeoutSeconds)*time.Second)
+	defer cancelVMs()
+	vmManager.Shutdown(vmCtx, cfg.ShutdownVMPolicy)
+
+	// Ship the final state once jobs and VMs have settled
+	if replicator != nil {
+		if err := replicator.Replicate(vmCtx); err != nil {
+			logger.Errorf("Failed to replicate the database on shutdown: %v", err)
+		}
+	}
+
+	// Close explicitly so a failure is logged; the deferred Close is then a no-op
+	if err := db.Close(); err != nil {
+		logger.Errorf("Failed to close the database: %v", err)
+	}
 
 	logger.Info("Server stopped")
 }
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 )
 
@@ -16,44 +17,247 @@ type Config struct {
 	DatabasePath   string
 	DatabaseDriver string // "sqlite3" (CGO) or "sqlite" (pure Go)
 
+	// Encryption at rest
+	EncryptionKeys    string // comma-separated id:base64-key pairs, primary first
+	EncryptionKeyFile string // file holding the same list, e.g. written by a KMS agent
+	EncryptVMFiles    bool   // also encrypt the per-VM metadata files
+
+	// Database replication
+	ReplicaURL     string // file:///dir or s3://bucket/prefix; empty disables replication
+	ReplicaSeconds int    // how often a snapshot is taken and shipped if it changed
+	S3Endpoint     string // S3-compatible endpoint; defaults to AWS for S3Region
+	S3Region       string
+	S3AccessKey    string
+	S3SecretKey    string
+	S3SessionToken string
+
 	// Firecracker configuration
 	FirecrackerBinary string
 	KernelPath        string
 	RootfsPath        string
 	SocketDir         string
+	KVMDevice         string // bind-mounted over /dev/kvm for Firecracker when different
+	SystemdScope      bool   // run each VM in a transient systemd scope via systemd-run
+	SystemdSlice      string // slice the VM scopes are placed in, if set
+	VMDiskDir         string // each VM's copy of RootfsPath, or its overlay, is created here
+	RootfsMode        string // copy or overlay, for VMs that don't set rootfs_mode
+	RootfsOverlayInit string // guest init that mounts the overlay drive in overlay mode
+	VolumesDir        string // images attached as secondary drives must be inside this directory
+
+	// Rescue mode
+	RescueKernelPath string // kernel rescue boots use; KernelPath when empty
+	RescueRootfsPath string // rescue system image; rescue mode is off when empty
+
+	// VM logging
+	VMLogDir            string // per-VM log directories are created here
+	FirecrackerLogLevel string // default Firecracker log level for VMs that don't set one
+
+	// VM console output
+	VMOutputMode     string // discard, file or buffer, for VMs that don't set output_mode
+	VMOutputBufferKB int    // console output kept in memory per VM in buffer mode
+
+	// Image verification
+	KernelSHA256      string // expected checksum of KernelPath, if set
+	RootfsSHA256      string // expected checksum of RootfsPath, if set
+	MinisignPublicKey string // verifies <image>.minisig signatures, if set
+	StrictImageVerify bool   // refuse to boot images that were not verified
 
 	// Networking configuration
-	BridgeName    string
-	TAPDeviceBase string
+	BridgeName       string
+	TAPDeviceBase    string
+	VMSubnet         string // CIDR guest addresses are assigned from; .1 is the gateway
+	VMIPPool         string // CIDR inside VMSubnet addresses are assigned from automatically; empty means .10 up
+	MACPrefix        string // OUI guest MAC addresses are generated under, as three octets
+	EnableNAT        bool   // masquerade guest traffic out of the uplink so guests reach the internet
+	UplinkInterface  string // interface NATed traffic leaves through; empty uses the default route's
+	EnableDHCP       bool   // answer guest DHCP requests on the bridge with their VM's address
+	DHCPDNSServers   string // comma-separated DNS servers handed out over DHCP
+	DHCPLeaseSeconds int    // lease time handed out over DHCP
+	InjectHosts      bool   // serve an /etc/hosts fragment for all VMs over MMDS
+	ForwardPortMin   int    // lowest host port port forwards may publish
+	ForwardPortMax   int    // highest host port port forwards may publish
 
 	// VM defaults
 	DefaultMemoryMB int64
 	DefaultCPUs     int
 	DefaultDiskGB   int64
+	EnableEntropy   bool // attach a virtio-rng device unless the VM request says otherwise
+
+	// Host capacity, enforced for VM creation and reservations when non-zero
+	HostMemoryMB int64
+	HostCPUs     int
+
+	// Cost rates for VM cost estimates; all zero disables the estimates
+	CostCurrency       string
+	CostPerVCPUHour    float64
+	CostPerGBHour      float64 // per GB of memory
+	CostPerDiskGBMonth float64
+
+	// Naming
+	NameMaxLength int // VM and container names must be DNS labels no longer than this (at most 63)
+
+	// Container placement
+	AutoProvisionVMs bool // create a VM when no running VM can take a container
+
+	// Metrics
+	MetricsSampleSeconds int   // how often per-VM network counters are recorded
+	DriftCheckSeconds    int   // how often VM config files are compared with their specs
+	ClockSkewThresholdMS int64 // guest clock skew beyond this is flagged
+
+	// Idle VMs
+	IdleAfterHours   int    // VMs idle this long get IdleAction; 0 disables the idle reaper
+	IdleAction       string // notify, stop or delete; a VM's idle_action overrides it
+	IdleCheckSeconds int    // how often VM activity is sampled
+	IdleCPUPercent   int    // a VM below this share of one host CPU...
+	IdleNetworkBPS   int64  // ...and below this many network bytes per second is idle
+
+	// Resource policies
+	PolicyFile string // YAML rules evaluated against VM and container creates and updates; empty disables them
+
+	// Authentication
+	AuthProvider  string // registered authenticator API requests must pass; empty leaves the API open
+	AuthPlugin    string // Go plugin loaded at startup to register authenticators
+	AuthTokenFile string // YAML file of hashed bearer tokens for AUTH_PROVIDER=static-token
+
+	// Local accounts, for AUTH_PROVIDER=local
+	PasswordMinLength  int    // shortest password local accounts may set
+	SessionTTLHours    int    // how long a login stays valid
+	LocalAdminPassword string // initial password of the "admin" account created when there are no users
+
+	// Lifecycle hooks
+	HooksDir           string // exec hooks must be executables inside this directory
+	HookPrivateNetwork bool   // let webhook hooks reach private addresses; loopback and link-local stay blocked
+
+	// Job queue
+	JobWorkers          int // concurrent job workers in this process
+	JobHeartbeatSeconds int // a running job is requeued when its worker is silent this long
+	JobRetentionDays    int // finished jobs older than this are deleted; 0 keeps them
+
+	// Process management
+	ReapIntervalSeconds int // how often exited children nobody waits for are collected
+
+	// Restarting VMs whose Firecracker process exits on its own
+	RestartPolicy       string // never, on-failure or always, for VMs that don't set restart_policy
+	RestartDelaySeconds int    // delay before the first restart, doubled for each one after
+	RestartMaxDelay     int    // longest delay between restarts, in seconds
+	RestartResetSeconds int    // a VM up this long starts over from the first delay
+	RestartMaxAttempts  int    // restarts in a row before giving up; 0 never gives up
+
+	// Stopping VMs
+	StopTimeoutSeconds int // how long a guest gets to shut down after Ctrl-Alt-Del before it is killed; 0 kills at once
+
+	// Shutdown
+	DrainTimeoutSeconds int    // how long in-flight requests and jobs get to finish on SIGTERM
+	ShutdownVMPolicy    string // stop or detach running VMs on SIGTERM
 
 	// Logging
 	LogLevel string
+	LogFile  string // structured logs are also appended here when set, for the admin log API
 }
 
 // LoadConfig loads configuration from environment variables with defaults
 func LoadConfig() *Config {
 	config := &Config{
-		Host:              getEnv("HOST", "0.0.0.0"),
-		Port:              getEnvAsInt("PORT", 8080),
-		DatabasePath:      getEnv("DATABASE_PATH", "./orchestrator.db"),
-		DatabaseDriver:    getEnv("DATABASE_DRIVER", "sqlite"), // Default to pure Go
-		FirecrackerBinary: getEnv("FIRECRACKER_BINARY", "/usr/bin/firecracker"),
-		KernelPath:        getEnv("KERNEL_PATH", "./vm-images/vmlinux.bin"),
-		RootfsPath:        getEnv("ROOTFS_PATH", "./vm-images/rootfs.ext4"),
-		SocketDir:         getEnv("SOCKET_DIR", "/tmp/firecracker"),
-		BridgeName:        getEnv("BRIDGE_NAME", "fc-br0"),
-		TAPDeviceBase:     getEnv("TAP_DEVICE_BASE", "fc-tap"),
-		DefaultMemoryMB:   getEnvAsInt64("DEFAULT_MEMORY_MB", 512),
-		DefaultCPUs:       getEnvAsInt("DEFAULT_CPUS", 1),
-		DefaultDiskGB:     getEnvAsInt64("DEFAULT_DISK_GB", 2),
-		LogLevel:          getEnv("LOG_LEVEL", "info"),
+		Host:                 getEnv("HOST", "0.0.0.0"),
+		Port:                 getEnvAsInt("PORT", 8080),
+		DatabasePath:         getEnv("DATABASE_PATH", "./orchestrator.db"),
+		DatabaseDriver:       getEnv("DATABASE_DRIVER", "sqlite"), // Default to pure Go
+		EncryptionKeys:       getEnv("ENCRYPTION_KEYS", ""),
+		EncryptionKeyFile:    getEnv("ENCRYPTION_KEY_FILE", ""),
+		EncryptVMFiles:       getEnvAsBool("ENCRYPT_VM_FILES", false),
+		ReplicaURL:           getEnv("REPLICA_URL", ""),
+		ReplicaSeconds:       getEnvAsInt("REPLICA_INTERVAL", 60),
+		S3Endpoint:           getEnv("S3_ENDPOINT", ""),
+		S3Region:             getEnv("AWS_REGION", "us-east-1"),
+		S3AccessKey:          getEnv("AWS_ACCESS_KEY_ID", ""),
+		S3SecretKey:          getEnv("AWS_SECRET_ACCESS_KEY", ""),
+		S3SessionToken:       getEnv("AWS_SESSION_TOKEN", ""),
+		FirecrackerBinary:    getEnv("FIRECRACKER_BINARY", "/usr/bin/firecracker"),
+		KernelPath:           getEnv("KERNEL_PATH", "./vm-images/vmlinux.bin"),
+		RootfsPath:           getEnv("ROOTFS_PATH", "./vm-images/rootfs.ext4"),
+		SocketDir:            getEnv("SOCKET_DIR", "/tmp/firecracker"),
+		KVMDevice:            getEnv("KVM_DEVICE", "/dev/kvm"),
+		FirecrackerLogLevel:  getEnv("FIRECRACKER_LOG_LEVEL", "Warning"),
+		RescueKernelPath:     getEnv("RESCUE_KERNEL_PATH", ""),
+		RescueRootfsPath:     getEnv("RESCUE_ROOTFS_PATH", ""),
+		VMOutputMode:         getEnv("VM_OUTPUT_MODE", "file"),
+		VMOutputBufferKB:     getEnvAsInt("VM_OUTPUT_BUFFER_KB", 256),
+		SystemdScope:         getEnvAsBool("SYSTEMD_SCOPE", false),
+		SystemdSlice:         getEnv("SYSTEMD_SLICE", ""),
+		RootfsMode:           getEnv("ROOTFS_MODE", "copy"),
+		RootfsOverlayInit:    getEnv("ROOTFS_OVERLAY_INIT", "/sbin/overlay-init"),
+		VolumesDir:           getEnv("VOLUMES_DIR", "/var/lib/firecracker-orchestrator/volumes"),
+		KernelSHA256:         getEnv("KERNEL_SHA256", ""),
+		RootfsSHA256:         getEnv("ROOTFS_SHA256", ""),
+		MinisignPublicKey:    getEnv("IMAGE_MINISIGN_PUBKEY", ""),
+		StrictImageVerify:    getEnvAsBool("STRICT_IMAGE_VERIFICATION", false),
+		BridgeName:           getEnv("BRIDGE_NAME", "fc-br0"),
+		TAPDeviceBase:        getEnv("TAP_DEVICE_BASE", "fc-tap"),
+		VMSubnet:             getEnv("VM_SUBNET", "192.168.100.0/24"),
+		VMIPPool:             getEnv("VM_IP_POOL", ""),
+		MACPrefix:            getEnv("MAC_PREFIX", "02:fc:00"),
+		EnableNAT:            getEnvAsBool("ENABLE_NAT", false),
+		UplinkInterface:      getEnv("UPLINK_INTERFACE", ""),
+		EnableDHCP:           getEnvAsBool("ENABLE_DHCP", false),
+		DHCPDNSServers:       getEnv("DHCP_DNS_SERVERS", "1.1.1.1"),
+		DHCPLeaseSeconds:     getEnvAsInt("DHCP_LEASE_SECONDS", 3600),
+		InjectHosts:          getEnvAsBool("INJECT_GUEST_HOSTS", false),
+		ForwardPortMin:       getEnvAsInt("PORT_FORWARD_MIN", 1024),
+		ForwardPortMax:       getEnvAsInt("PORT_FORWARD_MAX", 65535),
+		DefaultMemoryMB:      getEnvAsInt64("DEFAULT_MEMORY_MB", 512),
+		DefaultCPUs:          getEnvAsInt("DEFAULT_CPUS", 1),
+		DefaultDiskGB:        getEnvAsInt64("DEFAULT_DISK_GB", 2),
+		EnableEntropy:        getEnvAsBool("ENABLE_ENTROPY", true),
+		HostMemoryMB:         getEnvAsInt64("HOST_MEMORY_MB", 0),
+		HostCPUs:             getEnvAsInt("HOST_CPUS", 0),
+		CostCurrency:         getEnv("COST_CURRENCY", "USD"),
+		CostPerVCPUHour:      getEnvAsFloat("COST_PER_VCPU_HOUR", 0),
+		CostPerGBHour:        getEnvAsFloat("COST_PER_GB_HOUR", 0),
+		CostPerDiskGBMonth:   getEnvAsFloat("COST_PER_DISK_GB_MONTH", 0),
+		NameMaxLength:        getEnvAsInt("NAME_MAX_LENGTH", 63),
+		AutoProvisionVMs:     getEnvAsBool("AUTO_PROVISION_VMS", false),
+		MetricsSampleSeconds: getEnvAsInt("METRICS_SAMPLE_INTERVAL", 10),
+		DriftCheckSeconds:    getEnvAsInt("DRIFT_CHECK_INTERVAL", 60),
+		ClockSkewThresholdMS: getEnvAsInt64("CLOCK_SKEW_THRESHOLD_MS", 500),
+		IdleAfterHours:       getEnvAsInt("IDLE_AFTER_HOURS", 0),
+		IdleAction:           getEnv("IDLE_ACTION", "notify"),
+		IdleCheckSeconds:     getEnvAsInt("IDLE_CHECK_INTERVAL", 300),
+		IdleCPUPercent:       getEnvAsInt("IDLE_CPU_PERCENT", 2),
+		IdleNetworkBPS:       getEnvAsInt64("IDLE_NETWORK_BYTES_PER_SECOND", 1024),
+		PolicyFile:           getEnv("POLICY_FILE", ""),
+		AuthProvider:         getEnv("AUTH_PROVIDER", ""),
+		AuthPlugin:           getEnv("AUTH_PLUGIN", ""),
+		AuthTokenFile:        getEnv("AUTH_TOKEN_FILE", ""),
+		PasswordMinLength:    getEnvAsInt("PASSWORD_MIN_LENGTH", 12),
+		SessionTTLHours:      getEnvAsInt("SESSION_TTL_HOURS", 12),
+		LocalAdminPassword:   getEnv("LOCAL_ADMIN_PASSWORD", ""),
+		HooksDir:             getEnv("HOOKS_DIR", "/etc/firecracker-orchestrator/hooks"),
+		HookPrivateNetwork:   getEnvAsBool("HOOK_ALLOW_PRIVATE_NETWORK", false),
+		JobWorkers:           getEnvAsInt("JOB_WORKERS", 2),
+		JobHeartbeatSeconds:  getEnvAsInt("JOB_HEARTBEAT_TIMEOUT", 60),
+		JobRetentionDays:     getEnvAsInt("JOB_RETENTION_DAYS", 30),
+		ReapIntervalSeconds:  getEnvAsInt("ZOMBIE_REAP_INTERVAL", 10),
+		RestartPolicy:        getEnv("RESTART_POLICY", "never"),
+		RestartDelaySeconds:  getEnvAsInt("RESTART_BACKOFF_SECONDS", 1),
+		RestartMaxDelay:      getEnvAsInt("RESTART_BACKOFF_MAX_SECONDS", 300),
+		RestartResetSeconds:  getEnvAsInt("RESTART_BACKOFF_RESET_SECONDS", 600),
+		RestartMaxAttempts:   getEnvAsInt("RESTART_MAX_ATTEMPTS", 0),
+		StopTimeoutSeconds:   getEnvAsInt("STOP_TIMEOUT", 30),
+		DrainTimeoutSeconds:  getEnvAsInt("DRAIN_TIMEOUT", 30),
+		LogLevel:             getEnv("LOG_LEVEL", "info"),
+		LogFile:              getEnv("LOG_FILE", ""),
 	}
 
+	config.VMLogDir = getEnv("VM_LOG_DIR", filepath.Join(config.SocketDir, "logs"))
+	config.VMDiskDir = getEnv("VM_DISK_DIR", filepath.Join(config.SocketDir, "disks"))
+
+	// VMs in their own systemd scopes are there to outlive the orchestrator
+	shutdownPolicy := "stop"
+	if config.SystemdScope {
+		shutdownPolicy = "detach"
+	}
+	config.ShutdownVMPolicy = getEnv("SHUTDOWN_VM_POLICY", shutdownPolicy)
+
 	return config
 }
 
@@ -89,3 +293,23 @@ func getEnvAsInt64(key string, defaultValue int64) int64 {
 	}
 	return defaultValue
 }
+
+// getEnvAsFloat gets an environment variable as a float64 or returns a default value
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsBool gets an environment variable as a boolean or returns a default value
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
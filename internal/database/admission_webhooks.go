@@ -0,0 +1,120 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AdmissionWebhook is an external service consulted before VMs or containers
+// are created. Mutating webhooks may rewrite the creation request; validating
+// webhooks may only accept or reject it.
+type AdmissionWebhook struct {
+	ID             string    `json:"id" db:"id"`
+	Name           string    `json:"name" db:"name"`
+	Type           string    `json:"type" db:"type"` // validating or mutating
+	URL            string    `json:"url" db:"url"`
+	Resources      []string  `json:"resources" db:"resources"`           // vm, container
+	FailurePolicy  string    `json:"failure_policy" db:"failure_policy"` // fail (reject) or ignore when the webhook can't be reached
+	TimeoutSeconds int       `json:"timeout_seconds" db:"timeout_seconds"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+const admissionWebhookColumns = `id, name, type, url, resources, failure_policy, timeout_seconds, created_at, updated_at`
+
+// createAdmissionWebhookTable creates the admission_webhooks table
+func (d *Database) createAdmissionWebhookTable() error {
+	table := `
+	CREATE TABLE IF NOT EXISTS admission_webhooks (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		type TEXT NOT NULL,
+		url TEXT NOT NULL,
+		resources TEXT NOT NULL DEFAULT '[]',
+		failure_policy TEXT NOT NULL DEFAULT 'fail',
+		timeout_seconds INTEGER NOT NULL DEFAULT 10,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	_, err := d.db.Exec(table)
+	return err
+}
+
+func scanAdmissionWebhook(row rowScanner) (*AdmissionWebhook, error) {
+	webhook := &AdmissionWebhook{}
+	var resources string
+	if err := row.Scan(&webhook.ID, &webhook.Name, &webhook.Type, &webhook.URL, &resources, &webhook.FailurePolicy, &webhook.TimeoutSeconds, &webhook.CreatedAt, &webhook.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(resources), &webhook.Resources); err != nil {
+		return nil, fmt.Errorf("invalid stored resources of admission webhook %s: %w", webhook.ID, err)
+	}
+	return webhook, nil
+}
+
+// CreateAdmissionWebhook inserts a new admission webhook into the database
+func (d *Database) CreateAdmissionWebhook(webhook *AdmissionWebhook) error {
+	resources, err := json.Marshal(webhook.Resources)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO admission_webhooks (` + admissionWebhookColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	webhook.CreatedAt = time.Now()
+	webhook.UpdatedAt = time.Now()
+
+	_, err = d.db.Exec(query, webhook.ID, webhook.Name, webhook.Type, webhook.URL, string(resources), webhook.FailurePolicy, webhook.TimeoutSeconds, webhook.CreatedAt, webhook.UpdatedAt)
+	return err
+}
+
+// UpdateAdmissionWebhook updates an existing admission webhook in the database
+func (d *Database) UpdateAdmissionWebhook(webhook *AdmissionWebhook) error {
+	resources, err := json.Marshal(webhook.Resources)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE admission_webhooks SET name=?, type=?, url=?, resources=?, failure_policy=?, timeout_seconds=?, updated_at=? WHERE id=?`
+
+	webhook.UpdatedAt = time.Now()
+
+	_, err = d.db.Exec(query, webhook.Name, webhook.Type, webhook.URL, string(resources), webhook.FailurePolicy, webhook.TimeoutSeconds, webhook.UpdatedAt, webhook.ID)
+	return err
+}
+
+// GetAdmissionWebhook retrieves an admission webhook by ID
+func (d *Database) GetAdmissionWebhook(id string) (*AdmissionWebhook, error) {
+	query := `SELECT ` + admissionWebhookColumns + ` FROM admission_webhooks WHERE id=?`
+	return scanAdmissionWebhook(d.db.QueryRow(query, id))
+}
+
+// ListAdmissionWebhooks retrieves all admission webhooks in the order they were registered
+func (d *Database) ListAdmissionWebhooks() ([]*AdmissionWebhook, error) {
+	query := `SELECT ` + admissionWebhookColumns + ` FROM admission_webhooks ORDER BY created_at`
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*AdmissionWebhook
+	for rows.Next() {
+		webhook, err := scanAdmissionWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, nil
+}
+
+// DeleteAdmissionWebhook removes an admission webhook from the database
+func (d *Database) DeleteAdmissionWebhook(id string) error {
+	_, err := d.db.Exec(`DELETE FROM admission_webhooks WHERE id=?`, id)
+	return err
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"time"
+)
+
+// BootProfile is a named, reusable set of kernel boot arguments
+type BootProfile struct {
+	Name        string    `json:"name" db:"name"`
+	BootArgs    string    `json:"boot_args" db:"boot_args"`
+	Description string    `json:"description" db:"description"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// defaultBootProfiles are seeded on first start so common setups work out of the box
+var defaultBootProfiles = []BootProfile{
+	{
+		Name:        "quiet-fast-boot",
+		BootArgs:    "console=ttyS0 reboot=k panic=1 pci=off quiet loglevel=0 i8042.noaux i8042.nomux i8042.nopnp i8042.dumbkbd",
+		Description: "Minimal console output and no legacy keyboard probing for the fastest boot",
+	},
+	{
+		Name:        "debug-console",
+		BootArgs:    "console=ttyS0 reboot=k panic=1 pci=off loglevel=8 ignore_loglevel",
+		Description: "Verbose kernel logging on the serial console",
+	},
+	{
+		Name:        "netboot",
+		BootArgs:    "console=ttyS0 reboot=k panic=1 pci=off ip=dhcp",
+		Description: "Configure eth0 via DHCP from the kernel before init runs",
+	},
+}
+
+// createBootProfileTable creates the boot_profiles table and seeds the default profiles
+func (d *Database) createBootProfileTable() error {
+	table := `
+	CREATE TABLE IF NOT EXISTS boot_profiles (
+		name TEXT PRIMARY KEY,
+		boot_args TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := d.db.Exec(table); err != nil {
+		return err
+	}
+
+	seed := `INSERT OR IGNORE INTO boot_profiles (name, boot_args, description, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`
+	for _, profile := range defaultBootProfiles {
+		now := time.Now()
+		if _, err := d.db.Exec(seed, profile.Name, profile.BootArgs, profile.Description, now, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateBootProfile inserts a new boot profile into the database
+func (d *Database) CreateBootProfile(profile *BootProfile) error {
+	query := `
+		INSERT INTO boot_profiles (name, boot_args, description, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)`
+
+	profile.CreatedAt = time.Now()
+	profile.UpdatedAt = time.Now()
+
+	_, err := d.db.Exec(query, profile.Name, profile.BootArgs, profile.Description, profile.CreatedAt, profile.UpdatedAt)
+	return err
+}
+
+// UpdateBootProfile updates an existing boot profile in the database
+func (d *Database) UpdateBootProfile(profile *BootProfile) error {
+	query := `UPDATE boot_profiles SET boot_args=?, description=?, updated_at=? WHERE name=?`
+
+	profile.UpdatedAt = time.Now()
+
+	_, err := d.db.Exec(query, profile.BootArgs, profile.Description, profile.UpdatedAt, profile.Name)
+	return err
+}
+
+// GetBootProfile retrieves a boot profile by name
+func (d *Database) GetBootProfile(name string) (*BootProfile, error) {
+	query := `SELECT name, boot_args, description, created_at, updated_at FROM boot_profiles WHERE name=?`
+
+	profile := &BootProfile{}
+	err := d.db.QueryRow(query, name).Scan(&profile.Name, &profile.BootArgs, &profile.Description, &profile.CreatedAt, &profile.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// ListBootProfiles retrieves all boot profiles
+func (d *Database) ListBootProfiles() ([]*BootProfile, error) {
+	query := `SELECT name, boot_args, description, created_at, updated_at FROM boot_profiles ORDER BY name`
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []*BootProfile
+	for rows.Next() {
+		profile := &BootProfile{}
+		if err := rows.Scan(&profile.Name, &profile.BootArgs, &profile.Description, &profile.CreatedAt, &profile.UpdatedAt); err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+
+	return profiles, nil
+}
+
+// DeleteBootProfile removes a boot profile from the database
+func (d *Database) DeleteBootProfile(name string) error {
+	query := `DELETE FROM boot_profiles WHERE name=?`
+	_, err := d.db.Exec(query, name)
+	return err
+}
+
+// CountVMsWithBootProfile returns how many VMs reference a boot profile
+func (d *Database) CountVMsWithBootProfile(name string) (int, error) {
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM vms WHERE boot_profile=?`, name).Scan(&count)
+	return count, err
+}
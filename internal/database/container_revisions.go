@@ -0,0 +1,124 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// ContainerRevision is a snapshot of a container's deployable spec
+type ContainerRevision struct {
+	ContainerID string    `json:"container_id" db:"container_id"`
+	Revision    int       `json:"revision" db:"revision"`
+	Image       string    `json:"image" db:"image"`
+	Ports       string    `json:"ports" db:"ports"`             // JSON string of port mappings
+	Environment string    `json:"environment" db:"environment"` // JSON string of env vars
+	Volumes     string    `json:"volumes" db:"volumes"`         // JSON string of volume mounts
+	InitSteps   string    `json:"init_steps" db:"init_steps"`   // JSON string of init steps
+	Memory      int64     `json:"memory" db:"memory"`
+	CPUs        int       `json:"cpus" db:"cpus"`
+	Reason      string    `json:"reason" db:"reason"` // e.g. "create", "update", "rollback to 2"
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// createContainerRevisionTable creates the container_revisions table
+func (d *Database) createContainerRevisionTable() error {
+	table := `
+	CREATE TABLE IF NOT EXISTS container_revisions (
+		container_id TEXT NOT NULL,
+		revision INTEGER NOT NULL,
+		image TEXT NOT NULL,
+		ports TEXT NOT NULL DEFAULT '',
+		environment TEXT NOT NULL DEFAULT '',
+		volumes TEXT NOT NULL DEFAULT '',
+		init_steps TEXT NOT NULL DEFAULT '',
+		memory INTEGER NOT NULL DEFAULT 0,
+		cpus INTEGER NOT NULL DEFAULT 0,
+		reason TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (container_id, revision),
+		FOREIGN KEY (container_id) REFERENCES containers (id)
+	);`
+
+	_, err := d.db.Exec(table)
+	return err
+}
+
+// CreateContainerRevision records the current spec of a container as its revision
+func (d *Database) CreateContainerRevision(container *Container, reason string) (*ContainerRevision, error) {
+	query := `
+		INSERT INTO container_revisions (container_id, revision, image, ports, environment, volumes, init_steps, memory, cpus, reason, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	revision := &ContainerRevision{
+		ContainerID: container.ID,
+		Revision:    container.Revision,
+		Image:       container.Image,
+		Ports:       container.Ports,
+		Environment: container.Environment,
+		Volumes:     container.Volumes,
+		InitSteps:   container.InitSteps,
+		Memory:      container.Memory,
+		CPUs:        container.CPUs,
+		Reason:      reason,
+		CreatedAt:   time.Now(),
+	}
+
+	environment, err := d.keys.Seal(revision.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = d.db.Exec(query, revision.ContainerID, revision.Revision, revision.Image, revision.Ports, environment, revision.Volumes, revision.InitSteps, revision.Memory, revision.CPUs, revision.Reason, revision.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// GetContainerRevision retrieves one revision of a container
+func (d *Database) GetContainerRevision(containerID string, revision int) (*ContainerRevision, error) {
+	query := `SELECT container_id, revision, image, ports, environment, volumes, init_steps, memory, cpus, reason, created_at FROM container_revisions WHERE container_id=? AND revision=?`
+
+	r := &ContainerRevision{}
+	err := d.db.QueryRow(query, containerID, revision).Scan(&r.ContainerID, &r.Revision, &r.Image, &r.Ports, &r.Environment, &r.Volumes, &r.InitSteps, &r.Memory, &r.CPUs, &r.Reason, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if r.Environment, err = d.keys.Open(r.Environment); err != nil {
+		return nil, fmt.Errorf("container %s revision %d environment: %w", containerID, revision, err)
+	}
+	return r, nil
+}
+
+// ListContainerRevisions retrieves a container's revisions, newest first
+func (d *Database) ListContainerRevisions(containerID string) ([]*ContainerRevision, error) {
+	query := `SELECT container_id, revision, image, ports, environment, volumes, init_steps, memory, cpus, reason, created_at FROM container_revisions WHERE container_id=? ORDER BY revision DESC`
+
+	rows, err := d.db.Query(query, containerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []*ContainerRevision
+	for rows.Next() {
+		r := &ContainerRevision{}
+		if err := rows.Scan(&r.ContainerID, &r.Revision, &r.Image, &r.Ports, &r.Environment, &r.Volumes, &r.InitSteps, &r.Memory, &r.CPUs, &r.Reason, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		var err error
+		if r.Environment, err = d.keys.Open(r.Environment); err != nil {
+			return nil, fmt.Errorf("container %s revision %d environment: %w", containerID, r.Revision, err)
+		}
+		revisions = append(revisions, r)
+	}
+
+	return revisions, nil
+}
+
+// DeleteContainerRevisions removes the revision history of a container
+func (d *Database) DeleteContainerRevisions(containerID string) error {
+	query := `DELETE FROM container_revisions WHERE container_id=?`
+	_, err := d.db.Exec(query, containerID)
+	return err
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ContainerStatusChange records a container moving to a status, and the
+// output the guest agent captured with the event that moved it
+type ContainerStatusChange struct {
+	ContainerID string    `json:"-" db:"container_id"`
+	Status      string    `json:"status" db:"status"`
+	Action      string    `json:"action" db:"action"` // "create", or the Docker event from the guest agent
+	ExitCode    int       `json:"exit_code,omitempty" db:"exit_code"`
+	Logs        []string  `json:"logs,omitempty" db:"logs"` // stored as a JSON array
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// createContainerStatusTable creates the container_status_history table
+func (d *Database) createContainerStatusTable() error {
+	table := `
+	CREATE TABLE IF NOT EXISTS container_status_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		container_id TEXT NOT NULL,
+		status TEXT NOT NULL,
+		action TEXT NOT NULL DEFAULT '',
+		exit_code INTEGER NOT NULL DEFAULT 0,
+		logs TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (container_id) REFERENCES containers (id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_container_status_history_container ON container_status_history (container_id, id);`
+
+	_, err := d.db.Exec(table)
+	return err
+}
+
+// AddContainerStatusChange appends a status change to a container's history
+func (d *Database) AddContainerStatusChange(change *ContainerStatusChange) error {
+	query := `
+		INSERT INTO container_status_history (container_id, status, action, exit_code, logs, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
+
+	logs := ""
+	if len(change.Logs) > 0 {
+		data, err := json.Marshal(change.Logs)
+		if err != nil {
+			return err
+		}
+		logs = string(data)
+	}
+	if change.CreatedAt.IsZero() {
+		change.CreatedAt = time.Now()
+	}
+
+	_, err := d.db.Exec(query, change.ContainerID, change.Status, change.Action, change.ExitCode, logs, change.CreatedAt)
+	return err
+}
+
+// ListContainerStatusChanges retrieves up to limit of a container's most
+// recent status changes, newest first
+func (d *Database) ListContainerStatusChanges(containerID string, limit int) ([]*ContainerStatusChange, error) {
+	query := `SELECT container_id, status, action, exit_code, logs, created_at FROM container_status_history WHERE container_id=? ORDER BY id DESC LIMIT ?`
+
+	rows, err := d.db.Query(query, containerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []*ContainerStatusChange
+	for rows.Next() {
+		change := &ContainerStatusChange{}
+		var logs string
+		if err := rows.Scan(&change.ContainerID, &change.Status, &change.Action, &change.ExitCode, &logs, &change.CreatedAt); err != nil {
+			return nil, err
+		}
+		if logs != "" {
+			if err := json.Unmarshal([]byte(logs), &change.Logs); err != nil {
+				return nil, fmt.Errorf("container %s status history logs: %w", containerID, err)
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, rows.Err()
+}
+
+// LatestContainerStatusLogs returns the most recent status change of a
+// container that came with output, or nil if none did
+func (d *Database) LatestContainerStatusLogs(containerID string) (*ContainerStatusChange, error) {
+	query := `SELECT container_id, status, action, exit_code, logs, created_at FROM container_status_history WHERE container_id=? AND logs != '' ORDER BY id DESC LIMIT 1`
+
+	change := &ContainerStatusChange{}
+	var logs string
+	err := d.db.QueryRow(query, containerID).Scan(&change.ContainerID, &change.Status, &change.Action, &change.ExitCode, &logs, &change.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(logs), &change.Logs); err != nil {
+		return nil, fmt.Errorf("container %s status history logs: %w", containerID, err)
+	}
+	return change, nil
+}
+
+// DeleteContainerStatusChanges removes the status history of a container
+func (d *Database) DeleteContainerStatusChanges(containerID string) error {
+	query := `DELETE FROM container_status_history WHERE container_id=?`
+	_, err := d.db.Exec(query, containerID)
+	return err
+}
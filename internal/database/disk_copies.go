@@ -0,0 +1,137 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Disk copy statuses
+const (
+	DiskCopyRunning   = "running"
+	DiskCopyCompleted = "completed"
+	DiskCopyFailed    = "failed"
+)
+
+// DiskCopy is an offline copy of a VM's drives taken for incident response.
+// Copies are kept when their VM is deleted, since evidence usually outlives it.
+type DiskCopy struct {
+	ID         string          `json:"id" db:"id"`
+	VMID       string          `json:"vm_id" db:"vm_id"`
+	Status     string          `json:"status" db:"status"` // running, completed, failed
+	Error      string          `json:"error,omitempty" db:"error"`
+	Live       bool            `json:"live" db:"live"` // taken from a running (quarantined) VM, so it may be inconsistent
+	Drives     []DiskCopyDrive `json:"drives" db:"drives"`
+	StartedAt  time.Time       `json:"started_at" db:"started_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty" db:"finished_at"`
+}
+
+// DiskCopyDrive is the copy of one drive with its checksum
+type DiskCopyDrive struct {
+	DriveID   string `json:"drive_id"`
+	Source    string `json:"source"`
+	SizeBytes int64  `json:"size_bytes"`
+	SHA256    string `json:"sha256,omitempty"`
+}
+
+const diskCopyColumns = `id, vm_id, status, error, live, drives, started_at, finished_at`
+
+// createDiskCopyTable creates the disk_copies table. There is no foreign key
+// to vms so copies survive their VM.
+func (d *Database) createDiskCopyTable() error {
+	table := `
+	CREATE TABLE IF NOT EXISTS disk_copies (
+		id TEXT PRIMARY KEY,
+		vm_id TEXT NOT NULL,
+		status TEXT NOT NULL,
+		error TEXT NOT NULL DEFAULT '',
+		live BOOLEAN NOT NULL DEFAULT 0,
+		drives TEXT NOT NULL DEFAULT '[]',
+		started_at DATETIME NOT NULL,
+		finished_at DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_disk_copies_vm ON disk_copies (vm_id);`
+
+	_, err := d.db.Exec(table)
+	return err
+}
+
+func scanDiskCopy(row rowScanner) (*DiskCopy, error) {
+	diskCopy := &DiskCopy{}
+	var drives string
+	if err := row.Scan(&diskCopy.ID, &diskCopy.VMID, &diskCopy.Status, &diskCopy.Error, &diskCopy.Live, &drives, &diskCopy.StartedAt, &diskCopy.FinishedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(drives), &diskCopy.Drives); err != nil {
+		return nil, fmt.Errorf("invalid stored drives of disk copy %s: %w", diskCopy.ID, err)
+	}
+	return diskCopy, nil
+}
+
+// CreateDiskCopy inserts a new disk copy into the database
+func (d *Database) CreateDiskCopy(diskCopy *DiskCopy) error {
+	drives, err := json.Marshal(diskCopy.Drives)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO disk_copies (` + diskCopyColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = d.db.Exec(query, diskCopy.ID, diskCopy.VMID, diskCopy.Status, diskCopy.Error, diskCopy.Live, string(drives), diskCopy.StartedAt, diskCopy.FinishedAt)
+	return err
+}
+
+// UpdateDiskCopy records a disk copy's progress
+func (d *Database) UpdateDiskCopy(diskCopy *DiskCopy) error {
+	drives, err := json.Marshal(diskCopy.Drives)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE disk_copies SET status=?, error=?, drives=?, finished_at=? WHERE id=?`
+	_, err = d.db.Exec(query, diskCopy.Status, diskCopy.Error, string(drives), diskCopy.FinishedAt, diskCopy.ID)
+	return err
+}
+
+// GetDiskCopy retrieves a disk copy by ID
+func (d *Database) GetDiskCopy(id string) (*DiskCopy, error) {
+	query := `SELECT ` + diskCopyColumns + ` FROM disk_copies WHERE id=?`
+	return scanDiskCopy(d.db.QueryRow(query, id))
+}
+
+// ListDiskCopiesByVM retrieves the disk copies of a VM, oldest first
+func (d *Database) ListDiskCopiesByVM(vmID string) ([]*DiskCopy, error) {
+	query := `SELECT ` + diskCopyColumns + ` FROM disk_copies WHERE vm_id=? ORDER BY started_at`
+
+	rows, err := d.db.Query(query, vmID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	copies := []*DiskCopy{}
+	for rows.Next() {
+		diskCopy, err := scanDiskCopy(rows)
+		if err != nil {
+			return nil, err
+		}
+		copies = append(copies, diskCopy)
+	}
+	return copies, rows.Err()
+}
+
+// FailRunningDiskCopies marks every disk copy still running as failed, for
+// copies whose process went away before they finished
+func (d *Database) FailRunningDiskCopies(reason string) (int64, error) {
+	query := `UPDATE disk_copies SET status=?, error=?, finished_at=? WHERE status=?`
+	result, err := d.db.Exec(query, DiskCopyFailed, reason, time.Now(), DiskCopyRunning)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteDiskCopy removes a disk copy from the database
+func (d *Database) DeleteDiskCopy(id string) error {
+	_, err := d.db.Exec(`DELETE FROM disk_copies WHERE id=?`, id)
+	return err
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"time"
+)
+
+// DriveAttachment represents a secondary block device attached to a VM
+type DriveAttachment struct {
+	ID         string    `json:"id" db:"id"`
+	VMID       string    `json:"vm_id" db:"vm_id"`
+	DriveID    string    `json:"drive_id" db:"drive_id"`         // Firecracker drive identifier
+	PathOnHost string    `json:"path_on_host" db:"path_on_host"` // Backing file or block device
+	ReadOnly   bool      `json:"read_only" db:"read_only"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// createDriveTable creates the drive_attachments table
+func (d *Database) createDriveTable() error {
+	table := `
+	CREATE TABLE IF NOT EXISTS drive_attachments (
+		id TEXT PRIMARY KEY,
+		vm_id TEXT NOT NULL,
+		drive_id TEXT NOT NULL,
+		path_on_host TEXT NOT NULL,
+		read_only BOOLEAN NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (vm_id, drive_id),
+		FOREIGN KEY (vm_id) REFERENCES vms (id)
+	);`
+
+	_, err := d.db.Exec(table)
+	return err
+}
+
+// CreateDriveAttachment inserts a new drive attachment into the database
+func (d *Database) CreateDriveAttachment(drive *DriveAttachment) error {
+	query := `
+		INSERT INTO drive_attachments (id, vm_id, drive_id, path_on_host, read_only, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
+
+	drive.CreatedAt = time.Now()
+
+	_, err := d.db.Exec(query, drive.ID, drive.VMID, drive.DriveID, drive.PathOnHost, drive.ReadOnly, drive.CreatedAt)
+	return err
+}
+
+// ListDriveAttachmentsByVM retrieves the drives attached to a specific VM
+func (d *Database) ListDriveAttachmentsByVM(vmID string) ([]*DriveAttachment, error) {
+	query := `SELECT id, vm_id, drive_id, path_on_host, read_only, created_at FROM drive_attachments WHERE vm_id=? ORDER BY created_at`
+	return d.queryDriveAttachments(query, vmID)
+}
+
+// ListDriveAttachmentsByPath retrieves every attachment of a backing file across all VMs
+func (d *Database) ListDriveAttachmentsByPath(path string) ([]*DriveAttachment, error) {
+	query := `SELECT id, vm_id, drive_id, path_on_host, read_only, created_at FROM drive_attachments WHERE path_on_host=? ORDER BY created_at`
+	return d.queryDriveAttachments(query, path)
+}
+
+// DeleteDriveAttachment removes a drive from a VM
+func (d *Database) DeleteDriveAttachment(vmID, driveID string) error {
+	query := `DELETE FROM drive_attachments WHERE vm_id=? AND drive_id=?`
+	_, err := d.db.Exec(query, vmID, driveID)
+	return err
+}
+
+// DeleteDriveAttachmentsByVM removes all drives attached to a VM
+func (d *Database) DeleteDriveAttachmentsByVM(vmID string) error {
+	query := `DELETE FROM drive_attachments WHERE vm_id=?`
+	_, err := d.db.Exec(query, vmID)
+	return err
+}
+
+func (d *Database) queryDriveAttachments(query string, args ...interface{}) ([]*DriveAttachment, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var drives []*DriveAttachment
+	for rows.Next() {
+		drive := &DriveAttachment{}
+		if err := rows.Scan(&drive.ID, &drive.VMID, &drive.DriveID, &drive.PathOnHost, &drive.ReadOnly, &drive.CreatedAt); err != nil {
+			return nil, err
+		}
+		drives = append(drives, drive)
+	}
+
+	return drives, nil
+}
@@ -0,0 +1,277 @@
+package database
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sealedPrefix marks a value encrypted by a Keyring:
+// enc:v1:<key id>:<base64 of nonce and AES-256-GCM ciphertext>
+const sealedPrefix = "enc:v1:"
+
+// ErrNoKey is returned when reading a value encrypted with a key the keyring
+// doesn't have, or any encrypted value when encryption isn't configured
+var ErrNoKey = errors.New("encryption key not available")
+
+// Keyring holds the AES-256 keys sensitive values are encrypted with. The
+// primary key encrypts new values; the others only decrypt, so values written
+// under a retired key stay readable until they are re-encrypted.
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// ParseKeyring parses a comma-separated list of id:key pairs, each key 32
+// bytes in standard base64. The first key is the primary.
+func ParseKeyring(spec string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("encryption key %q must be written id:base64-key", entry)
+		}
+		if _, exists := k.keys[id]; exists {
+			return nil, fmt.Errorf("encryption key %s is listed twice", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %s is not valid base64: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("encryption key %s is %d bytes; AES-256 needs 32", id, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+		if k.primary == "" {
+			k.primary = id
+		}
+	}
+	if k.primary == "" {
+		return nil, errors.New("no encryption keys given")
+	}
+	return k, nil
+}
+
+// Primary returns the ID of the key new values are encrypted with
+func (k *Keyring) Primary() string {
+	if k == nil {
+		return ""
+	}
+	return k.primary
+}
+
+// Seal encrypts a value with the primary key. Empty values are left empty,
+// and a nil keyring leaves values in plaintext.
+func (k *Keyring) Seal(plaintext string) (string, error) {
+	if k == nil || plaintext == "" {
+		return plaintext, nil
+	}
+	aead := k.keys[k.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return sealedPrefix + k.primary + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value written by Seal. Values without the sealed prefix
+// were written before encryption was enabled and are returned unchanged.
+func (k *Keyring) Open(value string) (string, error) {
+	id, ok := sealedKeyID(value)
+	if !ok {
+		return value, nil
+	}
+	if k == nil || k.keys[id] == nil {
+		return "", fmt.Errorf("%w: value is encrypted with key %s", ErrNoKey, id)
+	}
+	aead := k.keys[id]
+
+	sealed, err := base64.StdEncoding.DecodeString(value[len(sealedPrefix)+len(id)+1:])
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("encrypted value is corrupt")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value with key %s: %w", id, err)
+	}
+	return string(plaintext), nil
+}
+
+// sealedKeyID returns the ID of the key a sealed value was encrypted with
+func sealedKeyID(value string) (string, bool) {
+	if !strings.HasPrefix(value, sealedPrefix) {
+		return "", false
+	}
+	id, _, ok := strings.Cut(value[len(sealedPrefix):], ":")
+	return id, ok
+}
+
+// sensitiveColumns are the columns kept encrypted when a keyring is set: VM
+// environment variables and Ignition configs, and container environments
+var sensitiveColumns = []struct {
+	table, key, column string
+}{
+	{"vms", "id", "env"},
+	{"vms", "id", "ignition"},
+	{"containers", "id", "environment"},
+	{"container_revisions", "rowid", "environment"},
+}
+
+// SetKeyring enables encryption of sensitive columns. Values already stored
+// in plaintext stay readable and are encrypted by ReencryptSecrets.
+func (d *Database) SetKeyring(k *Keyring) {
+	d.keys = k
+}
+
+// Keyring returns the keyring sensitive columns are encrypted with, or nil
+func (d *Database) Keyring() *Keyring {
+	return d.keys
+}
+
+// EncryptionCounts reports how many stored sensitive values are encrypted
+// with each key, with plaintext values counted under "plaintext"
+func (d *Database) EncryptionCounts() (map[string]int, error) {
+	counts := make(map[string]int)
+	for _, c := range sensitiveColumns {
+		rows, err := d.db.Query(`SELECT ` + c.column + ` FROM ` + c.table + ` WHERE ` + c.column + ` <> ''`)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var value string
+			if err := rows.Scan(&value); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if id, ok := sealedKeyID(value); ok {
+				counts[id]++
+			} else {
+				counts["plaintext"]++
+			}
+		}
+		err = rows.Close()
+		if err == nil {
+			err = rows.Err()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
+
+// ReencryptSecrets rewrites every sensitive value not already encrypted with
+// the primary key: plaintext values after encryption is enabled, and values
+// under older keys after the primary key is rotated. It returns the number of
+// values rewritten. Nothing is written if any value can't be decrypted.
+func (d *Database) ReencryptSecrets() (int, error) {
+	if d.keys == nil {
+		return 0, fmt.Errorf("%w: no encryption keys configured", ErrNoKey)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rewritten := 0
+	for _, c := range sensitiveColumns {
+		type stale struct {
+			key   interface{}
+			value string
+		}
+		var pending []stale
+
+		rows, err := tx.Query(`SELECT ` + c.key + `, ` + c.column + ` FROM ` + c.table + ` WHERE ` + c.column + ` <> ''`)
+		if err != nil {
+			return 0, err
+		}
+		for rows.Next() {
+			var s stale
+			if err := rows.Scan(&s.key, &s.value); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			if id, ok := sealedKeyID(s.value); !ok || id != d.keys.primary {
+				pending = append(pending, s)
+			}
+		}
+		err = rows.Close()
+		if err == nil {
+			err = rows.Err()
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		for _, s := range pending {
+			plaintext, err := d.keys.Open(s.value)
+			if err != nil {
+				return 0, fmt.Errorf("%s.%s of %v: %w", c.table, c.column, s.key, err)
+			}
+			sealed, err := d.keys.Seal(plaintext)
+			if err != nil {
+				return 0, err
+			}
+			if _, err := tx.Exec(`UPDATE `+c.table+` SET `+c.column+`=? WHERE `+c.key+`=?`, sealed, s.key); err != nil {
+				return 0, err
+			}
+			rewritten++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return rewritten, nil
+}
+
+// sealVM returns the stored forms of a VM's sensitive fields
+func (d *Database) sealVM(vm *VM) (env, ignition string, err error) {
+	if env, err = d.keys.Seal(vm.Env); err != nil {
+		return "", "", err
+	}
+	if ignition, err = d.keys.Seal(vm.Ignition); err != nil {
+		return "", "", err
+	}
+	return env, ignition, nil
+}
+
+// openVM decrypts the sensitive fields of a VM read from the database
+func (d *Database) openVM(vm *VM) (*VM, error) {
+	var err error
+	if vm.Env, err = d.keys.Open(vm.Env); err != nil {
+		return nil, fmt.Errorf("VM %s env: %w", vm.ID, err)
+	}
+	if vm.Ignition, err = d.keys.Open(vm.Ignition); err != nil {
+		return nil, fmt.Errorf("VM %s ignition: %w", vm.ID, err)
+	}
+	return vm, nil
+}
+
+// openContainer decrypts the sensitive fields of a container read from the database
+func (d *Database) openContainer(container *Container) (*Container, error) {
+	var err error
+	if container.Environment, err = d.keys.Open(container.Environment); err != nil {
+		return nil, fmt.Errorf("container %s environment: %w", container.ID, err)
+	}
+	return container, nil
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"time"
+)
+
+// GuestInfo is what the guest agent reports about the OS running inside a VM
+type GuestInfo struct {
+	VMID             string    `json:"-" db:"vm_id"`
+	OS               string    `json:"os" db:"os"`
+	KernelVersion    string    `json:"kernel_version" db:"kernel_version"`
+	Hostname         string    `json:"hostname" db:"hostname"`
+	AgentVersion     string    `json:"agent_version" db:"agent_version"`
+	ContainerRuntime string    `json:"container_runtime" db:"container_runtime"`
+	ClockSource      string    `json:"clock_source,omitempty" db:"clock_source"` // e.g. kvm-clock
+	ClockSkewMS      int64     `json:"clock_skew_ms" db:"clock_skew_ms"`         // guest clock minus host clock at report time
+	ReportedAt       time.Time `json:"reported_at" db:"reported_at"`
+
+	// ClockSkewed is set when the skew exceeds CLOCK_SKEW_THRESHOLD_MS
+	ClockSkewed bool `json:"clock_skewed" db:"-"`
+}
+
+const guestInfoColumns = `vm_id, os, kernel_version, hostname, agent_version, container_runtime, clock_source, clock_skew_ms, reported_at`
+
+func scanGuestInfo(row rowScanner) (*GuestInfo, error) {
+	info := &GuestInfo{}
+	err := row.Scan(&info.VMID, &info.OS, &info.KernelVersion, &info.Hostname, &info.AgentVersion, &info.ContainerRuntime, &info.ClockSource, &info.ClockSkewMS, &info.ReportedAt)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// createGuestInfoTable creates the guest_info table
+func (d *Database) createGuestInfoTable() error {
+	table := `
+	CREATE TABLE IF NOT EXISTS guest_info (
+		vm_id TEXT PRIMARY KEY,
+		os TEXT NOT NULL DEFAULT '',
+		kernel_version TEXT NOT NULL DEFAULT '',
+		hostname TEXT NOT NULL DEFAULT '',
+		agent_version TEXT NOT NULL DEFAULT '',
+		container_runtime TEXT NOT NULL DEFAULT '',
+		clock_source TEXT NOT NULL DEFAULT '',
+		clock_skew_ms INTEGER NOT NULL DEFAULT 0,
+		reported_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (vm_id) REFERENCES vms (id)
+	);`
+
+	_, err := d.db.Exec(table)
+	return err
+}
+
+// UpsertGuestInfo stores the latest guest report for a VM
+func (d *Database) UpsertGuestInfo(info *GuestInfo) error {
+	query := `
+		INSERT INTO guest_info (` + guestInfoColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (vm_id) DO UPDATE SET
+			os=excluded.os, kernel_version=excluded.kernel_version, hostname=excluded.hostname,
+			agent_version=excluded.agent_version, container_runtime=excluded.container_runtime,
+			clock_source=excluded.clock_source, clock_skew_ms=excluded.clock_skew_ms,
+			reported_at=excluded.reported_at`
+
+	if info.ReportedAt.IsZero() {
+		info.ReportedAt = time.Now()
+	}
+
+	_, err := d.db.Exec(query, info.VMID, info.OS, info.KernelVersion, info.Hostname, info.AgentVersion, info.ContainerRuntime, info.ClockSource, info.ClockSkewMS, info.ReportedAt)
+	return err
+}
+
+// GetGuestInfo retrieves the latest guest report for a VM
+func (d *Database) GetGuestInfo(vmID string) (*GuestInfo, error) {
+	query := `SELECT ` + guestInfoColumns + ` FROM guest_info WHERE vm_id=?`
+	return scanGuestInfo(d.db.QueryRow(query, vmID))
+}
+
+// ListGuestInfo retrieves the latest guest report of every VM
+func (d *Database) ListGuestInfo() ([]*GuestInfo, error) {
+	rows, err := d.db.Query(`SELECT ` + guestInfoColumns + ` FROM guest_info`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var infos []*GuestInfo
+	for rows.Next() {
+		info, err := scanGuestInfo(rows)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, rows.Err()
+}
+
+// DeleteGuestInfo removes the guest report for a VM
+func (d *Database) DeleteGuestInfo(vmID string) error {
+	query := `DELETE FROM guest_info WHERE vm_id=?`
+	_, err := d.db.Exec(query, vmID)
+	return err
+}
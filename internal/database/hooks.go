@@ -0,0 +1,86 @@
+package database
+
+import (
+	"time"
+)
+
+// LifecycleHook is an action run when a VM goes through a lifecycle transition
+type LifecycleHook struct {
+	ID             string    `json:"id" db:"id"`
+	VMID           string    `json:"vm_id" db:"vm_id"`
+	Event          string    `json:"event" db:"event"`   // pre-start, post-start, pre-stop
+	Type           string    `json:"type" db:"type"`     // exec (host-side script) or webhook
+	Target         string    `json:"target" db:"target"` // script path or webhook URL
+	TimeoutSeconds int       `json:"timeout_seconds" db:"timeout_seconds"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// createHookTable creates the lifecycle_hooks table
+func (d *Database) createHookTable() error {
+	table := `
+	CREATE TABLE IF NOT EXISTS lifecycle_hooks (
+		id TEXT PRIMARY KEY,
+		vm_id TEXT NOT NULL,
+		event TEXT NOT NULL,
+		type TEXT NOT NULL,
+		target TEXT NOT NULL,
+		timeout_seconds INTEGER NOT NULL DEFAULT 30,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (vm_id) REFERENCES vms (id)
+	);`
+
+	_, err := d.db.Exec(table)
+	return err
+}
+
+// CreateLifecycleHook inserts a new lifecycle hook into the database
+func (d *Database) CreateLifecycleHook(hook *LifecycleHook) error {
+	query := `
+		INSERT INTO lifecycle_hooks (id, vm_id, event, type, target, timeout_seconds, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	hook.CreatedAt = time.Now()
+
+	_, err := d.db.Exec(query, hook.ID, hook.VMID, hook.Event, hook.Type, hook.Target, hook.TimeoutSeconds, hook.CreatedAt)
+	return err
+}
+
+// ListLifecycleHooksByVM retrieves a VM's hooks in the order they were registered
+func (d *Database) ListLifecycleHooksByVM(vmID string) ([]*LifecycleHook, error) {
+	query := `SELECT id, vm_id, event, type, target, timeout_seconds, created_at FROM lifecycle_hooks WHERE vm_id=? ORDER BY created_at`
+
+	rows, err := d.db.Query(query, vmID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []*LifecycleHook
+	for rows.Next() {
+		hook := &LifecycleHook{}
+		if err := rows.Scan(&hook.ID, &hook.VMID, &hook.Event, &hook.Type, &hook.Target, &hook.TimeoutSeconds, &hook.CreatedAt); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+
+	return hooks, nil
+}
+
+// DeleteLifecycleHook removes a hook from a VM, reporting whether it existed
+func (d *Database) DeleteLifecycleHook(vmID, hookID string) (bool, error) {
+	query := `DELETE FROM lifecycle_hooks WHERE vm_id=? AND id=?`
+	result, err := d.db.Exec(query, vmID, hookID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteLifecycleHooksByVM removes all hooks of a VM
+func (d *Database) DeleteLifecycleHooksByVM(vmID string) error {
+	query := `DELETE FROM lifecycle_hooks WHERE vm_id=?`
+	_, err := d.db.Exec(query, vmID)
+	return err
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"time"
+)
+
+// IPLease records a guest address held by a VM
+type IPLease struct {
+	IP        string    `json:"ip" db:"ip"`
+	VMID      string    `json:"vm_id" db:"vm_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// createIPLeaseTable creates the ip_leases table; the primary key keeps two
+// VMs from ever holding the same address
+func (d *Database) createIPLeaseTable() error {
+	table := `
+	CREATE TABLE IF NOT EXISTS ip_leases (
+		ip TEXT PRIMARY KEY,
+		vm_id TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_ip_leases_vm ON ip_leases (vm_id);`
+
+	_, err := d.db.Exec(table)
+	return err
+}
+
+// CreateIPLease records that a VM holds an address; it fails if the address
+// is already leased
+func (d *Database) CreateIPLease(lease *IPLease) error {
+	query := `INSERT INTO ip_leases (ip, vm_id, created_at) VALUES (?, ?, ?)`
+
+	if lease.CreatedAt.IsZero() {
+		lease.CreatedAt = time.Now()
+	}
+
+	_, err := d.db.Exec(query, lease.IP, lease.VMID, lease.CreatedAt)
+	return err
+}
+
+// GetIPLease retrieves the lease on an address
+func (d *Database) GetIPLease(ip string) (*IPLease, error) {
+	query := `SELECT ip, vm_id, created_at FROM ip_leases WHERE ip=?`
+
+	lease := &IPLease{}
+	if err := d.db.QueryRow(query, ip).Scan(&lease.IP, &lease.VMID, &lease.CreatedAt); err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+// ListIPLeases retrieves every lease, ordered by address
+func (d *Database) ListIPLeases() ([]*IPLease, error) {
+	rows, err := d.db.Query(`SELECT ip, vm_id, created_at FROM ip_leases ORDER BY ip`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var leases []*IPLease
+	for rows.Next() {
+		lease := &IPLease{}
+		if err := rows.Scan(&lease.IP, &lease.VMID, &lease.CreatedAt); err != nil {
+			return nil, err
+		}
+		leases = append(leases, lease)
+	}
+	return leases, rows.Err()
+}
+
+// DeleteIPLeasesByVM releases the addresses a VM holds
+func (d *Database) DeleteIPLeasesByVM(vmID string) error {
+	query := `DELETE FROM ip_leases WHERE vm_id=?`
+	_, err := d.db.Exec(query, vmID)
+	return err
+}
@@ -0,0 +1,372 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Job statuses
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed" // failed and will be retried
+	JobDead      = "dead"   // out of attempts
+)
+
+// Job is a persisted long-running operation. Jobs are claimed by one worker at a
+// time and handed to another if the worker stops heartbeating, so handlers must
+// tolerate running more than once.
+type Job struct {
+	ID          string     `json:"id" db:"id"`
+	Type        string     `json:"type" db:"type"`
+	ResourceID  string     `json:"resource_id,omitempty" db:"resource_id"` // e.g. the VM a job acts on
+	Payload     string     `json:"payload" db:"payload"`                   // JSON
+	Status      string     `json:"status" db:"status"`
+	Attempts    int        `json:"attempts" db:"attempts"`
+	MaxAttempts int        `json:"max_attempts" db:"max_attempts"`
+	LastError   string     `json:"last_error,omitempty" db:"last_error"`
+	Result      string     `json:"result,omitempty" db:"result"` // JSON
+	WorkerID    string     `json:"worker_id,omitempty" db:"worker_id"`
+	RunAfter    time.Time  `json:"run_after" db:"run_after"`
+	HeartbeatAt *time.Time `json:"heartbeat_at,omitempty" db:"heartbeat_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty" db:"finished_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+const jobColumns = `id, type, resource_id, payload, status, attempts, max_attempts, last_error, result, worker_id, run_after, heartbeat_at, finished_at, created_at, updated_at`
+
+// claimBatch is how many due jobs ClaimJob reads at a time; more than one so
+// a worker losing a race to another can try the next job
+const claimBatch = 10
+
+func scanJob(row rowScanner) (*Job, error) {
+	job := &Job{}
+	err := row.Scan(&job.ID, &job.Type, &job.ResourceID, &job.Payload, &job.Status, &job.Attempts, &job.MaxAttempts, &job.LastError, &job.Result, &job.WorkerID, &job.RunAfter, &job.HeartbeatAt, &job.FinishedAt, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// createJobTable creates the jobs table
+func (d *Database) createJobTable() error {
+	table := `
+	CREATE TABLE IF NOT EXISTS jobs (
+		id TEXT PRIMARY KEY,
+		type TEXT NOT NULL,
+		resource_id TEXT NOT NULL DEFAULT '',
+		payload TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		max_attempts INTEGER NOT NULL DEFAULT 3,
+		last_error TEXT NOT NULL DEFAULT '',
+		result TEXT NOT NULL DEFAULT '',
+		worker_id TEXT NOT NULL DEFAULT '',
+		run_after DATETIME NOT NULL,
+		heartbeat_at DATETIME,
+		finished_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		run_after_ns INTEGER NOT NULL DEFAULT 0,
+		created_ns INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs (status);`
+
+	_, err := d.db.Exec(table)
+	return err
+}
+
+// migrateJobTimes fills in run_after_ns and created_ns, the Unix nanosecond
+// copies of run_after and created_at that queries filter and sort on, for
+// jobs from before they existed, and indexes them. The drivers' text
+// encodings of DATETIME values don't compare reliably in SQL.
+func (d *Database) migrateJobTimes() error {
+	jobs, err := d.queryJobs(`SELECT ` + jobColumns + ` FROM jobs WHERE created_ns=0`)
+	if err != nil {
+		return fmt.Errorf("failed to migrate job times: %w", err)
+	}
+	for _, job := range jobs {
+		if _, err := d.db.Exec(`UPDATE jobs SET run_after_ns=?, created_ns=? WHERE id=?`, job.RunAfter.UnixNano(), job.CreatedAt.UnixNano(), job.ID); err != nil {
+			return fmt.Errorf("failed to migrate times of job %s: %w", job.ID, err)
+		}
+	}
+
+	_, err = d.db.Exec(`
+	CREATE INDEX IF NOT EXISTS idx_jobs_due ON jobs (status, run_after_ns);
+	CREATE INDEX IF NOT EXISTS idx_jobs_created ON jobs (created_ns);`)
+	return err
+}
+
+// CreateJob enqueues a job
+func (d *Database) CreateJob(job *Job) error {
+	query := `INSERT INTO jobs (` + jobColumns + `, run_after_ns, created_ns) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	now := time.Now()
+	job.Status = JobPending
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	if job.RunAfter.IsZero() {
+		job.RunAfter = now
+	}
+
+	_, err := d.db.Exec(query, job.ID, job.Type, job.ResourceID, job.Payload, job.Status, job.Attempts, job.MaxAttempts, job.LastError, job.Result, job.WorkerID, job.RunAfter, job.HeartbeatAt, job.FinishedAt, job.CreatedAt, job.UpdatedAt, job.RunAfter.UnixNano(), job.CreatedAt.UnixNano())
+	return err
+}
+
+// GetJob retrieves a job by ID
+func (d *Database) GetJob(id string) (*Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE id=?`
+	return scanJob(d.db.QueryRow(query, id))
+}
+
+// JobFilter narrows a job listing; zero fields match everything
+type JobFilter struct {
+	Status     string
+	Type       string
+	ResourceID string
+	Since      time.Time // created at or after
+	Until      time.Time // created before
+	Limit      int
+	Offset     int
+}
+
+// ListJobs retrieves a page of the jobs matching a filter, newest first, along
+// with the number of matching jobs
+func (d *Database) ListJobs(filter JobFilter) ([]*Job, int, error) {
+	where := ` WHERE 1=1`
+	var args []interface{}
+	if filter.Status != "" {
+		where += ` AND status=?`
+		args = append(args, filter.Status)
+	}
+	if filter.Type != "" {
+		where += ` AND type=?`
+		args = append(args, filter.Type)
+	}
+	if filter.ResourceID != "" {
+		where += ` AND resource_id=?`
+		args = append(args, filter.ResourceID)
+	}
+	if !filter.Since.IsZero() {
+		where += ` AND created_ns>=?`
+		args = append(args, filter.Since.UnixNano())
+	}
+	if !filter.Until.IsZero() {
+		where += ` AND created_ns<?`
+		args = append(args, filter.Until.UnixNano())
+	}
+
+	var total int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM jobs`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	if filter.Offset >= total {
+		return nil, total, nil
+	}
+
+	// A negative LIMIT means no limit in SQLite
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	query := `SELECT ` + jobColumns + ` FROM jobs` + where + ` ORDER BY created_ns DESC, rowid DESC LIMIT ? OFFSET ?`
+	jobs, err := d.queryJobs(query, append(args, limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return jobs, total, nil
+}
+
+// PruneJobs deletes succeeded and dead jobs that finished longer ago than
+// retention, returning how many were deleted
+func (d *Database) PruneJobs(retention time.Duration) (int, error) {
+	finished, err := d.queryJobs(`SELECT `+jobColumns+` FROM jobs WHERE status IN (?, ?)`, JobSucceeded, JobDead)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-retention)
+	pruned := 0
+	for _, job := range finished {
+		if job.FinishedAt == nil || job.FinishedAt.After(cutoff) {
+			continue
+		}
+		// The status check keeps a job that was just retried
+		result, err := d.db.Exec(`DELETE FROM jobs WHERE id=? AND status IN (?, ?)`, job.ID, JobSucceeded, JobDead)
+		if err != nil {
+			return pruned, err
+		}
+		if n, _ := result.RowsAffected(); n == 1 {
+			pruned++
+		}
+	}
+
+	return pruned, nil
+}
+
+// ClaimJob hands the oldest due pending or failed job of the given types to a
+// worker, or returns nil if there is none. The status check in the UPDATE makes
+// the claim safe against other workers racing for the same job.
+func (d *Database) ClaimJob(workerID string, types []string) (*Job, error) {
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	args := []interface{}{JobPending, JobFailed, now.UnixNano()}
+	for _, jobType := range types {
+		args = append(args, jobType)
+	}
+	args = append(args, claimBatch)
+	candidates, err := d.queryJobs(`
+		SELECT `+jobColumns+` FROM jobs
+		WHERE status IN (?, ?) AND run_after_ns<=? AND type IN (?`+strings.Repeat(", ?", len(types)-1)+`)
+		ORDER BY created_ns, rowid LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, job := range candidates {
+
+		result, err := d.db.Exec(`
+			UPDATE jobs SET status=?, worker_id=?, attempts=attempts+1, heartbeat_at=?, updated_at=?
+			WHERE id=? AND status=?`,
+			JobRunning, workerID, now, now, job.ID, job.Status)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := result.RowsAffected(); n == 1 {
+			return d.GetJob(job.ID)
+		}
+	}
+
+	return nil, nil
+}
+
+// HeartbeatJob records that a worker is still running a job. It returns
+// sql.ErrNoRows if the job was taken away from the worker.
+func (d *Database) HeartbeatJob(id, workerID string) error {
+	now := time.Now()
+	result, err := d.db.Exec(`UPDATE jobs SET heartbeat_at=?, updated_at=? WHERE id=? AND worker_id=? AND status=?`, now, now, id, workerID, JobRunning)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SaveJobProgress stores the partial result of a running job, so it can be
+// read while the job runs and picked up by a later attempt
+func (d *Database) SaveJobProgress(id, workerID, result string) error {
+	_, err := d.db.Exec(`UPDATE jobs SET result=?, updated_at=? WHERE id=? AND worker_id=? AND status=?`,
+		result, time.Now(), id, workerID, JobRunning)
+	return err
+}
+
+// CompleteJob marks a job as succeeded with its JSON result
+func (d *Database) CompleteJob(id, workerID, result string) error {
+	now := time.Now()
+	_, err := d.db.Exec(`UPDATE jobs SET status=?, result=?, last_error='', finished_at=?, updated_at=? WHERE id=? AND worker_id=?`,
+		JobSucceeded, result, now, now, id, workerID)
+	return err
+}
+
+// FailJob records a failed attempt. The job is retried after retryAfter, or
+// dead-lettered once it has used all its attempts.
+func (d *Database) FailJob(id, workerID, message string, retryAfter time.Duration) error {
+	job, err := d.GetJob(id)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if job.Attempts >= job.MaxAttempts {
+		_, err = d.db.Exec(`UPDATE jobs SET status=?, last_error=?, finished_at=?, updated_at=? WHERE id=? AND worker_id=?`,
+			JobDead, message, now, now, id, workerID)
+		return err
+	}
+
+	runAfter := now.Add(retryAfter)
+	_, err = d.db.Exec(`UPDATE jobs SET status=?, last_error=?, run_after=?, run_after_ns=?, updated_at=? WHERE id=? AND worker_id=?`,
+		JobFailed, message, runAfter, runAfter.UnixNano(), now, id, workerID)
+	return err
+}
+
+// RequeueStaleJobs hands running jobs whose worker stopped heartbeating back to the
+// queue, or dead-letters them if they are out of attempts. It returns how many
+// jobs were requeued and the jobs that were dead-lettered.
+func (d *Database) RequeueStaleJobs(timeout time.Duration) (int, []*Job, error) {
+	running, err := d.queryJobs(`SELECT `+jobColumns+` FROM jobs WHERE status=?`, JobRunning)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	now := time.Now()
+	requeued := 0
+	var dead []*Job
+	for _, job := range running {
+		if job.HeartbeatAt != nil && now.Sub(*job.HeartbeatAt) < timeout {
+			continue
+		}
+
+		status, finishedAt := JobFailed, (*time.Time)(nil)
+		if job.Attempts >= job.MaxAttempts {
+			status, finishedAt = JobDead, &now
+		}
+		result, err := d.db.Exec(`
+			UPDATE jobs SET status=?, last_error=?, worker_id='', run_after=?, run_after_ns=?, finished_at=?, updated_at=?
+			WHERE id=? AND status=? AND worker_id=?`,
+			status, "worker "+job.WorkerID+" stopped heartbeating", now, now.UnixNano(), finishedAt, now, job.ID, JobRunning, job.WorkerID)
+		if err != nil {
+			return requeued, dead, err
+		}
+		if n, _ := result.RowsAffected(); n == 1 {
+			if status == JobFailed {
+				requeued++
+			} else {
+				dead = append(dead, job)
+			}
+		}
+	}
+
+	return requeued, dead, nil
+}
+
+// RetryJob puts a dead job back in the queue with a fresh set of attempts
+func (d *Database) RetryJob(id string) error {
+	now := time.Now()
+	result, err := d.db.Exec(`UPDATE jobs SET status=?, attempts=0, worker_id='', run_after=?, run_after_ns=?, finished_at=NULL, updated_at=? WHERE id=? AND status=?`,
+		JobPending, now, now.UnixNano(), now, id, JobDead)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (d *Database) queryJobs(query string, args ...interface{}) ([]*Job, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
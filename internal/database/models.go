@@ -2,20 +2,125 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 )
 
+// busyTimeoutMS is how long a connection waits for another writer's lock, so
+// job workers and API requests writing at the same time don't fail with SQLITE_BUSY
+const busyTimeoutMS = 5000
+
+// withDSNParam appends a connection parameter to a database path unless the
+// path already carries its own parameters
+func withDSNParam(dbPath, param string) string {
+	if strings.Contains(dbPath, "?") {
+		return dbPath
+	}
+	return dbPath + "?" + param
+}
+
 // VM represents a Firecracker virtual machine
 type VM struct {
-	ID        string    `json:"id" db:"id"`
-	Name      string    `json:"name" db:"name"`
-	Status    string    `json:"status" db:"status"` // creating, running, stopped, error
-	Memory    int64     `json:"memory" db:"memory"` // MB
-	CPUs      int       `json:"cpus" db:"cpus"`
-	DiskSize  int64     `json:"disk_size" db:"disk_size"` // GB
-	IPAddress string    `json:"ip_address" db:"ip_address"`
+	ID          string `json:"id" db:"id"`
+	Name        string `json:"name" db:"name"`
+	Status      string `json:"status" db:"status"` // creating, running, stopped, crashed, error
+	Memory      int64  `json:"memory" db:"memory"` // MB
+	CPUs        int    `json:"cpus" db:"cpus"`
+	DiskSize    int64  `json:"disk_size" db:"disk_size"` // GB
+	IPAddress   string `json:"ip_address" db:"ip_address"`
+	BootProfile string `json:"boot_profile,omitempty" db:"boot_profile"`
+	Profile     string `json:"profile,omitempty" db:"sizing_profile"` // sizing profile, see SizingProfile
+	Entropy     bool   `json:"entropy" db:"entropy"`                  // virtio-rng device attached
+	Ignition    string `json:"-" db:"ignition"`                       // Ignition config served over MMDS
+	Labels      string `json:"labels" db:"labels"`                    // JSON string of labels used for container placement
+	Env         string `json:"-" db:"env"`                            // JSON string of environment variables served over MMDS
+
+	// StatusReason explains an error status
+	StatusReason string `json:"status_reason,omitempty" db:"status_reason"`
+
+	// How the VM's last Firecracker process ended when it wasn't stopped
+	// through the orchestrator: its exit status, or 128 plus the signal that
+	// killed it (-1 if it couldn't be collected), and when it was noticed
+	ExitCode int        `json:"exit_code,omitempty" db:"exit_code"`
+	ExitedAt *time.Time `json:"exited_at,omitempty" db:"exited_at"`
+
+	// Quarantined VMs keep running with their network cut, for forensics
+	Quarantined      bool   `json:"quarantined" db:"quarantined"`
+	QuarantineReason string `json:"quarantine_reason,omitempty" db:"quarantine_reason"`
+
+	// Degraded VMs are still running but their guest logged a kernel oops or
+	// ran the OOM killer; cleared on the next start
+	Degraded       bool   `json:"degraded" db:"degraded"`
+	DegradedReason string `json:"degraded_reason,omitempty" db:"degraded_reason"`
+
+	// Firecracker logger settings; an empty level uses FIRECRACKER_LOG_LEVEL
+	LogLevel      string `json:"log_level,omitempty" db:"log_level"`
+	LogShowLevel  bool   `json:"log_show_level" db:"log_show_level"`
+	LogShowOrigin bool   `json:"log_show_origin" db:"log_show_origin"`
+
+	// IdleAction overrides IDLE_ACTION for this VM: none, notify, stop or delete
+	IdleAction string `json:"idle_action,omitempty" db:"idle_action"`
+
+	// OutputMode overrides VM_OUTPUT_MODE for where the serial console goes:
+	// discard, file or buffer
+	OutputMode string `json:"output_mode,omitempty" db:"output_mode"`
+
+	// RestartPolicy overrides RESTART_POLICY for whether the VM is started
+	// again when its Firecracker process exits on its own: never, on-failure
+	// or always. RestartCount is how many restarts have been attempted since
+	// it was last started through the API.
+	RestartPolicy string `json:"restart_policy,omitempty" db:"restart_policy"`
+	RestartCount  int    `json:"restart_count" db:"restart_count"`
+
+	// RootfsMode is how the VM's root filesystem was made, copy or overlay;
+	// fixed at creation, and empty for VMs that predate it (copy)
+	RootfsMode string `json:"rootfs_mode,omitempty" db:"rootfs_mode"`
+
+	// Where the manager finds the VM after a restart: its API socket and TAP
+	// device, and the PID of its Firecracker process while one is running
+	SocketPath string `json:"socket_path,omitempty" db:"socket_path"`
+	TAPDevice  string `json:"tap_device,omitempty" db:"tap_device"`
+	PID        int    `json:"pid,omitempty" db:"pid"`
+
+	// MACAddress is the guest's eth0 address, unique among VMs and kept for
+	// the VM's lifetime so DHCP leases stay stable
+	MACAddress string `json:"mac_address,omitempty" db:"mac_address"`
+
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	// GuestInfo is the latest guest agent report; only loaded for single-VM lookups
+	GuestInfo *GuestInfo `json:"guest_info,omitempty" db:"-"`
+	// Drifted is set when the VM's Firecracker config file no longer matches its spec
+	Drifted bool `json:"drifted" db:"-"`
+	// Cost is the VM's estimated cost; only set when cost rates are configured
+	Cost *Cost `json:"cost,omitempty" db:"-"`
+}
+
+// Cost is an estimate of what a VM costs to run at the configured rates
+type Cost struct {
+	Hourly   float64 `json:"hourly"`
+	Monthly  float64 `json:"monthly"`
+	Currency string  `json:"currency"`
+}
+
+// vmColumns lists the vms columns in the order scanVM expects them
+const vmColumns = `id, name, status, status_reason, exit_code, exited_at, memory, cpus, disk_size, ip_address, boot_profile, sizing_profile, entropy, ignition, labels, env, quarantined, quarantine_reason, degraded, degraded_reason, log_level, log_show_level, log_show_origin, idle_action, output_mode, restart_policy, restart_count, rootfs_mode, socket_path, tap_device, pid, mac_address, created_at, updated_at`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanVM scans a row selected with vmColumns into a VM
+func scanVM(row rowScanner) (*VM, error) {
+	vm := &VM{}
+	err := row.Scan(&vm.ID, &vm.Name, &vm.Status, &vm.StatusReason, &vm.ExitCode, &vm.ExitedAt, &vm.Memory, &vm.CPUs, &vm.DiskSize, &vm.IPAddress, &vm.BootProfile, &vm.Profile, &vm.Entropy, &vm.Ignition, &vm.Labels, &vm.Env, &vm.Quarantined, &vm.QuarantineReason, &vm.Degraded, &vm.DegradedReason, &vm.LogLevel, &vm.LogShowLevel, &vm.LogShowOrigin, &vm.IdleAction, &vm.OutputMode, &vm.RestartPolicy, &vm.RestartCount, &vm.RootfsMode, &vm.SocketPath, &vm.TAPDevice, &vm.PID, &vm.MACAddress, &vm.CreatedAt, &vm.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return vm, nil
 }
 
 // Container represents a Docker container running in a VM
@@ -23,23 +128,53 @@ type Container struct {
 	ID          string    `json:"id" db:"id"`
 	Name        string    `json:"name" db:"name"`
 	Image       string    `json:"image" db:"image"`
-	Status      string    `json:"status" db:"status"` // creating, running, stopped, error
+	Status      string    `json:"status" db:"status"` // creating, running, stopped, error, init-failed
 	VMID        string    `json:"vm_id" db:"vm_id"`
 	ContainerID string    `json:"container_id" db:"container_id"` // Docker container ID
 	Ports       string    `json:"ports" db:"ports"`               // JSON string of port mappings
 	Environment string    `json:"environment" db:"environment"`   // JSON string of env vars
+	Volumes     string    `json:"volumes" db:"volumes"`           // JSON string of volume mounts
+	InitSteps   string    `json:"init_steps" db:"init_steps"`     // JSON string of steps run before the container starts
+	Memory      int64     `json:"memory" db:"memory"`             // MB requested from the VM, 0 if unspecified
+	CPUs        int       `json:"cpus" db:"cpus"`                 // vCPUs requested from the VM, 0 if unspecified
+	Isolation   string    `json:"isolation" db:"isolation"`       // shared, or dedicated to run alone in its own VM
+	Revision    int       `json:"revision" db:"revision"`         // current spec revision, see ContainerRevision
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+
+	// Placement describes how the scheduler chose the VM; only set on creation
+	Placement *Placement `json:"placement,omitempty" db:"-"`
+}
+
+// Placement records the VM the scheduler chose for a container
+type Placement struct {
+	VMID        string `json:"vm_id"`
+	VMName      string `json:"vm_name"`
+	Provisioned bool   `json:"provisioned"` // a new VM was created for the container
+}
+
+// containerColumns lists the containers columns in the order scanContainer expects them
+const containerColumns = `id, name, image, status, vm_id, container_id, ports, environment, volumes, init_steps, memory, cpus, isolation, revision, created_at, updated_at`
+
+// scanContainer scans a row selected with containerColumns into a Container
+func scanContainer(row rowScanner) (*Container, error) {
+	container := &Container{}
+	err := row.Scan(&container.ID, &container.Name, &container.Image, &container.Status, &container.VMID, &container.ContainerID, &container.Ports, &container.Environment, &container.Volumes, &container.InitSteps, &container.Memory, &container.CPUs, &container.Isolation, &container.Revision, &container.CreatedAt, &container.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return container, nil
 }
 
 // Database handles SQLite operations
 type Database struct {
-	db *sql.DB
+	db   *sql.DB
+	keys *Keyring // encrypts sensitive columns; nil stores them in plaintext
 }
 
 // NewDatabase creates a new database connection
 func NewDatabase(dbPath string) (*Database, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open("sqlite3", withDSNParam(dbPath, fmt.Sprintf("_busy_timeout=%d", busyTimeoutMS)))
 	if err != nil {
 		return nil, err
 	}
@@ -59,10 +194,35 @@ func (d *Database) createTables() error {
 		id TEXT PRIMARY KEY,
 		name TEXT NOT NULL,
 		status TEXT NOT NULL,
+		status_reason TEXT NOT NULL DEFAULT '',
+		exit_code INTEGER NOT NULL DEFAULT 0,
+		exited_at DATETIME,
 		memory INTEGER NOT NULL,
 		cpus INTEGER NOT NULL,
 		disk_size INTEGER NOT NULL,
 		ip_address TEXT,
+		boot_profile TEXT NOT NULL DEFAULT '',
+		sizing_profile TEXT NOT NULL DEFAULT '',
+		entropy BOOLEAN NOT NULL DEFAULT 0,
+		ignition TEXT NOT NULL DEFAULT '',
+		labels TEXT NOT NULL DEFAULT '',
+		env TEXT NOT NULL DEFAULT '',
+		quarantined BOOLEAN NOT NULL DEFAULT 0,
+		quarantine_reason TEXT NOT NULL DEFAULT '',
+		degraded BOOLEAN NOT NULL DEFAULT 0,
+		degraded_reason TEXT NOT NULL DEFAULT '',
+		log_level TEXT NOT NULL DEFAULT '',
+		log_show_level BOOLEAN NOT NULL DEFAULT 0,
+		log_show_origin BOOLEAN NOT NULL DEFAULT 0,
+		idle_action TEXT NOT NULL DEFAULT '',
+		output_mode TEXT NOT NULL DEFAULT '',
+		restart_policy TEXT NOT NULL DEFAULT '',
+		restart_count INTEGER NOT NULL DEFAULT 0,
+		rootfs_mode TEXT NOT NULL DEFAULT '',
+		socket_path TEXT NOT NULL DEFAULT '',
+		tap_device TEXT NOT NULL DEFAULT '',
+		pid INTEGER NOT NULL DEFAULT 0,
+		mac_address TEXT NOT NULL DEFAULT '',
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);`
@@ -77,6 +237,12 @@ func (d *Database) createTables() error {
 		container_id TEXT,
 		ports TEXT,
 		environment TEXT,
+		volumes TEXT NOT NULL DEFAULT '',
+		init_steps TEXT NOT NULL DEFAULT '',
+		memory INTEGER NOT NULL DEFAULT 0,
+		cpus INTEGER NOT NULL DEFAULT 0,
+		isolation TEXT NOT NULL DEFAULT 'shared',
+		revision INTEGER NOT NULL DEFAULT 0,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (vm_id) REFERENCES vms (id)
@@ -90,55 +256,237 @@ func (d *Database) createTables() error {
 		return err
 	}
 
+	if err := d.createBootProfileTable(); err != nil {
+		return err
+	}
+
+	if err := d.createSizingProfileTable(); err != nil {
+		return err
+	}
+
+	if err := d.createDriveTable(); err != nil {
+		return err
+	}
+
+	if err := d.createGuestInfoTable(); err != nil {
+		return err
+	}
+
+	if err := d.createContainerRevisionTable(); err != nil {
+		return err
+	}
+
+	if err := d.createContainerStatusTable(); err != nil {
+		return err
+	}
+
+	if err := d.createHookTable(); err != nil {
+		return err
+	}
+
+	if err := d.createJobTable(); err != nil {
+		return err
+	}
+
+	if err := d.createReservationTable(); err != nil {
+		return err
+	}
+
+	if err := d.createAdmissionWebhookTable(); err != nil {
+		return err
+	}
+
+	if err := d.createIPLeaseTable(); err != nil {
+		return err
+	}
+
+	if err := d.createPortForwardTable(); err != nil {
+		return err
+	}
+
+	if err := d.createUserTable(); err != nil {
+		return err
+	}
+
+	if err := d.createSecurityGroupTable(); err != nil {
+		return err
+	}
+
+	if err := d.createDiskCopyTable(); err != nil {
+		return err
+	}
+
+	if err := d.migrate(); err != nil {
+		return err
+	}
+	return d.migrateJobTimes()
+}
+
+// migrate adds columns introduced after the initial schema to existing databases
+func (d *Database) migrate() error {
+	migrations := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"vms", "boot_profile", "TEXT NOT NULL DEFAULT ''"},
+		{"vms", "entropy", "BOOLEAN NOT NULL DEFAULT 0"},
+		{"vms", "ignition", "TEXT NOT NULL DEFAULT ''"},
+		{"vms", "labels", "TEXT NOT NULL DEFAULT ''"},
+		{"vms", "sizing_profile", "TEXT NOT NULL DEFAULT ''"},
+		{"vms", "log_level", "TEXT NOT NULL DEFAULT ''"},
+		{"vms", "log_show_level", "BOOLEAN NOT NULL DEFAULT 0"},
+		{"vms", "log_show_origin", "BOOLEAN NOT NULL DEFAULT 0"},
+		{"vms", "env", "TEXT NOT NULL DEFAULT ''"},
+		{"vms", "quarantined", "BOOLEAN NOT NULL DEFAULT 0"},
+		{"vms", "quarantine_reason", "TEXT NOT NULL DEFAULT ''"},
+		{"guest_info", "clock_source", "TEXT NOT NULL DEFAULT ''"},
+		{"guest_info", "clock_skew_ms", "INTEGER NOT NULL DEFAULT 0"},
+		{"jobs", "resource_id", "TEXT NOT NULL DEFAULT ''"},
+		{"jobs", "run_after_ns", "INTEGER NOT NULL DEFAULT 0"},
+		{"jobs", "created_ns", "INTEGER NOT NULL DEFAULT 0"},
+		{"vms", "status_reason", "TEXT NOT NULL DEFAULT ''"},
+		{"vms", "idle_action", "TEXT NOT NULL DEFAULT ''"},
+		{"vms", "output_mode", "TEXT NOT NULL DEFAULT ''"},
+		{"vms", "degraded", "BOOLEAN NOT NULL DEFAULT 0"},
+		{"vms", "degraded_reason", "TEXT NOT NULL DEFAULT ''"},
+		{"vms", "rootfs_mode", "TEXT NOT NULL DEFAULT ''"},
+		{"vms", "socket_path", "TEXT NOT NULL DEFAULT ''"},
+		{"vms", "tap_device", "TEXT NOT NULL DEFAULT ''"},
+		{"vms", "pid", "INTEGER NOT NULL DEFAULT 0"},
+		{"vms", "exit_code", "INTEGER NOT NULL DEFAULT 0"},
+		{"vms", "exited_at", "DATETIME"},
+		{"vms", "restart_policy", "TEXT NOT NULL DEFAULT ''"},
+		{"vms", "restart_count", "INTEGER NOT NULL DEFAULT 0"},
+		{"vms", "mac_address", "TEXT NOT NULL DEFAULT ''"},
+		{"containers", "volumes", "TEXT NOT NULL DEFAULT ''"},
+		{"containers", "init_steps", "TEXT NOT NULL DEFAULT ''"},
+		{"containers", "memory", "INTEGER NOT NULL DEFAULT 0"},
+		{"containers", "cpus", "INTEGER NOT NULL DEFAULT 0"},
+		{"containers", "isolation", "TEXT NOT NULL DEFAULT 'shared'"},
+		{"containers", "revision", "INTEGER NOT NULL DEFAULT 0"},
+	}
+
+	for _, m := range migrations {
+		if err := d.addColumnIfMissing(m.table, m.column, m.definition); err != nil {
+			return fmt.Errorf("failed to migrate %s.%s: %w", m.table, m.column, err)
+		}
+	}
+
 	return nil
 }
 
+// addColumnIfMissing adds a column to a table unless it already exists
+func (d *Database) addColumnIfMissing(table, column, definition string) error {
+	rows, err := d.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = d.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
 // Close closes the database connection
 func (d *Database) Close() error {
 	return d.db.Close()
 }
 
+// SnapshotTo writes a consistent copy of the database to a new file at path
+func (d *Database) SnapshotTo(path string) error {
+	_, err := d.db.Exec(`VACUUM INTO ?`, path)
+	return err
+}
+
 // CreateVM inserts a new VM into the database
 func (d *Database) CreateVM(vm *VM) error {
 	query := `
-		INSERT INTO vms (id, name, status, memory, cpus, disk_size, ip_address, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		INSERT INTO vms (` + vmColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	env, ignition, err := d.sealVM(vm)
+	if err != nil {
+		return err
+	}
 
 	vm.CreatedAt = time.Now()
 	vm.UpdatedAt = time.Now()
 
-	_, err := d.db.Exec(query, vm.ID, vm.Name, vm.Status, vm.Memory, vm.CPUs, vm.DiskSize, vm.IPAddress, vm.CreatedAt, vm.UpdatedAt)
+	_, err = d.db.Exec(query, vm.ID, vm.Name, vm.Status, vm.StatusReason, vm.ExitCode, vm.ExitedAt, vm.Memory, vm.CPUs, vm.DiskSize, vm.IPAddress, vm.BootProfile, vm.Profile, vm.Entropy, ignition, vm.Labels, env, vm.Quarantined, vm.QuarantineReason, vm.Degraded, vm.DegradedReason, vm.LogLevel, vm.LogShowLevel, vm.LogShowOrigin, vm.IdleAction, vm.OutputMode, vm.RestartPolicy, vm.RestartCount, vm.RootfsMode, vm.SocketPath, vm.TAPDevice, vm.PID, vm.MACAddress, vm.CreatedAt, vm.UpdatedAt)
 	return err
 }
 
 // UpdateVM updates an existing VM in the database
 func (d *Database) UpdateVM(vm *VM) error {
 	query := `
-		UPDATE vms SET name=?, status=?, memory=?, cpus=?, disk_size=?, ip_address=?, updated_at=?
+		UPDATE vms SET name=?, status=?, status_reason=?, exit_code=?, exited_at=?, memory=?, cpus=?, disk_size=?, ip_address=?, boot_profile=?, sizing_profile=?, entropy=?, ignition=?, labels=?, env=?, quarantined=?, quarantine_reason=?, degraded=?, degraded_reason=?, log_level=?, log_show_level=?, log_show_origin=?, idle_action=?, output_mode=?, restart_policy=?, restart_count=?, rootfs_mode=?, socket_path=?, tap_device=?, pid=?, mac_address=?, updated_at=?
 		WHERE id=?`
 
+	env, ignition, err := d.sealVM(vm)
+	if err != nil {
+		return err
+	}
+
 	vm.UpdatedAt = time.Now()
 
-	_, err := d.db.Exec(query, vm.Name, vm.Status, vm.Memory, vm.CPUs, vm.DiskSize, vm.IPAddress, vm.UpdatedAt, vm.ID)
+	_, err = d.db.Exec(query, vm.Name, vm.Status, vm.StatusReason, vm.ExitCode, vm.ExitedAt, vm.Memory, vm.CPUs, vm.DiskSize, vm.IPAddress, vm.BootProfile, vm.Profile, vm.Entropy, ignition, vm.Labels, env, vm.Quarantined, vm.QuarantineReason, vm.Degraded, vm.DegradedReason, vm.LogLevel, vm.LogShowLevel, vm.LogShowOrigin, vm.IdleAction, vm.OutputMode, vm.RestartPolicy, vm.RestartCount, vm.RootfsMode, vm.SocketPath, vm.TAPDevice, vm.PID, vm.MACAddress, vm.UpdatedAt, vm.ID)
 	return err
 }
 
 // GetVM retrieves a VM by ID
 func (d *Database) GetVM(id string) (*VM, error) {
-	query := `SELECT id, name, status, memory, cpus, disk_size, ip_address, created_at, updated_at FROM vms WHERE id=?`
+	query := `SELECT ` + vmColumns + ` FROM vms WHERE id=?`
 
-	vm := &VM{}
-	err := d.db.QueryRow(query, id).Scan(&vm.ID, &vm.Name, &vm.Status, &vm.Memory, &vm.CPUs, &vm.DiskSize, &vm.IPAddress, &vm.CreatedAt, &vm.UpdatedAt)
+	vm, err := scanVM(d.db.QueryRow(query, id))
 	if err != nil {
 		return nil, err
 	}
+	return d.openVM(vm)
+}
 
-	return vm, nil
+// ListTAPDevices returns the TAP device names VMs hold
+func (d *Database) ListTAPDevices() ([]string, error) {
+	rows, err := d.db.Query(`SELECT tap_device FROM vms WHERE tap_device<>''`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var taps []string
+	for rows.Next() {
+		var tap string
+		if err := rows.Scan(&tap); err != nil {
+			return nil, err
+		}
+		taps = append(taps, tap)
+	}
+	return taps, rows.Err()
 }
 
 // ListVMs retrieves all VMs
 func (d *Database) ListVMs() ([]*VM, error) {
-	query := `SELECT id, name, status, memory, cpus, disk_size, ip_address, created_at, updated_at FROM vms ORDER BY created_at DESC`
+	query := `SELECT ` + vmColumns + ` FROM vms ORDER BY created_at DESC`
 
 	rows, err := d.db.Query(query)
 	if err != nil {
@@ -148,17 +496,41 @@ func (d *Database) ListVMs() ([]*VM, error) {
 
 	var vms []*VM
 	for rows.Next() {
-		vm := &VM{}
-		err := rows.Scan(&vm.ID, &vm.Name, &vm.Status, &vm.Memory, &vm.CPUs, &vm.DiskSize, &vm.IPAddress, &vm.CreatedAt, &vm.UpdatedAt)
+		vm, err := scanVM(rows)
 		if err != nil {
 			return nil, err
 		}
+		if vm, err = d.openVM(vm); err != nil {
+			return nil, err
+		}
 		vms = append(vms, vm)
 	}
 
 	return vms, nil
 }
 
+// GetVMByIPAddress retrieves the VM holding an IP address
+func (d *Database) GetVMByIPAddress(ip string) (*VM, error) {
+	query := `SELECT ` + vmColumns + ` FROM vms WHERE ip_address=?`
+
+	vm, err := scanVM(d.db.QueryRow(query, ip))
+	if err != nil {
+		return nil, err
+	}
+	return d.openVM(vm)
+}
+
+// GetVMByMACAddress retrieves the VM holding a MAC address
+func (d *Database) GetVMByMACAddress(mac string) (*VM, error) {
+	query := `SELECT ` + vmColumns + ` FROM vms WHERE mac_address=?`
+
+	vm, err := scanVM(d.db.QueryRow(query, mac))
+	if err != nil {
+		return nil, err
+	}
+	return d.openVM(vm)
+}
+
 // DeleteVM removes a VM from the database
 func (d *Database) DeleteVM(id string) error {
 	query := `DELETE FROM vms WHERE id=?`
@@ -169,44 +541,52 @@ func (d *Database) DeleteVM(id string) error {
 // CreateContainer inserts a new container into the database
 func (d *Database) CreateContainer(container *Container) error {
 	query := `
-		INSERT INTO containers (id, name, image, status, vm_id, container_id, ports, environment, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		INSERT INTO containers (` + containerColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	environment, err := d.keys.Seal(container.Environment)
+	if err != nil {
+		return err
+	}
 
 	container.CreatedAt = time.Now()
 	container.UpdatedAt = time.Now()
 
-	_, err := d.db.Exec(query, container.ID, container.Name, container.Image, container.Status, container.VMID, container.ContainerID, container.Ports, container.Environment, container.CreatedAt, container.UpdatedAt)
+	_, err = d.db.Exec(query, container.ID, container.Name, container.Image, container.Status, container.VMID, container.ContainerID, container.Ports, environment, container.Volumes, container.InitSteps, container.Memory, container.CPUs, container.Isolation, container.Revision, container.CreatedAt, container.UpdatedAt)
 	return err
 }
 
 // UpdateContainer updates an existing container in the database
 func (d *Database) UpdateContainer(container *Container) error {
 	query := `
-		UPDATE containers SET name=?, image=?, status=?, vm_id=?, container_id=?, ports=?, environment=?, updated_at=?
+		UPDATE containers SET name=?, image=?, status=?, vm_id=?, container_id=?, ports=?, environment=?, volumes=?, init_steps=?, memory=?, cpus=?, isolation=?, revision=?, updated_at=?
 		WHERE id=?`
 
+	environment, err := d.keys.Seal(container.Environment)
+	if err != nil {
+		return err
+	}
+
 	container.UpdatedAt = time.Now()
 
-	_, err := d.db.Exec(query, container.Name, container.Image, container.Status, container.VMID, container.ContainerID, container.Ports, container.Environment, container.UpdatedAt, container.ID)
+	_, err = d.db.Exec(query, container.Name, container.Image, container.Status, container.VMID, container.ContainerID, container.Ports, environment, container.Volumes, container.InitSteps, container.Memory, container.CPUs, container.Isolation, container.Revision, container.UpdatedAt, container.ID)
 	return err
 }
 
 // GetContainer retrieves a container by ID
 func (d *Database) GetContainer(id string) (*Container, error) {
-	query := `SELECT id, name, image, status, vm_id, container_id, ports, environment, created_at, updated_at FROM containers WHERE id=?`
+	query := `SELECT ` + containerColumns + ` FROM containers WHERE id=?`
 
-	container := &Container{}
-	err := d.db.QueryRow(query, id).Scan(&container.ID, &container.Name, &container.Image, &container.Status, &container.VMID, &container.ContainerID, &container.Ports, &container.Environment, &container.CreatedAt, &container.UpdatedAt)
+	container, err := scanContainer(d.db.QueryRow(query, id))
 	if err != nil {
 		return nil, err
 	}
-
-	return container, nil
+	return d.openContainer(container)
 }
 
 // ListContainers retrieves all containers
 func (d *Database) ListContainers() ([]*Container, error) {
-	query := `SELECT id, name, image, status, vm_id, container_id, ports, environment, created_at, updated_at FROM containers ORDER BY created_at DESC`
+	query := `SELECT ` + containerColumns + ` FROM containers ORDER BY created_at DESC`
 
 	rows, err := d.db.Query(query)
 	if err != nil {
@@ -216,11 +596,13 @@ func (d *Database) ListContainers() ([]*Container, error) {
 
 	var containers []*Container
 	for rows.Next() {
-		container := &Container{}
-		err := rows.Scan(&container.ID, &container.Name, &container.Image, &container.Status, &container.VMID, &container.ContainerID, &container.Ports, &container.Environment, &container.CreatedAt, &container.UpdatedAt)
+		container, err := scanContainer(rows)
 		if err != nil {
 			return nil, err
 		}
+		if container, err = d.openContainer(container); err != nil {
+			return nil, err
+		}
 		containers = append(containers, container)
 	}
 
@@ -229,7 +611,7 @@ func (d *Database) ListContainers() ([]*Container, error) {
 
 // ListContainersByVM retrieves containers for a specific VM
 func (d *Database) ListContainersByVM(vmID string) ([]*Container, error) {
-	query := `SELECT id, name, image, status, vm_id, container_id, ports, environment, created_at, updated_at FROM containers WHERE vm_id=? ORDER BY created_at DESC`
+	query := `SELECT ` + containerColumns + ` FROM containers WHERE vm_id=? ORDER BY created_at DESC`
 
 	rows, err := d.db.Query(query, vmID)
 	if err != nil {
@@ -239,17 +621,30 @@ func (d *Database) ListContainersByVM(vmID string) ([]*Container, error) {
 
 	var containers []*Container
 	for rows.Next() {
-		container := &Container{}
-		err := rows.Scan(&container.ID, &container.Name, &container.Image, &container.Status, &container.VMID, &container.ContainerID, &container.Ports, &container.Environment, &container.CreatedAt, &container.UpdatedAt)
+		container, err := scanContainer(rows)
 		if err != nil {
 			return nil, err
 		}
+		if container, err = d.openContainer(container); err != nil {
+			return nil, err
+		}
 		containers = append(containers, container)
 	}
 
 	return containers, nil
 }
 
+// DedicatedContainerID returns the ID of the container a VM was created for
+// with dedicated isolation, or "" if the VM isn't one
+func (d *Database) DedicatedContainerID(vmID string) (string, error) {
+	var id string
+	err := d.db.QueryRow(`SELECT id FROM containers WHERE vm_id=? AND isolation='dedicated' LIMIT 1`, vmID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
 // DeleteContainer removes a container from the database
 func (d *Database) DeleteContainer(id string) error {
 	query := `DELETE FROM containers WHERE id=?`
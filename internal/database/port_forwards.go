@@ -0,0 +1,104 @@
+package database
+
+import (
+	"time"
+)
+
+// PortForward publishes a host port to a port on a VM's guest address
+type PortForward struct {
+	ID        string    `json:"id" db:"id"`
+	VMID      string    `json:"vm_id" db:"vm_id"`
+	Protocol  string    `json:"protocol" db:"protocol"` // tcp or udp
+	HostPort  int       `json:"host_port" db:"host_port"`
+	GuestPort int       `json:"guest_port" db:"guest_port"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// createPortForwardTable creates the port_forwards table; a host port can be
+// forwarded to one VM per protocol
+func (d *Database) createPortForwardTable() error {
+	table := `
+	CREATE TABLE IF NOT EXISTS port_forwards (
+		id TEXT PRIMARY KEY,
+		vm_id TEXT NOT NULL,
+		protocol TEXT NOT NULL,
+		host_port INTEGER NOT NULL,
+		guest_port INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (protocol, host_port),
+		FOREIGN KEY (vm_id) REFERENCES vms (id)
+	);`
+
+	_, err := d.db.Exec(table)
+	return err
+}
+
+// CreatePortForward inserts a new port forward into the database
+func (d *Database) CreatePortForward(pf *PortForward) error {
+	query := `
+		INSERT INTO port_forwards (id, vm_id, protocol, host_port, guest_port, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
+
+	pf.CreatedAt = time.Now()
+
+	_, err := d.db.Exec(query, pf.ID, pf.VMID, pf.Protocol, pf.HostPort, pf.GuestPort, pf.CreatedAt)
+	return err
+}
+
+// GetPortForwardByHostPort retrieves the forward of a host port
+func (d *Database) GetPortForwardByHostPort(protocol string, hostPort int) (*PortForward, error) {
+	query := `SELECT id, vm_id, protocol, host_port, guest_port, created_at FROM port_forwards WHERE protocol=? AND host_port=?`
+
+	pf := &PortForward{}
+	err := d.db.QueryRow(query, protocol, hostPort).Scan(&pf.ID, &pf.VMID, &pf.Protocol, &pf.HostPort, &pf.GuestPort, &pf.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return pf, nil
+}
+
+// ListPortForwards retrieves every port forward, ordered by host port
+func (d *Database) ListPortForwards() ([]*PortForward, error) {
+	return d.listPortForwards(`SELECT id, vm_id, protocol, host_port, guest_port, created_at FROM port_forwards ORDER BY host_port, protocol`)
+}
+
+// ListPortForwardsByVM retrieves a VM's port forwards, ordered by host port
+func (d *Database) ListPortForwardsByVM(vmID string) ([]*PortForward, error) {
+	return d.listPortForwards(`SELECT id, vm_id, protocol, host_port, guest_port, created_at FROM port_forwards WHERE vm_id=? ORDER BY host_port, protocol`, vmID)
+}
+
+func (d *Database) listPortForwards(query string, args ...interface{}) ([]*PortForward, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	forwards := []*PortForward{}
+	for rows.Next() {
+		pf := &PortForward{}
+		if err := rows.Scan(&pf.ID, &pf.VMID, &pf.Protocol, &pf.HostPort, &pf.GuestPort, &pf.CreatedAt); err != nil {
+			return nil, err
+		}
+		forwards = append(forwards, pf)
+	}
+	return forwards, rows.Err()
+}
+
+// DeletePortForward removes a port forward from a VM, reporting whether it existed
+func (d *Database) DeletePortForward(vmID, id string) (bool, error) {
+	query := `DELETE FROM port_forwards WHERE vm_id=? AND id=?`
+	result, err := d.db.Exec(query, vmID, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// DeletePortForwardsByVM removes all of a VM's port forwards
+func (d *Database) DeletePortForwardsByVM(vmID string) error {
+	query := `DELETE FROM port_forwards WHERE vm_id=?`
+	_, err := d.db.Exec(query, vmID)
+	return err
+}
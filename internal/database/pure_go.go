@@ -2,6 +2,7 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
 
 	_ "modernc.org/sqlite" // Pure Go SQLite driver
 )
@@ -9,7 +10,7 @@ import (
 // NewPureGoDatabase creates a new database connection using pure Go SQLite driver
 // This doesn't require CGO and is easier for cross-compilation and deployment
 func NewPureGoDatabase(dbPath string) (*Database, error) {
-	db, err := sql.Open("sqlite", dbPath)
+	db, err := sql.Open("sqlite", withDSNParam(dbPath, fmt.Sprintf("_pragma=busy_timeout(%d)", busyTimeoutMS)))
 	if err != nil {
 		return nil, err
 	}
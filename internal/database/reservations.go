@@ -0,0 +1,185 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Reservation holds back host capacity and guest addresses for VMs that will be
+// created later. Memory, CPUs and addresses shrink as VMs are created against it.
+type Reservation struct {
+	ID          string    `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Memory      int64     `json:"memory" db:"memory"` // MB still reserved
+	CPUs        int       `json:"cpus" db:"cpus"`     // vCPUs still reserved
+	IPAddresses []string  `json:"ip_addresses" db:"ip_addresses"`
+	ExpiresAt   time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// Expired reports whether the reservation no longer holds anything back
+func (r *Reservation) Expired() bool {
+	return !time.Now().Before(r.ExpiresAt)
+}
+
+const reservationColumns = `id, name, memory, cpus, ip_addresses, expires_at, created_at`
+
+// createReservationTable creates the reservations table
+func (d *Database) createReservationTable() error {
+	table := `
+	CREATE TABLE IF NOT EXISTS reservations (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL DEFAULT '',
+		memory INTEGER NOT NULL DEFAULT 0,
+		cpus INTEGER NOT NULL DEFAULT 0,
+		ip_addresses TEXT NOT NULL DEFAULT '[]',
+		expires_at DATETIME NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	_, err := d.db.Exec(table)
+	return err
+}
+
+func scanReservation(row rowScanner) (*Reservation, error) {
+	r := &Reservation{}
+	var ips string
+	if err := row.Scan(&r.ID, &r.Name, &r.Memory, &r.CPUs, &ips, &r.ExpiresAt, &r.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(ips), &r.IPAddresses); err != nil {
+		return nil, fmt.Errorf("invalid stored addresses of reservation %s: %w", r.ID, err)
+	}
+	return r, nil
+}
+
+// CreateReservation inserts a new reservation into the database
+func (d *Database) CreateReservation(r *Reservation) error {
+	ips, err := json.Marshal(r.IPAddresses)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO reservations (` + reservationColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	r.CreatedAt = time.Now()
+
+	_, err = d.db.Exec(query, r.ID, r.Name, r.Memory, r.CPUs, string(ips), r.ExpiresAt, r.CreatedAt)
+	return err
+}
+
+// ClaimReservation stores what is left of a reservation after a VM took its
+// share, and leases the VM the address it took, if any, in one transaction so
+// the address is never free in between
+func (d *Database) ClaimReservation(r *Reservation, lease *IPLease) error {
+	ips, err := json.Marshal(r.IPAddresses)
+	if err != nil {
+		return err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE reservations SET memory=?, cpus=?, ip_addresses=? WHERE id=?`, r.Memory, r.CPUs, string(ips), r.ID); err != nil {
+		return err
+	}
+	if lease != nil {
+		if lease.CreatedAt.IsZero() {
+			lease.CreatedAt = time.Now()
+		}
+		if _, err := tx.Exec(`INSERT INTO ip_leases (ip, vm_id, created_at) VALUES (?, ?, ?)`, lease.IP, lease.VMID, lease.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ReturnToReservation gives a VM's claim back to its reservation and releases
+// the VM's addresses, in one transaction. ip, if set, goes back into the
+// reservation's addresses. Nothing is given back to a reservation that has
+// been released meanwhile.
+func (d *Database) ReturnToReservation(id string, memory int64, cpus int, vmID, ip string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM ip_leases WHERE vm_id=?`, vmID); err != nil {
+		return err
+	}
+
+	r, err := scanReservation(tx.QueryRow(`SELECT `+reservationColumns+` FROM reservations WHERE id=?`, id))
+	if err == sql.ErrNoRows {
+		return tx.Commit()
+	}
+	if err != nil {
+		return err
+	}
+
+	r.Memory += memory
+	r.CPUs += cpus
+	if ip != "" {
+		r.IPAddresses = append(r.IPAddresses, ip)
+	}
+	ips, err := json.Marshal(r.IPAddresses)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE reservations SET memory=?, cpus=?, ip_addresses=? WHERE id=?`, r.Memory, r.CPUs, string(ips), r.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetReservation retrieves a reservation by ID, including an expired one
+func (d *Database) GetReservation(id string) (*Reservation, error) {
+	return scanReservation(d.db.QueryRow(`SELECT `+reservationColumns+` FROM reservations WHERE id=?`, id))
+}
+
+// ListReservations retrieves the reservations that have not expired, deleting
+// expired ones along the way
+func (d *Database) ListReservations() ([]*Reservation, error) {
+	rows, err := d.db.Query(`SELECT ` + reservationColumns + ` FROM reservations ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+
+	var reservations, expired []*Reservation
+	for rows.Next() {
+		r, err := scanReservation(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if r.Expired() {
+			expired = append(expired, r)
+			continue
+		}
+		reservations = append(reservations, r)
+	}
+	rows.Close()
+
+	for _, r := range expired {
+		if _, err := d.DeleteReservation(r.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return reservations, rows.Err()
+}
+
+// DeleteReservation releases a reservation, reporting whether it existed
+func (d *Database) DeleteReservation(id string) (bool, error) {
+	result, err := d.db.Exec(`DELETE FROM reservations WHERE id=?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
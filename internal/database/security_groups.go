@@ -0,0 +1,192 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SecurityGroup is a named set of firewall rules attached to VMs. A VM with
+// at least one group only exchanges the traffic its groups' rules allow.
+type SecurityGroup struct {
+	ID          string              `json:"id" db:"id"`
+	Name        string              `json:"name" db:"name"`
+	Description string              `json:"description" db:"description"`
+	Rules       []SecurityGroupRule `json:"rules" db:"rules"`
+	CreatedAt   time.Time           `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at" db:"updated_at"`
+}
+
+// SecurityGroupRule allows traffic to (ingress) or from (egress) a VM
+type SecurityGroupRule struct {
+	Direction   string `json:"direction"`           // ingress or egress
+	Protocol    string `json:"protocol"`            // tcp, udp, icmp or all
+	CIDR        string `json:"cidr"`                // peer addresses; the source of ingress, the destination of egress
+	PortFrom    int    `json:"port_from,omitempty"` // tcp and udp only; 0 means every port
+	PortTo      int    `json:"port_to,omitempty"`   // end of the port range; 0 means just PortFrom
+	Description string `json:"description,omitempty"`
+}
+
+const securityGroupColumns = `id, name, description, rules, created_at, updated_at`
+
+// createSecurityGroupTable creates the security_groups table and the
+// vm_security_groups table recording which VMs each group is attached to
+func (d *Database) createSecurityGroupTable() error {
+	table := `
+	CREATE TABLE IF NOT EXISTS security_groups (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		description TEXT NOT NULL DEFAULT '',
+		rules TEXT NOT NULL DEFAULT '[]',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS vm_security_groups (
+		vm_id TEXT NOT NULL,
+		group_id TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (vm_id, group_id),
+		FOREIGN KEY (vm_id) REFERENCES vms (id),
+		FOREIGN KEY (group_id) REFERENCES security_groups (id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_vm_security_groups_group ON vm_security_groups (group_id);`
+
+	_, err := d.db.Exec(table)
+	return err
+}
+
+func scanSecurityGroup(row rowScanner) (*SecurityGroup, error) {
+	group := &SecurityGroup{}
+	var rules string
+	if err := row.Scan(&group.ID, &group.Name, &group.Description, &rules, &group.CreatedAt, &group.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(rules), &group.Rules); err != nil {
+		return nil, fmt.Errorf("invalid stored rules of security group %s: %w", group.ID, err)
+	}
+	return group, nil
+}
+
+// CreateSecurityGroup inserts a new security group into the database
+func (d *Database) CreateSecurityGroup(group *SecurityGroup) error {
+	rules, err := json.Marshal(group.Rules)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO security_groups (` + securityGroupColumns + `) VALUES (?, ?, ?, ?, ?, ?)`
+
+	group.CreatedAt = time.Now()
+	group.UpdatedAt = time.Now()
+
+	_, err = d.db.Exec(query, group.ID, group.Name, group.Description, string(rules), group.CreatedAt, group.UpdatedAt)
+	return err
+}
+
+// UpdateSecurityGroup updates an existing security group in the database
+func (d *Database) UpdateSecurityGroup(group *SecurityGroup) error {
+	rules, err := json.Marshal(group.Rules)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE security_groups SET name=?, description=?, rules=?, updated_at=? WHERE id=?`
+
+	group.UpdatedAt = time.Now()
+
+	_, err = d.db.Exec(query, group.Name, group.Description, string(rules), group.UpdatedAt, group.ID)
+	return err
+}
+
+// GetSecurityGroup retrieves a security group by ID
+func (d *Database) GetSecurityGroup(id string) (*SecurityGroup, error) {
+	query := `SELECT ` + securityGroupColumns + ` FROM security_groups WHERE id=?`
+	return scanSecurityGroup(d.db.QueryRow(query, id))
+}
+
+// GetSecurityGroupByName retrieves a security group by name
+func (d *Database) GetSecurityGroupByName(name string) (*SecurityGroup, error) {
+	query := `SELECT ` + securityGroupColumns + ` FROM security_groups WHERE name=?`
+	return scanSecurityGroup(d.db.QueryRow(query, name))
+}
+
+// ListSecurityGroups retrieves all security groups ordered by name
+func (d *Database) ListSecurityGroups() ([]*SecurityGroup, error) {
+	return d.listSecurityGroups(`SELECT ` + securityGroupColumns + ` FROM security_groups ORDER BY name`)
+}
+
+// ListVMSecurityGroups retrieves the security groups attached to a VM, ordered by name
+func (d *Database) ListVMSecurityGroups(vmID string) ([]*SecurityGroup, error) {
+	query := `
+		SELECT g.id, g.name, g.description, g.rules, g.created_at, g.updated_at
+		FROM security_groups g JOIN vm_security_groups v ON v.group_id = g.id
+		WHERE v.vm_id=? ORDER BY g.name`
+	return d.listSecurityGroups(query, vmID)
+}
+
+func (d *Database) listSecurityGroups(query string, args ...interface{}) ([]*SecurityGroup, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []*SecurityGroup{}
+	for rows.Next() {
+		group, err := scanSecurityGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+// DeleteSecurityGroup removes a security group from the database
+func (d *Database) DeleteSecurityGroup(id string) error {
+	_, err := d.db.Exec(`DELETE FROM security_groups WHERE id=?`, id)
+	return err
+}
+
+// AttachSecurityGroup attaches a security group to a VM; attaching it twice is a no-op
+func (d *Database) AttachSecurityGroup(vmID, groupID string) error {
+	query := `INSERT OR IGNORE INTO vm_security_groups (vm_id, group_id, created_at) VALUES (?, ?, ?)`
+	_, err := d.db.Exec(query, vmID, groupID, time.Now())
+	return err
+}
+
+// DetachSecurityGroup detaches a security group from a VM, reporting whether
+// it was attached
+func (d *Database) DetachSecurityGroup(vmID, groupID string) (bool, error) {
+	result, err := d.db.Exec(`DELETE FROM vm_security_groups WHERE vm_id=? AND group_id=?`, vmID, groupID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// DetachVMSecurityGroups detaches every security group from a VM
+func (d *Database) DetachVMSecurityGroups(vmID string) error {
+	_, err := d.db.Exec(`DELETE FROM vm_security_groups WHERE vm_id=?`, vmID)
+	return err
+}
+
+// ListSecurityGroupVMs returns the IDs of the VMs a security group is attached to
+func (d *Database) ListSecurityGroupVMs(groupID string) ([]string, error) {
+	rows, err := d.db.Query(`SELECT vm_id FROM vm_security_groups WHERE group_id=? ORDER BY vm_id`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
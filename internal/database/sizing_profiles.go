@@ -0,0 +1,132 @@
+package database
+
+import (
+	"time"
+)
+
+// SizingProfile is a named VM size with optional I/O rate limits
+type SizingProfile struct {
+	Name        string `json:"name" db:"name"`
+	Memory      int64  `json:"memory" db:"memory"` // MB
+	CPUs        int    `json:"cpus" db:"cpus"`
+	DiskSize    int64  `json:"disk_size" db:"disk_size"` // GB
+	Description string `json:"description" db:"description"`
+
+	// Rate limits applied to the VM's network interface and drives; 0 means unlimited
+	NetBandwidthMbps int64 `json:"net_bandwidth_mbps" db:"net_bandwidth_mbps"` // per direction
+	DiskIOPS         int64 `json:"disk_iops" db:"disk_iops"`
+
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// defaultSizingProfiles are seeded on first start
+var defaultSizingProfiles = []SizingProfile{
+	{Name: "small", Memory: 512, CPUs: 1, DiskSize: 2, NetBandwidthMbps: 100, DiskIOPS: 1000, Description: "Light services and sidecars"},
+	{Name: "medium", Memory: 2048, CPUs: 2, DiskSize: 10, NetBandwidthMbps: 500, DiskIOPS: 3000, Description: "General purpose workloads"},
+	{Name: "large", Memory: 8192, CPUs: 4, DiskSize: 40, Description: "Memory or CPU heavy workloads, no rate limits"},
+}
+
+const sizingProfileColumns = `name, memory, cpus, disk_size, description, net_bandwidth_mbps, disk_iops, created_at, updated_at`
+
+// createSizingProfileTable creates the sizing_profiles table and seeds the default profiles
+func (d *Database) createSizingProfileTable() error {
+	table := `
+	CREATE TABLE IF NOT EXISTS sizing_profiles (
+		name TEXT PRIMARY KEY,
+		memory INTEGER NOT NULL,
+		cpus INTEGER NOT NULL,
+		disk_size INTEGER NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		net_bandwidth_mbps INTEGER NOT NULL DEFAULT 0,
+		disk_iops INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := d.db.Exec(table); err != nil {
+		return err
+	}
+
+	seed := `INSERT OR IGNORE INTO sizing_profiles (` + sizingProfileColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	for _, p := range defaultSizingProfiles {
+		now := time.Now()
+		if _, err := d.db.Exec(seed, p.Name, p.Memory, p.CPUs, p.DiskSize, p.Description, p.NetBandwidthMbps, p.DiskIOPS, now, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func scanSizingProfile(row rowScanner) (*SizingProfile, error) {
+	p := &SizingProfile{}
+	err := row.Scan(&p.Name, &p.Memory, &p.CPUs, &p.DiskSize, &p.Description, &p.NetBandwidthMbps, &p.DiskIOPS, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// CreateSizingProfile inserts a new sizing profile into the database
+func (d *Database) CreateSizingProfile(p *SizingProfile) error {
+	query := `INSERT INTO sizing_profiles (` + sizingProfileColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	p.CreatedAt = time.Now()
+	p.UpdatedAt = time.Now()
+
+	_, err := d.db.Exec(query, p.Name, p.Memory, p.CPUs, p.DiskSize, p.Description, p.NetBandwidthMbps, p.DiskIOPS, p.CreatedAt, p.UpdatedAt)
+	return err
+}
+
+// UpdateSizingProfile updates an existing sizing profile in the database
+func (d *Database) UpdateSizingProfile(p *SizingProfile) error {
+	query := `UPDATE sizing_profiles SET memory=?, cpus=?, disk_size=?, description=?, net_bandwidth_mbps=?, disk_iops=?, updated_at=? WHERE name=?`
+
+	p.UpdatedAt = time.Now()
+
+	_, err := d.db.Exec(query, p.Memory, p.CPUs, p.DiskSize, p.Description, p.NetBandwidthMbps, p.DiskIOPS, p.UpdatedAt, p.Name)
+	return err
+}
+
+// GetSizingProfile retrieves a sizing profile by name
+func (d *Database) GetSizingProfile(name string) (*SizingProfile, error) {
+	query := `SELECT ` + sizingProfileColumns + ` FROM sizing_profiles WHERE name=?`
+	return scanSizingProfile(d.db.QueryRow(query, name))
+}
+
+// ListSizingProfiles retrieves all sizing profiles, smallest first
+func (d *Database) ListSizingProfiles() ([]*SizingProfile, error) {
+	query := `SELECT ` + sizingProfileColumns + ` FROM sizing_profiles ORDER BY memory, cpus, name`
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []*SizingProfile
+	for rows.Next() {
+		p, err := scanSizingProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, p)
+	}
+
+	return profiles, nil
+}
+
+// DeleteSizingProfile removes a sizing profile from the database
+func (d *Database) DeleteSizingProfile(name string) error {
+	query := `DELETE FROM sizing_profiles WHERE name=?`
+	_, err := d.db.Exec(query, name)
+	return err
+}
+
+// CountVMsWithSizingProfile returns how many VMs reference a sizing profile
+func (d *Database) CountVMsWithSizingProfile(name string) (int, error) {
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM vms WHERE sizing_profile=?`, name).Scan(&count)
+	return count, err
+}
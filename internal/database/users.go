@@ -0,0 +1,173 @@
+package database
+
+import (
+	"time"
+)
+
+// User is a local account for API and web UI logins when no external
+// identity provider is configured
+type User struct {
+	ID                 string    `json:"id" db:"id"`
+	Username           string    `json:"username" db:"username"`
+	PasswordHash       string    `json:"-" db:"password_hash"` // Argon2id, in PHC string format
+	Role               string    `json:"role" db:"role"`
+	MustChangePassword bool      `json:"must_change_password" db:"must_change_password"` // set by resets until the user picks their own
+	PasswordChangedAt  time.Time `json:"password_changed_at" db:"password_changed_at"`
+	CreatedAt          time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// UserSession is a login of a user; only the SHA-256 of its token is stored
+type UserSession struct {
+	TokenHash string    `json:"-" db:"token_hash"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+const userColumns = `id, username, password_hash, role, must_change_password, password_changed_at, created_at, updated_at`
+
+// createUserTable creates the users and user_sessions tables
+func (d *Database) createUserTable() error {
+	table := `
+	CREATE TABLE IF NOT EXISTS users (
+		id TEXT PRIMARY KEY,
+		username TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		password_hash TEXT NOT NULL,
+		role TEXT NOT NULL,
+		must_change_password BOOLEAN NOT NULL DEFAULT 0,
+		password_changed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS user_sessions (
+		token_hash TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		expires_at DATETIME NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_user_sessions_user ON user_sessions (user_id);`
+
+	_, err := d.db.Exec(table)
+	return err
+}
+
+func scanUser(row rowScanner) (*User, error) {
+	u := &User{}
+	if err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.MustChangePassword, &u.PasswordChangedAt, &u.CreatedAt, &u.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// CreateUser inserts a new user into the database
+func (d *Database) CreateUser(u *User) error {
+	query := `INSERT INTO users (` + userColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	u.CreatedAt = time.Now()
+	u.UpdatedAt = u.CreatedAt
+	u.PasswordChangedAt = u.CreatedAt
+
+	_, err := d.db.Exec(query, u.ID, u.Username, u.PasswordHash, u.Role, u.MustChangePassword, u.PasswordChangedAt, u.CreatedAt, u.UpdatedAt)
+	return err
+}
+
+// UpdateUser updates an existing user in the database
+func (d *Database) UpdateUser(u *User) error {
+	query := `UPDATE users SET username=?, password_hash=?, role=?, must_change_password=?, password_changed_at=?, updated_at=? WHERE id=?`
+
+	u.UpdatedAt = time.Now()
+
+	_, err := d.db.Exec(query, u.Username, u.PasswordHash, u.Role, u.MustChangePassword, u.PasswordChangedAt, u.UpdatedAt, u.ID)
+	return err
+}
+
+// GetUser retrieves a user by ID
+func (d *Database) GetUser(id string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id=?`
+	return scanUser(d.db.QueryRow(query, id))
+}
+
+// GetUserByUsername retrieves a user by username, ignoring case
+func (d *Database) GetUserByUsername(username string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE username=?`
+	return scanUser(d.db.QueryRow(query, username))
+}
+
+// ListUsers retrieves all users ordered by username
+func (d *Database) ListUsers() ([]*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users ORDER BY username`
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// CountUsersWithRole returns how many users have a role
+func (d *Database) CountUsersWithRole(role string) (int, error) {
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM users WHERE role=?`, role).Scan(&count)
+	return count, err
+}
+
+// DeleteUser removes a user and their sessions from the database
+func (d *Database) DeleteUser(id string) error {
+	if err := d.DeleteUserSessions(id, ""); err != nil {
+		return err
+	}
+	_, err := d.db.Exec(`DELETE FROM users WHERE id=?`, id)
+	return err
+}
+
+// CreateUserSession records a login
+func (d *Database) CreateUserSession(session *UserSession) error {
+	query := `INSERT INTO user_sessions (token_hash, user_id, expires_at, created_at) VALUES (?, ?, ?, ?)`
+
+	session.CreatedAt = time.Now()
+
+	_, err := d.db.Exec(query, session.TokenHash, session.UserID, session.ExpiresAt, session.CreatedAt)
+	return err
+}
+
+// GetUserSession retrieves a login by the hash of its token
+func (d *Database) GetUserSession(tokenHash string) (*UserSession, error) {
+	query := `SELECT token_hash, user_id, expires_at, created_at FROM user_sessions WHERE token_hash=?`
+
+	s := &UserSession{}
+	if err := d.db.QueryRow(query, tokenHash).Scan(&s.TokenHash, &s.UserID, &s.ExpiresAt, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// DeleteUserSession ends a login
+func (d *Database) DeleteUserSession(tokenHash string) error {
+	_, err := d.db.Exec(`DELETE FROM user_sessions WHERE token_hash=?`, tokenHash)
+	return err
+}
+
+// DeleteUserSessions ends every login of a user but the one whose token hash
+// is keep
+func (d *Database) DeleteUserSessions(userID, keep string) error {
+	_, err := d.db.Exec(`DELETE FROM user_sessions WHERE user_id=? AND token_hash<>?`, userID, keep)
+	return err
+}
+
+// DeleteExpiredUserSessions removes logins that expired before now
+func (d *Database) DeleteExpiredUserSessions(now time.Time) error {
+	_, err := d.db.Exec(`DELETE FROM user_sessions WHERE expires_at < ?`, now)
+	return err
+}
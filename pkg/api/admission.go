@@ -0,0 +1,319 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+)
+
+// Admission webhook types
+const (
+	admissionMutating   = "mutating"
+	admissionValidating = "validating"
+)
+
+// Admission webhook failure policies
+const (
+	admissionFail   = "fail"   // reject the request when the webhook can't answer
+	admissionIgnore = "ignore" // admit the request when the webhook can't answer
+)
+
+// defaultAdmissionTimeout is the timeout of webhooks registered without one, in seconds
+const defaultAdmissionTimeout = 10
+
+// errAdmissionUnavailable is returned when a fail-closed webhook can't answer
+var errAdmissionUnavailable = errors.New("admission webhook unavailable")
+
+// AdmissionDenied is returned when a webhook rejects a creation request
+type AdmissionDenied struct {
+	Webhook string
+	Reason  string
+}
+
+func (e *AdmissionDenied) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("denied by admission webhook %s", e.Webhook)
+	}
+	return fmt.Sprintf("denied by admission webhook %s: %s", e.Webhook, e.Reason)
+}
+
+// AdmissionReview is POSTed to admission webhooks
+type AdmissionReview struct {
+	UID       string      `json:"uid"`
+	Operation string      `json:"operation"` // create or update
+	Kind      string      `json:"kind"`      // vm or container
+	Object    interface{} `json:"object"`    // the create or update request, as sent to the API
+	DryRun    bool        `json:"dry_run"`   // the request is only being validated
+}
+
+// AdmissionResponse is what an admission webhook answers
+type AdmissionResponse struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+	// Object replaces the request; only honoured from mutating webhooks
+	Object json.RawMessage `json:"object,omitempty"`
+}
+
+type AdmissionWebhookRequest struct {
+	Name           string   `json:"name" binding:"required"`
+	Type           string   `json:"type" binding:"required,oneof=validating mutating"`
+	URL            string   `json:"url" binding:"required"`
+	Resources      []string `json:"resources" binding:"required,min=1,dive,oneof=vm container"`
+	FailurePolicy  string   `json:"failure_policy" binding:"omitempty,oneof=fail ignore"`
+	TimeoutSeconds int      `json:"timeout_seconds" binding:"min=0,max=30"`
+}
+
+// admit sends a create or update request through the admission webhooks
+// registered for its kind: mutating webhooks first, each seeing the previous one's changes,
+// then validating webhooks. req must be a pointer to the bound request struct,
+// which mutating webhooks may replace; the result is validated again.
+func (s *Server) admit(ctx context.Context, kind, operation string, req interface{}, dryRun bool) error {
+	webhooks, err := s.db.ListAdmissionWebhooks()
+	if err != nil {
+		return fmt.Errorf("failed to list admission webhooks: %w", err)
+	}
+
+	for _, webhookType := range []string{admissionMutating, admissionValidating} {
+		for _, webhook := range webhooks {
+			if webhook.Type != webhookType || !containsString(webhook.Resources, kind) {
+				continue
+			}
+
+			response, err := callAdmissionWebhook(ctx, webhook, kind, operation, req, dryRun)
+			if err != nil {
+				if webhook.FailurePolicy == admissionIgnore {
+					s.logger.Warnf("Admitting %s %s without admission webhook %s: %v", kind, operation, webhook.Name, err)
+					continue
+				}
+				return fmt.Errorf("%s: %v: %w", webhook.Name, err, errAdmissionUnavailable)
+			}
+			if !response.Allowed {
+				return &AdmissionDenied{Webhook: webhook.Name, Reason: response.Reason}
+			}
+
+			if webhook.Type == admissionMutating && len(response.Object) > 0 {
+				value := reflect.ValueOf(req).Elem()
+				value.Set(reflect.Zero(value.Type()))
+				if err := json.Unmarshal(response.Object, req); err != nil {
+					return &AdmissionDenied{Webhook: webhook.Name, Reason: "invalid mutated object: " + err.Error()}
+				}
+				if err := binding.Validator.ValidateStruct(req); err != nil {
+					return &AdmissionDenied{Webhook: webhook.Name, Reason: "invalid mutated object: " + err.Error()}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// callAdmissionWebhook POSTs a review to a webhook and decodes its answer
+func callAdmissionWebhook(ctx context.Context, webhook *database.AdmissionWebhook, kind, operation string, object interface{}, dryRun bool) (*AdmissionResponse, error) {
+	body, err := json.Marshal(AdmissionReview{
+		UID:       uuid.New().String(),
+		Operation: operation,
+		Kind:      kind,
+		Object:    object,
+		DryRun:    dryRun,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := time.Duration(webhook.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultAdmissionTimeout * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s returned %s", webhook.URL, resp.Status)
+	}
+
+	var response AdmissionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("invalid response from %s: %w", webhook.URL, err)
+	}
+	return &response, nil
+}
+
+// respondAdmissionError maps admission errors to client responses
+func (s *Server) respondAdmissionError(c *gin.Context, err error) {
+	var denied *AdmissionDenied
+	switch {
+	case errors.As(err, &denied):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, errAdmissionUnavailable):
+		s.logger.Errorf("Admission failed: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		s.logger.Errorf("Admission failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run admission webhooks"})
+	}
+}
+
+// containsString reports whether values includes value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Admission Webhook API Handlers
+
+func (s *Server) handleListAdmissionWebhooks(c *gin.Context) {
+	if !requireRole(c, RoleAdmin) {
+		return
+	}
+
+	webhooks, err := s.db.ListAdmissionWebhooks()
+	if err != nil {
+		s.logger.Errorf("Failed to list admission webhooks: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list admission webhooks"})
+		return
+	}
+
+	if webhooks == nil {
+		webhooks = []*database.AdmissionWebhook{}
+	}
+	c.JSON(http.StatusOK, webhooks)
+}
+
+func (s *Server) handleCreateAdmissionWebhook(c *gin.Context) {
+	if !requireRole(c, RoleAdmin) {
+		return
+	}
+
+	var req AdmissionWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	webhook := &database.AdmissionWebhook{ID: uuid.New().String()}
+	if err := applyAdmissionWebhookRequest(webhook, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.db.CreateAdmissionWebhook(webhook); err != nil {
+		s.logger.Errorf("Failed to create admission webhook: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create admission webhook"})
+		return
+	}
+
+	s.logger.Infof("Admission webhook %s (%s) registered for %v", webhook.Name, webhook.Type, webhook.Resources)
+	c.JSON(http.StatusCreated, webhook)
+}
+
+func (s *Server) handleGetAdmissionWebhook(c *gin.Context) {
+	if !requireRole(c, RoleAdmin) {
+		return
+	}
+
+	webhook, err := s.db.GetAdmissionWebhook(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Admission webhook not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (s *Server) handleUpdateAdmissionWebhook(c *gin.Context) {
+	if !requireRole(c, RoleAdmin) {
+		return
+	}
+
+	webhook, err := s.db.GetAdmissionWebhook(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Admission webhook not found"})
+		return
+	}
+
+	var req AdmissionWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := applyAdmissionWebhookRequest(webhook, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.db.UpdateAdmissionWebhook(webhook); err != nil {
+		s.logger.Errorf("Failed to update admission webhook: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update admission webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (s *Server) handleDeleteAdmissionWebhook(c *gin.Context) {
+	if !requireRole(c, RoleAdmin) {
+		return
+	}
+
+	id := c.Param("id")
+
+	if _, err := s.db.GetAdmissionWebhook(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Admission webhook not found"})
+		return
+	}
+
+	if err := s.db.DeleteAdmissionWebhook(id); err != nil {
+		s.logger.Errorf("Failed to delete admission webhook: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete admission webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Admission webhook deleted successfully"})
+}
+
+// applyAdmissionWebhookRequest validates a webhook request and copies it onto webhook
+func applyAdmissionWebhookRequest(webhook *database.AdmissionWebhook, req *AdmissionWebhookRequest) error {
+	if _, err := parseWebhookURL(req.URL); err != nil {
+		return errors.New("url must be an http(s) URL")
+	}
+
+	webhook.Name = req.Name
+	webhook.Type = req.Type
+	webhook.URL = req.URL
+	webhook.Resources = req.Resources
+	webhook.FailurePolicy = req.FailurePolicy
+	if webhook.FailurePolicy == "" {
+		webhook.FailurePolicy = admissionFail
+	}
+	webhook.TimeoutSeconds = req.TimeoutSeconds
+	if webhook.TimeoutSeconds == 0 {
+		webhook.TimeoutSeconds = defaultAdmissionTimeout
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"plugin"
+	"sort"
+	"sync"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/config"
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrUnauthenticated is returned by authenticators for requests without
+// valid credentials
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Principal is the caller an authenticator identified
+type Principal struct {
+	Name  string            `json:"name"`
+	Roles []string          `json:"roles,omitempty"`
+	Attrs map[string]string `json:"attrs,omitempty"` // whatever else the authenticator knows, e.g. groups or an email
+}
+
+// Authenticator identifies the caller of an API request. Authenticate returns
+// ErrUnauthenticated, possibly wrapped, when the request carries no valid
+// credentials (401); any other error is treated as a failure of the
+// authenticator itself (503).
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Reloader is implemented by authenticators that can reread their
+// configuration while running; the orchestrator calls Reload on SIGHUP
+type Reloader interface {
+	Reload() error
+}
+
+// AuthenticatorFactory builds an authenticator from the orchestrator's config
+type AuthenticatorFactory func(cfg *config.Config) (Authenticator, error)
+
+var (
+	authenticatorsMu sync.Mutex
+	authenticators   = make(map[string]AuthenticatorFactory)
+)
+
+// RegisterAuthenticator makes an authenticator selectable by AUTH_PROVIDER.
+// Packages compiled in, e.g. behind a build tag, call it from init.
+func RegisterAuthenticator(name string, factory AuthenticatorFactory) {
+	authenticatorsMu.Lock()
+	defer authenticatorsMu.Unlock()
+
+	if _, exists := authenticators[name]; exists || name == LocalProvider {
+		panic(fmt.Sprintf("authenticator %q registered twice", name))
+	}
+	authenticators[name] = factory
+}
+
+// Authenticators lists the registered authenticator names
+func Authenticators() []string {
+	authenticatorsMu.Lock()
+	defer authenticatorsMu.Unlock()
+
+	names := []string{LocalProvider}
+	for name := range authenticators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewAuthenticator builds the authenticator AUTH_PROVIDER names, loading
+// AUTH_PLUGIN first if set so the plugin can register it. The local accounts
+// authenticator is built in as it needs the database. It returns nil when
+// AUTH_PROVIDER is empty, leaving the API open.
+func NewAuthenticator(cfg *config.Config, db *database.Database) (Authenticator, error) {
+	if cfg.AuthPlugin != "" {
+		if _, err := plugin.Open(cfg.AuthPlugin); err != nil {
+			return nil, fmt.Errorf("failed to load auth plugin %s: %w", cfg.AuthPlugin, err)
+		}
+	}
+	if cfg.AuthProvider == "" {
+		return nil, nil
+	}
+	if cfg.AuthProvider == LocalProvider {
+		return NewLocalAuthenticator(cfg, db)
+	}
+
+	authenticatorsMu.Lock()
+	factory, ok := authenticators[cfg.AuthProvider]
+	authenticatorsMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown auth provider %q (registered: %v)", cfg.AuthProvider, Authenticators())
+	}
+	return factory(cfg)
+}
+
+// principalKey is the gin context key of the authenticated caller
+const principalKey = "principal"
+
+// authenticate rejects API requests the authenticator doesn't accept and
+// records the caller of those it does. Without an authenticator every
+// request passes.
+func (s *Server) authenticate(c *gin.Context) {
+	if s.auth == nil {
+		c.Next()
+		return
+	}
+
+	principal, err := s.auth.Authenticate(c.Request)
+	if errors.Is(err, ErrUnauthenticated) || (err == nil && principal == nil) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("Authentication failed: %v", err)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "authentication unavailable"})
+		return
+	}
+
+	// Users given a temporary password must replace it before anything else
+	if principal.Attrs["must_change_password"] == "true" && !passwordChangeRoutes[c.FullPath()] {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "password change required"})
+		return
+	}
+
+	c.Set(principalKey, principal)
+	c.Next()
+}
+
+// passwordChangeRoutes stay reachable for users who must change their
+// password, and for viewers, who can't change anything else
+var passwordChangeRoutes = map[string]bool{
+	"/api/v1/whoami":             true,
+	"/api/v1/logout":             true,
+	"/api/v1/users/:id/password": true,
+}
+
+// guestRoutes reach into guests or return what guests hold, such as their
+// disks, traffic, environment and console output, which can carry secrets.
+// They take the operator or admin role even for GET.
+var guestRoutes = map[string]bool{
+	"/api/v1/vms/:id/proxy/:port/*path":                     true,
+	"/api/v1/vms/:id/tunnel/:port":                          true,
+	"/api/v1/vms/:id/env":                                   true,
+	"/api/v1/vms/:id/vmm-log":                               true,
+	"/api/v1/vms/:id/output":                                true,
+	"/api/v1/vms/:id/captures/:capture_id/download":         true,
+	"/api/v1/vms/:id/disk-copies/:copy_id/drives/:drive_id": true,
+}
+
+// authorize rejects requests that change anything from callers who are
+// neither operators nor admins, which includes viewers and principals an
+// authenticator gave no roles. Reads are open to every authenticated caller
+// apart from guestRoutes; handlers check for the admin role themselves where they need it.
+func (s *Server) authorize(c *gin.Context) {
+	principal := principalFrom(c)
+	if principal == nil || principal.HasRole(RoleAdmin) || principal.HasRole(RoleOperator) {
+		c.Next()
+		return
+	}
+
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		if !guestRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+	default:
+		if passwordChangeRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "operator or admin role required"})
+}
+
+// HasRole reports whether the principal was given role
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// requireRole rejects requests whose caller lacks role with 403. With the API
+// open there is no caller and every request passes.
+func requireRole(c *gin.Context, role string) bool {
+	principal := principalFrom(c)
+	if principal == nil || principal.HasRole(role) {
+		return true
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("%s role required", role)})
+	return false
+}
+
+// principalFrom returns the authenticated caller of a request, or nil when
+// the API is open
+func principalFrom(c *gin.Context) *Principal {
+	if p, ok := c.Get(principalKey); ok {
+		return p.(*Principal)
+	}
+	return nil
+}
+
+// handleWhoAmI returns the authenticated caller
+func (s *Server) handleWhoAmI(c *gin.Context) {
+	principal := principalFrom(c)
+	if principal == nil {
+		c.JSON(http.StatusOK, gin.H{"authenticated": false})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"authenticated": true, "principal": principal})
+}
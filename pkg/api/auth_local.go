@@ -0,0 +1,329 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/config"
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/argon2"
+)
+
+// LocalProvider is the AUTH_PROVIDER that checks logins against the local
+// user accounts in the database
+const LocalProvider = "local"
+
+// Roles of local users
+const (
+	RoleAdmin    = "admin"    // everything, including managing users
+	RoleOperator = "operator" // everything but managing users
+	RoleViewer   = "viewer"
+)
+
+// ValidUserRole reports whether role can be given to a local user
+func ValidUserRole(role string) bool {
+	return role == RoleAdmin || role == RoleOperator || role == RoleViewer
+}
+
+// sessionCookie carries the session token of web UI logins
+const sessionCookie = "fc_session"
+
+// maxPasswordLength bounds the work a login attempt can cause
+const maxPasswordLength = 256
+
+// Argon2id parameters, the second recommendation of RFC 9106
+const (
+	argonTime    = 3
+	argonMemory  = 64 * 1024 // KiB
+	argonThreads = 4
+	argonKeyLen  = 32
+	argonSaltLen = 16
+)
+
+// hashPassword hashes a password with Argon2id into a PHC string that records
+// the parameters, so they can be raised without invalidating stored hashes
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+
+	b64 := base64.RawStdEncoding
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argonMemory, argonTime, argonThreads, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+// verifyPassword reports whether password matches a hash from hashPassword
+func verifyPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+	var memory, iterations uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false
+	}
+	b64 := base64.RawStdEncoding
+	salt, err := b64.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	want, err := b64.DecodeString(parts[5])
+	if err != nil || len(want) == 0 {
+		return false
+	}
+
+	got := argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(want)))
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// checkPasswordPolicy rejects passwords local users may not set: shorter than
+// PASSWORD_MIN_LENGTH, made of a single kind of character, or containing the
+// username
+func checkPasswordPolicy(minLength int, username, password string) error {
+	if len([]rune(password)) < minLength {
+		return fmt.Errorf("password must be at least %d characters", minLength)
+	}
+	if len(password) > maxPasswordLength {
+		return fmt.Errorf("password must be at most %d bytes", maxPasswordLength)
+	}
+
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, other} {
+		if present {
+			classes++
+		}
+	}
+	if classes < 2 {
+		return errors.New("password must mix at least two of lowercase letters, uppercase letters, digits and symbols")
+	}
+
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return errors.New("password must not contain the username")
+	}
+	return nil
+}
+
+// generatePassword returns a random 36-character password for resets
+func generatePassword() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return "Fc1-" + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the stored form of a session token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// LocalAuthenticator accepts requests carrying the token of a login, as a
+// bearer token or in the web UI's session cookie
+type LocalAuthenticator struct {
+	db        *database.Database
+	ttl       time.Duration
+	minLength int
+	dummyHash string // verified against for unknown usernames, so they take as long as wrong passwords
+}
+
+// NewLocalAuthenticator checks logins against the users in db. When there are
+// no users and LOCAL_ADMIN_PASSWORD is set it creates an "admin" account with
+// that password, which has to be changed on first login.
+func NewLocalAuthenticator(cfg *config.Config, db *database.Database) (*LocalAuthenticator, error) {
+	if cfg.SessionTTLHours <= 0 {
+		return nil, fmt.Errorf("SESSION_TTL_HOURS must be positive, not %d", cfg.SessionTTLHours)
+	}
+	if cfg.PasswordMinLength < 8 {
+		return nil, fmt.Errorf("PASSWORD_MIN_LENGTH must be at least 8, not %d", cfg.PasswordMinLength)
+	}
+
+	dummy, err := hashPassword("not a password")
+	if err != nil {
+		return nil, err
+	}
+	a := &LocalAuthenticator{
+		db:        db,
+		ttl:       time.Duration(cfg.SessionTTLHours) * time.Hour,
+		minLength: cfg.PasswordMinLength,
+		dummyHash: dummy,
+	}
+
+	users, err := db.ListUsers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	if len(users) == 0 && cfg.LocalAdminPassword != "" {
+		if err := a.checkPassword("admin", cfg.LocalAdminPassword); err != nil {
+			return nil, fmt.Errorf("LOCAL_ADMIN_PASSWORD: %w", err)
+		}
+		if _, err := a.createUser("admin", cfg.LocalAdminPassword, RoleAdmin, true); err != nil {
+			return nil, fmt.Errorf("failed to create admin user: %w", err)
+		}
+	}
+	return a, nil
+}
+
+// checkPassword applies the password policy
+func (a *LocalAuthenticator) checkPassword(username, password string) error {
+	return checkPasswordPolicy(a.minLength, username, password)
+}
+
+// createUser stores a new user; the caller has checked the password policy
+func (a *LocalAuthenticator) createUser(username, password, role string, mustChange bool) (*database.User, error) {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user := &database.User{
+		ID:                 uuid.New().String(),
+		Username:           username,
+		PasswordHash:       hash,
+		Role:               role,
+		MustChangePassword: mustChange,
+	}
+	if err := a.db.CreateUser(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// setPassword replaces a user's password and ends their other logins; keep is
+// the token hash of the login to leave alone, if any
+func (a *LocalAuthenticator) setPassword(user *database.User, password string, mustChange bool, keep string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+	user.MustChangePassword = mustChange
+	user.PasswordChangedAt = time.Now()
+	if err := a.db.UpdateUser(user); err != nil {
+		return err
+	}
+	return a.db.DeleteUserSessions(user.ID, keep)
+}
+
+// Login checks a username and password and starts a session, returning its
+// token. Wrong usernames and wrong passwords fail alike with ErrUnauthenticated.
+func (a *LocalAuthenticator) Login(username, password string) (string, *database.User, time.Time, error) {
+	if len(password) > maxPasswordLength {
+		return "", nil, time.Time{}, ErrUnauthenticated
+	}
+
+	user, err := a.db.GetUserByUsername(username)
+	if errors.Is(err, sql.ErrNoRows) {
+		verifyPassword(a.dummyHash, password)
+		return "", nil, time.Time{}, ErrUnauthenticated
+	}
+	if err != nil {
+		return "", nil, time.Time{}, fmt.Errorf("failed to look up user: %w", err)
+	}
+	if !verifyPassword(user.PasswordHash, password) {
+		return "", nil, time.Time{}, ErrUnauthenticated
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, time.Time{}, fmt.Errorf("failed to generate session token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	session := &database.UserSession{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(a.ttl),
+	}
+	if err := a.db.DeleteExpiredUserSessions(time.Now()); err != nil {
+		return "", nil, time.Time{}, fmt.Errorf("failed to expire sessions: %w", err)
+	}
+	if err := a.db.CreateUserSession(session); err != nil {
+		return "", nil, time.Time{}, fmt.Errorf("failed to save session: %w", err)
+	}
+	return token, user, session.ExpiresAt, nil
+}
+
+// Logout ends the session a request carries
+func (a *LocalAuthenticator) Logout(r *http.Request) error {
+	token := sessionToken(r)
+	if token == "" {
+		return nil
+	}
+	return a.db.DeleteUserSession(hashToken(token))
+}
+
+// sessionToken returns the session token of a request, from the
+// Authorization header or else the session cookie
+func sessionToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// Authenticate identifies the caller by their session token
+func (a *LocalAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token := sessionToken(r)
+	if token == "" {
+		return nil, ErrUnauthenticated
+	}
+	session, err := a.db.GetUserSession(hashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUnauthenticated
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up session: %w", err)
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, ErrUnauthenticated
+	}
+
+	user, err := a.db.GetUser(session.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUnauthenticated
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up user: %w", err)
+	}
+
+	attrs := map[string]string{
+		"user_id": user.ID,
+	}
+	if user.MustChangePassword {
+		attrs["must_change_password"] = "true"
+	}
+	return &Principal{Name: user.Username, Roles: []string{user.Role}, Attrs: attrs}, nil
+}
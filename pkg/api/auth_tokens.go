@@ -0,0 +1,126 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// StaticTokenProvider is the AUTH_PROVIDER that checks bearer tokens against
+// the hashes in AUTH_TOKEN_FILE
+const StaticTokenProvider = "static-token"
+
+func init() {
+	RegisterAuthenticator(StaticTokenProvider, func(cfg *config.Config) (Authenticator, error) {
+		if cfg.AuthTokenFile == "" {
+			return nil, fmt.Errorf("AUTH_PROVIDER=%s needs AUTH_TOKEN_FILE", StaticTokenProvider)
+		}
+		return NewTokenFileAuthenticator(cfg.AuthTokenFile)
+	})
+}
+
+// tokenEntry is one token in the token file. Only the SHA-256 of the token is
+// stored, hex encoded, so the file can't be used to call the API.
+type tokenEntry struct {
+	Name   string   `yaml:"name"`
+	SHA256 string   `yaml:"sha256"`
+	Roles  []string `yaml:"roles"`
+
+	hash []byte
+}
+
+// TokenFileAuthenticator accepts requests carrying "Authorization: Bearer
+// <token>" for any token whose hash is in its file. Reload rereads the file.
+type TokenFileAuthenticator struct {
+	path   string
+	mu     sync.RWMutex
+	tokens []tokenEntry
+}
+
+// NewTokenFileAuthenticator loads the token file at path
+func NewTokenFileAuthenticator(path string) (*TokenFileAuthenticator, error) {
+	a := &TokenFileAuthenticator{path: path}
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Reload rereads the token file. A file that can't be read or parsed leaves
+// the tokens loaded before in place.
+func (a *TokenFileAuthenticator) Reload() error {
+	data, err := os.ReadFile(a.path)
+	if err != nil {
+		return fmt.Errorf("failed to read token file: %w", err)
+	}
+
+	var file struct {
+		Tokens []tokenEntry `yaml:"tokens"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid token file %s: %w", a.path, err)
+	}
+
+	names := make(map[string]bool, len(file.Tokens))
+	for i := range file.Tokens {
+		token := &file.Tokens[i]
+		if token.Name == "" {
+			return fmt.Errorf("token %d in %s has no name", i+1, a.path)
+		}
+		if names[token.Name] {
+			return fmt.Errorf("token %s appears twice in %s", token.Name, a.path)
+		}
+		names[token.Name] = true
+
+		hash, err := hex.DecodeString(strings.TrimSpace(token.SHA256))
+		if err != nil || len(hash) != sha256.Size {
+			return fmt.Errorf("token %s in %s: sha256 must be 64 hex digits", token.Name, a.path)
+		}
+		token.hash = hash
+	}
+
+	a.mu.Lock()
+	a.tokens = file.Tokens
+	a.mu.Unlock()
+	return nil
+}
+
+// Len returns how many tokens are loaded
+func (a *TokenFileAuthenticator) Len() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.tokens)
+}
+
+// Authenticate identifies the caller by the bearer token in the request
+func (a *TokenFileAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" {
+		return nil, ErrUnauthenticated
+	}
+	hash := sha256.Sum256([]byte(token))
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	// Compare against every token so timing doesn't reveal which one matched
+	var match *tokenEntry
+	for i := range a.tokens {
+		if subtle.ConstantTimeCompare(hash[:], a.tokens[i].hash) == 1 {
+			match = &a.tokens[i]
+		}
+	}
+	if match == nil {
+		return nil, ErrUnauthenticated
+	}
+	return &Principal{Name: match.Name, Roles: match.Roles}, nil
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// Boot Profile API Handlers
+
+type BootProfileRequest struct {
+	Name        string `json:"name"`
+	BootArgs    string `json:"boot_args" binding:"required"`
+	Description string `json:"description"`
+}
+
+func (s *Server) handleListBootProfiles(c *gin.Context) {
+	profiles, err := s.db.ListBootProfiles()
+	if err != nil {
+		s.logger.Errorf("Failed to list boot profiles: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list boot profiles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profiles)
+}
+
+func (s *Server) handleCreateBootProfile(c *gin.Context) {
+	var req BootProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Boot profile name is required"})
+		return
+	}
+
+	if _, err := s.db.GetBootProfile(req.Name); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Boot profile already exists"})
+		return
+	}
+
+	profile := &database.BootProfile{
+		Name:        req.Name,
+		BootArgs:    req.BootArgs,
+		Description: req.Description,
+	}
+
+	if err := s.db.CreateBootProfile(profile); err != nil {
+		s.logger.Errorf("Failed to create boot profile: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create boot profile"})
+		return
+	}
+
+	s.logger.Infof("Boot profile %s created successfully", profile.Name)
+	c.JSON(http.StatusCreated, profile)
+}
+
+func (s *Server) handleGetBootProfile(c *gin.Context) {
+	name := c.Param("name")
+
+	profile, err := s.db.GetBootProfile(name)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Boot profile not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func (s *Server) handleUpdateBootProfile(c *gin.Context) {
+	name := c.Param("name")
+
+	profile, err := s.db.GetBootProfile(name)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Boot profile not found"})
+		return
+	}
+
+	var req BootProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile.BootArgs = req.BootArgs
+	profile.Description = req.Description
+
+	if err := s.db.UpdateBootProfile(profile); err != nil {
+		s.logger.Errorf("Failed to update boot profile: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update boot profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func (s *Server) handleDeleteBootProfile(c *gin.Context) {
+	name := c.Param("name")
+
+	if _, err := s.db.GetBootProfile(name); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Boot profile not found"})
+		return
+	}
+
+	inUse, err := s.db.CountVMsWithBootProfile(name)
+	if err != nil {
+		s.logger.Errorf("Failed to check boot profile usage: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete boot profile"})
+		return
+	}
+	if inUse > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Boot profile is referenced by existing VMs"})
+		return
+	}
+
+	if err := s.db.DeleteBootProfile(name); err != nil {
+		s.logger.Errorf("Failed to delete boot profile %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete boot profile"})
+		return
+	}
+
+	s.logger.Infof("Boot profile %s deleted successfully", name)
+	c.JSON(http.StatusOK, gin.H{"message": "Boot profile deleted successfully"})
+}
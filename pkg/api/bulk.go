@@ -0,0 +1,96 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/jobs"
+	"github.com/gin-gonic/gin"
+)
+
+// BulkVMRequest applies one action to a selection of VMs, given either as IDs
+// or as a filter
+type BulkVMRequest struct {
+	Action string        `json:"action" binding:"required,oneof=start stop pause resume delete"`
+	IDs    []string      `json:"ids"`
+	Filter *BulkVMFilter `json:"filter"`
+}
+
+// BulkVMFilter selects VMs by status and labels; an empty filter selects every VM
+type BulkVMFilter struct {
+	Status string            `json:"status"`
+	Labels map[string]string `json:"labels"`
+}
+
+// handleBulkVMs resolves the selection and enqueues a job that applies the
+// action to each VM in turn. The job's result reports progress as it runs.
+func (s *Server) handleBulkVMs(c *gin.Context) {
+	var req BulkVMRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (len(req.IDs) > 0) == (req.Filter != nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "give either ids or filter"})
+		return
+	}
+
+	vms, err := s.db.ListVMs()
+	if err != nil {
+		s.logger.Errorf("Failed to list VMs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list VMs"})
+		return
+	}
+
+	var ids []string
+	if req.Filter != nil {
+		for _, vm := range vms {
+			if req.Filter.Status != "" && vm.Status != req.Filter.Status {
+				continue
+			}
+			if !matchLabels(vm.Labels, req.Filter.Labels) {
+				continue
+			}
+			ids = append(ids, vm.ID)
+		}
+	} else {
+		known := make(map[string]bool, len(vms))
+		for _, vm := range vms {
+			known[vm.ID] = true
+		}
+		seen := make(map[string]bool, len(req.IDs))
+		var unknown []string
+		for _, id := range req.IDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if !known[id] {
+				unknown = append(unknown, id)
+				continue
+			}
+			ids = append(ids, id)
+		}
+		if len(unknown) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown VMs: " + strings.Join(unknown, ", ")})
+			return
+		}
+	}
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "selection matches no VMs"})
+		return
+	}
+	if req.Action == jobs.BulkDelete && !s.checkNotDedicated(c, ids) {
+		return
+	}
+
+	job, err := jobs.Enqueue(s.db, jobs.TypeVMBulk, "", jobs.BulkPayload{Action: req.Action, VMIDs: ids}, 3)
+	if err != nil {
+		s.logger.Errorf("Failed to enqueue bulk %s: %v", req.Action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enqueue bulk action"})
+		return
+	}
+
+	s.logger.Infof("Bulk %s of %d VMs enqueued as job %s", req.Action, len(ids), job.ID)
+	c.JSON(http.StatusAccepted, gin.H{"job": job, "vm_ids": ids})
+}
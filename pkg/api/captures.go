@@ -0,0 +1,90 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/firecracker"
+	"github.com/gin-gonic/gin"
+)
+
+// Packet Capture API Handlers
+
+type CaptureRequest struct {
+	DurationSeconds int   `json:"duration_seconds"` // default and maximum 300
+	MaxPackets      int   `json:"max_packets"`      // default and maximum 1000000
+	MaxBytes        int64 `json:"max_bytes"`        // default and maximum 100 MiB
+	SnapLen         int   `json:"snap_len"`         // bytes captured per packet, at most 262144
+}
+
+func (s *Server) handleStartCapture(c *gin.Context) {
+	vmID := c.Param("id")
+
+	vm, err := s.db.GetVM(vmID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+
+	if vm.Status != "running" {
+		c.JSON(http.StatusConflict, gin.H{"error": "VM must be running to capture traffic"})
+		return
+	}
+
+	// All fields are optional, so an empty body means "use the defaults"
+	var req CaptureRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	capture, err := s.vmManager.StartCapture(vmID, firecracker.CaptureOptions{
+		Duration:   time.Duration(req.DurationSeconds) * time.Second,
+		MaxPackets: req.MaxPackets,
+		MaxBytes:   req.MaxBytes,
+		SnapLen:    req.SnapLen,
+	})
+	if errors.Is(err, firecracker.ErrTooManyCaptures) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("Failed to start capture on VM %s: %v", vmID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start capture"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, capture)
+}
+
+func (s *Server) handleListCaptures(c *gin.Context) {
+	c.JSON(http.StatusOK, s.vmManager.ListCaptures(c.Param("id")))
+}
+
+func (s *Server) handleGetCapture(c *gin.Context) {
+	capture, exists := s.vmManager.GetCapture(c.Param("id"), c.Param("capture_id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Capture not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, capture)
+}
+
+func (s *Server) handleDownloadCapture(c *gin.Context) {
+	capture, exists := s.vmManager.GetCapture(c.Param("id"), c.Param("capture_id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Capture not found"})
+		return
+	}
+
+	if capture.Status == "running" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Capture is still running"})
+		return
+	}
+
+	c.FileAttachment(capture.Path, fmt.Sprintf("%s-%s.pcap", capture.VMID, capture.ID))
+}
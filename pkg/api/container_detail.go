@@ -0,0 +1,162 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/firecracker"
+	"github.com/gin-gonic/gin"
+)
+
+// containerHistoryLimit bounds how many status changes a detail view returns
+const containerHistoryLimit = 50
+
+// Sections GET /containers/:id can expand; "all" expands every one
+var containerExpansions = []string{"history", "logs", "usage", "vm", "ports"}
+
+// ContainerDetail is a container with the sections a detail view asked for
+type ContainerDetail struct {
+	*database.Container
+
+	History []*database.ContainerStatusChange `json:"history,omitempty"`
+	Logs    *database.ContainerStatusChange   `json:"logs,omitempty"` // latest output the guest agent sent
+	Usage   *ContainerUsage                   `json:"usage,omitempty"`
+	VM      *VMSummary                        `json:"vm,omitempty"`
+	Ports   []PublishedPort                   `json:"published_ports,omitempty"`
+}
+
+// ContainerUsage is what a container requests of its VM, next to what all of
+// the VM's containers request and what the VM has
+type ContainerUsage struct {
+	Memory            int64                 `json:"memory"`
+	CPUs              int                   `json:"cpus"`
+	VMMemory          int64                 `json:"vm_memory"`
+	VMCPUs            int                   `json:"vm_cpus"`
+	VMMemoryRequested int64                 `json:"vm_memory_requested"`
+	VMCPUsRequested   int                   `json:"vm_cpus_requested"`
+	VMActivity        *firecracker.Activity `json:"vm_activity,omitempty"` // sampled by the idle reaper
+}
+
+// VMSummary is the part of a VM a container detail view shows
+type VMSummary struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	IPAddress   string `json:"ip_address"`
+	Memory      int64  `json:"memory"`
+	CPUs        int    `json:"cpus"`
+	Quarantined bool   `json:"quarantined"`
+	Degraded    bool   `json:"degraded"`
+}
+
+// PublishedPort is a container port published on its VM's address
+type PublishedPort struct {
+	ContainerPort string `json:"container_port"`
+	HostPort      string `json:"host_port"`
+	Address       string `json:"address,omitempty"` // VM IP and host port, once the VM has an address
+}
+
+// parseExpand reads a comma-separated expand parameter into the set of sections
+func parseExpand(expand string) (map[string]bool, error) {
+	sections := make(map[string]bool)
+	for _, section := range strings.Split(expand, ",") {
+		section = strings.TrimSpace(section)
+		if section == "all" {
+			for _, name := range containerExpansions {
+				sections[name] = true
+			}
+			continue
+		}
+		known := false
+		for _, name := range containerExpansions {
+			known = known || name == section
+		}
+		if !known {
+			return nil, fmt.Errorf("expand must be all or a list of %s", strings.Join(containerExpansions, ", "))
+		}
+		sections[section] = true
+	}
+	return sections, nil
+}
+
+// respondContainerDetail responds with a container and the sections of expand,
+// so a detail view needs a single request
+func (s *Server) respondContainerDetail(c *gin.Context, container *database.Container, expand string) {
+	sections, err := parseExpand(expand)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	detail := &ContainerDetail{Container: container}
+	fail := func(what string, err error) {
+		s.logger.Errorf("Failed to load %s of container %s: %v", what, container.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load container details"})
+	}
+
+	if sections["history"] {
+		if detail.History, err = s.db.ListContainerStatusChanges(container.ID, containerHistoryLimit); err != nil {
+			fail("status history", err)
+			return
+		}
+	}
+	if sections["logs"] {
+		if detail.Logs, err = s.db.LatestContainerStatusLogs(container.ID); err != nil {
+			fail("logs", err)
+			return
+		}
+	}
+
+	// The VM may be gone if it was deleted from under the container
+	vm, vmErr := s.db.GetVM(container.VMID)
+	if sections["vm"] && vmErr == nil {
+		detail.VM = &VMSummary{
+			ID:          vm.ID,
+			Name:        vm.Name,
+			Status:      vm.Status,
+			IPAddress:   vm.IPAddress,
+			Memory:      vm.Memory,
+			CPUs:        vm.CPUs,
+			Quarantined: vm.Quarantined,
+			Degraded:    vm.Degraded,
+		}
+	}
+	if sections["usage"] {
+		detail.Usage = &ContainerUsage{Memory: container.Memory, CPUs: container.CPUs}
+		if vmErr == nil {
+			usage, err := s.containerUsage()
+			if err != nil {
+				fail("resource usage", err)
+				return
+			}
+			detail.Usage.VMMemory = vm.Memory
+			detail.Usage.VMCPUs = vm.CPUs
+			detail.Usage.VMMemoryRequested = usage[vm.ID].memory
+			detail.Usage.VMCPUsRequested = usage[vm.ID].cpus
+			if activity, ok := s.vmManager.VMActivity(vm.ID); ok {
+				detail.Usage.VMActivity = activity
+			}
+		}
+	}
+	if sections["ports"] {
+		var ports map[string]string
+		if err := decodeSpecField(container.Ports, &ports); err != nil {
+			fail("ports", err)
+			return
+		}
+		for containerPort, hostPort := range ports {
+			port := PublishedPort{ContainerPort: containerPort, HostPort: hostPort}
+			if vmErr == nil && vm.IPAddress != "" {
+				port.Address = net.JoinHostPort(vm.IPAddress, hostPort)
+			}
+			detail.Ports = append(detail.Ports, port)
+		}
+		sort.Slice(detail.Ports, func(i, j int) bool { return detail.Ports[i].ContainerPort < detail.Ports[j].ContainerPort })
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// hoursPerMonth is the average month length cost estimates are based on
+const hoursPerMonth = 730
+
+// VMCost is one VM's line in the cost report
+type VMCost struct {
+	VMID     string  `json:"vm_id"`
+	Name     string  `json:"name"`
+	Status   string  `json:"status"`
+	Group    string  `json:"group,omitempty"`
+	Memory   int64   `json:"memory"`
+	CPUs     int     `json:"cpus"`
+	DiskSize int64   `json:"disk_size"`
+	Hourly   float64 `json:"hourly"`
+	Monthly  float64 `json:"monthly"`
+}
+
+// CostTotal sums the estimated cost of a set of VMs
+type CostTotal struct {
+	Name    string  `json:"name,omitempty"`
+	VMCount int     `json:"vm_count"`
+	Hourly  float64 `json:"hourly"`
+	Monthly float64 `json:"monthly"`
+}
+
+func (t *CostTotal) add(cost VMCost) {
+	t.VMCount++
+	t.Hourly += cost.Hourly
+	t.Monthly += cost.Monthly
+}
+
+func (t *CostTotal) round() {
+	t.Hourly = roundCost(t.Hourly, 4)
+	t.Monthly = roundCost(t.Monthly, 2)
+}
+
+// hourlyCost estimates what a VM costs per hour at the configured rates
+func (s *Server) hourlyCost(vm *database.VM) float64 {
+	return float64(vm.CPUs)*s.config.CostPerVCPUHour +
+		float64(vm.Memory)/1024*s.config.CostPerGBHour +
+		float64(vm.DiskSize)*s.config.CostPerDiskGBMonth/hoursPerMonth
+}
+
+// vmCost returns a VM's estimated cost, or nil when no cost rates are configured
+func (s *Server) vmCost(vm *database.VM) *database.Cost {
+	if s.config.CostPerVCPUHour == 0 && s.config.CostPerGBHour == 0 && s.config.CostPerDiskGBMonth == 0 {
+		return nil
+	}
+
+	hourly := s.hourlyCost(vm)
+	return &database.Cost{
+		Hourly:   roundCost(hourly, 4),
+		Monthly:  roundCost(hourly*hoursPerMonth, 2),
+		Currency: s.config.CostCurrency,
+	}
+}
+
+// roundCost rounds a cost to the given number of decimal places
+func roundCost(cost float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(cost*scale) / scale
+}
+
+// handleCostReport estimates the cost of every VM as sized, in total, for the
+// running VMs alone and, with group_by=<label>, per value of that label
+func (s *Server) handleCostReport(c *gin.Context) {
+	groupBy := c.Query("group_by")
+
+	vms, err := s.db.ListVMs()
+	if err != nil {
+		s.logger.Errorf("Failed to list VMs for the cost report: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load VMs"})
+		return
+	}
+
+	costs := make([]VMCost, 0, len(vms))
+	groups := make(map[string]*CostTotal)
+	var total, running CostTotal
+	for _, vm := range vms {
+		hourly := s.hourlyCost(vm)
+		cost := VMCost{
+			VMID:     vm.ID,
+			Name:     vm.Name,
+			Status:   vm.Status,
+			Memory:   vm.Memory,
+			CPUs:     vm.CPUs,
+			DiskSize: vm.DiskSize,
+			Hourly:   roundCost(hourly, 4),
+			Monthly:  roundCost(hourly*hoursPerMonth, 2),
+		}
+
+		if groupBy != "" {
+			var labels map[string]string
+			if err := decodeSpecField(vm.Labels, &labels); err != nil {
+				s.logger.Errorf("Failed to decode labels of VM %s: %v", vm.ID, err)
+			}
+			cost.Group = labels[groupBy]
+			if groups[cost.Group] == nil {
+				groups[cost.Group] = &CostTotal{Name: cost.Group}
+			}
+			groups[cost.Group].add(cost)
+		}
+
+		total.add(cost)
+		// Paused and rescued VMs hold their resources and are billed like running ones
+		if vm.Status == "running" || vm.Status == "paused" || vm.Status == "rescue" {
+			running.add(cost)
+		}
+		costs = append(costs, cost)
+	}
+
+	response := gin.H{
+		"currency": s.config.CostCurrency,
+		"rates": gin.H{
+			"vcpu_hour":     s.config.CostPerVCPUHour,
+			"gb_hour":       s.config.CostPerGBHour,
+			"disk_gb_month": s.config.CostPerDiskGBMonth,
+		},
+		"vms": costs,
+	}
+
+	total.round()
+	running.round()
+	response["total"] = total
+	response["running"] = running
+
+	if groupBy != "" {
+		// VMs without the label are grouped under an empty name
+		byGroup := make([]*CostTotal, 0, len(groups))
+		for _, group := range groups {
+			group.round()
+			byGroup = append(byGroup, group)
+		}
+		sort.Slice(byGroup, func(i, j int) bool { return byGroup[i].Name < byGroup[j].Name })
+		response["group_by"] = groupBy
+		response["groups"] = byGroup
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/firecracker"
+	"github.com/gin-gonic/gin"
+)
+
+// handleVMDrift checks a VM's Firecracker config file against its spec now,
+// rather than waiting for the next periodic check
+func (s *Server) handleVMDrift(c *gin.Context) {
+	vmID := c.Param("id")
+
+	vm, err := s.db.GetVM(vmID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+
+	drifts, err := s.vmManager.CheckDrift(vm)
+	if err != nil {
+		s.logger.Errorf("Failed to check VM %s for config drift: %v", vmID, err)
+		c.JSON(http.StatusConflict, gin.H{"error": "VM has no Firecracker config to check"})
+		return
+	}
+	s.vmManager.RecordDrift(vmID, drifts)
+
+	if drifts == nil {
+		drifts = []firecracker.Drift{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"vm_id":   vmID,
+		"drifted": len(drifts) > 0,
+		"drifts":  drifts,
+	})
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/firecracker"
+	"github.com/gin-gonic/gin"
+)
+
+// Drive API Handlers
+
+type AttachDriveRequest struct {
+	DriveID    string `json:"drive_id" binding:"required"`
+	PathOnHost string `json:"path_on_host" binding:"required"`
+	ReadOnly   bool   `json:"read_only"`
+}
+
+func (s *Server) handleListDrives(c *gin.Context) {
+	vmID := c.Param("id")
+
+	if _, err := s.db.GetVM(vmID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+
+	drives, err := s.db.ListDriveAttachmentsByVM(vmID)
+	if err != nil {
+		s.logger.Errorf("Failed to list drives for VM %s: %v", vmID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list drives"})
+		return
+	}
+
+	c.JSON(http.StatusOK, drives)
+}
+
+func (s *Server) handleAttachDrive(c *gin.Context) {
+	vmID := c.Param("id")
+
+	if _, err := s.db.GetVM(vmID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+
+	var req AttachDriveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.DriveID == "rootfs" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Drive ID rootfs is reserved"})
+		return
+	}
+
+	drive := &database.DriveAttachment{
+		DriveID:    req.DriveID,
+		PathOnHost: req.PathOnHost,
+		ReadOnly:   req.ReadOnly,
+	}
+
+	if err := s.vmManager.AttachDrive(vmID, drive); err != nil {
+		s.logger.Errorf("Failed to attach drive to VM %s: %v", vmID, err)
+		switch {
+		case errors.Is(err, firecracker.ErrVMRunning):
+			c.JSON(http.StatusConflict, gin.H{"error": "VM must be stopped to attach drives"})
+		case errors.Is(err, firecracker.ErrDriveConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, firecracker.ErrInvalidDrive):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to attach drive"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, drive)
+}
+
+func (s *Server) handleDetachDrive(c *gin.Context) {
+	vmID := c.Param("id")
+	driveID := c.Param("drive_id")
+
+	if err := s.vmManager.DetachDrive(vmID, driveID); err != nil {
+		s.logger.Errorf("Failed to detach drive %s from VM %s: %v", driveID, vmID, err)
+		switch {
+		case errors.Is(err, firecracker.ErrVMRunning):
+			c.JSON(http.StatusConflict, gin.H{"error": "VM must be stopped to detach drives"})
+		case errors.Is(err, firecracker.ErrDriveNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Drive not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to detach drive"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Drive detached successfully"})
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// handleEncryptionStatus reports the primary encryption key and how many
+// stored secrets each key, or none, protects
+func (s *Server) handleEncryptionStatus(c *gin.Context) {
+	counts, err := s.db.EncryptionCounts()
+	if err != nil {
+		s.logger.Errorf("Failed to count encrypted values: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load encryption status"})
+		return
+	}
+
+	keyring := s.db.Keyring()
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":     keyring != nil,
+		"primary_key": keyring.Primary(),
+		"vm_files":    s.config.EncryptVMFiles,
+		"values":      counts,
+	})
+}
+
+// handleRotateEncryption re-encrypts every stored secret with the primary key,
+// after encryption is enabled or a new primary key is put first
+func (s *Server) handleRotateEncryption(c *gin.Context) {
+	if !requireRole(c, RoleAdmin) {
+		return
+	}
+
+	rewritten, err := s.db.ReencryptSecrets()
+	if err != nil {
+		s.logger.Errorf("Failed to re-encrypt secrets: %v", err)
+		if errors.Is(err, database.ErrNoKey) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to re-encrypt secrets"})
+		return
+	}
+
+	s.logger.Infof("Re-encrypted %d secrets with key %s", rewritten, s.db.Keyring().Primary())
+	c.JSON(http.StatusOK, gin.H{"primary_key": s.db.Keyring().Primary(), "rewritten": rewritten})
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// envNamePattern matches POSIX shell variable names
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateEnv checks that environment variables can be exported by a shell
+func validateEnv(env map[string]string) error {
+	for name, value := range env {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid environment variable name %q", name)
+		}
+		if strings.ContainsRune(value, 0) {
+			return fmt.Errorf("environment variable %s contains a NUL byte", name)
+		}
+	}
+	return nil
+}
+
+func (s *Server) handleGetVMEnv(c *gin.Context) {
+	vmID := c.Param("id")
+
+	vm, err := s.db.GetVM(vmID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+
+	env := map[string]string{}
+	if err := decodeSpecField(vm.Env, &env); err != nil {
+		s.logger.Errorf("Failed to decode env of VM %s: %v", vmID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get VM env"})
+		return
+	}
+
+	c.JSON(http.StatusOK, env)
+}
+
+// handleSetVMEnv replaces a VM's environment variables. They reach the guest
+// through MMDS, which is loaded when the VM starts and updated in place on a
+// running VM that booted with MMDS enabled.
+func (s *Server) handleSetVMEnv(c *gin.Context) {
+	vmID := c.Param("id")
+
+	vm, err := s.db.GetVM(vmID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+
+	var env map[string]string
+	if err := c.ShouldBindJSON(&env); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateEnv(env); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if vm.Env, err = encodeSpecField(env, len(env) == 0); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.db.UpdateVM(vm); err != nil {
+		s.logger.Errorf("Failed to update env of VM %s: %v", vmID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update VM env"})
+		return
+	}
+
+	pushed, err := s.vmManager.PushMetadata(vm)
+	if err != nil {
+		s.logger.Warnf("Env of VM %s applies from its next start: %v", vmID, err)
+	}
+
+	if env == nil {
+		env = map[string]string{}
+	}
+	s.logger.Infof("Updated env of VM %s (%d variables)", vmID, len(env))
+	c.JSON(http.StatusOK, gin.H{
+		"env": env,
+		// A VM that booted without MMDS can only get it on its next start
+		"restart_required": s.vmManager.Running(vmID) && !pushed,
+	})
+}
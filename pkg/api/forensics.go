@@ -0,0 +1,102 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/firecracker"
+	"github.com/gin-gonic/gin"
+)
+
+// Forensic Disk Copy API Handlers
+
+func (s *Server) handleStartDiskCopy(c *gin.Context) {
+	vmID := c.Param("id")
+
+	if _, err := s.db.GetVM(vmID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+
+	diskCopy, err := s.vmManager.StartDiskCopy(vmID)
+	if err != nil {
+		s.logger.Errorf("Failed to start disk copy of VM %s: %v", vmID, err)
+		if errors.Is(err, firecracker.ErrDiskCopyNotAllowed) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start disk copy"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, diskCopy)
+}
+
+func (s *Server) handleListDiskCopies(c *gin.Context) {
+	copies, err := s.vmManager.ListDiskCopies(c.Param("id"))
+	if err != nil {
+		s.logger.Errorf("Failed to list disk copies: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list disk copies"})
+		return
+	}
+	c.JSON(http.StatusOK, copies)
+}
+
+func (s *Server) handleGetDiskCopy(c *gin.Context) {
+	diskCopy, exists := s.vmManager.GetDiskCopy(c.Param("id"), c.Param("copy_id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Disk copy not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, diskCopy)
+}
+
+func (s *Server) handleDeleteDiskCopy(c *gin.Context) {
+	copyID := c.Param("copy_id")
+
+	found, err := s.vmManager.DeleteDiskCopy(c.Param("id"), copyID)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Disk copy not found"})
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("Failed to delete disk copy %s: %v", copyID, err)
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Disk copy deleted successfully"})
+}
+
+// handleDownloadDiskCopy serves one drive image of a completed disk copy with
+// its checksum in the response headers
+func (s *Server) handleDownloadDiskCopy(c *gin.Context) {
+	vmID, copyID, driveID := c.Param("id"), c.Param("copy_id"), c.Param("drive_id")
+
+	diskCopy, exists := s.vmManager.GetDiskCopy(vmID, copyID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Disk copy not found"})
+		return
+	}
+	if diskCopy.Status != "completed" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Disk copy is " + diskCopy.Status})
+		return
+	}
+
+	for _, drive := range diskCopy.Drives {
+		if drive.DriveID == driveID {
+			path, err := s.vmManager.DiskCopyImagePath(copyID, driveID)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Drive not found in disk copy"})
+				return
+			}
+			c.Header("X-Checksum-SHA256", drive.SHA256)
+			c.FileAttachment(path, fmt.Sprintf("%s-%s-%s.img", vmID, copyID, driveID))
+			return
+		}
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{"error": "Drive not found in disk copy"})
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// Guest Agent API Handlers
+
+type GuestInfoRequest struct {
+	OS               string `json:"os"`
+	KernelVersion    string `json:"kernel_version"`
+	Hostname         string `json:"hostname"`
+	AgentVersion     string `json:"agent_version" binding:"required"`
+	ContainerRuntime string `json:"container_runtime"`
+
+	// ClockTime is the guest's wall clock when it sent the report; the
+	// orchestrator compares it with its own clock to measure skew
+	ClockTime   *time.Time `json:"clock_time"`
+	ClockSource string     `json:"clock_source"`
+}
+
+// handleReportGuestInfo records the OS details the guest agent reports from inside a VM
+func (s *Server) handleReportGuestInfo(c *gin.Context) {
+	vmID := c.Param("id")
+
+	if _, err := s.db.GetVM(vmID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+
+	var req GuestInfoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	info := &database.GuestInfo{
+		VMID:             vmID,
+		OS:               req.OS,
+		KernelVersion:    req.KernelVersion,
+		Hostname:         req.Hostname,
+		AgentVersion:     req.AgentVersion,
+		ContainerRuntime: req.ContainerRuntime,
+		ClockSource:      req.ClockSource,
+		ReportedAt:       time.Now(),
+	}
+	if req.ClockTime != nil {
+		info.ClockSkewMS = req.ClockTime.Sub(info.ReportedAt).Milliseconds()
+	}
+
+	if err := s.db.UpsertGuestInfo(info); err != nil {
+		s.logger.Errorf("Failed to save guest info for VM %s: %v", vmID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save guest info"})
+		return
+	}
+
+	if s.checkClockSkew(info) {
+		s.logger.Warnf("Guest clock of VM %s is off by %dms", vmID, info.ClockSkewMS)
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// ContainerEvent is a Docker event forwarded by the guest agent
+type ContainerEvent struct {
+	ContainerID string `json:"id" binding:"required"`     // Docker container ID
+	Name        string `json:"name"`                      // Docker container name
+	Action      string `json:"action" binding:"required"` // Docker action, or "init-failed" for a failed init step
+	ExitCode    int    `json:"exit_code"`
+
+	// Logs are the container's last lines of output, sent with events that
+	// end it so the detail view can show why
+	Logs []string `json:"logs" binding:"max=200"`
+}
+
+type ContainerEventsRequest struct {
+	Events []ContainerEvent `json:"events" binding:"required,dive"`
+}
+
+// containerEventStatus maps a Docker event to the container status it implies,
+// or "" for events that don't change status
+func containerEventStatus(event ContainerEvent) string {
+	switch event.Action {
+	case "start", "unpause", "restart":
+		return "running"
+	case "pause":
+		return "paused"
+	case "oom":
+		return "error"
+	case "init-failed":
+		// Reported by the agent when an init step fails; the container is never started
+		return "init-failed"
+	case "die":
+		if event.ExitCode != 0 {
+			return "error"
+		}
+		return "stopped"
+	case "stop", "kill", "destroy":
+		return "stopped"
+	}
+	return ""
+}
+
+// handleContainerEvents applies Docker events the guest agent observed inside a VM
+// to the containers recorded for that VM
+func (s *Server) handleContainerEvents(c *gin.Context) {
+	vmID := c.Param("id")
+
+	if _, err := s.db.GetVM(vmID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+
+	var req ContainerEventsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	containers, err := s.db.ListContainersByVM(vmID)
+	if err != nil {
+		s.logger.Errorf("Failed to list containers for VM %s: %v", vmID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply container events"})
+		return
+	}
+
+	applied := 0
+	for _, event := range req.Events {
+		status := containerEventStatus(event)
+		if status == "" {
+			continue
+		}
+
+		for _, container := range containers {
+			// Match by Docker ID, or by name for containers not yet linked to one
+			if container.ContainerID != event.ContainerID &&
+				(container.ContainerID != "" || event.Name == "" || container.Name != event.Name) {
+				continue
+			}
+
+			// An init step runs in its own container, which mustn't be linked as the main one
+			if event.Action != "init-failed" {
+				container.ContainerID = event.ContainerID
+			}
+			container.Status = status
+			if err := s.db.UpdateContainer(container); err != nil {
+				s.logger.Errorf("Failed to update container %s from event: %v", container.ID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply container events"})
+				return
+			}
+
+			change := &database.ContainerStatusChange{
+				ContainerID: container.ID,
+				Status:      status,
+				Action:      event.Action,
+				ExitCode:    event.ExitCode,
+				Logs:        event.Logs,
+			}
+			if err := s.db.AddContainerStatusChange(change); err != nil {
+				s.logger.Warnf("Failed to record status history of container %s: %v", container.ID, err)
+			}
+
+			if event.Action == "oom" {
+				s.logger.Warnf("Container %s in VM %s was OOM-killed", container.ID, vmID)
+			}
+			applied++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"applied": applied})
+}
+
+// checkClockSkew flags a guest report whose clock is further from the host's
+// than CLOCK_SKEW_THRESHOLD_MS, as happens after snapshot restores and long pauses
+func (s *Server) checkClockSkew(info *database.GuestInfo) bool {
+	skew := info.ClockSkewMS
+	if skew < 0 {
+		skew = -skew
+	}
+	info.ClockSkewed = s.config.ClockSkewThresholdMS > 0 && skew > s.config.ClockSkewThresholdMS
+	return info.ClockSkewed
+}
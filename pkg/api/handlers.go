@@ -1,12 +1,22 @@
 package api
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/config"
 	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
 	"github.com/abhaybhargav/firecracker-orchestrator/pkg/firecracker"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/jobs"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/policy"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
@@ -14,20 +24,37 @@ import (
 
 // Server represents the API server
 type Server struct {
+	config    *config.Config
 	vmManager *firecracker.Manager
 	db        *database.Database
+	policies  *policy.Engine
+	auth      Authenticator
+	local     *LocalAuthenticator // auth, when it is the local accounts authenticator
 	logger    *logrus.Logger
+
+	// placementMu serialises container placement, from choosing a VM to saving
+	// the container
+	placementMu sync.Mutex
 }
 
-// NewServer creates a new API server
-func NewServer(vmManager *firecracker.Manager, db *database.Database, logger *logrus.Logger) *Server {
+// NewServer creates a new API server; policies may be nil
+func NewServer(config *config.Config, vmManager *firecracker.Manager, db *database.Database, policies *policy.Engine, logger *logrus.Logger) *Server {
 	return &Server{
+		config:    config,
 		vmManager: vmManager,
 		db:        db,
+		policies:  policies,
 		logger:    logger,
 	}
 }
 
+// SetAuthenticator makes every API request except the health check pass auth
+// first; call it before SetupRoutes
+func (s *Server) SetAuthenticator(auth Authenticator) {
+	s.auth = auth
+	s.local, _ = auth.(*LocalAuthenticator)
+}
+
 // SetupRoutes configures the API routes
 func (s *Server) SetupRoutes(r *gin.Engine) {
 	// Serve static files
@@ -36,6 +63,9 @@ func (s *Server) SetupRoutes(r *gin.Engine) {
 	// Load HTML templates
 	r.LoadHTMLGlob("web/templates/*")
 
+	// Prometheus scrape endpoint
+	r.GET("/metrics", s.handlePrometheusMetrics)
+
 	// Web UI routes
 	r.GET("/", s.handleDashboard)
 	r.GET("/vms", s.handleVMsPage)
@@ -43,23 +73,132 @@ func (s *Server) SetupRoutes(r *gin.Engine) {
 	r.GET("/vms/:id", s.handleVMDetailPage)
 	r.GET("/containers", s.handleContainersPage)
 	r.GET("/containers/new", s.handleNewContainerPage)
+	r.GET("/login", s.handleLoginPage)
+
+	// The health check and login stay reachable without credentials
+	r.GET("/api/v1/health", s.handleHealth)
+	r.POST("/api/v1/login", s.handleLogin)
 
 	// API routes
-	api := r.Group("/api/v1")
+	api := r.Group("/api/v1", s.authenticate, s.authorize)
 	{
-		// Status and health
+		// Status
 		api.GET("/status", s.handleStatus)
-		api.GET("/health", s.handleHealth)
+		api.GET("/preflight", s.handlePreflight)
+		api.GET("/whoami", s.handleWhoAmI)
+		api.POST("/logout", s.handleLogout)
+
+		// Local user accounts
+		api.GET("/users", s.handleListUsers)
+		api.POST("/users", s.handleCreateUser)
+		api.GET("/users/:id", s.handleGetUser)
+		api.PUT("/users/:id", s.handleUpdateUser)
+		api.DELETE("/users/:id", s.handleDeleteUser)
+		api.POST("/users/:id/password", s.handleChangePassword)
+		api.POST("/users/:id/password-reset", s.handleResetPassword)
+
+		// Administration
+		api.GET("/admin/logs", s.handleAdminLogs)
+		api.GET("/admin/names", s.handleListInvalidNames)
+		api.POST("/admin/names/normalize", s.handleNormalizeNames)
+		api.GET("/admin/encryption", s.handleEncryptionStatus)
+		api.POST("/admin/encryption/rotate", s.handleRotateEncryption)
+		api.GET("/export", s.handleExport)
+		api.POST("/import", s.handleImport)
+
+		// Jobs
+		api.GET("/jobs", s.handleListJobs)
+		api.GET("/jobs/:id", s.handleGetJob)
+		api.POST("/jobs/:id/retry", s.handleRetryJob)
+
 		api.GET("/stats", s.handleStats)
+		api.GET("/usage/costs", s.handleCostReport)
+
+		// Admission webhooks
+		api.GET("/admission-webhooks", s.handleListAdmissionWebhooks)
+		api.POST("/admission-webhooks", s.handleCreateAdmissionWebhook)
+		api.GET("/admission-webhooks/:id", s.handleGetAdmissionWebhook)
+		api.PUT("/admission-webhooks/:id", s.handleUpdateAdmissionWebhook)
+		api.DELETE("/admission-webhooks/:id", s.handleDeleteAdmissionWebhook)
+
+		// Creation wizard
+		api.GET("/images", s.handleListImages)
+		api.GET("/networks", s.handleListNetworks)
+		api.GET("/capacity", s.handleCapacity)
+
+		// Capacity reservations
+		api.GET("/reservations", s.handleListReservations)
+		api.POST("/reservations", s.handleCreateReservation)
+		api.GET("/reservations/:id", s.handleGetReservation)
+		api.DELETE("/reservations/:id", s.handleDeleteReservation)
 
 		// VM management
 		api.GET("/vms", s.handleListVMs)
 		api.POST("/vms", s.handleCreateVM)
+		api.POST("/vms/bulk", s.handleBulkVMs)
+		api.POST("/vms/validate", s.handleValidateVM)
 		api.GET("/vms/:id", s.handleGetVM)
 		api.PUT("/vms/:id", s.handleUpdateVM)
 		api.DELETE("/vms/:id", s.handleDeleteVM)
 		api.POST("/vms/:id/start", s.handleStartVM)
 		api.POST("/vms/:id/stop", s.handleStopVM)
+		api.POST("/vms/:id/pause", s.handlePauseVM)
+		api.POST("/vms/:id/resume", s.handleResumeVM)
+		api.POST("/vms/:id/rescue", s.handleRescueVM)
+		api.GET("/vms/:id/drives", s.handleListDrives)
+		api.POST("/vms/:id/drives", s.handleAttachDrive)
+		api.DELETE("/vms/:id/drives/:drive_id", s.handleDetachDrive)
+		api.GET("/vms/:id/vmm-log", s.handleVMMLog)
+		api.GET("/vms/:id/output", s.handleVMOutput)
+		api.GET("/vms/:id/drift", s.handleVMDrift)
+		api.POST("/vms/:id/quarantine", s.handleQuarantineVM)
+		api.POST("/vms/:id/unquarantine", s.handleUnquarantineVM)
+		api.GET("/vms/:id/env", s.handleGetVMEnv)
+		api.PUT("/vms/:id/env", s.handleSetVMEnv)
+		api.GET("/vms/:id/hooks", s.handleListHooks)
+		api.POST("/vms/:id/hooks", s.handleCreateHook)
+		api.DELETE("/vms/:id/hooks/:hook_id", s.handleDeleteHook)
+		api.GET("/vms/:id/port-forwards", s.handleListPortForwards)
+		api.POST("/vms/:id/port-forwards", s.handleCreatePortForward)
+		api.DELETE("/vms/:id/port-forwards/:forward_id", s.handleDeletePortForward)
+		api.GET("/vms/:id/security-groups", s.handleListVMSecurityGroups)
+		api.POST("/vms/:id/security-groups", s.handleAttachSecurityGroup)
+		api.DELETE("/vms/:id/security-groups/:group_id", s.handleDetachSecurityGroup)
+		api.PUT("/vms/:id/guest-info", s.handleReportGuestInfo)
+		api.POST("/vms/:id/container-events", s.handleContainerEvents)
+		api.Any("/vms/:id/proxy/:port/*path", s.handleProxyHTTP)
+		api.GET("/vms/:id/tunnel/:port", s.handleProxyTCP)
+		api.GET("/vms/:id/metrics", s.handleVMMetrics)
+		api.POST("/vms/:id/capture", s.handleStartCapture)
+		api.GET("/vms/:id/captures", s.handleListCaptures)
+		api.GET("/vms/:id/captures/:capture_id", s.handleGetCapture)
+		api.GET("/vms/:id/captures/:capture_id/download", s.handleDownloadCapture)
+		api.POST("/vms/:id/disk-copies", s.handleStartDiskCopy)
+		api.GET("/vms/:id/disk-copies", s.handleListDiskCopies)
+		api.GET("/vms/:id/disk-copies/:copy_id", s.handleGetDiskCopy)
+		api.DELETE("/vms/:id/disk-copies/:copy_id", s.handleDeleteDiskCopy)
+		api.GET("/vms/:id/disk-copies/:copy_id/drives/:drive_id", s.handleDownloadDiskCopy)
+
+		// Security groups
+		api.GET("/security-groups", s.handleListSecurityGroups)
+		api.POST("/security-groups", s.handleCreateSecurityGroup)
+		api.GET("/security-groups/:id", s.handleGetSecurityGroup)
+		api.PUT("/security-groups/:id", s.handleUpdateSecurityGroup)
+		api.DELETE("/security-groups/:id", s.handleDeleteSecurityGroup)
+
+		// Boot profiles
+		api.GET("/boot-profiles", s.handleListBootProfiles)
+		api.POST("/boot-profiles", s.handleCreateBootProfile)
+		api.GET("/boot-profiles/:name", s.handleGetBootProfile)
+		api.PUT("/boot-profiles/:name", s.handleUpdateBootProfile)
+		api.DELETE("/boot-profiles/:name", s.handleDeleteBootProfile)
+
+		// Sizing profiles
+		api.GET("/sizing-profiles", s.handleListSizingProfiles)
+		api.POST("/sizing-profiles", s.handleCreateSizingProfile)
+		api.GET("/sizing-profiles/:name", s.handleGetSizingProfile)
+		api.PUT("/sizing-profiles/:name", s.handleUpdateSizingProfile)
+		api.DELETE("/sizing-profiles/:name", s.handleDeleteSizingProfile)
 
 		// Container management
 		api.GET("/containers", s.handleListContainers)
@@ -67,6 +206,8 @@ func (s *Server) SetupRoutes(r *gin.Engine) {
 		api.GET("/containers/:id", s.handleGetContainer)
 		api.PUT("/containers/:id", s.handleUpdateContainer)
 		api.DELETE("/containers/:id", s.handleDeleteContainer)
+		api.GET("/containers/:id/revisions", s.handleListContainerRevisions)
+		api.POST("/containers/:id/rollback", s.handleRollbackContainer)
 		api.POST("/containers/:id/start", s.handleStartContainer)
 		api.POST("/containers/:id/stop", s.handleStopContainer)
 	}
@@ -118,6 +259,12 @@ func (s *Server) handleNewContainerPage(c *gin.Context) {
 	})
 }
 
+func (s *Server) handleLoginPage(c *gin.Context) {
+	c.HTML(http.StatusOK, "login.html", gin.H{
+		"Title": "Sign in",
+	})
+}
+
 // API Handlers
 
 func (s *Server) handleStatus(c *gin.Context) {
@@ -132,6 +279,20 @@ func (s *Server) handleHealth(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 }
 
+// handlePreflight reports whether the host can run VMs, with 503 if any check fails
+func (s *Server) handlePreflight(c *gin.Context) {
+	checks := s.vmManager.Preflight()
+
+	status := http.StatusOK
+	for _, check := range checks {
+		if !check.OK {
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	c.JSON(status, gin.H{"ready": status == http.StatusOK, "checks": checks})
+}
+
 func (s *Server) handleStats(c *gin.Context) {
 	vms, err := s.db.ListVMs()
 	if err != nil {
@@ -170,11 +331,51 @@ func (s *Server) handleStats(c *gin.Context) {
 
 // VM API Handlers
 
+// Sizes of VMs created without a sizing profile or explicit sizes
+const (
+	defaultVMMemoryMB = 512
+	defaultVMCPUs     = 1
+	defaultVMDiskGB   = 2
+)
+
 type CreateVMRequest struct {
-	Name     string `json:"name" binding:"required"`
-	Memory   int64  `json:"memory"`
-	CPUs     int    `json:"cpus"`
-	DiskSize int64  `json:"disk_size"`
+	Name        string `json:"name" binding:"required"`
+	Memory      int64  `json:"memory"`
+	CPUs        int    `json:"cpus"`
+	DiskSize    int64  `json:"disk_size"`
+	BootProfile string `json:"boot_profile"`
+	Entropy     *bool  `json:"entropy"` // defaults to the ENABLE_ENTROPY setting
+
+	// Profile takes memory, CPUs, disk size and rate limits from a sizing profile
+	// instead of the raw numbers above
+	Profile string `json:"profile"`
+
+	// IPAddress requests a static guest address instead of the next free one
+	IPAddress string `json:"ip_address"`
+
+	// ReservationID creates the VM out of a capacity reservation, taking one of
+	// its addresses unless ip_address is set
+	ReservationID string `json:"reservation_id"`
+
+	// Ignition is a first-boot config for Flatcar/FCOS-style guests, served over MMDS
+	Ignition json.RawMessage `json:"ignition,omitempty"`
+
+	// Labels are matched against container vm_selector during placement
+	Labels map[string]string `json:"labels"`
+
+	// Firecracker logger settings, applied on the next start
+	LogLevel      string `json:"log_level"` // Off, Error, Warning, Info, Debug or Trace
+	LogShowLevel  *bool  `json:"log_show_level"`
+	LogShowOrigin *bool  `json:"log_show_origin"`
+
+	// IdleAction overrides IDLE_ACTION for this VM
+	IdleAction string `json:"idle_action" binding:"omitempty,oneof=none notify stop delete"`
+	// OutputMode overrides VM_OUTPUT_MODE for this VM
+	OutputMode string `json:"output_mode" binding:"omitempty,oneof=discard file buffer"`
+	// RootfsMode overrides ROOTFS_MODE for this VM; it can't be changed later
+	RootfsMode string `json:"rootfs_mode" binding:"omitempty,oneof=copy overlay"`
+	// RestartPolicy overrides RESTART_POLICY for this VM
+	RestartPolicy string `json:"restart_policy" binding:"omitempty,oneof=never on-failure always"`
 }
 
 func (s *Server) handleListVMs(c *gin.Context) {
@@ -194,6 +395,11 @@ func (s *Server) handleListVMs(c *gin.Context) {
 		}
 	}
 
+	for _, vm := range vms {
+		vm.Drifted = len(s.vmManager.Drift(vm.ID)) > 0
+		vm.Cost = s.vmCost(vm)
+	}
+
 	c.JSON(http.StatusOK, vms)
 }
 
@@ -204,39 +410,87 @@ func (s *Server) handleCreateVM(c *gin.Context) {
 		return
 	}
 
-	// Set defaults if not specified
-	if req.Memory == 0 {
-		req.Memory = 512
-	}
-	if req.CPUs == 0 {
-		req.CPUs = 1
-	}
-	if req.DiskSize == 0 {
-		req.DiskSize = 2
+	timeout, wait, err := waitTimeout(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	vm := &database.VM{
-		ID:       uuid.New().String(),
-		Name:     req.Name,
-		Status:   "creating",
-		Memory:   req.Memory,
-		CPUs:     req.CPUs,
-		DiskSize: req.DiskSize,
+	vm, ok := s.prepareVM(c, &req, false)
+	if !ok {
+		return
+	}
+	claim, ok := s.claimVM(c, &req, vm)
+	if !ok {
+		return
 	}
 
 	// Save to database first
 	if err := s.db.CreateVM(vm); err != nil {
 		s.logger.Errorf("Failed to create VM in database: %v", err)
+		s.returnClaim(claim)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create VM"})
 		return
 	}
 
+	// Lease a static address before answering, so of two requests for it the
+	// loser gets a 409 even when creation runs in the background
+	if err := s.vmManager.LeaseIPAddress(vm); err != nil {
+		s.db.DeleteVM(vm.ID)
+		s.returnClaim(claim)
+		s.respondIPError(c, err)
+		return
+	}
+
+	// Hand the Firecracker setup to the job queue so it survives a restart
+	if c.Query("async") == "true" {
+		job, err := jobs.Enqueue(s.db, jobs.TypeVMCreate, vm.ID, jobs.VMPayload{VMID: vm.ID, Claim: claim}, 3)
+		if err != nil {
+			s.logger.Errorf("Failed to enqueue creation of VM %s: %v", vm.ID, err)
+			if claim != nil {
+				s.db.DeleteVM(vm.ID)
+				s.returnClaim(claim)
+			} else {
+				vm.Status = "error"
+				vm.StatusReason = "failed to enqueue creation: " + err.Error()
+				s.db.UpdateVM(vm)
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create VM"})
+			return
+		}
+		if wait {
+			s.respondWhenSettled(c, vm.ID, job, timeout)
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"vm": vm, "job": job})
+		return
+	}
+
 	// Create the VM with Firecracker
 	if err := s.vmManager.CreateVM(vm); err != nil {
 		s.logger.Errorf("Failed to create VM with Firecracker: %v", err)
-		// Update status to error
-		vm.Status = "error"
-		s.db.UpdateVM(vm)
+		// A concurrent request claimed the address; don't keep a record for it
+		if errors.Is(err, firecracker.ErrIPInUse) {
+			s.db.DeleteVM(vm.ID)
+			s.returnClaim(claim)
+			s.respondIPError(c, err)
+			return
+		}
+		if claim != nil {
+			// Give the reservation back what the VM took rather than keep a
+			// failed VM holding it
+			s.db.DeleteVM(vm.ID)
+			s.returnClaim(claim)
+		} else {
+			// Update status to error
+			vm.Status = "error"
+			vm.StatusReason = "creation failed: " + err.Error()
+			s.db.UpdateVM(vm)
+		}
+		if errors.Is(err, firecracker.ErrImageUnverified) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create VM"})
 		return
 	}
@@ -245,6 +499,242 @@ func (s *Server) handleCreateVM(c *gin.Context) {
 	c.JSON(http.StatusCreated, vm)
 }
 
+// prepareVM checks a creation request and builds the VM it describes, with
+// admission webhooks, sizing profile, defaults and policies applied. On
+// failure the error response has been written.
+func (s *Server) prepareVM(c *gin.Context, req *CreateVMRequest, dryRun bool) (*database.VM, bool) {
+	vm, err := s.buildVM(c.Request.Context(), req, dryRun)
+	if err == nil {
+		// Policies run before capacity is claimed so a rejected VM holds nothing
+		err = s.evaluatePolicy("vm", policy.OperationCreate, vm, nil)
+	}
+	if err != nil {
+		s.respondVMError(c, err)
+		return nil, false
+	}
+	return vm, true
+}
+
+// requestError is a problem with a request, answered with 400 and its message
+type requestError struct {
+	message string
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+func invalidRequest(message string) error {
+	return &requestError{message: message}
+}
+
+// buildVM is prepareVM without the policy check, for callers that fill in more
+// of the VM first; they must call evaluatePolicy before creating it. Problems
+// with the request are returned as *requestError.
+func (s *Server) buildVM(ctx context.Context, req *CreateVMRequest, dryRun bool) (*database.VM, error) {
+	if err := s.admit(ctx, "vm", policy.OperationCreate, req, dryRun); err != nil {
+		return nil, err
+	}
+
+	if err := s.validateName("VM", req.Name); err != nil {
+		return nil, invalidRequest(err.Error())
+	}
+
+	if req.Profile != "" {
+		if req.Memory != 0 || req.CPUs != 0 || req.DiskSize != 0 {
+			return nil, invalidRequest("Set either profile or memory, cpus and disk_size")
+		}
+		profile, err := s.db.GetSizingProfile(req.Profile)
+		if err != nil {
+			return nil, invalidRequest("Sizing profile not found")
+		}
+		req.Memory, req.CPUs, req.DiskSize = profile.Memory, profile.CPUs, profile.DiskSize
+	}
+
+	// Set defaults if not specified
+	if req.Memory == 0 {
+		req.Memory = defaultVMMemoryMB
+	}
+	if req.CPUs == 0 {
+		req.CPUs = defaultVMCPUs
+	}
+	if req.DiskSize == 0 {
+		req.DiskSize = defaultVMDiskGB
+	}
+	if req.Entropy == nil {
+		req.Entropy = &s.config.EnableEntropy
+	}
+
+	if req.BootProfile != "" {
+		if _, err := s.db.GetBootProfile(req.BootProfile); err != nil {
+			return nil, invalidRequest("Boot profile not found")
+		}
+	}
+
+	if req.LogLevel != "" {
+		level, ok := firecracker.NormalizeLogLevel(req.LogLevel)
+		if !ok {
+			return nil, invalidRequest("log_level must be Off, Error, Warning, Info, Debug or Trace")
+		}
+		req.LogLevel = level
+	}
+
+	if len(req.Ignition) > 0 {
+		if err := validateIgnition(req.Ignition); err != nil {
+			return nil, invalidRequest(err.Error())
+		}
+	}
+
+	vm := &database.VM{
+		ID:            uuid.New().String(),
+		Name:          req.Name,
+		Status:        "creating",
+		Memory:        req.Memory,
+		CPUs:          req.CPUs,
+		DiskSize:      req.DiskSize,
+		BootProfile:   req.BootProfile,
+		Profile:       req.Profile,
+		Entropy:       *req.Entropy,
+		Ignition:      string(req.Ignition),
+		IPAddress:     req.IPAddress,
+		LogLevel:      req.LogLevel,
+		IdleAction:    req.IdleAction,
+		OutputMode:    req.OutputMode,
+		RootfsMode:    req.RootfsMode,
+		RestartPolicy: req.RestartPolicy,
+	}
+	if req.LogShowLevel != nil {
+		vm.LogShowLevel = *req.LogShowLevel
+	}
+	if req.LogShowOrigin != nil {
+		vm.LogShowOrigin = *req.LogShowOrigin
+	}
+
+	var err error
+	if vm.Labels, err = encodeSpecField(req.Labels, len(req.Labels) == 0); err != nil {
+		return nil, invalidRequest(err.Error())
+	}
+	return vm, nil
+}
+
+// respondVMError maps the errors of preparing a VM to client responses
+func (s *Server) respondVMError(c *gin.Context, err error) {
+	var invalid *requestError
+	var violations *PolicyViolations
+	var denied *AdmissionDenied
+	switch {
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.As(err, &violations):
+		s.respondPolicyError(c, err)
+	case errors.As(err, &denied), errors.Is(err, errAdmissionUnavailable):
+		s.respondAdmissionError(c, err)
+	default:
+		s.logger.Errorf("Failed to prepare VM: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare VM"})
+	}
+}
+
+// fitVM checks that a prepared VM fits in the host's capacity, or in its
+// reservation, and that its static address is free. On failure the error
+// response has been written.
+func (s *Server) fitVM(c *gin.Context, req *CreateVMRequest, vm *database.VM) bool {
+	if err := s.checkVMFits(req, vm); err != nil {
+		s.respondReservationError(c, err)
+		return false
+	}
+	return true
+}
+
+// claimVM is fitVM for a VM about to be created, which takes its share of its
+// reservation and leases its address from it in one step. The claim, nil for
+// a VM outside any reservation, is returned with returnClaim if the VM isn't
+// created after all.
+func (s *Server) claimVM(c *gin.Context, req *CreateVMRequest, vm *database.VM) (*firecracker.ReservationClaim, bool) {
+	if req.ReservationID == "" {
+		return nil, s.fitVM(c, req, vm)
+	}
+	claim, err := s.vmManager.ClaimReservation(req.ReservationID, vm)
+	if err != nil {
+		s.respondReservationError(c, err)
+		return nil, false
+	}
+	return claim, true
+}
+
+// returnClaim gives a failed VM's share back to its reservation, if it had one
+func (s *Server) returnClaim(claim *firecracker.ReservationClaim) {
+	if claim == nil {
+		return
+	}
+	if err := s.vmManager.ReturnClaim(claim); err != nil {
+		s.logger.Warnf("Failed to return claim of VM %s: %v", claim.VMID, err)
+	}
+}
+
+// checkVMFits is fitVM returning the reservation, capacity or IP error
+func (s *Server) checkVMFits(req *CreateVMRequest, vm *database.VM) error {
+	if req.ReservationID != "" {
+		ip, err := s.vmManager.CheckReservation(req.ReservationID, req.Memory, req.CPUs, req.IPAddress)
+		if err != nil {
+			return err
+		}
+		vm.IPAddress = ip
+		return nil
+	}
+
+	if req.IPAddress != "" {
+		if err := s.vmManager.ValidateStaticIP("", req.IPAddress); err != nil {
+			return err
+		}
+	}
+	return s.vmManager.CheckCapacity(req.Memory, req.CPUs)
+}
+
+// respondIPError maps static IP validation errors to client responses
+func (s *Server) respondIPError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, firecracker.ErrIPInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, firecracker.ErrInvalidIP):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		s.logger.Errorf("Failed to validate IP address: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate IP address"})
+	}
+}
+
+// respondReservationError maps reservation and capacity errors to client responses
+func (s *Server) respondReservationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, firecracker.ErrReservationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, firecracker.ErrReservationExceeded), errors.Is(err, firecracker.ErrInsufficientCapacity):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, firecracker.ErrIPInUse), errors.Is(err, firecracker.ErrInvalidIP):
+		s.respondIPError(c, err)
+	default:
+		s.logger.Errorf("Failed to check capacity: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check capacity"})
+	}
+}
+
+// validateIgnition checks that an Ignition config is a JSON object declaring its spec version
+func validateIgnition(raw json.RawMessage) error {
+	var ignition struct {
+		Ignition struct {
+			Version string `json:"version"`
+		} `json:"ignition"`
+	}
+	if err := json.Unmarshal(raw, &ignition); err != nil {
+		return fmt.Errorf("invalid ignition config: %v", err)
+	}
+	if ignition.Ignition.Version == "" {
+		return errors.New("invalid ignition config: ignition.version is required")
+	}
+	return nil
+}
+
 func (s *Server) handleGetVM(c *gin.Context) {
 	vmID := c.Param("id")
 
@@ -255,6 +745,13 @@ func (s *Server) handleGetVM(c *gin.Context) {
 		return
 	}
 
+	if info, err := s.db.GetGuestInfo(vmID); err == nil {
+		s.checkClockSkew(info)
+		vm.GuestInfo = info
+	}
+	vm.Drifted = len(s.vmManager.Drift(vmID)) > 0
+	vm.Cost = s.vmCost(vm)
+
 	c.JSON(http.StatusOK, vm)
 }
 
@@ -272,8 +769,34 @@ func (s *Server) handleUpdateVM(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := s.admit(c.Request.Context(), "vm", policy.OperationUpdate, &req, false); err != nil {
+		s.respondAdmissionError(c, err)
+		return
+	}
+	old := *vm
+
+	// Names that predate validation may be kept, but not introduced
+	if req.Name != vm.Name {
+		if err := s.validateName("VM", req.Name); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
 	vm.Name = req.Name
+	if req.Profile != "" {
+		if req.Memory != 0 || req.CPUs != 0 || req.DiskSize != 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Set either profile or memory, cpus and disk_size"})
+			return
+		}
+		profile, err := s.db.GetSizingProfile(req.Profile)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Sizing profile not found"})
+			return
+		}
+		vm.Profile = profile.Name
+		vm.Memory, vm.CPUs, vm.DiskSize = profile.Memory, profile.CPUs, profile.DiskSize
+	}
 	if req.Memory > 0 {
 		vm.Memory = req.Memory
 	}
@@ -283,27 +806,163 @@ func (s *Server) handleUpdateVM(c *gin.Context) {
 	if req.DiskSize > 0 {
 		vm.DiskSize = req.DiskSize
 	}
+	if req.BootProfile != "" {
+		if _, err := s.db.GetBootProfile(req.BootProfile); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Boot profile not found"})
+			return
+		}
+		vm.BootProfile = req.BootProfile
+	}
+	if req.Entropy != nil {
+		vm.Entropy = *req.Entropy
+	}
+	if req.LogLevel != "" {
+		level, ok := firecracker.NormalizeLogLevel(req.LogLevel)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "log_level must be Off, Error, Warning, Info, Debug or Trace"})
+			return
+		}
+		vm.LogLevel = level
+	}
+	if req.LogShowLevel != nil {
+		vm.LogShowLevel = *req.LogShowLevel
+	}
+	if req.LogShowOrigin != nil {
+		vm.LogShowOrigin = *req.LogShowOrigin
+	}
+	if req.IdleAction != "" {
+		vm.IdleAction = req.IdleAction
+	}
+	if req.OutputMode != "" {
+		vm.OutputMode = req.OutputMode
+	}
+	if req.RestartPolicy != "" {
+		vm.RestartPolicy = req.RestartPolicy
+	}
+	if req.Labels != nil {
+		if vm.Labels, err = encodeSpecField(req.Labels, len(req.Labels) == 0); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if !s.checkPolicy(c, "vm", policy.OperationUpdate, vm, &old) {
+		return
+	}
+
+	// Size, boot arguments and devices only take effect when the VM boots
+	bootChanged := vm.Memory != old.Memory || vm.CPUs != old.CPUs ||
+		vm.BootProfile != old.BootProfile || vm.Entropy != old.Entropy
+	if bootChanged && s.vmManager.Running(vmID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Stop the VM before changing its memory, cpus, boot_profile or entropy"})
+		return
+	}
+
+	// Growing a VM takes capacity like creating one does
+	if vm.Memory > old.Memory || vm.CPUs > old.CPUs {
+		if err := s.vmManager.CheckCapacity(vm.Memory-old.Memory, vm.CPUs-old.CPUs); err != nil {
+			s.respondReservationError(c, err)
+			return
+		}
+	}
 
 	if err := s.db.UpdateVM(vm); err != nil {
 		s.logger.Errorf("Failed to update VM: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update VM"})
 		return
 	}
+	// Starting the VM applies the spec anyway; this keeps the file current
+	if bootChanged {
+		if err := s.vmManager.RefreshConfig(vm); err != nil {
+			s.logger.Warnf("Failed to rewrite the Firecracker config of VM %s: %v", vmID, err)
+		}
+	}
 
 	c.JSON(http.StatusOK, vm)
 }
 
+// handleDeleteVM marks a VM as deleting and leaves the teardown to the job
+// queue, so clients don't wait on slow cleanup
 func (s *Server) handleDeleteVM(c *gin.Context) {
 	vmID := c.Param("id")
 
-	if err := s.vmManager.DeleteVM(vmID); err != nil {
-		s.logger.Errorf("Failed to delete VM %s: %v", vmID, err)
+	vm, err := s.db.GetVM(vmID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+
+	timeout, wait, err := waitTimeout(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A dedicated VM goes with its container
+	if !s.checkNotDedicated(c, []string{vmID}) {
+		return
+	}
+
+	// Deleting twice returns the teardown already under way
+	if vm.Status == "deleting" {
+		pending, _, err := s.db.ListJobs(database.JobFilter{Type: jobs.TypeVMDelete, ResourceID: vmID, Limit: 1})
+		if err == nil && len(pending) > 0 && pending[0].Status != database.JobDead {
+			if wait {
+				s.respondWhenSettled(c, vmID, pending[0], timeout)
+				return
+			}
+			c.JSON(http.StatusAccepted, gin.H{"vm": vm, "job": pending[0]})
+			return
+		}
+	}
+
+	vm.Status = "deleting"
+	vm.StatusReason = ""
+	if err := s.db.UpdateVM(vm); err != nil {
+		s.logger.Errorf("Failed to mark VM %s as deleting: %v", vmID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete VM"})
+		return
+	}
+
+	job, err := jobs.Enqueue(s.db, jobs.TypeVMDelete, vmID, jobs.VMPayload{VMID: vmID}, 5)
+	if err != nil {
+		s.logger.Errorf("Failed to enqueue deletion of VM %s: %v", vmID, err)
+		vm.Status = "error"
+		vm.StatusReason = "failed to enqueue deletion: " + err.Error()
+		s.db.UpdateVM(vm)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete VM"})
 		return
 	}
 
-	s.logger.Infof("VM %s deleted successfully", vmID)
-	c.JSON(http.StatusOK, gin.H{"message": "VM deleted successfully"})
+	s.logger.Infof("VM %s marked for deletion by job %s", vmID, job.ID)
+	if wait {
+		s.respondWhenSettled(c, vmID, job, timeout)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"vm": vm, "job": job})
+}
+
+// checkNotDedicated refuses with 409 to delete VMs that were created for a
+// container with dedicated isolation, which are deleted along with their
+// container. On failure the error response has been written.
+func (s *Server) checkNotDedicated(c *gin.Context, vmIDs []string) bool {
+	var owned []string
+	for _, vmID := range vmIDs {
+		containerID, err := s.db.DedicatedContainerID(vmID)
+		if err != nil {
+			s.logger.Errorf("Failed to look up the container of VM %s: %v", vmID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete VM"})
+			return false
+		}
+		if containerID != "" {
+			owned = append(owned, fmt.Sprintf("%s (container %s)", vmID, containerID))
+		}
+	}
+	if len(owned) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "VMs dedicated to a container are deleted with it: " + strings.Join(owned, ", ")})
+		return false
+	}
+	return true
 }
 
 func (s *Server) handleStartVM(c *gin.Context) {
@@ -311,6 +970,18 @@ func (s *Server) handleStartVM(c *gin.Context) {
 
 	if err := s.vmManager.StartVM(vmID); err != nil {
 		s.logger.Errorf("Failed to start VM %s: %v", vmID, err)
+		if errors.Is(err, firecracker.ErrImageUnverified) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, firecracker.ErrHookFailed) {
+			c.JSON(http.StatusFailedDependency, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, firecracker.ErrVMDeleting) || errors.Is(err, firecracker.ErrVMInRescue) || errors.Is(err, firecracker.ErrVMRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start VM"})
 		return
 	}
@@ -319,10 +990,13 @@ func (s *Server) handleStartVM(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "VM started successfully"})
 }
 
+// handleStopVM shuts the guest down cleanly, killing it after STOP_TIMEOUT;
+// force=true kills it straight away
 func (s *Server) handleStopVM(c *gin.Context) {
 	vmID := c.Param("id")
+	force := c.Query("force") == "true"
 
-	if err := s.vmManager.StopVM(vmID); err != nil {
+	if err := s.vmManager.StopVM(vmID, force); err != nil {
 		s.logger.Errorf("Failed to stop VM %s: %v", vmID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to stop VM"})
 		return
@@ -332,14 +1006,85 @@ func (s *Server) handleStopVM(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "VM stopped successfully"})
 }
 
+func (s *Server) handlePauseVM(c *gin.Context) {
+	vmID := c.Param("id")
+
+	if err := s.vmManager.PauseVM(vmID); err != nil {
+		s.respondVMStateError(c, "pause", vmID, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "VM paused successfully"})
+}
+
+func (s *Server) handleResumeVM(c *gin.Context) {
+	vmID := c.Param("id")
+
+	if err := s.vmManager.ResumeVM(vmID); err != nil {
+		s.respondVMStateError(c, "resume", vmID, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "VM resumed successfully"})
+}
+
+// handleRescueVM boots a stopped VM once from the rescue image, with its own
+// disks attached as secondary drives
+func (s *Server) handleRescueVM(c *gin.Context) {
+	vmID := c.Param("id")
+
+	if err := s.vmManager.RescueVM(vmID); err != nil {
+		s.logger.Errorf("Failed to rescue VM %s: %v", vmID, err)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		case errors.Is(err, firecracker.ErrRescueUnavailable), errors.Is(err, firecracker.ErrImageUnverified):
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		case errors.Is(err, firecracker.ErrVMRunning), errors.Is(err, firecracker.ErrVMDeleting):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rescue VM"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "VM booted into rescue mode"})
+}
+
+// respondVMStateError maps pause and resume errors to client responses
+func (s *Server) respondVMStateError(c *gin.Context, action, vmID string, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+	case errors.Is(err, firecracker.ErrVMNotRunning), errors.Is(err, firecracker.ErrVMNotPaused):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, firecracker.ErrCircuitOpen):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		s.logger.Errorf("Failed to %s VM %s: %v", action, vmID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " VM"})
+	}
+}
+
 // Container API Handlers
 
 type CreateContainerRequest struct {
 	Name        string            `json:"name" binding:"required"`
 	Image       string            `json:"image" binding:"required"`
-	VMID        string            `json:"vm_id" binding:"required"`
 	Ports       map[string]string `json:"ports"`
 	Environment map[string]string `json:"environment"`
+	Volumes     []VolumeMount     `json:"volumes"`
+	InitSteps   []InitStep        `json:"init_steps"` // run in the VM before the container starts
+
+	// VMID pins the container to a VM; when empty the scheduler places it
+	VMID       string            `json:"vm_id"`
+	VMSelector map[string]string `json:"vm_selector"`            // labels the chosen VM must have
+	Memory     int64             `json:"memory" binding:"min=0"` // MB reserved on the VM
+	CPUs       int               `json:"cpus" binding:"min=0"`   // vCPUs reserved on the VM
+
+	// Isolation "dedicated" runs the container alone in a VM created for it and
+	// deleted with it; the default "shared" places it alongside other containers
+	Isolation string `json:"isolation" binding:"omitempty,oneof=shared dedicated"`
 }
 
 func (s *Server) handleListContainers(c *gin.Context) {
@@ -360,29 +1105,124 @@ func (s *Server) handleCreateContainer(c *gin.Context) {
 		return
 	}
 
-	// Verify VM exists
-	vm, err := s.db.GetVM(req.VMID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "VM not found"})
+	if err := s.admit(c.Request.Context(), "container", policy.OperationCreate, &req, false); err != nil {
+		s.respondAdmissionError(c, err)
 		return
 	}
 
-	if vm.Status != "running" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "VM must be running to deploy containers"})
+	if err := s.validateName("container", req.Name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if req.Isolation == "" {
+		req.Isolation = isolationShared
+	}
+
 	container := &database.Container{
-		ID:     uuid.New().String(),
-		Name:   req.Name,
-		Image:  req.Image,
-		Status: "creating",
-		VMID:   req.VMID,
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		Image:     req.Image,
+		Status:    "creating",
+		VMID:      req.VMID,
+		Memory:    req.Memory,
+		CPUs:      req.CPUs,
+		Isolation: req.Isolation,
+		Revision:  1,
 	}
 
+	if err := validateInitSteps(req.InitSteps); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := encodeContainerSpec(container, req.Ports, req.Environment, req.Volumes, req.InitSteps); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Policies run before placement so a rejected container provisions nothing
+	if !s.checkPolicy(c, "container", policy.OperationCreate, container, nil) {
+		return
+	}
+
+	// Placement is serialised until the container is saved, so two containers
+	// can't both be given the last of a VM's capacity
+	s.placementMu.Lock()
+	defer s.placementMu.Unlock()
+
+	var placement *database.Placement
+	if req.Isolation == isolationDedicated {
+		if req.VMID != "" || len(req.VMSelector) > 0 || hasDriveVolumes(req.Volumes) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Dedicated containers get a new VM and cannot set vm_id, vm_selector or drive volumes"})
+			return
+		}
+		vm, err := s.provisionVM(c.Request.Context(), &req, true)
+		if err != nil {
+			s.respondPlacementError(c, req.Name, err)
+			return
+		}
+		req.VMID = vm.ID
+		placement = &database.Placement{VMID: vm.ID, VMName: vm.Name, Provisioned: true}
+	} else if req.VMID == "" {
+		vm, p, err := s.placeContainer(c.Request.Context(), &req)
+		if err != nil {
+			s.respondPlacementError(c, req.Name, err)
+			return
+		}
+		req.VMID = vm.ID
+		placement = p
+	} else {
+		// Verify VM exists
+		vm, err := s.db.GetVM(req.VMID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "VM not found"})
+			return
+		}
+
+		if vm.Status != "running" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "VM must be running to deploy containers"})
+			return
+		}
+		if vm.Quarantined {
+			c.JSON(http.StatusConflict, gin.H{"error": "VM is quarantined"})
+			return
+		}
+
+		usage, err := s.containerUsage()
+		if err != nil {
+			s.logger.Errorf("Failed to compute VM usage: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create container"})
+			return
+		}
+		if usage[vm.ID].dedicated {
+			c.JSON(http.StatusConflict, gin.H{"error": "VM is dedicated to another container"})
+			return
+		}
+		if !fits(vm, usage[vm.ID], req.Memory, req.CPUs) {
+			c.JSON(http.StatusConflict, gin.H{"error": "VM does not have enough free memory or CPUs for the container"})
+			return
+		}
+
+		if err := s.validateVolumes(req.VMID, req.Volumes); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	container.VMID = req.VMID
+
 	// Save to database
 	if err := s.db.CreateContainer(container); err != nil {
 		s.logger.Errorf("Failed to create container in database: %v", err)
+		s.discardPlacement(placement)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create container"})
+		return
+	}
+	if _, err := s.db.CreateContainerRevision(container, "create"); err != nil {
+		s.logger.Errorf("Failed to record revision of container %s: %v", container.ID, err)
+		s.db.DeleteContainer(container.ID)
+		s.discardPlacement(placement)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create container"})
 		return
 	}
@@ -390,11 +1230,56 @@ func (s *Server) handleCreateContainer(c *gin.Context) {
 	// TODO: Implement actual container creation in VM
 	container.Status = "created"
 	s.db.UpdateContainer(container)
+	if err := s.db.AddContainerStatusChange(&database.ContainerStatusChange{ContainerID: container.ID, Status: container.Status, Action: "create"}); err != nil {
+		s.logger.Warnf("Failed to record status history of container %s: %v", container.ID, err)
+	}
 
+	container.Placement = placement
 	s.logger.Infof("Container %s created successfully", container.ID)
 	c.JSON(http.StatusCreated, container)
 }
 
+// encodeContainerSpec stores the JSON-encoded ports, environment, volumes and init steps on a container
+func encodeContainerSpec(container *database.Container, ports, environment map[string]string, volumes []VolumeMount, initSteps []InitStep) error {
+	var err error
+	if container.Ports, err = encodeSpecField(ports, len(ports) == 0); err != nil {
+		return err
+	}
+	if container.Environment, err = encodeSpecField(environment, len(environment) == 0); err != nil {
+		return err
+	}
+	if container.Volumes, err = encodeSpecField(volumes, len(volumes) == 0); err != nil {
+		return err
+	}
+	if container.InitSteps, err = encodeSpecField(initSteps, len(initSteps) == 0); err != nil {
+		return err
+	}
+	return nil
+}
+
+// encodeSpecField marshals a container spec field, storing empty values as ""
+func encodeSpecField(value interface{}, empty bool) (string, error) {
+	if empty {
+		return "", nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid container spec: %w", err)
+	}
+	return string(data), nil
+}
+
+// decodeSpecField unmarshals a field stored by encodeSpecField, leaving value unset when empty
+func decodeSpecField(encoded string, value interface{}) error {
+	if encoded == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(encoded), value); err != nil {
+		return fmt.Errorf("invalid stored spec: %w", err)
+	}
+	return nil
+}
+
 func (s *Server) handleGetContainer(c *gin.Context) {
 	containerID := c.Param("id")
 
@@ -405,17 +1290,134 @@ func (s *Server) handleGetContainer(c *gin.Context) {
 		return
 	}
 
+	if expand := c.Query("expand"); expand != "" {
+		s.respondContainerDetail(c, container, expand)
+		return
+	}
+
 	c.JSON(http.StatusOK, container)
 }
 
+// UpdateContainerRequest changes a container's spec; omitted fields are kept
+type UpdateContainerRequest struct {
+	Name        string            `json:"name"`
+	Image       string            `json:"image"`
+	Ports       map[string]string `json:"ports"`
+	Environment map[string]string `json:"environment"`
+	Volumes     []VolumeMount     `json:"volumes"`
+	InitSteps   []InitStep        `json:"init_steps"`
+	Memory      *int64            `json:"memory" binding:"omitempty,min=0"`
+	CPUs        *int              `json:"cpus" binding:"omitempty,min=0"`
+}
+
 func (s *Server) handleUpdateContainer(c *gin.Context) {
-	// TODO: Implement container update
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Container update not implemented yet"})
+	containerID := c.Param("id")
+
+	container, err := s.db.GetContainer(containerID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Container not found"})
+		return
+	}
+
+	var req UpdateContainerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.admit(c.Request.Context(), "container", policy.OperationUpdate, &req, false); err != nil {
+		s.respondAdmissionError(c, err)
+		return
+	}
+	old := *container
+
+	if req.Name != "" && req.Name != container.Name {
+		if err := s.validateName("container", req.Name); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		container.Name = req.Name
+	}
+	if req.Image != "" {
+		container.Image = req.Image
+	}
+	if req.Ports != nil {
+		if container.Ports, err = encodeSpecField(req.Ports, len(req.Ports) == 0); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Environment != nil {
+		if container.Environment, err = encodeSpecField(req.Environment, len(req.Environment) == 0); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Volumes != nil {
+		if err := s.validateVolumes(container.VMID, req.Volumes); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if container.Volumes, err = encodeSpecField(req.Volumes, len(req.Volumes) == 0); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.InitSteps != nil {
+		if err := validateInitSteps(req.InitSteps); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if container.InitSteps, err = encodeSpecField(req.InitSteps, len(req.InitSteps) == 0); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Memory != nil {
+		container.Memory = *req.Memory
+	}
+	if req.CPUs != nil {
+		container.CPUs = *req.CPUs
+	}
+
+	if !s.checkPolicy(c, "container", policy.OperationUpdate, container, &old) {
+		return
+	}
+
+	s.deployContainerRevision(c, container, "update")
 }
 
 func (s *Server) handleDeleteContainer(c *gin.Context) {
 	containerID := c.Param("id")
 
+	container, err := s.db.GetContainer(containerID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Container not found"})
+		return
+	}
+
+	// A dedicated VM exists only for its container. It goes first so a failure
+	// leaves the container in place to retry the delete, rather than an
+	// orphaned VM nothing points to.
+	if container.Isolation == isolationDedicated {
+		if err := s.vmManager.DeleteVM(container.VMID); err != nil {
+			s.logger.Errorf("Failed to delete dedicated VM %s of container %s: %v", container.VMID, containerID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete the container's dedicated VM"})
+			return
+		}
+	}
+
+	if err := s.db.DeleteContainerRevisions(containerID); err != nil {
+		s.logger.Errorf("Failed to delete revisions of container %s: %v", containerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete container"})
+		return
+	}
+
+	if err := s.db.DeleteContainerStatusChanges(containerID); err != nil {
+		s.logger.Errorf("Failed to delete status history of container %s: %v", containerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete container"})
+		return
+	}
+
 	if err := s.db.DeleteContainer(containerID); err != nil {
 		s.logger.Errorf("Failed to delete container %s: %v", containerID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete container"})
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/firecracker"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Lifecycle Hook API Handlers
+
+type CreateHookRequest struct {
+	Event          string `json:"event" binding:"required,oneof=pre-start post-start pre-stop idle crash degraded drift"`
+	Type           string `json:"type" binding:"required"`
+	Target         string `json:"target" binding:"required"`
+	TimeoutSeconds int    `json:"timeout_seconds" binding:"min=0,max=300"`
+}
+
+func (s *Server) handleListHooks(c *gin.Context) {
+	vmID := c.Param("id")
+
+	if _, err := s.db.GetVM(vmID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+
+	hooks, err := s.db.ListLifecycleHooksByVM(vmID)
+	if err != nil {
+		s.logger.Errorf("Failed to list hooks for VM %s: %v", vmID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list hooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, hooks)
+}
+
+func (s *Server) handleCreateHook(c *gin.Context) {
+	// Hooks run on the host as the orchestrator
+	if !requireRole(c, RoleAdmin) {
+		return
+	}
+
+	vmID := c.Param("id")
+
+	if _, err := s.db.GetVM(vmID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+
+	var req CreateHookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.validateHookTarget(req.Type, req.Target); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.TimeoutSeconds == 0 {
+		req.TimeoutSeconds = int(firecracker.DefaultHookTimeout.Seconds())
+	}
+
+	hook := &database.LifecycleHook{
+		ID:             uuid.New().String(),
+		VMID:           vmID,
+		Event:          req.Event,
+		Type:           req.Type,
+		Target:         req.Target,
+		TimeoutSeconds: req.TimeoutSeconds,
+	}
+
+	if err := s.db.CreateLifecycleHook(hook); err != nil {
+		s.logger.Errorf("Failed to create hook for VM %s: %v", vmID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create hook"})
+		return
+	}
+
+	s.logger.Infof("Registered %s %s hook %s for VM %s", hook.Event, hook.Type, hook.ID, vmID)
+	c.JSON(http.StatusCreated, hook)
+}
+
+func (s *Server) handleDeleteHook(c *gin.Context) {
+	if !requireRole(c, RoleAdmin) {
+		return
+	}
+
+	vmID := c.Param("id")
+	hookID := c.Param("hook_id")
+
+	deleted, err := s.db.DeleteLifecycleHook(vmID, hookID)
+	if err != nil {
+		s.logger.Errorf("Failed to delete hook %s of VM %s: %v", hookID, vmID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete hook"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Hook not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Hook deleted successfully"})
+}
+
+// validateHookTarget checks that a hook's target can be run for its type: exec
+// hooks must be scripts in HOOKS_DIR and webhooks can't name an internal address
+func (s *Server) validateHookTarget(hookType, target string) error {
+	switch hookType {
+	case firecracker.HookTypeExec:
+		if _, err := s.vmManager.ResolveExecHook(target); err != nil {
+			return errors.New("exec hook target must be an executable file inside HOOKS_DIR")
+		}
+	case firecracker.HookTypeWebhook:
+		u, err := parseWebhookURL(target)
+		if err != nil {
+			return err
+		}
+		host := u.Hostname()
+		if strings.EqualFold(host, "localhost") {
+			return errors.New("webhook target can't be an internal address")
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			if err := firecracker.CheckHookAddress(ip, s.config.HookPrivateNetwork); err != nil {
+				return errors.New("webhook target can't be an internal address")
+			}
+		}
+	case "guest":
+		// There is no channel to run commands inside a guest yet
+		return errors.New("In-guest hooks are not supported yet")
+	default:
+		return errors.New("type must be \"exec\" or \"webhook\"")
+	}
+	return nil
+}
+
+// parseWebhookURL parses a webhook target, which must be an http(s) URL
+func parseWebhookURL(target string) (*url.URL, error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("webhook target must be an http(s) URL")
+	}
+	return u, nil
+}
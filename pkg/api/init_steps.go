@@ -0,0 +1,37 @@
+package api
+
+import (
+	"fmt"
+)
+
+// InitStep is a run-to-completion step executed inside the VM before the main
+// container starts. Steps run in order and any failure blocks the deployment.
+type InitStep struct {
+	Name    string   `json:"name" yaml:"name"`
+	Image   string   `json:"image,omitempty" yaml:"image,omitempty"` // run as a container from this image; otherwise Command runs on the guest
+	Command []string `json:"command,omitempty" yaml:"command,omitempty"`
+	// TimeoutSeconds bounds the step; 0 means no limit
+	TimeoutSeconds int `json:"timeout_seconds,omitempty" yaml:"timeout_seconds,omitempty"`
+}
+
+// validateInitSteps checks that init steps are uniquely named and runnable
+func validateInitSteps(steps []InitStep) error {
+	names := make(map[string]bool, len(steps))
+	for i, step := range steps {
+		if step.Name == "" {
+			return fmt.Errorf("init step %d: name is required", i)
+		}
+		if names[step.Name] {
+			return fmt.Errorf("init step %d: name %q is used more than once", i, step.Name)
+		}
+		names[step.Name] = true
+
+		if step.Image == "" && len(step.Command) == 0 {
+			return fmt.Errorf("init step %q: set an image, a command or both", step.Name)
+		}
+		if step.TimeoutSeconds < 0 {
+			return fmt.Errorf("init step %q: timeout_seconds must not be negative", step.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,675 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/firecracker"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/policy"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// inventoryVersion is the schema version of exported inventories
+const inventoryVersion = 1
+
+// Inventory is a declarative description of everything the orchestrator manages.
+// Ignition configs are left out because they usually carry credentials, as are
+// VM and container environments unless the export asks for them, and VMs
+// created for dedicated containers are recreated with their container.
+type Inventory struct {
+	Version        int                      `yaml:"version"`
+	BootProfiles   []InventoryBootProfile   `yaml:"boot_profiles,omitempty"`
+	SizingProfiles []InventorySizingProfile `yaml:"sizing_profiles,omitempty"`
+	VMs            []InventoryVM            `yaml:"vms,omitempty"`
+	Containers     []InventoryContainer     `yaml:"containers,omitempty"`
+}
+
+type InventoryBootProfile struct {
+	Name        string `yaml:"name"`
+	BootArgs    string `yaml:"boot_args"`
+	Description string `yaml:"description,omitempty"`
+}
+
+type InventorySizingProfile struct {
+	Name             string `yaml:"name"`
+	Memory           int64  `yaml:"memory"`
+	CPUs             int    `yaml:"cpus"`
+	DiskSize         int64  `yaml:"disk_size"`
+	Description      string `yaml:"description,omitempty"`
+	NetBandwidthMbps int64  `yaml:"net_bandwidth_mbps,omitempty"`
+	DiskIOPS         int64  `yaml:"disk_iops,omitempty"`
+}
+
+type InventoryVM struct {
+	Name string `yaml:"name"`
+	// Profile replaces memory, cpus and disk_size, as on VM creation
+	Profile       string            `yaml:"profile,omitempty"`
+	Memory        int64             `yaml:"memory,omitempty"`
+	CPUs          int               `yaml:"cpus,omitempty"`
+	DiskSize      int64             `yaml:"disk_size,omitempty"`
+	BootProfile   string            `yaml:"boot_profile,omitempty"`
+	Entropy       bool              `yaml:"entropy"`
+	IPAddress     string            `yaml:"ip_address,omitempty"`
+	Labels        map[string]string `yaml:"labels,omitempty"`
+	Env           map[string]string `yaml:"env,omitempty"`
+	LogLevel      string            `yaml:"log_level,omitempty"`
+	LogShowLevel  bool              `yaml:"log_show_level,omitempty"`
+	LogShowOrigin bool              `yaml:"log_show_origin,omitempty"`
+	IdleAction    string            `yaml:"idle_action,omitempty"`
+	OutputMode    string            `yaml:"output_mode,omitempty"`
+	RootfsMode    string            `yaml:"rootfs_mode,omitempty"`
+	RestartPolicy string            `yaml:"restart_policy,omitempty"`
+	Drives        []InventoryDrive  `yaml:"drives,omitempty"`
+	Hooks         []InventoryHook   `yaml:"hooks,omitempty"`
+	// Start boots the VM after import; set for VMs that were running at export
+	Start bool `yaml:"start,omitempty"`
+}
+
+type InventoryDrive struct {
+	DriveID    string `yaml:"drive_id"`
+	PathOnHost string `yaml:"path_on_host"`
+	ReadOnly   bool   `yaml:"read_only,omitempty"`
+}
+
+type InventoryHook struct {
+	Event          string `yaml:"event"`
+	Type           string `yaml:"type"`
+	Target         string `yaml:"target"`
+	TimeoutSeconds int    `yaml:"timeout_seconds,omitempty"`
+}
+
+type InventoryContainer struct {
+	Name  string `yaml:"name"`
+	Image string `yaml:"image"`
+	// VM is the name of the VM the container runs on; when empty the scheduler places it
+	VM          string            `yaml:"vm,omitempty"`
+	Isolation   string            `yaml:"isolation,omitempty"`
+	Memory      int64             `yaml:"memory,omitempty"`
+	CPUs        int               `yaml:"cpus,omitempty"`
+	Ports       map[string]string `yaml:"ports,omitempty"`
+	Environment map[string]string `yaml:"environment,omitempty"`
+	Volumes     []VolumeMount     `yaml:"volumes,omitempty"`
+	InitSteps   []InitStep        `yaml:"init_steps,omitempty"`
+}
+
+// ImportResult lists what an import created and what it left alone
+type ImportResult struct {
+	Created []string     `json:"created"`
+	Skipped []ImportSkip `json:"skipped"`
+}
+
+// ImportSkip is an inventory entry that was not imported
+type ImportSkip struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+func (r *ImportResult) created(kind, name string) {
+	r.Created = append(r.Created, kind+"/"+name)
+}
+
+func (r *ImportResult) skip(kind, name string, err error) {
+	r.Skipped = append(r.Skipped, ImportSkip{Kind: kind, Name: name, Reason: err.Error()})
+}
+
+// errAlreadyExists marks inventory entries whose name is already taken
+var errAlreadyExists = errors.New("already exists")
+
+func (s *Server) handleExport(c *gin.Context) {
+	if !requireRole(c, RoleAdmin) {
+		return
+	}
+
+	// Environments often hold credentials, so they are only exported on request
+	inventory, err := s.buildInventory(c.Query("include_secrets") == "true")
+	if err != nil {
+		s.logger.Errorf("Failed to build inventory: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export inventory"})
+		return
+	}
+
+	var data bytes.Buffer
+	encoder := yaml.NewEncoder(&data)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(inventory); err != nil {
+		s.logger.Errorf("Failed to marshal inventory: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export inventory"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="inventory.yaml"`)
+	c.Data(http.StatusOK, "application/yaml", data.Bytes())
+}
+
+// buildInventory collects the declarative state of every managed resource,
+// with VM and container environments only when includeSecrets is set
+func (s *Server) buildInventory(includeSecrets bool) (*Inventory, error) {
+	inventory := &Inventory{Version: inventoryVersion}
+
+	bootProfiles, err := s.db.ListBootProfiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list boot profiles: %w", err)
+	}
+	for _, profile := range bootProfiles {
+		inventory.BootProfiles = append(inventory.BootProfiles, InventoryBootProfile{
+			Name:        profile.Name,
+			BootArgs:    profile.BootArgs,
+			Description: profile.Description,
+		})
+	}
+
+	sizingProfiles, err := s.db.ListSizingProfiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sizing profiles: %w", err)
+	}
+	for _, profile := range sizingProfiles {
+		inventory.SizingProfiles = append(inventory.SizingProfiles, InventorySizingProfile{
+			Name:             profile.Name,
+			Memory:           profile.Memory,
+			CPUs:             profile.CPUs,
+			DiskSize:         profile.DiskSize,
+			Description:      profile.Description,
+			NetBandwidthMbps: profile.NetBandwidthMbps,
+			DiskIOPS:         profile.DiskIOPS,
+		})
+	}
+
+	containers, err := s.db.ListContainers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	dedicatedVMs := make(map[string]bool)
+	for _, container := range containers {
+		if container.Isolation == isolationDedicated {
+			dedicatedVMs[container.VMID] = true
+		}
+	}
+
+	vms, err := s.db.ListVMs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VMs: %w", err)
+	}
+	vmNames := make(map[string]string, len(vms))
+	for _, vm := range vms {
+		vmNames[vm.ID] = vm.Name
+		if dedicatedVMs[vm.ID] {
+			continue
+		}
+		entry, err := s.inventoryVM(vm, includeSecrets)
+		if err != nil {
+			return nil, err
+		}
+		inventory.VMs = append(inventory.VMs, *entry)
+	}
+
+	for _, container := range containers {
+		entry := InventoryContainer{
+			Name:      container.Name,
+			Image:     container.Image,
+			Isolation: container.Isolation,
+			Memory:    container.Memory,
+			CPUs:      container.CPUs,
+		}
+		if container.Isolation != isolationDedicated {
+			entry.VM = vmNames[container.VMID]
+		}
+		if err := decodeSpecField(container.Ports, &entry.Ports); err != nil {
+			return nil, err
+		}
+		if includeSecrets {
+			if err := decodeSpecField(container.Environment, &entry.Environment); err != nil {
+				return nil, err
+			}
+		}
+		if err := decodeSpecField(container.Volumes, &entry.Volumes); err != nil {
+			return nil, err
+		}
+		if err := decodeSpecField(container.InitSteps, &entry.InitSteps); err != nil {
+			return nil, err
+		}
+		inventory.Containers = append(inventory.Containers, entry)
+	}
+
+	return inventory, nil
+}
+
+// inventoryVM describes a VM with its drives and hooks, and its environment
+// when includeSecrets is set
+func (s *Server) inventoryVM(vm *database.VM, includeSecrets bool) (*InventoryVM, error) {
+	entry := &InventoryVM{
+		Name:          vm.Name,
+		Profile:       vm.Profile,
+		BootProfile:   vm.BootProfile,
+		Entropy:       vm.Entropy,
+		IPAddress:     vm.IPAddress,
+		LogLevel:      vm.LogLevel,
+		LogShowLevel:  vm.LogShowLevel,
+		LogShowOrigin: vm.LogShowOrigin,
+		IdleAction:    vm.IdleAction,
+		OutputMode:    vm.OutputMode,
+		RootfsMode:    vm.RootfsMode,
+		RestartPolicy: vm.RestartPolicy,
+		Start:         vm.Status == "running",
+	}
+	if vm.Profile == "" {
+		entry.Memory, entry.CPUs, entry.DiskSize = vm.Memory, vm.CPUs, vm.DiskSize
+	}
+	if err := decodeSpecField(vm.Labels, &entry.Labels); err != nil {
+		return nil, err
+	}
+	if includeSecrets {
+		if err := decodeSpecField(vm.Env, &entry.Env); err != nil {
+			return nil, err
+		}
+	}
+
+	drives, err := s.db.ListDriveAttachmentsByVM(vm.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list drives of VM %s: %w", vm.ID, err)
+	}
+	for _, drive := range drives {
+		entry.Drives = append(entry.Drives, InventoryDrive{
+			DriveID:    drive.DriveID,
+			PathOnHost: drive.PathOnHost,
+			ReadOnly:   drive.ReadOnly,
+		})
+	}
+
+	hooks, err := s.db.ListLifecycleHooksByVM(vm.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list hooks of VM %s: %w", vm.ID, err)
+	}
+	for _, hook := range hooks {
+		entry.Hooks = append(entry.Hooks, InventoryHook{
+			Event:          hook.Event,
+			Type:           hook.Type,
+			Target:         hook.Target,
+			TimeoutSeconds: hook.TimeoutSeconds,
+		})
+	}
+
+	return entry, nil
+}
+
+// handleImport creates the resources of an exported inventory that don't exist
+// yet. Entries are matched by name and existing ones are left untouched, so an
+// inventory can be imported again after fixing whatever was skipped.
+func (s *Server) handleImport(c *gin.Context) {
+	// An inventory can carry exec hooks, which run on the host
+	if !requireRole(c, RoleAdmin) {
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	var inventory Inventory
+	decoder := yaml.NewDecoder(bytes.NewReader(body))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&inventory); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid inventory: %v", err)})
+		return
+	}
+	if inventory.Version != inventoryVersion {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported inventory version %d", inventory.Version)})
+		return
+	}
+
+	result := &ImportResult{Created: []string{}, Skipped: []ImportSkip{}}
+
+	for _, profile := range inventory.BootProfiles {
+		if err := s.importBootProfile(profile); err != nil {
+			result.skip("boot_profile", profile.Name, err)
+			continue
+		}
+		result.created("boot_profile", profile.Name)
+	}
+
+	for _, profile := range inventory.SizingProfiles {
+		if err := s.importSizingProfile(profile); err != nil {
+			result.skip("sizing_profile", profile.Name, err)
+			continue
+		}
+		result.created("sizing_profile", profile.Name)
+	}
+
+	existing, err := s.vmsByName()
+	if err != nil {
+		s.logger.Errorf("Failed to list VMs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import inventory"})
+		return
+	}
+	for _, entry := range inventory.VMs {
+		if _, exists := existing[entry.Name]; exists {
+			result.skip("vm", entry.Name, errAlreadyExists)
+			continue
+		}
+		if err := s.importVM(entry, result); err != nil {
+			result.skip("vm", entry.Name, err)
+			continue
+		}
+		result.created("vm", entry.Name)
+	}
+
+	containers, err := s.db.ListContainers()
+	if err != nil {
+		s.logger.Errorf("Failed to list containers: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import inventory"})
+		return
+	}
+	containerNames := make(map[string]bool, len(containers))
+	for _, container := range containers {
+		containerNames[container.Name] = true
+	}
+	for _, entry := range inventory.Containers {
+		if containerNames[entry.Name] {
+			result.skip("container", entry.Name, errAlreadyExists)
+			continue
+		}
+		if err := s.importContainer(entry); err != nil {
+			result.skip("container", entry.Name, err)
+			continue
+		}
+		result.created("container", entry.Name)
+	}
+
+	s.logger.Infof("Imported inventory: %d created, %d skipped", len(result.Created), len(result.Skipped))
+	c.JSON(http.StatusOK, result)
+}
+
+func (s *Server) importBootProfile(entry InventoryBootProfile) error {
+	if _, err := s.db.GetBootProfile(entry.Name); err == nil {
+		return errAlreadyExists
+	}
+	if entry.Name == "" || entry.BootArgs == "" {
+		return errors.New("name and boot_args are required")
+	}
+	return s.db.CreateBootProfile(&database.BootProfile{
+		Name:        entry.Name,
+		BootArgs:    entry.BootArgs,
+		Description: entry.Description,
+	})
+}
+
+func (s *Server) importSizingProfile(entry InventorySizingProfile) error {
+	if _, err := s.db.GetSizingProfile(entry.Name); err == nil {
+		return errAlreadyExists
+	}
+	if entry.Name == "" || entry.Memory <= 0 || entry.CPUs <= 0 || entry.DiskSize <= 0 {
+		return errors.New("name, memory, cpus and disk_size are required")
+	}
+	return s.db.CreateSizingProfile(&database.SizingProfile{
+		Name:             entry.Name,
+		Memory:           entry.Memory,
+		CPUs:             entry.CPUs,
+		DiskSize:         entry.DiskSize,
+		Description:      entry.Description,
+		NetBandwidthMbps: entry.NetBandwidthMbps,
+		DiskIOPS:         entry.DiskIOPS,
+	})
+}
+
+// importVM creates a VM the way POST /vms does, through admission webhooks,
+// policies, capacity checks and address leasing, then attaches its drives and
+// registers its hooks. Drives and hooks that fail are reported without
+// failing the VM.
+func (s *Server) importVM(entry InventoryVM, result *ImportResult) error {
+	req := &CreateVMRequest{
+		Name:          entry.Name,
+		Memory:        entry.Memory,
+		CPUs:          entry.CPUs,
+		DiskSize:      entry.DiskSize,
+		BootProfile:   entry.BootProfile,
+		Entropy:       &entry.Entropy,
+		Profile:       entry.Profile,
+		IPAddress:     entry.IPAddress,
+		Labels:        entry.Labels,
+		LogLevel:      entry.LogLevel,
+		LogShowLevel:  &entry.LogShowLevel,
+		LogShowOrigin: &entry.LogShowOrigin,
+		IdleAction:    entry.IdleAction,
+		OutputMode:    entry.OutputMode,
+		RootfsMode:    entry.RootfsMode,
+		RestartPolicy: entry.RestartPolicy,
+	}
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return err
+	}
+
+	vm, err := s.buildVM(context.Background(), req, false)
+	if err != nil {
+		return err
+	}
+	if err := validateEnv(entry.Env); err != nil {
+		return err
+	}
+	if vm.Env, err = encodeSpecField(entry.Env, len(entry.Env) == 0); err != nil {
+		return err
+	}
+	if err := s.evaluatePolicy("vm", policy.OperationCreate, vm, nil); err != nil {
+		return err
+	}
+	if err := s.checkVMFits(req, vm); err != nil {
+		return err
+	}
+
+	if err := s.db.CreateVM(vm); err != nil {
+		s.logger.Errorf("Failed to create VM in database: %v", err)
+		return errors.New("failed to create VM")
+	}
+	if err := s.vmManager.LeaseIPAddress(vm); err != nil {
+		s.db.DeleteVM(vm.ID)
+		return err
+	}
+	if err := s.vmManager.CreateVM(vm); err != nil {
+		s.logger.Errorf("Failed to create VM with Firecracker: %v", err)
+		if errors.Is(err, firecracker.ErrIPInUse) {
+			s.db.DeleteVM(vm.ID)
+			return err
+		}
+		vm.Status = "error"
+		vm.StatusReason = "creation failed: " + err.Error()
+		s.db.UpdateVM(vm)
+		return errors.New("failed to create VM with Firecracker")
+	}
+
+	for _, d := range entry.Drives {
+		name := entry.Name + "/" + d.DriveID
+		if d.DriveID == "rootfs" {
+			result.skip("drive", name, errors.New("drive ID rootfs is reserved"))
+			continue
+		}
+		drive := &database.DriveAttachment{DriveID: d.DriveID, PathOnHost: d.PathOnHost, ReadOnly: d.ReadOnly}
+		if err := s.vmManager.AttachDrive(vm.ID, drive); err != nil {
+			result.skip("drive", name, err)
+			continue
+		}
+		result.created("drive", name)
+	}
+
+	for _, h := range entry.Hooks {
+		name := entry.Name + "/" + h.Event + "/" + h.Type
+		if err := s.importHook(vm.ID, h); err != nil {
+			result.skip("hook", name, err)
+			continue
+		}
+		result.created("hook", name)
+	}
+
+	if entry.Start {
+		if err := s.vmManager.StartVM(vm.ID); err != nil {
+			s.logger.Errorf("Failed to start imported VM %s: %v", vm.ID, err)
+			result.skip("vm_start", entry.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) importHook(vmID string, entry InventoryHook) error {
+	switch entry.Event {
+	case firecracker.HookPreStart, firecracker.HookPostStart, firecracker.HookPreStop, firecracker.HookIdle, firecracker.HookCrash, firecracker.HookDegraded, firecracker.HookDrift:
+	default:
+		return errors.New("event must be pre-start, post-start, pre-stop, idle, crash, degraded or drift")
+	}
+	if err := s.validateHookTarget(entry.Type, entry.Target); err != nil {
+		return err
+	}
+
+	timeout := entry.TimeoutSeconds
+	if timeout == 0 {
+		timeout = int(firecracker.DefaultHookTimeout.Seconds())
+	}
+	if timeout < 0 || timeout > int(firecracker.MaxHookTimeout.Seconds()) {
+		return fmt.Errorf("timeout_seconds must be between 0 and %d", int(firecracker.MaxHookTimeout.Seconds()))
+	}
+
+	return s.db.CreateLifecycleHook(&database.LifecycleHook{
+		ID:             uuid.New().String(),
+		VMID:           vmID,
+		Event:          entry.Event,
+		Type:           entry.Type,
+		Target:         entry.Target,
+		TimeoutSeconds: timeout,
+	})
+}
+
+// importContainer places a container the same way container creation does,
+// on the named VM, a dedicated VM, or wherever the scheduler finds room
+func (s *Server) importContainer(entry InventoryContainer) error {
+	if entry.Image == "" {
+		return errors.New("image is required")
+	}
+	if err := s.validateName("container", entry.Name); err != nil {
+		return err
+	}
+	if err := validateInitSteps(entry.InitSteps); err != nil {
+		return err
+	}
+
+	req := &CreateContainerRequest{
+		Name:        entry.Name,
+		Image:       entry.Image,
+		Ports:       entry.Ports,
+		Environment: entry.Environment,
+		Volumes:     entry.Volumes,
+		InitSteps:   entry.InitSteps,
+		Memory:      entry.Memory,
+		CPUs:        entry.CPUs,
+		Isolation:   entry.Isolation,
+	}
+	if req.Isolation == "" {
+		req.Isolation = isolationShared
+	}
+
+	s.placementMu.Lock()
+	defer s.placementMu.Unlock()
+
+	var placement *database.Placement
+	switch {
+	case req.Isolation == isolationDedicated:
+		if entry.VM != "" || hasDriveVolumes(req.Volumes) {
+			return errors.New("dedicated containers get a new VM and cannot set vm or drive volumes")
+		}
+		vm, err := s.provisionVM(context.Background(), req, true)
+		if err != nil {
+			return err
+		}
+		req.VMID = vm.ID
+		placement = &database.Placement{VMID: vm.ID, VMName: vm.Name, Provisioned: true}
+	case req.Isolation != isolationShared:
+		return errors.New("isolation must be shared or dedicated")
+	case entry.VM == "":
+		vm, p, err := s.placeContainer(context.Background(), req)
+		if err != nil {
+			return err
+		}
+		req.VMID = vm.ID
+		placement = p
+	default:
+		vms, err := s.vmsByName()
+		if err != nil {
+			return err
+		}
+		vm, exists := vms[entry.VM]
+		if !exists {
+			return fmt.Errorf("VM %s not found", entry.VM)
+		}
+		if vm.Status != "running" {
+			return fmt.Errorf("VM %s must be running to deploy containers", entry.VM)
+		}
+		if vm.Quarantined {
+			return fmt.Errorf("VM %s is quarantined", entry.VM)
+		}
+		usage, err := s.containerUsage()
+		if err != nil {
+			return err
+		}
+		if usage[vm.ID].dedicated {
+			return fmt.Errorf("VM %s is dedicated to another container", entry.VM)
+		}
+		if !fits(vm, usage[vm.ID], req.Memory, req.CPUs) {
+			return fmt.Errorf("VM %s does not have enough free memory or CPUs for the container", entry.VM)
+		}
+		if err := s.validateVolumes(vm.ID, req.Volumes); err != nil {
+			return err
+		}
+		req.VMID = vm.ID
+	}
+
+	container := &database.Container{
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		Image:     req.Image,
+		Status:    "creating",
+		VMID:      req.VMID,
+		Memory:    req.Memory,
+		CPUs:      req.CPUs,
+		Isolation: req.Isolation,
+		Revision:  1,
+	}
+	if err := encodeContainerSpec(container, req.Ports, req.Environment, req.Volumes, req.InitSteps); err != nil {
+		return err
+	}
+
+	if err := s.db.CreateContainer(container); err != nil {
+		s.logger.Errorf("Failed to create container in database: %v", err)
+		s.discardPlacement(placement)
+		return errors.New("failed to create container")
+	}
+	if _, err := s.db.CreateContainerRevision(container, "import"); err != nil {
+		s.logger.Errorf("Failed to record revision of container %s: %v", container.ID, err)
+		s.db.DeleteContainer(container.ID)
+		s.discardPlacement(placement)
+		return errors.New("failed to create container")
+	}
+
+	// TODO: Implement actual container creation in VM
+	container.Status = "created"
+	s.db.UpdateContainer(container)
+	return nil
+}
+
+// vmsByName indexes all VMs by name
+func (s *Server) vmsByName() (map[string]*database.VM, error) {
+	vms, err := s.db.ListVMs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VMs: %w", err)
+	}
+	byName := make(map[string]*database.VM, len(vms))
+	for _, vm := range vms {
+		byName[vm.Name] = vm
+	}
+	return byName, nil
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// Job listing page sizes
+const (
+	defaultJobPageSize = 100
+	maxJobPageSize     = 1000
+)
+
+// Job API Handlers
+
+// handleListJobs returns a page of jobs, newest first, filtered by status, type,
+// resource and creation time. The number of matching jobs is in X-Total-Count.
+func (s *Server) handleListJobs(c *gin.Context) {
+	filter := database.JobFilter{
+		Status:     c.Query("status"),
+		Type:       c.Query("type"),
+		ResourceID: c.Query("resource_id"),
+		Limit:      defaultJobPageSize,
+	}
+
+	var err error
+	for param, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := c.Query(param); value != "" {
+			if *t, err = time.Parse(time.RFC3339, value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be an RFC 3339 timestamp"})
+				return
+			}
+		}
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 1 || filter.Limit > maxJobPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+			return
+		}
+	}
+	if offset := c.Query("offset"); offset != "" {
+		if filter.Offset, err = strconv.Atoi(offset); err != nil || filter.Offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+			return
+		}
+	}
+
+	jobs, total, err := s.db.ListJobs(filter)
+	if err != nil {
+		s.logger.Errorf("Failed to list jobs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list jobs"})
+		return
+	}
+
+	if jobs == nil {
+		jobs = []*database.Job{}
+	}
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	c.JSON(http.StatusOK, jobs)
+}
+
+func (s *Server) handleGetJob(c *gin.Context) {
+	jobID := c.Param("id")
+
+	job, err := s.db.GetJob(jobID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// handleRetryJob requeues a dead-lettered job
+func (s *Server) handleRetryJob(c *gin.Context) {
+	jobID := c.Param("id")
+
+	job, err := s.db.GetJob(jobID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	if err := s.db.RetryJob(jobID); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only dead jobs can be retried, job is " + job.Status})
+		return
+	}
+
+	job, err = s.db.GetJob(jobID)
+	if err != nil {
+		s.logger.Errorf("Failed to get job %s: %v", jobID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry job"})
+		return
+	}
+
+	s.logger.Infof("Job %s requeued", jobID)
+	c.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/firecracker"
+	"github.com/gin-gonic/gin"
+)
+
+// Log Handlers
+
+// maxTailLines bounds how many log lines a single request can return
+const maxTailLines = 5000
+
+// handleVMMLog returns the end of a VM's Firecracker log
+func (s *Server) handleVMMLog(c *gin.Context) {
+	vmID := c.Param("id")
+
+	if _, err := s.db.GetVM(vmID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+
+	tail, ok := parseTail(c)
+	if !ok {
+		return
+	}
+
+	lines, err := s.vmManager.TailVMMLog(vmID, tail)
+	if err != nil {
+		s.logger.Errorf("Failed to read Firecracker log of VM %s: %v", vmID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read Firecracker log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"lines": lines})
+}
+
+// handleVMOutput returns the end of a VM's console output
+func (s *Server) handleVMOutput(c *gin.Context) {
+	vmID := c.Param("id")
+
+	vm, err := s.db.GetVM(vmID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+
+	tail, ok := parseTail(c)
+	if !ok {
+		return
+	}
+
+	mode := s.vmManager.OutputMode(vm.OutputMode)
+	lines, err := s.vmManager.TailOutput(vmID, mode, tail)
+	if err != nil {
+		if errors.Is(err, firecracker.ErrOutputDiscarded) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		s.logger.Errorf("Failed to read output of VM %s: %v", vmID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read VM output"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mode": mode, "lines": lines})
+}
+
+// parseTail reads the tail query parameter, defaulting to 200 lines
+func parseTail(c *gin.Context) (int, bool) {
+	tail := 200
+	if value := c.Query("tail"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxTailLines {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tail must be between 1 and 5000"})
+			return 0, false
+		}
+		tail = n
+	}
+	return tail, true
+}
+
+// logPollInterval is how often a followed log file is checked for new lines
+const logPollInterval = 500 * time.Millisecond
+
+// handleAdminLogs streams the orchestrator's own structured logs from the LOG_FILE
+// sink as newline-delimited JSON: the last tail lines, then new lines as they are
+// written when follow=true
+func (s *Server) handleAdminLogs(c *gin.Context) {
+	if !requireRole(c, RoleAdmin) {
+		return
+	}
+
+	if s.config.LogFile == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No log file configured; set LOG_FILE"})
+		return
+	}
+
+	tail, ok := parseTail(c)
+	if !ok {
+		return
+	}
+	follow := c.Query("follow") == "true"
+
+	f, err := os.Open(s.config.LogFile)
+	if err != nil {
+		s.logger.Errorf("Failed to open log file: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open log file"})
+		return
+	}
+	defer f.Close()
+
+	lines, offset, err := tailFile(f, tail)
+	if err != nil {
+		s.logger.Errorf("Failed to read log file: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read log file"})
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	for _, line := range lines {
+		c.Writer.Write(append(line, '\n'))
+	}
+	c.Writer.Flush()
+
+	if !follow {
+		return
+	}
+
+	ticker := time.NewTicker(logPollInterval)
+	defer ticker.Stop()
+
+	var partial []byte
+	buf := make([]byte, 32*1024)
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Start over if the file was truncated or rotated in place
+		if info, err := f.Stat(); err == nil && info.Size() < offset {
+			offset, partial = 0, nil
+		}
+
+		for {
+			n, err := f.ReadAt(buf, offset)
+			offset += int64(n)
+			partial = append(partial, buf[:n]...)
+			if err != nil || n == 0 {
+				break
+			}
+		}
+
+		// Only send complete lines
+		if i := bytes.LastIndexByte(partial, '\n'); i >= 0 {
+			c.Writer.Write(partial[:i+1])
+			c.Writer.Flush()
+			partial = append([]byte(nil), partial[i+1:]...)
+		}
+	}
+}
+
+// tailFile returns the last n complete lines of a file and the offset just after
+// them, reading backwards from the end so large files aren't scanned in full
+func tailFile(f *os.File, n int) ([][]byte, int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	size := info.Size()
+
+	// Ignore a trailing line that is still being written
+	end := size
+	var data []byte
+	const chunk = 64 * 1024
+	for pos := size; pos > 0 && bytes.Count(data, []byte{'\n'}) <= n; {
+		read := int64(chunk)
+		if pos < read {
+			read = pos
+		}
+		pos -= read
+		buf := make([]byte, read)
+		if _, err := f.ReadAt(buf, pos); err != nil && err != io.EOF {
+			return nil, 0, err
+		}
+		data = append(buf, data...)
+	}
+
+	if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+		end -= int64(len(data) - i - 1)
+		data = data[:i]
+	} else {
+		return nil, 0, nil
+	}
+
+	lines := bytes.Split(data, []byte{'\n'})
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, end, nil
+}
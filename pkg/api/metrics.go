@@ -0,0 +1,174 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/firecracker"
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics Handlers
+
+func (s *Server) handleVMMetrics(c *gin.Context) {
+	vmID := c.Param("id")
+
+	vm, err := s.db.GetVM(vmID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+
+	response := gin.H{
+		"vm_id":           vm.ID,
+		"network_history": s.vmManager.NetworkHistory(vmID),
+	}
+
+	// Live counters are only available while the TAP device exists
+	if stats, err := s.vmManager.NetworkStats(vmID); err == nil {
+		response["network"] = stats
+	}
+	if activity, ok := s.vmManager.VMActivity(vmID); ok {
+		response["activity"] = activity
+	}
+	if cpu, ok := s.vmManager.AllCPUStats()[vmID]; ok {
+		response["cpu"] = cpu
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// handlePrometheusMetrics exposes per-VM counters in the Prometheus text format
+func (s *Server) handlePrometheusMetrics(c *gin.Context) {
+	vms, err := s.db.ListVMs()
+	if err != nil {
+		s.logger.Errorf("Failed to list VMs for metrics: %v", err)
+		c.String(http.StatusInternalServerError, "failed to list VMs\n")
+		return
+	}
+
+	names := make(map[string]string, len(vms))
+	for _, vm := range vms {
+		names[vm.ID] = vm.Name
+	}
+
+	stats := s.vmManager.AllNetworkStats()
+	ids := make([]string, 0, len(stats))
+	for id := range stats {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var out strings.Builder
+	metrics := []struct {
+		name  string
+		help  string
+		value func(id string) uint64
+	}{
+		{"firecracker_vm_network_receive_bytes_total", "Bytes received by the guest.", func(id string) uint64 { return stats[id].RxBytes }},
+		{"firecracker_vm_network_transmit_bytes_total", "Bytes transmitted by the guest.", func(id string) uint64 { return stats[id].TxBytes }},
+		{"firecracker_vm_network_receive_packets_total", "Packets received by the guest.", func(id string) uint64 { return stats[id].RxPackets }},
+		{"firecracker_vm_network_transmit_packets_total", "Packets transmitted by the guest.", func(id string) uint64 { return stats[id].TxPackets }},
+	}
+	for _, metric := range metrics {
+		fmt.Fprintf(&out, "# HELP %s %s\n# TYPE %s counter\n", metric.name, metric.help, metric.name)
+		for _, id := range ids {
+			fmt.Fprintf(&out, "%s{vm_id=%q,vm_name=%q,tap=%q} %d\n", metric.name, id, names[id], stats[id].TAPDevice, metric.value(id))
+		}
+	}
+
+	writeCPUMetrics(&out, s.vmManager.AllCPUStats(), names)
+	writeAPILatencyMetrics(&out)
+
+	if err := s.writeClockSkewMetrics(&out, names); err != nil {
+		s.logger.Errorf("Failed to list guest info for metrics: %v", err)
+		c.String(http.StatusInternalServerError, "failed to list guest info\n")
+		return
+	}
+
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(out.String()))
+}
+
+// writeClockSkewMetrics writes the guest clock skew last reported by each VM's agent
+func (s *Server) writeClockSkewMetrics(out *strings.Builder, names map[string]string) error {
+	infos, err := s.db.ListGuestInfo()
+	if err != nil {
+		return err
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].VMID < infos[j].VMID })
+
+	fmt.Fprintf(out, "# HELP firecracker_vm_clock_skew_seconds Guest clock minus host clock at the last agent report.\n# TYPE firecracker_vm_clock_skew_seconds gauge\n")
+	for _, info := range infos {
+		fmt.Fprintf(out, "firecracker_vm_clock_skew_seconds{vm_id=%q,vm_name=%q} %g\n", info.VMID, names[info.VMID], float64(info.ClockSkewMS)/1000)
+	}
+
+	fmt.Fprintf(out, "# HELP firecracker_vm_clock_skewed Whether the guest clock skew exceeds CLOCK_SKEW_THRESHOLD_MS.\n# TYPE firecracker_vm_clock_skewed gauge\n")
+	for _, info := range infos {
+		skewed := 0
+		if s.checkClockSkew(info) {
+			skewed = 1
+		}
+		fmt.Fprintf(out, "firecracker_vm_clock_skewed{vm_id=%q,vm_name=%q} %d\n", info.VMID, names[info.VMID], skewed)
+	}
+	return nil
+}
+
+// writeCPUMetrics writes each running VM's vCPU run and wait time, the CPU
+// time of the rest of its Firecracker process, and histograms of how busy and
+// how starved of host CPU its vCPUs were per sampling interval
+func writeCPUMetrics(out *strings.Builder, stats map[string]*firecracker.CPUStats, names map[string]string) {
+	ids := make([]string, 0, len(stats))
+	for id := range stats {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	fmt.Fprintf(out, "# HELP firecracker_vm_vcpu_run_seconds_total Host CPU time spent running the vCPU thread.\n# TYPE firecracker_vm_vcpu_run_seconds_total counter\n")
+	for _, id := range ids {
+		for _, vcpu := range stats[id].VCPUs {
+			fmt.Fprintf(out, "firecracker_vm_vcpu_run_seconds_total{vm_id=%q,vm_name=%q,vcpu=\"%d\"} %g\n", id, names[id], vcpu.Index, vcpu.RunSeconds)
+		}
+	}
+
+	fmt.Fprintf(out, "# HELP firecracker_vm_vcpu_wait_seconds_total Time the vCPU thread was runnable but waiting for a host CPU.\n# TYPE firecracker_vm_vcpu_wait_seconds_total counter\n")
+	for _, id := range ids {
+		for _, vcpu := range stats[id].VCPUs {
+			fmt.Fprintf(out, "firecracker_vm_vcpu_wait_seconds_total{vm_id=%q,vm_name=%q,vcpu=\"%d\"} %g\n", id, names[id], vcpu.Index, vcpu.WaitSeconds)
+		}
+	}
+
+	fmt.Fprintf(out, "# HELP firecracker_vm_vmm_cpu_seconds_total Host CPU time of the Firecracker process outside its vCPU threads.\n# TYPE firecracker_vm_vmm_cpu_seconds_total counter\n")
+	for _, id := range ids {
+		fmt.Fprintf(out, "firecracker_vm_vmm_cpu_seconds_total{vm_id=%q,vm_name=%q} %g\n", id, names[id], stats[id].VMMSeconds)
+	}
+
+	fmt.Fprintf(out, "# HELP firecracker_vm_vcpu_utilization Share of each sampling interval a vCPU spent running.\n# TYPE firecracker_vm_vcpu_utilization histogram\n")
+	for _, id := range ids {
+		writeHistogram(out, "firecracker_vm_vcpu_utilization", fmt.Sprintf("vm_id=%q,vm_name=%q", id, names[id]), stats[id].Utilization)
+	}
+
+	fmt.Fprintf(out, "# HELP firecracker_vm_vcpu_wait_ratio Share of each sampling interval a vCPU spent waiting for a host CPU.\n# TYPE firecracker_vm_vcpu_wait_ratio histogram\n")
+	for _, id := range ids {
+		writeHistogram(out, "firecracker_vm_vcpu_wait_ratio", fmt.Sprintf("vm_id=%q,vm_name=%q", id, names[id]), stats[id].WaitRatio)
+	}
+}
+
+// writeAPILatencyMetrics writes how long Firecracker API socket calls took
+func writeAPILatencyMetrics(out *strings.Builder) {
+	fmt.Fprintf(out, "# HELP firecracker_api_request_duration_seconds Latency of Firecracker API socket calls.\n# TYPE firecracker_api_request_duration_seconds histogram\n")
+	for _, latency := range firecracker.APILatencies() {
+		writeHistogram(out, "firecracker_api_request_duration_seconds", fmt.Sprintf("method=%q,endpoint=%q", latency.Method, latency.Endpoint), latency.Seconds)
+	}
+}
+
+// writeHistogram writes the bucket, sum and count series of a histogram
+func writeHistogram(out *strings.Builder, name, labels string, h *firecracker.Histogram) {
+	for i, bound := range h.Buckets {
+		fmt.Fprintf(out, "%s_bucket{%s,le=\"%g\"} %d\n", name, labels, bound, h.Counts[i])
+	}
+	fmt.Fprintf(out, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.Count)
+	fmt.Fprintf(out, "%s_sum{%s} %g\n", name, labels, h.Sum)
+	fmt.Fprintf(out, "%s_count{%s} %d\n", name, labels, h.Count)
+}
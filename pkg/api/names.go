@@ -0,0 +1,171 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxNameLength is the longest RFC 1123 DNS label
+const maxNameLength = 63
+
+// namePattern matches RFC 1123 DNS labels in lower case
+var namePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// invalidNameChars matches runs of characters a DNS label can't contain
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// nameLimit is the configured maximum name length, capped at a DNS label
+func (s *Server) nameLimit() int {
+	if s.config.NameMaxLength <= 0 || s.config.NameMaxLength > maxNameLength {
+		return maxNameLength
+	}
+	return s.config.NameMaxLength
+}
+
+// validateName checks that a VM or container name is usable as a DNS label
+func (s *Server) validateName(kind, name string) error {
+	limit := s.nameLimit()
+	if len(name) > limit {
+		return fmt.Errorf("%s name %q is %d characters long; the limit is %d", kind, name, len(name), limit)
+	}
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("%s name %q is not DNS-safe: use lowercase letters, digits and '-', starting and ending with a letter or digit (for example %q)",
+			kind, name, suggestName(name, limit))
+	}
+	return nil
+}
+
+// suggestName turns a name into the closest DNS-safe one
+func suggestName(name string, limit int) string {
+	suggested := invalidNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	suggested = strings.Trim(suggested, "-")
+	if len(suggested) > limit {
+		suggested = strings.TrimRight(suggested[:limit], "-")
+	}
+	if suggested == "" {
+		return "unnamed"
+	}
+	return suggested
+}
+
+// InvalidName is an existing record whose name predates name validation
+type InvalidName struct {
+	Kind      string `json:"kind"` // vm or container
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Suggested string `json:"suggested"`
+}
+
+// invalidNames lists VMs and containers whose names aren't DNS-safe, with
+// suggestions that are unique among the records of their kind
+func (s *Server) invalidNames() ([]InvalidName, error) {
+	vms, err := s.db.ListVMs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VMs: %w", err)
+	}
+	containers, err := s.db.ListContainers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	limit := s.nameLimit()
+	invalid := []InvalidName{}
+
+	taken := map[string]bool{}
+	for _, vm := range vms {
+		taken[vm.Name] = true
+	}
+	for _, vm := range vms {
+		if s.validateName("VM", vm.Name) != nil {
+			invalid = append(invalid, InvalidName{Kind: "vm", ID: vm.ID, Name: vm.Name, Suggested: uniqueName(suggestName(vm.Name, limit), limit, taken)})
+		}
+	}
+
+	taken = map[string]bool{}
+	for _, container := range containers {
+		taken[container.Name] = true
+	}
+	for _, container := range containers {
+		if s.validateName("container", container.Name) != nil {
+			invalid = append(invalid, InvalidName{Kind: "container", ID: container.ID, Name: container.Name, Suggested: uniqueName(suggestName(container.Name, limit), limit, taken)})
+		}
+	}
+
+	return invalid, nil
+}
+
+// uniqueName numbers a name until it is not taken, and takes it
+func uniqueName(name string, limit int, taken map[string]bool) string {
+	candidate := name
+	for i := 2; taken[candidate]; i++ {
+		suffix := "-" + strconv.Itoa(i)
+		base := name
+		if len(base)+len(suffix) > limit {
+			base = strings.TrimRight(base[:limit-len(suffix)], "-")
+		}
+		candidate = base + suffix
+	}
+	taken[candidate] = true
+	return candidate
+}
+
+// handleListInvalidNames reports records created before names were validated
+func (s *Server) handleListInvalidNames(c *gin.Context) {
+	invalid, err := s.invalidNames()
+	if err != nil {
+		s.logger.Errorf("Failed to check names: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check names"})
+		return
+	}
+
+	c.JSON(http.StatusOK, invalid)
+}
+
+// handleNormalizeNames renames every record with an invalid name to its suggestion
+func (s *Server) handleNormalizeNames(c *gin.Context) {
+	if !requireRole(c, RoleAdmin) {
+		return
+	}
+
+	invalid, err := s.invalidNames()
+	if err != nil {
+		s.logger.Errorf("Failed to check names: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check names"})
+		return
+	}
+
+	for _, entry := range invalid {
+		if err := s.rename(entry); err != nil {
+			s.logger.Errorf("Failed to rename %s %s: %v", entry.Kind, entry.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rename " + entry.Kind + " " + entry.ID})
+			return
+		}
+		s.logger.Infof("Renamed %s %s from %q to %q", entry.Kind, entry.ID, entry.Name, entry.Suggested)
+	}
+
+	c.JSON(http.StatusOK, invalid)
+}
+
+// rename gives a record its suggested name
+func (s *Server) rename(entry InvalidName) error {
+	if entry.Kind == "vm" {
+		vm, err := s.db.GetVM(entry.ID)
+		if err != nil {
+			return err
+		}
+		vm.Name = entry.Suggested
+		return s.db.UpdateVM(vm)
+	}
+
+	container, err := s.db.GetContainer(entry.ID)
+	if err != nil {
+		return err
+	}
+	container.Name = entry.Suggested
+	return s.db.UpdateContainer(container)
+}
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/firecracker"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/policy"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// errNoPlacement is returned when no VM can take a container
+var errNoPlacement = errors.New("no running VM has the capacity, labels and drives the container needs")
+
+// Container isolation modes
+const (
+	isolationShared    = "shared"
+	isolationDedicated = "dedicated"
+)
+
+// dedicatedVMOverheadMB is added to a dedicated container's memory request to
+// leave room for the guest kernel and container runtime
+const dedicatedVMOverheadMB = 128
+
+// vmUsage is the memory and CPU already requested by containers on a VM
+type vmUsage struct {
+	memory    int64
+	cpus      int
+	dedicated bool // the VM belongs to a dedicated container
+}
+
+// placeContainer picks the running VM with the most free memory that satisfies the
+// container's resource requests, VM selector and drive volumes. When none fits and
+// AUTO_PROVISION_VMS is set, a VM sized for the container is created and started.
+// The caller holds placementMu until the container is saved.
+func (s *Server) placeContainer(ctx context.Context, req *CreateContainerRequest) (*database.VM, *database.Placement, error) {
+	vms, err := s.db.ListVMs()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list VMs: %w", err)
+	}
+	usage, err := s.containerUsage()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var best *database.VM
+	var bestFree int64
+	for _, vm := range vms {
+		if vm.Status != "running" || vm.Quarantined || usage[vm.ID].dedicated || !matchLabels(vm.Labels, req.VMSelector) {
+			continue
+		}
+		if !fits(vm, usage[vm.ID], req.Memory, req.CPUs) {
+			continue
+		}
+		if s.validateVolumes(vm.ID, req.Volumes) != nil {
+			continue
+		}
+		free := vm.Memory - usage[vm.ID].memory
+		if best == nil || free > bestFree {
+			best, bestFree = vm, free
+		}
+	}
+
+	if best != nil {
+		return best, &database.Placement{VMID: best.ID, VMName: best.Name}, nil
+	}
+
+	// A new VM has no data drives, so containers mounting one can't be provisioned for
+	if !s.config.AutoProvisionVMs || hasDriveVolumes(req.Volumes) {
+		return nil, nil, errNoPlacement
+	}
+
+	vm, err := s.provisionVM(ctx, req, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return vm, &database.Placement{VMID: vm.ID, VMName: vm.Name, Provisioned: true}, nil
+}
+
+// containerUsage sums the resources requested by containers on each VM
+func (s *Server) containerUsage() (map[string]vmUsage, error) {
+	containers, err := s.db.ListContainers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	usage := make(map[string]vmUsage)
+	for _, container := range containers {
+		u := usage[container.VMID]
+		u.memory += container.Memory
+		u.cpus += container.CPUs
+		u.dedicated = u.dedicated || container.Isolation == isolationDedicated
+		usage[container.VMID] = u
+	}
+	return usage, nil
+}
+
+// fits reports whether a VM has room for the requested memory and CPUs
+func fits(vm *database.VM, used vmUsage, memory int64, cpus int) bool {
+	return used.memory+memory <= vm.Memory && used.cpus+cpus <= vm.CPUs
+}
+
+// matchLabels reports whether a VM's JSON-encoded labels include every selector entry
+func matchLabels(encoded string, selector map[string]string) bool {
+	if len(selector) == 0 {
+		return true
+	}
+
+	labels := map[string]string{}
+	if encoded != "" {
+		if err := json.Unmarshal([]byte(encoded), &labels); err != nil {
+			return false
+		}
+	}
+
+	for key, value := range selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// hasDriveVolumes reports whether any volume mounts a VM data drive
+func hasDriveVolumes(volumes []VolumeMount) bool {
+	for _, volume := range volumes {
+		if volume.Type == "drive" {
+			return true
+		}
+	}
+	return false
+}
+
+// provisionVM creates and starts a VM large enough for a container, labelled
+// with the container's VM selector so later containers can be placed on it.
+// Dedicated VMs are sized to the container alone rather than the VM defaults.
+// The VM goes through the same admission webhooks, policies, capacity checks
+// and defaults as one created with POST /vms, and is deleted again if it
+// can't be created and started.
+func (s *Server) provisionVM(ctx context.Context, req *CreateContainerRequest, dedicated bool) (*database.VM, error) {
+	suffix := uuid.New().String()[:8]
+	vmReq := &CreateVMRequest{
+		Name:   "auto-" + suffix,
+		Labels: req.VMSelector,
+	}
+	if dedicated {
+		vmReq.Name = "dedicated-" + suffix
+		if req.Memory > 0 {
+			vmReq.Memory = req.Memory + dedicatedVMOverheadMB
+		}
+		if req.CPUs > 0 {
+			vmReq.CPUs = req.CPUs
+		}
+	} else {
+		if req.Memory > defaultVMMemoryMB {
+			vmReq.Memory = req.Memory
+		}
+		if req.CPUs > defaultVMCPUs {
+			vmReq.CPUs = req.CPUs
+		}
+	}
+
+	vm, err := s.buildVM(ctx, vmReq, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.evaluatePolicy("vm", policy.OperationCreate, vm, nil); err != nil {
+		return nil, err
+	}
+	if err := s.checkVMFits(vmReq, vm); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.CreateVM(vm); err != nil {
+		return nil, fmt.Errorf("failed to create VM in database: %w", err)
+	}
+	// A mutating admission webhook may have given it a static address
+	if err := s.vmManager.LeaseIPAddress(vm); err != nil {
+		s.db.DeleteVM(vm.ID)
+		return nil, err
+	}
+
+	if err := s.vmManager.CreateVM(vm); err != nil {
+		s.discardVM(vm.ID)
+		return nil, fmt.Errorf("failed to create VM with Firecracker: %w", err)
+	}
+	if err := s.vmManager.StartVM(vm.ID); err != nil {
+		s.discardVM(vm.ID)
+		return nil, fmt.Errorf("failed to start VM: %w", err)
+	}
+
+	s.logger.Infof("Provisioned VM %s for container %s", vm.ID, req.Name)
+	return s.db.GetVM(vm.ID)
+}
+
+// discardVM deletes a VM provisioned for a container that wasn't created after all
+func (s *Server) discardVM(vmID string) {
+	if err := s.vmManager.DeleteVM(vmID); err != nil {
+		s.logger.Errorf("Failed to delete provisioned VM %s: %v", vmID, err)
+	}
+}
+
+// discardPlacement deletes the VM a placement provisioned, if it did
+func (s *Server) discardPlacement(placement *database.Placement) {
+	if placement != nil && placement.Provisioned {
+		s.discardVM(placement.VMID)
+	}
+}
+
+// respondPlacementError maps container placement and VM provisioning errors
+// to client responses
+func (s *Server) respondPlacementError(c *gin.Context, containerName string, err error) {
+	var invalid *requestError
+	var violations *PolicyViolations
+	var denied *AdmissionDenied
+	switch {
+	case errors.Is(err, errNoPlacement), errors.Is(err, firecracker.ErrInsufficientCapacity), errors.Is(err, firecracker.ErrIPInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, &invalid), errors.As(err, &violations), errors.As(err, &denied), errors.Is(err, errAdmissionUnavailable):
+		s.respondVMError(c, err)
+	default:
+		s.logger.Errorf("Failed to place container %s: %v", containerName, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to place container"})
+	}
+}
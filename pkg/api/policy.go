@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/policy"
+	"github.com/gin-gonic/gin"
+)
+
+// policyJSONFields are resource fields stored as JSON strings, decoded before
+// rules see them so that object.labels.team reads a label
+var policyJSONFields = map[string][]string{
+	"vm":        {"labels", "env", "ignition"},
+	"container": {"ports", "environment", "volumes", "init_steps"},
+}
+
+// PolicyViolations is returned when a create or update breaks resource policies
+type PolicyViolations struct {
+	Violations []policy.Violation
+}
+
+func (e *PolicyViolations) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Rule + ": " + v.Message
+	}
+	return "violates policy: " + strings.Join(messages, "; ")
+}
+
+// evaluatePolicy evaluates the resource policies for a create or update and
+// returns a *PolicyViolations error naming the violated rules if there are
+// any. object and oldObject are the database records as they would be after
+// and were before the request; oldObject is nil on create.
+func (s *Server) evaluatePolicy(resource, operation string, object, oldObject interface{}) error {
+	if s.policies.Len() == 0 {
+		return nil
+	}
+
+	current, err := policyObject(resource, object)
+	if err != nil {
+		return fmt.Errorf("failed to prepare %s for policy evaluation: %w", resource, err)
+	}
+	var previous map[string]interface{}
+	if oldObject != nil {
+		if previous, err = policyObject(resource, oldObject); err != nil {
+			return fmt.Errorf("failed to prepare %s for policy evaluation: %w", resource, err)
+		}
+	}
+
+	violations := s.policies.Evaluate(resource, operation, current, previous)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	s.logger.Warnf("Rejected %s %s %v: %d policy violations", operation, resource, current["name"], len(violations))
+	return &PolicyViolations{Violations: violations}
+}
+
+// checkPolicy is evaluatePolicy for handlers: it responds 403 with the
+// violated rules, or 500, and returns false when the request can't go ahead
+func (s *Server) checkPolicy(c *gin.Context, resource, operation string, object, oldObject interface{}) bool {
+	if err := s.evaluatePolicy(resource, operation, object, oldObject); err != nil {
+		s.respondPolicyError(c, err)
+		return false
+	}
+	return true
+}
+
+// respondPolicyError maps policy evaluation errors to client responses
+func (s *Server) respondPolicyError(c *gin.Context, err error) {
+	var violations *PolicyViolations
+	if errors.As(err, &violations) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Request violates policy", "violations": violations.Violations})
+		return
+	}
+	s.logger.Errorf("Failed to evaluate policies: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate policies"})
+}
+
+// policyObject converts a record to the map rules are evaluated against, as it
+// is returned by the API but with its JSON string fields decoded
+func policyObject(resource string, record interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+	// Rules still see the VM fields API responses leave out
+	if vm, ok := record.(*database.VM); ok {
+		object["env"], object["ignition"] = vm.Env, vm.Ignition
+	}
+
+	for _, field := range policyJSONFields[resource] {
+		encoded, _ := object[field].(string)
+		if encoded == "" {
+			object[field] = nil
+			continue
+		}
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(encoded), &decoded); err != nil {
+			return nil, err
+		}
+		object[field] = decoded
+	}
+	return object, nil
+}
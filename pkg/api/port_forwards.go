@@ -0,0 +1,98 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/firecracker"
+	"github.com/gin-gonic/gin"
+)
+
+// Port Forward API Handlers
+
+type CreatePortForwardRequest struct {
+	Protocol  string `json:"protocol" binding:"omitempty,oneof=tcp udp"`
+	HostPort  int    `json:"host_port" binding:"required,min=1,max=65535"`
+	GuestPort int    `json:"guest_port" binding:"required,min=1,max=65535"`
+}
+
+func (s *Server) handleListPortForwards(c *gin.Context) {
+	vmID := c.Param("id")
+
+	if _, err := s.db.GetVM(vmID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+
+	forwards, err := s.db.ListPortForwardsByVM(vmID)
+	if err != nil {
+		s.logger.Errorf("Failed to list port forwards for VM %s: %v", vmID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list port forwards"})
+		return
+	}
+
+	c.JSON(http.StatusOK, forwards)
+}
+
+func (s *Server) handleCreatePortForward(c *gin.Context) {
+	vmID := c.Param("id")
+
+	vm, err := s.db.GetVM(vmID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+	if vm.IPAddress == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "VM has no IP address yet"})
+		return
+	}
+
+	var req CreatePortForwardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Protocol == "" {
+		req.Protocol = "tcp"
+	}
+
+	pf := &database.PortForward{
+		Protocol:  req.Protocol,
+		HostPort:  req.HostPort,
+		GuestPort: req.GuestPort,
+	}
+
+	if err := s.vmManager.AddPortForward(vmID, pf); err != nil {
+		s.logger.Errorf("Failed to add port forward to VM %s: %v", vmID, err)
+		if errors.Is(err, firecracker.ErrPortNotAllowed) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, firecracker.ErrPortInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add port forward"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, pf)
+}
+
+func (s *Server) handleDeletePortForward(c *gin.Context) {
+	vmID := c.Param("id")
+	forwardID := c.Param("forward_id")
+
+	if err := s.vmManager.RemovePortForward(vmID, forwardID); err != nil {
+		if errors.Is(err, firecracker.ErrPortForwardNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Port forward not found"})
+			return
+		}
+		s.logger.Errorf("Failed to remove port forward %s of VM %s: %v", forwardID, vmID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove port forward"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Port forward deleted successfully"})
+}
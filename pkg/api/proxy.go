@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// Guest Port Proxy Handlers
+
+// tunnelUpgrader only accepts tunnels opened from pages served by the
+// orchestrator itself; otherwise any site a signed in user visits could use
+// their session cookie to reach into guests. Clients that send no Origin
+// header, like websocat, are unaffected.
+var tunnelUpgrader = websocket.Upgrader{
+	ReadBufferSize:  32 * 1024,
+	WriteBufferSize: 32 * 1024,
+	CheckOrigin:     sameOrigin,
+}
+
+// sameOrigin reports whether a request carries no Origin header or one naming
+// the host it was sent to
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// guestAddress resolves the host:port of a service inside a running VM
+func (s *Server) guestAddress(c *gin.Context) (string, bool) {
+	vm, err := s.db.GetVM(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return "", false
+	}
+
+	if vm.Status != "running" || vm.IPAddress == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "VM must be running to proxy to it"})
+		return "", false
+	}
+	if vm.Quarantined {
+		c.JSON(http.StatusConflict, gin.H{"error": "VM is quarantined"})
+		return "", false
+	}
+
+	port, err := strconv.Atoi(c.Param("port"))
+	if err != nil || port < 1 || port > 65535 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid port"})
+		return "", false
+	}
+
+	return net.JoinHostPort(vm.IPAddress, strconv.Itoa(port)), true
+}
+
+// handleProxyHTTP forwards an HTTP request to a port inside the VM
+func (s *Server) handleProxyHTTP(c *gin.Context) {
+	target, ok := s.guestAddress(c)
+	if !ok {
+		return
+	}
+
+	prefix := strings.TrimSuffix(c.Request.URL.Path, c.Param("path"))
+
+	proxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = "http"
+			req.URL.Host = target
+			req.URL.Path = c.Param("path")
+			req.URL.RawPath = ""
+			req.Host = target
+			req.Header.Set("X-Forwarded-Prefix", prefix)
+			stripCredentials(req)
+		},
+		ModifyResponse: sandboxResponse,
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			s.logger.Warnf("Proxy to %s failed: %v", target, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprintf(w, `{"error":%q}`, "Failed to reach guest service")
+		},
+	}
+
+	proxy.ServeHTTP(c.Writer, c.Request)
+}
+
+// sandboxResponse keeps a guest's response from acting on the orchestrator's
+// origin, which it is served from: pages and scripts are sandboxed into an
+// opaque origin so they can't call the API with the viewer's session, and
+// the guest can't set cookies on the orchestrator's domain
+func sandboxResponse(resp *http.Response) error {
+	resp.Header.Del("Set-Cookie")
+	resp.Header.Set("Content-Security-Policy", "sandbox")
+	return nil
+}
+
+// stripCredentials removes the caller's orchestrator credentials from a request
+// before it is forwarded into a guest, which must not be able to replay them
+func stripCredentials(req *http.Request) {
+	req.Header.Del("Authorization")
+
+	cookies := req.Cookies()
+	req.Header.Del("Cookie")
+	for _, cookie := range cookies {
+		if cookie.Name != sessionCookie {
+			req.AddCookie(cookie)
+		}
+	}
+}
+
+// handleProxyTCP tunnels a raw TCP connection to a port inside the VM over a
+// WebSocket, carrying the stream in binary messages
+func (s *Server) handleProxyTCP(c *gin.Context) {
+	target, ok := s.guestAddress(c)
+	if !ok {
+		return
+	}
+
+	backend, err := net.DialTimeout("tcp", target, 10*time.Second)
+	if err != nil {
+		s.logger.Warnf("Tunnel to %s failed: %v", target, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to reach guest service"})
+		return
+	}
+	defer backend.Close()
+
+	ws, err := tunnelUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already written an error response
+		return
+	}
+	defer ws.Close()
+
+	// Close the tunnel when the server shuts down; hijacked connections
+	// aren't drained by http.Server.Shutdown
+	stop := context.AfterFunc(c.Request.Context(), func() {
+		ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(time.Second))
+		backend.Close()
+		ws.Close()
+	})
+	defer stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := backend.Read(buf)
+			if n > 0 {
+				if werr := ws.WriteMessage(websocket.BinaryMessage, buf[:n]); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+				return
+			}
+		}
+	}()
+
+	for {
+		_, reader, err := ws.NextReader()
+		if err != nil {
+			break
+		}
+		if _, err := io.Copy(backend, reader); err != nil {
+			break
+		}
+	}
+
+	backend.Close()
+	<-done
+}
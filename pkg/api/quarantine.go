@@ -0,0 +1,70 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QuarantineRequest struct {
+	Reason string `json:"reason"`
+}
+
+// handleQuarantineVM cuts a VM off the network but keeps it running for forensics
+func (s *Server) handleQuarantineVM(c *gin.Context) {
+	vmID := c.Param("id")
+
+	if _, err := s.db.GetVM(vmID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+
+	// The reason is optional, so an empty body is accepted
+	var req QuarantineRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.vmManager.Quarantine(vmID, req.Reason); err != nil {
+		s.logger.Errorf("Failed to quarantine VM %s: %v", vmID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to quarantine VM"})
+		return
+	}
+
+	vm, err := s.db.GetVM(vmID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+	c.JSON(http.StatusOK, vm)
+}
+
+func (s *Server) handleUnquarantineVM(c *gin.Context) {
+	vmID := c.Param("id")
+
+	vm, err := s.db.GetVM(vmID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+	if !vm.Quarantined {
+		c.JSON(http.StatusConflict, gin.H{"error": "VM is not quarantined"})
+		return
+	}
+
+	if err := s.vmManager.Unquarantine(vmID); err != nil {
+		s.logger.Errorf("Failed to release VM %s from quarantine: %v", vmID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release VM from quarantine"})
+		return
+	}
+
+	vm, err = s.db.GetVM(vmID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+	c.JSON(http.StatusOK, vm)
+}
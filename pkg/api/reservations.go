@@ -0,0 +1,119 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/firecracker"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Reservation lifetimes
+const (
+	defaultReservationTTL = time.Hour
+	maxReservationTTL     = 24 * time.Hour
+)
+
+// CreateReservationRequest represents a request to hold back capacity for VMs
+// that will be created later
+type CreateReservationRequest struct {
+	Name       string `json:"name"`
+	Memory     int64  `json:"memory"`      // MB
+	CPUs       int    `json:"cpus"`        // vCPUs
+	IPCount    int    `json:"ip_count"`    // guest addresses to hold
+	TTLSeconds int    `json:"ttl_seconds"` // defaults to an hour, at most a day
+}
+
+// Reservation API Handlers
+
+func (s *Server) handleCreateReservation(c *gin.Context) {
+	var req CreateReservationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Memory < 0 || req.CPUs < 0 || req.IPCount < 0 || req.TTLSeconds < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "memory, cpus, ip_count and ttl_seconds can't be negative"})
+		return
+	}
+	if req.Memory == 0 && req.CPUs == 0 && req.IPCount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reserve at least some memory, cpus or ip_count"})
+		return
+	}
+
+	ttl := defaultReservationTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	if ttl > maxReservationTTL {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ttl_seconds can be at most 86400"})
+		return
+	}
+
+	reservation := &database.Reservation{
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		Memory:    req.Memory,
+		CPUs:      req.CPUs,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	if err := s.vmManager.CreateReservation(reservation, req.IPCount); err != nil {
+		if errors.Is(err, firecracker.ErrInsufficientCapacity) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		s.logger.Errorf("Failed to create reservation: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reservation"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, reservation)
+}
+
+func (s *Server) handleListReservations(c *gin.Context) {
+	reservations, err := s.db.ListReservations()
+	if err != nil {
+		s.logger.Errorf("Failed to list reservations: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list reservations"})
+		return
+	}
+
+	if reservations == nil {
+		reservations = []*database.Reservation{}
+	}
+	c.JSON(http.StatusOK, reservations)
+}
+
+func (s *Server) handleGetReservation(c *gin.Context) {
+	reservation, err := s.db.GetReservation(c.Param("id"))
+	if err != nil || reservation.Expired() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, reservation)
+}
+
+// handleDeleteReservation releases whatever a reservation still holds
+func (s *Server) handleDeleteReservation(c *gin.Context) {
+	reservationID := c.Param("id")
+
+	found, err := s.db.DeleteReservation(reservationID)
+	if err != nil {
+		s.logger.Errorf("Failed to delete reservation %s: %v", reservationID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete reservation"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
+		return
+	}
+
+	s.logger.Infof("Reservation %s released", reservationID)
+	c.JSON(http.StatusOK, gin.H{"message": "Reservation released"})
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/policy"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) handleListContainerRevisions(c *gin.Context) {
+	containerID := c.Param("id")
+
+	if _, err := s.db.GetContainer(containerID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Container not found"})
+		return
+	}
+
+	revisions, err := s.db.ListContainerRevisions(containerID)
+	if err != nil {
+		s.logger.Errorf("Failed to list revisions of container %s: %v", containerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list container revisions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// handleRollbackContainer redeploys the image, environment, ports, volumes, init
+// steps and resources of an earlier revision (the previous one by default) as a new revision
+func (s *Server) handleRollbackContainer(c *gin.Context) {
+	containerID := c.Param("id")
+
+	container, err := s.db.GetContainer(containerID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Container not found"})
+		return
+	}
+
+	target := container.Revision - 1
+	if value := c.Query("revision"); value != "" {
+		if target, err = strconv.Atoi(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "revision must be a number"})
+			return
+		}
+	}
+	old := *container
+	if target == container.Revision {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Container is already at that revision"})
+		return
+	}
+
+	revision, err := s.db.GetContainerRevision(containerID, target)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+
+	// Drives referenced by the old revision may have been detached since
+	if revision.Volumes != "" {
+		volumes, err := decodeVolumes(revision.Volumes)
+		if err == nil {
+			err = s.validateVolumes(container.VMID, volumes)
+		}
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Revision %d can't be restored: %v", target, err)})
+			return
+		}
+	}
+
+	container.Image = revision.Image
+	container.Ports = revision.Ports
+	container.Environment = revision.Environment
+	container.Volumes = revision.Volumes
+	container.InitSteps = revision.InitSteps
+	container.Memory = revision.Memory
+	container.CPUs = revision.CPUs
+
+	if !s.checkPolicy(c, "container", policy.OperationUpdate, container, &old) {
+		return
+	}
+
+	s.deployContainerRevision(c, container, fmt.Sprintf("rollback to %d", target))
+}
+
+// deployContainerRevision checks that a container's changed spec still fits its
+// VM, saves it as the next revision and responds with the container
+func (s *Server) deployContainerRevision(c *gin.Context, container *database.Container, reason string) {
+	s.placementMu.Lock()
+	defer s.placementMu.Unlock()
+
+	vm, err := s.db.GetVM(container.VMID)
+	if err != nil {
+		s.logger.Errorf("Failed to get VM %s of container %s: %v", container.VMID, container.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update container"})
+		return
+	}
+
+	usage, err := s.containerUsage()
+	if err != nil {
+		s.logger.Errorf("Failed to compute VM usage: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update container"})
+		return
+	}
+	current, err := s.db.GetContainer(container.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Container not found"})
+		return
+	}
+	used := usage[vm.ID]
+	used.memory -= current.Memory
+	used.cpus -= current.CPUs
+	if !fits(vm, used, container.Memory, container.CPUs) {
+		c.JSON(http.StatusConflict, gin.H{"error": "VM does not have enough free memory or CPUs for the container"})
+		return
+	}
+
+	container.Revision++
+	if err := s.db.UpdateContainer(container); err != nil {
+		s.logger.Errorf("Failed to update container %s: %v", container.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update container"})
+		return
+	}
+	if _, err := s.db.CreateContainerRevision(container, reason); err != nil {
+		s.logger.Errorf("Failed to record revision of container %s: %v", container.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update container"})
+		return
+	}
+
+	// TODO: Redeploy the container in its VM with the new spec
+
+	s.logger.Infof("Container %s is now at revision %d (%s)", container.ID, container.Revision, reason)
+	c.JSON(http.StatusOK, container)
+}
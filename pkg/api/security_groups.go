@@ -0,0 +1,239 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/firecracker"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Security Group API Handlers
+
+type SecurityGroupRequest struct {
+	Name        string                       `json:"name" binding:"required"`
+	Description string                       `json:"description"`
+	Rules       []database.SecurityGroupRule `json:"rules"`
+}
+
+type AttachSecurityGroupRequest struct {
+	SecurityGroupID string `json:"security_group_id" binding:"required"`
+}
+
+func (s *Server) handleListSecurityGroups(c *gin.Context) {
+	groups, err := s.db.ListSecurityGroups()
+	if err != nil {
+		s.logger.Errorf("Failed to list security groups: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list security groups"})
+		return
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
+
+func (s *Server) handleCreateSecurityGroup(c *gin.Context) {
+	var req SecurityGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Rules == nil {
+		req.Rules = []database.SecurityGroupRule{}
+	}
+	if err := firecracker.ValidateSecurityGroupRules(req.Rules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := s.db.GetSecurityGroupByName(req.Name); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Security group already exists"})
+		return
+	}
+
+	group := &database.SecurityGroup{
+		ID:          uuid.New().String(),
+		Name:        req.Name,
+		Description: req.Description,
+		Rules:       req.Rules,
+	}
+	if err := s.db.CreateSecurityGroup(group); err != nil {
+		s.logger.Errorf("Failed to create security group: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create security group"})
+		return
+	}
+
+	s.logger.Infof("Security group %s created with %d rules", group.Name, len(group.Rules))
+	c.JSON(http.StatusCreated, group)
+}
+
+func (s *Server) handleGetSecurityGroup(c *gin.Context) {
+	group, err := s.db.GetSecurityGroup(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Security group not found"})
+		return
+	}
+
+	vmIDs, err := s.db.ListSecurityGroupVMs(group.ID)
+	if err != nil {
+		s.logger.Errorf("Failed to list VMs of security group %s: %v", group.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get security group"})
+		return
+	}
+	if vmIDs == nil {
+		vmIDs = []string{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"security_group": group, "vms": vmIDs})
+}
+
+// handleUpdateSecurityGroup replaces a group's rules, which takes effect on
+// the VMs it is attached to right away
+func (s *Server) handleUpdateSecurityGroup(c *gin.Context) {
+	group, err := s.db.GetSecurityGroup(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Security group not found"})
+		return
+	}
+
+	var req SecurityGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Rules == nil {
+		req.Rules = []database.SecurityGroupRule{}
+	}
+	if err := firecracker.ValidateSecurityGroupRules(req.Rules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if existing, err := s.db.GetSecurityGroupByName(req.Name); err == nil && existing.ID != group.ID {
+		c.JSON(http.StatusConflict, gin.H{"error": "Security group already exists"})
+		return
+	}
+
+	group.Name = req.Name
+	group.Description = req.Description
+	group.Rules = req.Rules
+	if err := s.db.UpdateSecurityGroup(group); err != nil {
+		s.logger.Errorf("Failed to update security group %s: %v", group.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update security group"})
+		return
+	}
+
+	if err := s.vmManager.ApplySecurityGroup(group.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+func (s *Server) handleDeleteSecurityGroup(c *gin.Context) {
+	group, err := s.db.GetSecurityGroup(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Security group not found"})
+		return
+	}
+
+	vmIDs, err := s.db.ListSecurityGroupVMs(group.ID)
+	if err != nil {
+		s.logger.Errorf("Failed to check security group usage: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete security group"})
+		return
+	}
+	if len(vmIDs) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Security group is attached to VMs", "vms": vmIDs})
+		return
+	}
+
+	if err := s.db.DeleteSecurityGroup(group.ID); err != nil {
+		s.logger.Errorf("Failed to delete security group %s: %v", group.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete security group"})
+		return
+	}
+
+	s.logger.Infof("Security group %s deleted", group.Name)
+	c.JSON(http.StatusOK, gin.H{"message": "Security group deleted successfully"})
+}
+
+func (s *Server) handleListVMSecurityGroups(c *gin.Context) {
+	vmID := c.Param("id")
+
+	if _, err := s.db.GetVM(vmID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+
+	groups, err := s.db.ListVMSecurityGroups(vmID)
+	if err != nil {
+		s.logger.Errorf("Failed to list security groups of VM %s: %v", vmID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list security groups"})
+		return
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
+
+// handleAttachSecurityGroup attaches a group to a VM, filtering its traffic
+// right away if it has a TAP device
+func (s *Server) handleAttachSecurityGroup(c *gin.Context) {
+	vmID := c.Param("id")
+
+	if _, err := s.db.GetVM(vmID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "VM not found"})
+		return
+	}
+
+	var req AttachSecurityGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	group, err := s.db.GetSecurityGroup(req.SecurityGroupID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Security group not found"})
+		return
+	}
+
+	if err := s.db.AttachSecurityGroup(vmID, group.ID); err != nil {
+		s.logger.Errorf("Failed to attach security group %s to VM %s: %v", group.ID, vmID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to attach security group"})
+		return
+	}
+	if err := s.vmManager.ApplySecurityGroups(vmID); err != nil {
+		s.respondSecurityGroupError(c, vmID, err)
+		return
+	}
+
+	s.logger.Infof("Security group %s attached to VM %s", group.Name, vmID)
+	c.JSON(http.StatusOK, group)
+}
+
+func (s *Server) handleDetachSecurityGroup(c *gin.Context) {
+	vmID := c.Param("id")
+
+	detached, err := s.db.DetachSecurityGroup(vmID, c.Param("group_id"))
+	if err != nil {
+		s.logger.Errorf("Failed to detach security group from VM %s: %v", vmID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to detach security group"})
+		return
+	}
+	if !detached {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Security group not attached to VM"})
+		return
+	}
+	if err := s.vmManager.ApplySecurityGroups(vmID); err != nil {
+		s.respondSecurityGroupError(c, vmID, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Security group detached successfully"})
+}
+
+// respondSecurityGroupError reports rules that were saved but couldn't be
+// applied to a VM's TAP device
+func (s *Server) respondSecurityGroupError(c *gin.Context, vmID string, err error) {
+	s.logger.Errorf("Failed to apply security groups of VM %s: %v", vmID, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Security groups saved but not applied: " + err.Error()})
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// Sizing Profile API Handlers
+
+type SizingProfileRequest struct {
+	Name             string `json:"name"`
+	Memory           int64  `json:"memory" binding:"required,min=1"`
+	CPUs             int    `json:"cpus" binding:"required,min=1"`
+	DiskSize         int64  `json:"disk_size" binding:"required,min=1"`
+	Description      string `json:"description"`
+	NetBandwidthMbps int64  `json:"net_bandwidth_mbps" binding:"min=0"`
+	DiskIOPS         int64  `json:"disk_iops" binding:"min=0"`
+}
+
+func (s *Server) handleListSizingProfiles(c *gin.Context) {
+	profiles, err := s.db.ListSizingProfiles()
+	if err != nil {
+		s.logger.Errorf("Failed to list sizing profiles: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sizing profiles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profiles)
+}
+
+func (s *Server) handleCreateSizingProfile(c *gin.Context) {
+	var req SizingProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sizing profile name is required"})
+		return
+	}
+
+	if _, err := s.db.GetSizingProfile(req.Name); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Sizing profile already exists"})
+		return
+	}
+
+	profile := &database.SizingProfile{Name: req.Name}
+	applySizingProfileRequest(profile, &req)
+
+	if err := s.db.CreateSizingProfile(profile); err != nil {
+		s.logger.Errorf("Failed to create sizing profile: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create sizing profile"})
+		return
+	}
+
+	s.logger.Infof("Sizing profile %s created successfully", profile.Name)
+	c.JSON(http.StatusCreated, profile)
+}
+
+func (s *Server) handleGetSizingProfile(c *gin.Context) {
+	name := c.Param("name")
+
+	profile, err := s.db.GetSizingProfile(name)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sizing profile not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// handleUpdateSizingProfile changes a profile; rate limits of VMs using it are
+// refreshed on their next start, while their memory, CPUs and disk are kept
+func (s *Server) handleUpdateSizingProfile(c *gin.Context) {
+	name := c.Param("name")
+
+	profile, err := s.db.GetSizingProfile(name)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sizing profile not found"})
+		return
+	}
+
+	var req SizingProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	applySizingProfileRequest(profile, &req)
+
+	if err := s.db.UpdateSizingProfile(profile); err != nil {
+		s.logger.Errorf("Failed to update sizing profile: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update sizing profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func (s *Server) handleDeleteSizingProfile(c *gin.Context) {
+	name := c.Param("name")
+
+	if _, err := s.db.GetSizingProfile(name); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sizing profile not found"})
+		return
+	}
+
+	inUse, err := s.db.CountVMsWithSizingProfile(name)
+	if err != nil {
+		s.logger.Errorf("Failed to check sizing profile usage: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete sizing profile"})
+		return
+	}
+	if inUse > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Sizing profile is referenced by existing VMs"})
+		return
+	}
+
+	if err := s.db.DeleteSizingProfile(name); err != nil {
+		s.logger.Errorf("Failed to delete sizing profile %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete sizing profile"})
+		return
+	}
+
+	s.logger.Infof("Sizing profile %s deleted successfully", name)
+	c.JSON(http.StatusOK, gin.H{"message": "Sizing profile deleted successfully"})
+}
+
+func applySizingProfileRequest(profile *database.SizingProfile, req *SizingProfileRequest) {
+	profile.Memory = req.Memory
+	profile.CPUs = req.CPUs
+	profile.DiskSize = req.DiskSize
+	profile.Description = req.Description
+	profile.NetBandwidthMbps = req.NetBandwidthMbps
+	profile.DiskIOPS = req.DiskIOPS
+}
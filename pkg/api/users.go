@@ -0,0 +1,331 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// User Account API Handlers
+
+// usernamePattern is what local usernames may look like
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type CreateUserRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	Role     string `json:"role" binding:"required"`
+}
+
+type UpdateUserRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// requireLocalAccounts answers 404 unless AUTH_PROVIDER=local
+func (s *Server) requireLocalAccounts(c *gin.Context) bool {
+	if s.local == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Local accounts are not enabled (AUTH_PROVIDER=local)"})
+		return false
+	}
+	return true
+}
+
+// isSelf reports whether the caller is the user with id
+func isSelf(c *gin.Context, id string) bool {
+	principal := principalFrom(c)
+	return principal != nil && principal.Attrs["user_id"] == id
+}
+
+// handleLogin checks a username and password, and returns a session token
+// that is also set as the web UI's session cookie
+func (s *Server) handleLogin(c *gin.Context) {
+	if !s.requireLocalAccounts(c) {
+		return
+	}
+
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, user, expires, err := s.local.Login(req.Username, req.Password)
+	if errors.Is(err, ErrUnauthenticated) {
+		s.logger.Warnf("Failed login for user %q from %s", req.Username, c.ClientIP())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("Login failed: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Login unavailable"})
+		return
+	}
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   c.Request.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	s.logger.Infof("User %s logged in", user.Username)
+	c.JSON(http.StatusOK, gin.H{"token": token, "expires_at": expires, "user": user})
+}
+
+// handleLogout ends the caller's session
+func (s *Server) handleLogout(c *gin.Context) {
+	if !s.requireLocalAccounts(c) {
+		return
+	}
+
+	if err := s.local.Logout(c.Request); err != nil {
+		s.logger.Errorf("Failed to end session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+func (s *Server) handleListUsers(c *gin.Context) {
+	if !s.requireLocalAccounts(c) || !requireRole(c, RoleAdmin) {
+		return
+	}
+
+	users, err := s.db.ListUsers()
+	if err != nil {
+		s.logger.Errorf("Failed to list users: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+func (s *Server) handleCreateUser(c *gin.Context) {
+	if !s.requireLocalAccounts(c) || !requireRole(c, RoleAdmin) {
+		return
+	}
+
+	var req CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !usernamePattern.MatchString(req.Username) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username must be 1-64 letters, digits, dots, dashes or underscores"})
+		return
+	}
+	if !ValidUserRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be admin, operator or viewer"})
+		return
+	}
+	if err := s.local.checkPassword(req.Username, req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := s.db.GetUserByUsername(req.Username); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+		return
+	}
+
+	user, err := s.local.createUser(req.Username, req.Password, req.Role, false)
+	if err != nil {
+		s.logger.Errorf("Failed to create user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	s.logger.Infof("User %s created with role %s", user.Username, user.Role)
+	c.JSON(http.StatusCreated, user)
+}
+
+// handleGetUser returns a user to admins or to the user themselves
+func (s *Server) handleGetUser(c *gin.Context) {
+	if !s.requireLocalAccounts(c) {
+		return
+	}
+	id := c.Param("id")
+	if !isSelf(c, id) && !requireRole(c, RoleAdmin) {
+		return
+	}
+
+	user, err := s.db.GetUser(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// handleUpdateUser changes a user's role
+func (s *Server) handleUpdateUser(c *gin.Context) {
+	if !s.requireLocalAccounts(c) || !requireRole(c, RoleAdmin) {
+		return
+	}
+
+	user, err := s.db.GetUser(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !ValidUserRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be admin, operator or viewer"})
+		return
+	}
+	if user.Role == RoleAdmin && req.Role != RoleAdmin && !s.otherAdminsExist(c) {
+		return
+	}
+
+	user.Role = req.Role
+	if err := s.db.UpdateUser(user); err != nil {
+		s.logger.Errorf("Failed to update user %s: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (s *Server) handleDeleteUser(c *gin.Context) {
+	if !s.requireLocalAccounts(c) || !requireRole(c, RoleAdmin) {
+		return
+	}
+
+	user, err := s.db.GetUser(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.Role == RoleAdmin && !s.otherAdminsExist(c) {
+		return
+	}
+
+	if err := s.db.DeleteUser(user.ID); err != nil {
+		s.logger.Errorf("Failed to delete user %s: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		return
+	}
+
+	s.logger.Infof("User %s deleted", user.Username)
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+}
+
+// handleChangePassword lets users replace their own password, which takes the
+// current one; their other sessions are ended
+func (s *Server) handleChangePassword(c *gin.Context) {
+	if !s.requireLocalAccounts(c) {
+		return
+	}
+	id := c.Param("id")
+	if !isSelf(c, id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Users can only change their own password; admins reset others'"})
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := s.db.GetUser(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !verifyPassword(user.PasswordHash, req.CurrentPassword) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Current password is wrong"})
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New password must differ from the current one"})
+		return
+	}
+	if err := s.local.checkPassword(user.Username, req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.local.setPassword(user, req.NewPassword, false, hashToken(sessionToken(c.Request))); err != nil {
+		s.logger.Errorf("Failed to change password of user %s: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	s.logger.Infof("User %s changed their password", user.Username)
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed"})
+}
+
+// handleResetPassword gives a user a random temporary password, returned
+// once, that they must change on their next login; their sessions are ended
+func (s *Server) handleResetPassword(c *gin.Context) {
+	if !s.requireLocalAccounts(c) || !requireRole(c, RoleAdmin) {
+		return
+	}
+
+	user, err := s.db.GetUser(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	password, err := generatePassword()
+	if err == nil {
+		err = s.local.setPassword(user, password, true, "")
+	}
+	if err != nil {
+		s.logger.Errorf("Failed to reset password of user %s: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	s.logger.Infof("Password of user %s reset", user.Username)
+	c.JSON(http.StatusOK, gin.H{"password": password, "must_change_password": true})
+}
+
+// otherAdminsExist answers 409 when removing an admin would leave none
+func (s *Server) otherAdminsExist(c *gin.Context) bool {
+	admins, err := s.db.CountUsersWithRole(RoleAdmin)
+	if err != nil {
+		s.logger.Errorf("Failed to count admins: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count admins"})
+		return false
+	}
+	if admins <= 1 {
+		c.JSON(http.StatusConflict, gin.H{"error": "The last admin can't be removed or demoted"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+)
+
+// VolumeMount declares storage mounted into a container
+type VolumeMount struct {
+	Type     string   `json:"type" yaml:"type"`     // "drive" (a data drive attached to the VM) or "bind" (a path inside the guest)
+	Source   string   `json:"source" yaml:"source"` // drive ID for "drive", absolute guest path for "bind"
+	Target   string   `json:"target" yaml:"target"` // absolute mount path inside the container
+	ReadOnly bool     `json:"read_only" yaml:"read_only,omitempty"`
+	Options  []string `json:"options,omitempty" yaml:"options,omitempty"` // extra mount options, e.g. "noexec"
+}
+
+// validateVolumes checks container volume mounts against the drives attached to its VM
+func (s *Server) validateVolumes(vmID string, volumes []VolumeMount) error {
+	if len(volumes) == 0 {
+		return nil
+	}
+
+	drives, err := s.db.ListDriveAttachmentsByVM(vmID)
+	if err != nil {
+		return fmt.Errorf("failed to load VM drives: %w", err)
+	}
+	attached := make(map[string]*database.DriveAttachment, len(drives))
+	for _, drive := range drives {
+		attached[drive.DriveID] = drive
+	}
+
+	targets := make(map[string]bool, len(volumes))
+	for i, volume := range volumes {
+		if !path.IsAbs(volume.Target) {
+			return fmt.Errorf("volume %d: target must be an absolute path", i)
+		}
+		target := path.Clean(volume.Target)
+		if targets[target] {
+			return fmt.Errorf("volume %d: target %s is mounted more than once", i, target)
+		}
+		targets[target] = true
+
+		switch volume.Type {
+		case "drive":
+			drive, ok := attached[volume.Source]
+			if !ok {
+				return fmt.Errorf("volume %d: drive %q is not attached to the VM", i, volume.Source)
+			}
+			if drive.ReadOnly && !volume.ReadOnly {
+				return fmt.Errorf("volume %d: drive %q is read-only, set read_only", i, volume.Source)
+			}
+		case "bind":
+			if !path.IsAbs(volume.Source) {
+				return fmt.Errorf("volume %d: bind source must be an absolute guest path", i)
+			}
+		default:
+			return fmt.Errorf("volume %d: type must be \"drive\" or \"bind\"", i)
+		}
+	}
+
+	return nil
+}
+
+// decodeVolumes parses volume mounts stored on a container
+func decodeVolumes(encoded string) ([]VolumeMount, error) {
+	var volumes []VolumeMount
+	if err := json.Unmarshal([]byte(encoded), &volumes); err != nil {
+		return nil, fmt.Errorf("invalid stored volumes: %w", err)
+	}
+	return volumes, nil
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/jobs"
+	"github.com/gin-gonic/gin"
+)
+
+// Limits for requests made with wait=true
+const (
+	defaultWaitTimeout = 5 * time.Minute
+	maxWaitTimeout     = 30 * time.Minute
+	waitPollInterval   = 500 * time.Millisecond
+)
+
+// waitTimeout reports whether the request asked to wait for the resource to
+// settle and for how long, from the wait and timeout query parameters
+func waitTimeout(c *gin.Context) (time.Duration, bool, error) {
+	if c.Query("wait") != "true" {
+		return 0, false, nil
+	}
+
+	timeout := defaultWaitTimeout
+	if value := c.Query("timeout"); value != "" {
+		var err error
+		if timeout, err = time.ParseDuration(value); err != nil || timeout <= 0 || timeout > maxWaitTimeout {
+			return 0, false, fmt.Errorf("timeout must be a duration between 1s and %s, such as 90s or 5m", maxWaitTimeout)
+		}
+	}
+	return timeout, true, nil
+}
+
+// waitForVM polls a VM until it leaves the creating and deleting statuses, the
+// timeout passes or the request is cancelled. It returns the VM as last seen,
+// nil once it has been deleted, and whether it settled.
+func (s *Server) waitForVM(c *gin.Context, vmID string, timeout time.Duration) (*database.VM, bool, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+
+	for {
+		vm, err := s.db.GetVM(vmID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, true, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if vm.Status != "creating" && vm.Status != "deleting" {
+			return vm, true, nil
+		}
+
+		select {
+		case <-c.Request.Context().Done():
+			return vm, false, nil
+		case <-deadline.C:
+			return vm, false, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// latestJob reloads a job so responses show its final state, falling back to
+// the copy the handler already has
+func (s *Server) latestJob(job *database.Job) *database.Job {
+	if latest, err := s.db.GetJob(job.ID); err == nil {
+		return latest
+	}
+	return job
+}
+
+// respondWhenSettled waits for a VM's create or delete job to settle the VM and
+// responds with the outcome: the VM once created, a confirmation once deleted,
+// 500 if the VM ended in error, or 202 with the VM and job as they are when the
+// wait ends
+func (s *Server) respondWhenSettled(c *gin.Context, vmID string, job *database.Job, timeout time.Duration) {
+	vm, settled, err := s.waitForVM(c, vmID, timeout)
+	if err != nil {
+		s.logger.Errorf("Failed to get VM %s while waiting for job %s: %v", vmID, job.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get VM"})
+		return
+	}
+	job = s.latestJob(job)
+
+	switch {
+	case !settled:
+		c.JSON(http.StatusAccepted, gin.H{"vm": vm, "job": job})
+	case vm == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "VM deleted successfully", "job": job})
+	case vm.Status == "error":
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "VM ended in error: " + vm.StatusReason, "vm": vm, "job": job})
+	case job.Type == jobs.TypeVMCreate:
+		c.JSON(http.StatusCreated, vm)
+	default:
+		c.JSON(http.StatusOK, vm)
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleValidateVM runs every check a creation request goes through, admission
+// webhooks and policies included, without creating anything or claiming
+// capacity. It answers 200 with the VM as it would be created, or the error
+// response creation would give.
+func (s *Server) handleValidateVM(c *gin.Context) {
+	var req CreateVMRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	vm, ok := s.prepareVM(c, &req, true)
+	if !ok {
+		return
+	}
+	if !s.fitVM(c, &req, vm) {
+		return
+	}
+
+	if err := s.vmManager.CheckImages(); err != nil {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Nothing was created, so there is no ID or status to report
+	vm.ID = ""
+	vm.Status = ""
+	c.JSON(http.StatusOK, gin.H{"valid": true, "vm": vm})
+}
+
+// handleListImages lists the kernel and root filesystem images VMs boot from,
+// with their verification status
+func (s *Server) handleListImages(c *gin.Context) {
+	c.JSON(http.StatusOK, s.vmManager.Images())
+}
+
+// handleListNetworks lists the networks VMs can join and their free addresses
+func (s *Server) handleListNetworks(c *gin.Context) {
+	networks, err := s.vmManager.Networks()
+	if err != nil {
+		s.logger.Errorf("Failed to list networks: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list networks"})
+		return
+	}
+	c.JSON(http.StatusOK, networks)
+}
+
+// handleCapacity reports the host's capacity and how much of it is committed
+func (s *Server) handleCapacity(c *gin.Context) {
+	capacity, err := s.vmManager.Capacity()
+	if err != nil {
+		s.logger.Errorf("Failed to compute capacity: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute capacity"})
+		return
+	}
+	c.JSON(http.StatusOK, capacity)
+}
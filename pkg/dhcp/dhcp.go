@@ -0,0 +1,271 @@
+// Package dhcp answers DHCPv4 requests from guests on the VM bridge with the
+// address IPAM assigned to their MAC, so guests can configure their network
+// without any orchestrator-specific tooling.
+package dhcp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Message types (option 53)
+const (
+	msgDiscover = 1
+	msgOffer    = 2
+	msgRequest  = 3
+	msgDecline  = 4
+	msgAck      = 5
+	msgNak      = 6
+	msgRelease  = 7
+	msgInform   = 8
+)
+
+// Options the server reads or writes
+const (
+	optPad         = 0
+	optSubnetMask  = 1
+	optRouter      = 3
+	optDNS         = 6
+	optHostname    = 12
+	optRequestedIP = 50
+	optLeaseTime   = 51
+	optMessageType = 53
+	optServerID    = 54
+	optRenewal     = 58
+	optRebinding   = 59
+	optEnd         = 255
+)
+
+const (
+	opRequest  = 1
+	opReply    = 2
+	headerSize = 236
+	minPacket  = 300 // BOOTP's minimum, which some clients insist on
+)
+
+var magicCookie = []byte{99, 130, 83, 99}
+
+// Lease is what a guest is told about its network
+type Lease struct {
+	IP       net.IP
+	Hostname string
+}
+
+// LookupFunc returns the lease of a guest by its MAC address, or false for
+// MACs that belong to no VM
+type LookupFunc func(mac net.HardwareAddr) (Lease, bool, error)
+
+// Server is a DHCP server bound to one interface. It never allocates
+// addresses itself: each guest gets the address its VM already holds.
+type Server struct {
+	Interface string
+	ServerIP  net.IP // the bridge's address, also handed out as the router
+	Mask      net.IPMask
+	DNS       []net.IP
+	LeaseTime time.Duration
+	Lookup    LookupFunc
+	Logger    *logrus.Logger
+}
+
+// Run serves DHCP on port 67 of the server's interface until ctx is cancelled
+func (s *Server) Run(ctx context.Context) error {
+	lc := net.ListenConfig{Control: s.control}
+	pc, err := lc.ListenPacket(ctx, "udp4", ":67")
+	if err != nil {
+		return fmt.Errorf("failed to listen for DHCP on %s: %w", s.Interface, err)
+	}
+	go func() {
+		<-ctx.Done()
+		pc.Close()
+	}()
+
+	s.Logger.Infof("Serving DHCP on %s", s.Interface)
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			s.Logger.Warnf("DHCP read failed: %v", err)
+			continue
+		}
+
+		reply, err := s.handle(buf[:n])
+		if err != nil {
+			s.Logger.Debugf("Ignoring DHCP packet: %v", err)
+			continue
+		}
+		if reply == nil {
+			continue
+		}
+		// Clients without an address can't take unicast, so replies are broadcast
+		if _, err := pc.WriteTo(reply, &net.UDPAddr{IP: net.IPv4bcast, Port: 68}); err != nil {
+			s.Logger.Warnf("DHCP reply failed: %v", err)
+		}
+	}
+}
+
+// control binds the socket to the interface and allows broadcast replies
+func (s *Server) control(network, address string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		if sockErr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, s.Interface); sockErr != nil {
+			return
+		}
+		if sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1); sockErr != nil {
+			return
+		}
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// handle answers one request, returning nil for requests that get no reply
+func (s *Server) handle(packet []byte) ([]byte, error) {
+	if len(packet) < headerSize+len(magicCookie) || packet[0] != opRequest {
+		return nil, errors.New("not a BOOTP request")
+	}
+	if packet[1] != 1 || packet[2] != 6 {
+		return nil, errors.New("not an Ethernet client")
+	}
+	if string(packet[headerSize:headerSize+4]) != string(magicCookie) {
+		return nil, errors.New("no DHCP magic cookie")
+	}
+	options := parseOptions(packet[headerSize+4:])
+	if len(options[optMessageType]) != 1 {
+		return nil, errors.New("no message type")
+	}
+	msgType := options[optMessageType][0]
+	mac := net.HardwareAddr(append([]byte(nil), packet[28:34]...))
+
+	lease, ok, err := s.Lookup(mac)
+	if err != nil {
+		return nil, fmt.Errorf("lookup of %s failed: %w", mac, err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%s belongs to no VM", mac)
+	}
+	ip := lease.IP.To4()
+
+	switch msgType {
+	case msgDiscover:
+		s.Logger.Debugf("DHCP offer of %s to %s", ip, mac)
+		return s.reply(packet, msgOffer, ip, lease), nil
+	case msgRequest:
+		requested := net.IP(options[optRequestedIP])
+		if len(requested) != 4 {
+			requested = net.IP(packet[12:16]) // ciaddr, when renewing
+		}
+		if server := options[optServerID]; len(server) == 4 && !net.IP(server).Equal(s.ServerIP) {
+			return nil, nil // the client chose another server
+		}
+		if !requested.Equal(ip) && !requested.Equal(net.IPv4zero) {
+			s.Logger.Infof("DHCP NAK to %s, which asked for %s instead of %s", mac, requested, ip)
+			return s.reply(packet, msgNak, nil, lease), nil
+		}
+		s.Logger.Debugf("DHCP ack of %s to %s", ip, mac)
+		return s.reply(packet, msgAck, ip, lease), nil
+	case msgInform:
+		return s.reply(packet, msgAck, nil, lease), nil
+	case msgRelease, msgDecline:
+		// The address stays the VM's either way
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unhandled message type %d", msgType)
+}
+
+// reply builds a reply to request offering or acknowledging yiaddr
+func (s *Server) reply(request []byte, msgType byte, yiaddr net.IP, lease Lease) []byte {
+	packet := make([]byte, headerSize, minPacket)
+	packet[0] = opReply
+	packet[1], packet[2] = 1, 6
+	copy(packet[4:8], request[4:8])     // xid
+	copy(packet[10:12], request[10:12]) // flags
+	if msgType == msgAck && yiaddr == nil {
+		copy(packet[12:16], request[12:16]) // ciaddr for INFORM
+	}
+	if yiaddr != nil {
+		copy(packet[16:20], yiaddr.To4())
+	}
+	copy(packet[20:24], s.ServerIP.To4())
+	copy(packet[24:28], request[24:28]) // giaddr
+	copy(packet[28:44], request[28:44]) // chaddr
+	packet = append(packet, magicCookie...)
+
+	packet = appendOption(packet, optMessageType, []byte{msgType})
+	packet = appendOption(packet, optServerID, s.ServerIP.To4())
+	if msgType != msgNak {
+		packet = appendOption(packet, optSubnetMask, s.Mask)
+		packet = appendOption(packet, optRouter, s.ServerIP.To4())
+		if len(s.DNS) > 0 {
+			var dns []byte
+			for _, server := range s.DNS {
+				dns = append(dns, server.To4()...)
+			}
+			packet = appendOption(packet, optDNS, dns)
+		}
+		if lease.Hostname != "" {
+			packet = appendOption(packet, optHostname, []byte(lease.Hostname))
+		}
+		if yiaddr != nil {
+			seconds := uint32(s.LeaseTime.Seconds())
+			packet = appendOption(packet, optLeaseTime, binary.BigEndian.AppendUint32(nil, seconds))
+			packet = appendOption(packet, optRenewal, binary.BigEndian.AppendUint32(nil, seconds/2))
+			packet = appendOption(packet, optRebinding, binary.BigEndian.AppendUint32(nil, seconds/8*7))
+		}
+	}
+	packet = append(packet, optEnd)
+
+	for len(packet) < minPacket {
+		packet = append(packet, optPad)
+	}
+	return packet
+}
+
+// parseOptions reads DHCP options up to the end option; later copies of an
+// option replace earlier ones
+func parseOptions(data []byte) map[byte][]byte {
+	options := make(map[byte][]byte)
+	for i := 0; i < len(data); {
+		code := data[i]
+		if code == optEnd {
+			break
+		}
+		if code == optPad {
+			i++
+			continue
+		}
+		if i+1 >= len(data) {
+			break
+		}
+		length := int(data[i+1])
+		if i+2+length > len(data) {
+			break
+		}
+		options[code] = data[i+2 : i+2+length]
+		i += 2 + length
+	}
+	return options
+}
+
+func appendOption(packet []byte, code byte, value []byte) []byte {
+	if len(value) > 255 {
+		value = value[:255]
+	}
+	packet = append(packet, code, byte(len(value)))
+	return append(packet, value...)
+}
@@ -0,0 +1,64 @@
+package firecracker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Circuit breaker settings for a VM's API socket
+const (
+	breakerThreshold = 5                // consecutive failed calls that open the circuit
+	breakerCooldown  = 30 * time.Second // how long an open circuit fails calls before letting one through
+)
+
+// ErrCircuitOpen is returned without calling the API socket when recent calls
+// to it kept failing, so a wedged VMM doesn't tie up a handler per request
+var ErrCircuitOpen = errors.New("firecracker API socket is not responding")
+
+// circuitBreaker tracks the health of one VM's API socket. Calls that get no
+// answer (timeouts, refused or dropped connections) count as failures; error
+// responses from Firecracker don't, since the VMM is evidently alive.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool // a call is testing whether an open circuit can close
+}
+
+// allow reports whether a call may go ahead. Once the cooldown has passed, a
+// single call is let through to probe the socket.
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < breakerThreshold {
+		return nil
+	}
+	wait := time.Until(b.openUntil)
+	if wait > 0 || b.probing {
+		if wait < 0 {
+			wait = 0
+		}
+		return fmt.Errorf("%w: %d consecutive calls failed, retrying in %s", ErrCircuitOpen, b.failures, wait.Round(time.Second))
+	}
+	b.probing = true
+	return nil
+}
+
+// record updates the breaker with the outcome of a call that allow let through
+func (b *circuitBreaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= breakerThreshold {
+		b.openUntil = time.Now().Add(breakerCooldown)
+	}
+}
@@ -0,0 +1,62 @@
+package firecracker
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// EnsureBridge creates BRIDGE_NAME if it doesn't exist, gives it the VM
+// subnet's gateway address and brings it up, so guests on its TAP devices
+// can reach each other and the host. An existing bridge is reused as is,
+// apart from adding the gateway address if it lacks it.
+func (m *Manager) EnsureBridge() error {
+	bridge := m.config.BridgeName
+	pool, err := m.ipPool()
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(filepath.Join("/sys/class/net", bridge)); os.IsNotExist(err) {
+		if err := runIP("link", "add", "name", bridge, "type", "bridge"); err != nil {
+			return fmt.Errorf("failed to create bridge %s: %w", bridge, err)
+		}
+		m.logger.Infof("Created bridge %s", bridge)
+	} else if _, err := os.Stat(filepath.Join("/sys/class/net", bridge, "bridge")); err != nil {
+		return fmt.Errorf("%s exists but is not a bridge", bridge)
+	}
+
+	ones, _ := pool.Subnet().Mask.Size()
+	gateway := fmt.Sprintf("%s/%d", pool.Gateway(), ones)
+	if err := runIP("addr", "replace", gateway, "dev", bridge); err != nil {
+		return fmt.Errorf("failed to assign %s to bridge %s: %w", gateway, bridge, err)
+	}
+	if err := runIP("link", "set", "dev", bridge, "up"); err != nil {
+		return fmt.Errorf("failed to bring up bridge %s: %w", bridge, err)
+	}
+
+	m.logger.Infof("Bridge %s is up with gateway %s", bridge, gateway)
+	return nil
+}
+
+// attachTAPDevice puts a TAP device on BRIDGE_NAME
+func (m *Manager) attachTAPDevice(tap string) error {
+	if err := runIP("link", "set", "dev", tap, "master", m.config.BridgeName); err != nil {
+		return fmt.Errorf("failed to attach TAP device %s to bridge %s: %w", tap, m.config.BridgeName, err)
+	}
+	return nil
+}
+
+// runIP runs the ip command, folding its output into the error
+func runIP(args ...string) error {
+	output, err := exec.Command("ip", args...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
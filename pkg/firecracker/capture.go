@@ -0,0 +1,236 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Capture limits keep a forgotten capture from filling the disk
+const (
+	MaxCaptureDuration = 5 * time.Minute
+	MaxCapturePackets  = 1000000
+	MaxCaptureBytes    = 100 << 20
+	MaxCaptureSnapLen  = 262144
+	// MaxRunningCaptures is how many captures may run at once on the host
+	MaxRunningCaptures = 4
+)
+
+// captureSizeInterval is how often a running capture's file size is checked
+const captureSizeInterval = time.Second
+
+// ErrTooManyCaptures is returned when MaxRunningCaptures captures are running
+var ErrTooManyCaptures = errors.New("too many packet captures running")
+
+// CaptureOptions bound a packet capture
+type CaptureOptions struct {
+	Duration   time.Duration
+	MaxPackets int
+	MaxBytes   int64
+	SnapLen    int
+}
+
+// Capture is a packet capture taken on a VM's TAP device
+type Capture struct {
+	ID         string     `json:"id"`
+	VMID       string     `json:"vm_id"`
+	TAPDevice  string     `json:"tap_device"`
+	Status     string     `json:"status"` // running, completed, failed
+	Error      string     `json:"error,omitempty"`
+	SizeBytes  int64      `json:"size_bytes"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Path       string     `json:"-"`
+}
+
+// captureStore tracks captures in memory; the pcap files live under the socket dir
+type captureStore struct {
+	mu       sync.Mutex
+	captures map[string]*Capture
+}
+
+// StartCapture runs tcpdump on a running VM's TAP device in the background,
+// stopping after the duration, packet or size limit, whichever comes first.
+// The size is checked every second, so the file may overshoot it slightly.
+func (m *Manager) StartCapture(vmID string, opts CaptureOptions) (*Capture, error) {
+	fcVM, exists := m.getVM(vmID)
+	if !exists {
+		return nil, fmt.Errorf("VM %s not found in manager", vmID)
+	}
+	if fcVM.Process.Load() == nil {
+		return nil, fmt.Errorf("VM %s is not running", vmID)
+	}
+
+	tcpdump, err := exec.LookPath("tcpdump")
+	if err != nil {
+		return nil, fmt.Errorf("tcpdump is not installed on the host: %w", err)
+	}
+
+	if opts.Duration <= 0 || opts.Duration > MaxCaptureDuration {
+		opts.Duration = MaxCaptureDuration
+	}
+	if opts.MaxPackets <= 0 || opts.MaxPackets > MaxCapturePackets {
+		opts.MaxPackets = MaxCapturePackets
+	}
+	if opts.MaxBytes <= 0 || opts.MaxBytes > MaxCaptureBytes {
+		opts.MaxBytes = MaxCaptureBytes
+	}
+	if opts.SnapLen <= 0 || opts.SnapLen > MaxCaptureSnapLen {
+		opts.SnapLen = MaxCaptureSnapLen
+	}
+
+	dir := filepath.Join(m.config.SocketDir, "captures")
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create capture directory: %w", err)
+	}
+
+	capture := &Capture{
+		ID:        uuid.New().String(),
+		VMID:      vmID,
+		TAPDevice: fcVM.TAPDevice,
+		Status:    "running",
+		StartedAt: time.Now(),
+	}
+	capture.Path = filepath.Join(dir, capture.ID+".pcap")
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Duration)
+	cmd := exec.CommandContext(ctx, tcpdump,
+		"-i", fcVM.TAPDevice,
+		"-w", capture.Path,
+		"-c", strconv.Itoa(opts.MaxPackets),
+		"-s", strconv.Itoa(opts.SnapLen),
+		"-U",
+		// Don't drop to the tcpdump user, which can't write to the capture directory
+		"-Z", "root",
+	)
+	// Interrupt rather than kill so tcpdump flushes a complete pcap file
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGINT) }
+	cmd.WaitDelay = 10 * time.Second
+
+	// The capture takes its slot before tcpdump starts so racing requests
+	// can't exceed the limit
+	m.captures.mu.Lock()
+	running := 0
+	for _, other := range m.captures.captures {
+		if other.Status == "running" {
+			running++
+		}
+	}
+	if running >= MaxRunningCaptures {
+		m.captures.mu.Unlock()
+		cancel()
+		return nil, fmt.Errorf("%d captures are running: %w", running, ErrTooManyCaptures)
+	}
+	m.captures.captures[capture.ID] = capture
+	m.captures.mu.Unlock()
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		m.captures.mu.Lock()
+		delete(m.captures.captures, capture.ID)
+		m.captures.mu.Unlock()
+		return nil, fmt.Errorf("failed to start tcpdump: %w", err)
+	}
+
+	m.logger.Infof("Started packet capture %s on %s for VM %s", capture.ID, fcVM.TAPDevice, vmID)
+
+	// Ending the context interrupts tcpdump, as the time limit does
+	go func() {
+		ticker := time.NewTicker(captureSizeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if info, err := os.Stat(capture.Path); err == nil && info.Size() >= opts.MaxBytes {
+					m.logger.Infof("Packet capture %s reached %d bytes; stopping it", capture.ID, opts.MaxBytes)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	go func() {
+		defer cancel()
+		err := cmd.Wait()
+
+		m.captures.mu.Lock()
+		defer m.captures.mu.Unlock()
+
+		now := time.Now()
+		capture.FinishedAt = &now
+		if info, statErr := os.Stat(capture.Path); statErr == nil {
+			capture.SizeBytes = info.Size()
+		}
+		// Hitting the time or size limit is a normal way for a capture to end
+		if err != nil && ctx.Err() == nil {
+			capture.Status = "failed"
+			capture.Error = err.Error()
+			m.logger.Warnf("Packet capture %s failed: %v", capture.ID, err)
+			return
+		}
+		capture.Status = "completed"
+	}()
+
+	return m.snapshotCapture(capture), nil
+}
+
+// GetCapture returns a capture taken on a VM
+func (m *Manager) GetCapture(vmID, captureID string) (*Capture, bool) {
+	m.captures.mu.Lock()
+	defer m.captures.mu.Unlock()
+
+	capture, exists := m.captures.captures[captureID]
+	if !exists || capture.VMID != vmID {
+		return nil, false
+	}
+	c := *capture
+	return &c, true
+}
+
+// ListCaptures returns the captures taken on a VM
+func (m *Manager) ListCaptures(vmID string) []*Capture {
+	m.captures.mu.Lock()
+	defer m.captures.mu.Unlock()
+
+	captures := []*Capture{}
+	for _, capture := range m.captures.captures {
+		if capture.VMID == vmID {
+			c := *capture
+			captures = append(captures, &c)
+		}
+	}
+	return captures
+}
+
+// deleteCaptures removes all capture files of a VM
+func (m *Manager) deleteCaptures(vmID string) {
+	m.captures.mu.Lock()
+	defer m.captures.mu.Unlock()
+
+	for id, capture := range m.captures.captures {
+		if capture.VMID == vmID {
+			os.Remove(capture.Path)
+			delete(m.captures.captures, id)
+		}
+	}
+}
+
+// snapshotCapture copies a capture so callers don't race with the capture goroutine
+func (m *Manager) snapshotCapture(capture *Capture) *Capture {
+	m.captures.mu.Lock()
+	defer m.captures.mu.Unlock()
+	c := *capture
+	return &c
+}
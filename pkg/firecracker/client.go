@@ -0,0 +1,267 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Actions accepted by PUT /actions
+const (
+	ActionInstanceStart  = "InstanceStart"
+	ActionSendCtrlAltDel = "SendCtrlAltDel"
+	ActionFlushMetrics   = "FlushMetrics"
+)
+
+// VM states accepted by PATCH /vm
+const (
+	StatePaused  = "Paused"
+	StateResumed = "Resumed"
+)
+
+// API socket timeouts and retries
+const (
+	apiSocketTimeout  = 5 * time.Second  // how long a new Firecracker process gets to open its API socket
+	apiCallTimeout    = 10 * time.Second // the longest any single call may take
+	apiConnectRetries = 4                // retries of a call whose connection was refused
+	apiRetryBackoff   = 50 * time.Millisecond
+)
+
+// APIError is a non-2xx response from the Firecracker API
+type APIError struct {
+	Method       string
+	Path         string
+	StatusCode   int
+	FaultMessage string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("firecracker API %s %s: %d %s", e.Method, e.Path, e.StatusCode, e.FaultMessage)
+}
+
+// InstanceInfo is the response of GET /
+type InstanceInfo struct {
+	ID         string `json:"id"`
+	State      string `json:"state"` // Not started, Running or Paused
+	VMMVersion string `json:"vmm_version"`
+	AppName    string `json:"app_name"`
+}
+
+// Client talks to one Firecracker process over its API socket
+type Client struct {
+	socketPath string
+	http       *http.Client
+	breaker    *circuitBreaker // nil when calls aren't circuit-broken
+}
+
+// NewClient returns a client for the Firecracker API socket at socketPath
+func NewClient(socketPath string) *Client {
+	return newClient(socketPath, nil)
+}
+
+// newClient returns a client whose calls are guarded by breaker, if not nil
+func newClient(socketPath string, breaker *circuitBreaker) *Client {
+	dialer := &net.Dialer{}
+	return &Client{
+		breaker:    breaker,
+		socketPath: socketPath,
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+			},
+			Timeout: apiCallTimeout,
+		},
+	}
+}
+
+// WaitReady waits until the API socket accepts requests
+func (c *Client) WaitReady(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if _, err := os.Stat(c.socketPath); err == nil {
+			if _, err := c.DescribeInstance(ctx); err == nil {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("API socket %s not ready: %w", c.socketPath, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// DescribeInstance returns the instance's ID, state and VMM version
+func (c *Client) DescribeInstance(ctx context.Context) (*InstanceInfo, error) {
+	var info InstanceInfo
+	if err := c.do(ctx, http.MethodGet, "/", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// PutMachineConfig sets the vCPU count and memory size; only before boot
+func (c *Client) PutMachineConfig(ctx context.Context, config MachineConfig) error {
+	return c.do(ctx, http.MethodPut, "/machine-config", config, nil)
+}
+
+// GetMachineConfig returns the machine configuration in effect
+func (c *Client) GetMachineConfig(ctx context.Context) (*MachineConfig, error) {
+	var config MachineConfig
+	if err := c.do(ctx, http.MethodGet, "/machine-config", nil, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// PutBootSource sets the kernel and its boot arguments; only before boot
+func (c *Client) PutBootSource(ctx context.Context, source BootSource) error {
+	return c.do(ctx, http.MethodPut, "/boot-source", source, nil)
+}
+
+// PutDrive adds or replaces a block device; only before boot
+func (c *Client) PutDrive(ctx context.Context, drive Drive) error {
+	return c.do(ctx, http.MethodPut, "/drives/"+drive.DriveID, drive, nil)
+}
+
+// PutNetworkInterface adds or replaces a network interface; only before boot
+func (c *Client) PutNetworkInterface(ctx context.Context, iface NetworkIface) error {
+	return c.do(ctx, http.MethodPut, "/network-interfaces/"+iface.IfaceID, iface, nil)
+}
+
+// PutEntropy attaches a virtio-rng device; only before boot
+func (c *Client) PutEntropy(ctx context.Context) error {
+	return c.do(ctx, http.MethodPut, "/entropy", Entropy{}, nil)
+}
+
+// PutLogger configures Firecracker's own log; only before boot
+func (c *Client) PutLogger(ctx context.Context, logger LoggerConfig) error {
+	return c.do(ctx, http.MethodPut, "/logger", logger, nil)
+}
+
+// PutMmdsConfig sets the MMDS version and the interfaces it is reachable on
+func (c *Client) PutMmdsConfig(ctx context.Context, config MmdsConfig) error {
+	return c.do(ctx, http.MethodPut, "/mmds/config", config, nil)
+}
+
+// PutMmds replaces the contents of the metadata store
+func (c *Client) PutMmds(ctx context.Context, metadata json.RawMessage) error {
+	return c.do(ctx, http.MethodPut, "/mmds", metadata, nil)
+}
+
+// Action performs an instance action such as ActionInstanceStart
+func (c *Client) Action(ctx context.Context, action string) error {
+	body := struct {
+		ActionType string `json:"action_type"`
+	}{action}
+	return c.do(ctx, http.MethodPut, "/actions", body, nil)
+}
+
+// SetState pauses or resumes a running VM with StatePaused or StateResumed
+func (c *Client) SetState(ctx context.Context, state string) error {
+	body := struct {
+		State string `json:"state"`
+	}{state}
+	return c.do(ctx, http.MethodPatch, "/vm", body, nil)
+}
+
+// do sends a request to the API socket, decoding a JSON response into out when
+// it is non-nil. Calls are retried while the socket refuses connections, which
+// happens briefly while Firecracker starts, and fail fast while the VM's
+// circuit breaker is open.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to encode %s %s: %w", method, path, err)
+		}
+	}
+
+	if c.breaker != nil {
+		if err := c.breaker.allow(); err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+	resp, err := c.send(ctx, method, path, data)
+	backoff := apiRetryBackoff
+	for retry := 0; retry < apiConnectRetries && err != nil && connectionRefused(err); retry++ {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-time.After(backoff):
+			backoff *= 2
+			start = time.Now()
+			resp, err = c.send(ctx, method, path, data)
+		}
+	}
+	if err == nil {
+		observeAPILatency(method, path, time.Since(start))
+	}
+	if c.breaker != nil {
+		// A caller giving up isn't the socket's fault
+		c.breaker.record(err != nil && !errors.Is(err, context.Canceled))
+	}
+	if err != nil {
+		return fmt.Errorf("firecracker API %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &APIError{Method: method, Path: path, StatusCode: resp.StatusCode}
+		var fault struct {
+			FaultMessage string `json:"fault_message"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&fault); err == nil {
+			apiErr.FaultMessage = fault.FaultMessage
+		}
+		return apiErr
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to decode %s %s response: %w", method, path, err)
+		}
+	}
+	return nil
+}
+
+// send makes one attempt at a request
+func (c *Client) send(ctx context.Context, method, path string, data []byte) (*http.Response, error) {
+	var reader io.Reader
+	if data != nil {
+		reader = bytes.NewReader(data)
+	}
+
+	// The host is ignored; requests are dialled to the socket
+	req, err := http.NewRequestWithContext(ctx, method, "http://localhost"+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.http.Do(req)
+}
+
+// connectionRefused reports whether a call failed before reaching Firecracker
+// because its socket isn't accepting connections yet, so it is safe to retry
+func connectionRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ENOENT)
+}
@@ -0,0 +1,265 @@
+package firecracker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bucket bounds for the vCPU ratio histograms and API call latencies
+var (
+	ratioBuckets   = []float64{0.05, 0.1, 0.25, 0.5, 0.75, 0.9, 1}
+	latencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+)
+
+// Histogram counts observations in cumulative buckets, like a Prometheus
+// histogram: Counts[i] is how many observations were at most Buckets[i]
+type Histogram struct {
+	Buckets []float64 `json:"buckets"`
+	Counts  []uint64  `json:"counts"`
+	Count   uint64    `json:"count"`
+	Sum     float64   `json:"sum"`
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{Buckets: buckets, Counts: make([]uint64, len(buckets))}
+}
+
+func (h *Histogram) observe(v float64) {
+	for i, bound := range h.Buckets {
+		if v <= bound {
+			h.Counts[i]++
+		}
+	}
+	h.Count++
+	h.Sum += v
+}
+
+func (h *Histogram) copy() *Histogram {
+	copied := *h
+	copied.Counts = append([]uint64(nil), h.Counts...)
+	return &copied
+}
+
+// VCPUStats is the host's view of one vCPU thread: how long it ran, and how
+// long it was runnable but waiting for a host CPU, which the guest sees as
+// steal time
+type VCPUStats struct {
+	Index       int     `json:"index"`
+	RunSeconds  float64 `json:"run_seconds"`
+	WaitSeconds float64 `json:"wait_seconds"`
+}
+
+// CPUStats splits a running VM's host CPU time between its vCPU threads,
+// which run guest code, and the rest of the Firecracker process (the VMM,
+// API server and device emulation). The histograms hold each vCPU's share of
+// every sampling interval spent running and waiting.
+type CPUStats struct {
+	VMID        string      `json:"vm_id"`
+	VCPUs       []VCPUStats `json:"vcpus"`
+	VMMSeconds  float64     `json:"vmm_seconds"`
+	Utilization *Histogram  `json:"utilization"`
+	WaitRatio   *Histogram  `json:"wait_ratio"`
+	SampledAt   time.Time   `json:"sampled_at"`
+}
+
+type cpuStatsStore struct {
+	mu    sync.Mutex
+	stats map[string]*CPUStats
+}
+
+// sampleCPUStats reads the thread CPU times of every running VM and records
+// each vCPU's utilization and wait ratio since the previous sample
+func (m *Manager) sampleCPUStats() {
+	m.vmsMu.RLock()
+	pids := make(map[string]int, len(m.vms))
+	for id, fcVM := range m.vms {
+		if process := fcVM.Process.Load(); process != nil {
+			pids[id] = process.Pid
+		}
+	}
+	m.vmsMu.RUnlock()
+
+	m.cpuStats.mu.Lock()
+	defer m.cpuStats.mu.Unlock()
+
+	for id := range m.cpuStats.stats {
+		if _, running := pids[id]; !running {
+			delete(m.cpuStats.stats, id)
+		}
+	}
+
+	for id, pid := range pids {
+		vcpus, vmmSeconds, err := readThreadCPU(pid)
+		if err != nil {
+			m.logger.Debugf("Skipping CPU sample of VM %s: %v", id, err)
+			continue
+		}
+
+		now := time.Now()
+		previous, seen := m.cpuStats.stats[id]
+		current := &CPUStats{VMID: id, VCPUs: vcpus, VMMSeconds: vmmSeconds, SampledAt: now}
+		if !seen {
+			current.Utilization = newHistogram(ratioBuckets)
+			current.WaitRatio = newHistogram(ratioBuckets)
+			m.cpuStats.stats[id] = current
+			continue
+		}
+		current.Utilization = previous.Utilization
+		current.WaitRatio = previous.WaitRatio
+		m.cpuStats.stats[id] = current
+
+		elapsed := now.Sub(previous.SampledAt).Seconds()
+		if elapsed <= 0 {
+			continue
+		}
+		before := make(map[int]VCPUStats, len(previous.VCPUs))
+		for _, vcpu := range previous.VCPUs {
+			before[vcpu.Index] = vcpu
+		}
+		for _, vcpu := range vcpus {
+			last, ok := before[vcpu.Index]
+			if !ok || vcpu.RunSeconds < last.RunSeconds || vcpu.WaitSeconds < last.WaitSeconds {
+				continue
+			}
+			current.Utilization.observe((vcpu.RunSeconds - last.RunSeconds) / elapsed)
+			current.WaitRatio.observe((vcpu.WaitSeconds - last.WaitSeconds) / elapsed)
+		}
+	}
+}
+
+// AllCPUStats returns the latest CPU sample of every running VM
+func (m *Manager) AllCPUStats() map[string]*CPUStats {
+	m.cpuStats.mu.Lock()
+	defer m.cpuStats.mu.Unlock()
+
+	stats := make(map[string]*CPUStats, len(m.cpuStats.stats))
+	for id, s := range m.cpuStats.stats {
+		copied := *s
+		copied.VCPUs = append([]VCPUStats(nil), s.VCPUs...)
+		copied.Utilization = s.Utilization.copy()
+		copied.WaitRatio = s.WaitRatio.copy()
+		stats[id] = &copied
+	}
+	return stats
+}
+
+// readThreadCPU reads the scheduler statistics of a Firecracker process's
+// threads. Threads named "fc_vcpu N" are vCPUs; the rest count as VMM time.
+func readThreadCPU(pid int) ([]VCPUStats, float64, error) {
+	taskDir := filepath.Join("/proc", strconv.Itoa(pid), "task")
+	tids, err := os.ReadDir(taskDir)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var vcpus []VCPUStats
+	var vmmSeconds float64
+	for _, tid := range tids {
+		comm, err := os.ReadFile(filepath.Join(taskDir, tid.Name(), "comm"))
+		if err != nil {
+			continue // the thread exited
+		}
+		run, wait, err := readSchedstat(filepath.Join(taskDir, tid.Name(), "schedstat"))
+		if err != nil {
+			continue
+		}
+
+		name := strings.TrimSpace(string(comm))
+		if index, ok := strings.CutPrefix(name, "fc_vcpu"); ok {
+			n, err := strconv.Atoi(strings.TrimSpace(index))
+			if err == nil {
+				vcpus = append(vcpus, VCPUStats{Index: n, RunSeconds: run, WaitSeconds: wait})
+				continue
+			}
+		}
+		vmmSeconds += run
+	}
+	if len(vcpus) == 0 && vmmSeconds == 0 {
+		return nil, 0, fmt.Errorf("no scheduler statistics for process %d", pid)
+	}
+
+	sort.Slice(vcpus, func(i, j int) bool { return vcpus[i].Index < vcpus[j].Index })
+	return vcpus, vmmSeconds, nil
+}
+
+// readSchedstat reads a thread's time on a CPU and time waiting on a run
+// queue, in seconds
+func readSchedstat(path string) (float64, float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0, 0, fmt.Errorf("malformed %s", path)
+	}
+	run, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	wait, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return float64(run) / 1e9, float64(wait) / 1e9, nil
+}
+
+// apiLatencies holds the latency of Firecracker API calls across all VMs,
+// by method and endpoint
+var apiLatencies = struct {
+	mu         sync.Mutex
+	histograms map[string]*Histogram
+}{histograms: make(map[string]*Histogram)}
+
+// APILatency is the latency histogram of one kind of Firecracker API call
+type APILatency struct {
+	Method   string     `json:"method"`
+	Endpoint string     `json:"endpoint"`
+	Seconds  *Histogram `json:"seconds"`
+}
+
+// observeAPILatency records how long an API call took. Resource IDs are cut
+// from the path, so /drives/rootfs counts as /drives.
+func observeAPILatency(method, path string, d time.Duration) {
+	endpoint := path
+	if i := strings.IndexByte(strings.TrimPrefix(path, "/"), '/'); i >= 0 {
+		endpoint = path[:i+1]
+	}
+	key := method + " " + endpoint
+
+	apiLatencies.mu.Lock()
+	defer apiLatencies.mu.Unlock()
+
+	h, ok := apiLatencies.histograms[key]
+	if !ok {
+		h = newHistogram(latencyBuckets)
+		apiLatencies.histograms[key] = h
+	}
+	h.observe(d.Seconds())
+}
+
+// APILatencies returns the Firecracker API call latency histograms, ordered
+// by endpoint and method
+func APILatencies() []APILatency {
+	apiLatencies.mu.Lock()
+	defer apiLatencies.mu.Unlock()
+
+	latencies := make([]APILatency, 0, len(apiLatencies.histograms))
+	for key, h := range apiLatencies.histograms {
+		method, endpoint, _ := strings.Cut(key, " ")
+		latencies = append(latencies, APILatency{Method: method, Endpoint: endpoint, Seconds: h.copy()})
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		if latencies[i].Endpoint != latencies[j].Endpoint {
+			return latencies[i].Endpoint < latencies[j].Endpoint
+		}
+		return latencies[i].Method < latencies[j].Method
+	})
+	return latencies
+}
@@ -0,0 +1,142 @@
+package firecracker
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Guest failures recognised in console output
+const (
+	failurePanic = "kernel panic"
+	failureOops  = "kernel oops"
+	failureOOM   = "out of memory"
+)
+
+// failureSignatures maps console line fragments to the failure they reveal
+var failureSignatures = []struct {
+	fragment string
+	failure  string
+}{
+	{"Kernel panic - not syncing", failurePanic},
+	{"Oops:", failureOops},
+	{"BUG: unable to handle", failureOops},
+	{"BUG: kernel NULL pointer dereference", failureOops},
+	{"general protection fault", failureOops},
+	{"invoked oom-killer", failureOOM},
+	{"Out of memory: Killed process", failureOOM},
+}
+
+// Limits on what a console scanner keeps
+const (
+	excerptLines   = 5    // lines of context reported with a failure, ending at the match
+	maxConsoleLine = 4096 // longer lines are cut here
+)
+
+// consoleScanner passes console output through to the VM's output destination
+// while watching it, line by line, for guest failures. Each kind of failure is
+// reported once per process.
+type consoleScanner struct {
+	out      io.Writer // nil discards the output
+	onFailed func(failure, excerpt string)
+
+	mu       sync.Mutex
+	partial  []byte
+	recent   []string
+	reported map[string]bool
+}
+
+func newConsoleScanner(out io.Writer, onFailed func(failure, excerpt string)) *consoleScanner {
+	return &consoleScanner{out: out, onFailed: onFailed, reported: make(map[string]bool)}
+}
+
+func (s *consoleScanner) Write(p []byte) (int, error) {
+	s.scan(p)
+	if s.out == nil {
+		return len(p), nil
+	}
+	return s.out.Write(p)
+}
+
+func (s *consoleScanner) scan(p []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.partial = append(s.partial, p...)
+	for {
+		i := bytes.IndexByte(s.partial, '\n')
+		if i < 0 {
+			break
+		}
+		s.line(strings.TrimRight(string(s.partial[:i]), "\r"))
+		s.partial = s.partial[i+1:]
+	}
+	if len(s.partial) > maxConsoleLine {
+		s.line(string(s.partial[:maxConsoleLine]))
+		s.partial = s.partial[:0]
+	}
+}
+
+// line checks one complete console line
+func (s *consoleScanner) line(text string) {
+	if len(text) > maxConsoleLine {
+		text = text[:maxConsoleLine]
+	}
+	if len(s.recent) == excerptLines {
+		s.recent = s.recent[1:]
+	}
+	s.recent = append(s.recent, text)
+
+	for _, signature := range failureSignatures {
+		if !strings.Contains(text, signature.fragment) || s.reported[signature.failure] {
+			continue
+		}
+		s.reported[signature.failure] = true
+		s.onFailed(signature.failure, strings.Join(s.recent, "\n"))
+		return
+	}
+}
+
+// guestFailed records a failure seen on a VM's console. A panic puts the VM in
+// error and runs its crash hooks; an oops or OOM kill marks it degraded and
+// runs its degraded hooks. Either way the excerpt is the hooks' reason. The
+// record is updated in line so failures are applied in order; hooks run in the
+// background so they don't hold up the console.
+func (m *Manager) guestFailed(vmID, failure, excerpt string) {
+	vm, err := m.db.GetVM(vmID)
+	if err != nil {
+		m.logger.Errorf("Failed to get VM %s after guest %s: %v", vmID, failure, err)
+		return
+	}
+	// Output of a process the VM has since moved on from
+	if vm.Status != "running" {
+		return
+	}
+
+	event := HookDegraded
+	if failure == failurePanic {
+		event = HookCrash
+		vm.Status = "error"
+		vm.StatusReason = failure + ": " + lastLine(excerpt)
+	} else {
+		vm.Degraded = true
+		vm.DegradedReason = failure + ": " + lastLine(excerpt)
+	}
+	if err := m.db.UpdateVM(vm); err != nil {
+		m.logger.Errorf("Failed to record guest %s on VM %s: %v", failure, vmID, err)
+		return
+	}
+	m.logger.Warnf("VM %s guest %s:\n%s", vmID, failure, excerpt)
+
+	go func() {
+		if err := m.runHooksWithReason(vm, event, excerpt); err != nil {
+			m.logger.Warnf("VM %s %v", vmID, err)
+		}
+	}()
+}
+
+// lastLine returns the last line of text
+func lastLine(text string) string {
+	return text[strings.LastIndexByte(text, '\n')+1:]
+}
@@ -0,0 +1,68 @@
+package firecracker
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/dhcp"
+)
+
+// DHCPServer returns a DHCP server for the VM bridge that hands each guest its
+// VM's address, or nil when ENABLE_DHCP is off
+func (m *Manager) DHCPServer() (*dhcp.Server, error) {
+	if !m.config.EnableDHCP {
+		return nil, nil
+	}
+	if m.config.DHCPLeaseSeconds <= 0 {
+		return nil, fmt.Errorf("DHCP_LEASE_SECONDS must be positive, not %d", m.config.DHCPLeaseSeconds)
+	}
+
+	pool, err := m.ipPool()
+	if err != nil {
+		return nil, err
+	}
+
+	var dns []net.IP
+	for _, server := range strings.Split(m.config.DHCPDNSServers, ",") {
+		server = strings.TrimSpace(server)
+		if server == "" {
+			continue
+		}
+		ip := net.ParseIP(server).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("DHCP DNS server %q is not an IPv4 address", server)
+		}
+		dns = append(dns, ip)
+	}
+
+	return &dhcp.Server{
+		Interface: m.config.BridgeName,
+		ServerIP:  pool.Gateway(),
+		Mask:      pool.Subnet().Mask,
+		DNS:       dns,
+		LeaseTime: time.Duration(m.config.DHCPLeaseSeconds) * time.Second,
+		Lookup:    m.dhcpLease,
+		Logger:    m.logger,
+	}, nil
+}
+
+// dhcpLease finds the VM holding mac; VMs without an address yet get no lease
+func (m *Manager) dhcpLease(mac net.HardwareAddr) (dhcp.Lease, bool, error) {
+	vm, err := m.db.GetVMByMACAddress(mac.String())
+	if errors.Is(err, sql.ErrNoRows) {
+		return dhcp.Lease{}, false, nil
+	}
+	if err != nil {
+		return dhcp.Lease{}, false, err
+	}
+
+	ip := net.ParseIP(vm.IPAddress).To4()
+	if ip == nil {
+		return dhcp.Lease{}, false, nil
+	}
+	return dhcp.Lease{IP: ip, Hostname: vm.Name}, true, nil
+}
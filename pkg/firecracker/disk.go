@@ -0,0 +1,217 @@
+package firecracker
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+)
+
+// Root filesystem modes, deciding how a VM gets a root filesystem of its own
+const (
+	RootfsModeCopy    = "copy"    // a reflink or full copy of ROOTFS_PATH
+	RootfsModeOverlay = "overlay" // ROOTFS_PATH read-only, with a writable overlay drive
+)
+
+// overlayDriveID is the writable drive an overlay mode VM's changes go to. It
+// follows the root drive, so the guest sees it as /dev/vdb.
+const overlayDriveID = "overlay"
+
+// ValidRootfsMode reports whether mode is one of the root filesystem modes
+func ValidRootfsMode(mode string) bool {
+	return mode == RootfsModeCopy || mode == RootfsModeOverlay
+}
+
+// RootfsMode returns the root filesystem mode for a new VM with the given
+// override, falling back to ROOTFS_MODE
+func (m *Manager) RootfsMode(override string) string {
+	if override != "" {
+		return override
+	}
+	if ValidRootfsMode(m.config.RootfsMode) {
+		return m.config.RootfsMode
+	}
+	return RootfsModeCopy
+}
+
+// ficlone is the FICLONE ioctl, which makes dst share src's blocks copy-on-write
+// on filesystems that support it (XFS, Btrfs)
+const ficlone = 0x40049409
+
+// rootfsPath returns the path of a VM's own copy of the root filesystem, or
+// of its overlay in overlay mode
+func (m *Manager) rootfsPath(vmID string) string {
+	return filepath.Join(m.config.VMDiskDir, vmID+".ext4")
+}
+
+// createRootfs gives a VM a root filesystem of its own and returns the drives
+// it boots from. In copy mode that is a reflink or copy of ROOTFS_PATH, grown to
+// the VM's disk_size. In overlay mode ROOTFS_PATH itself is the read-only root
+// drive, shared by every such VM, and changes go to an empty overlay drive of
+// disk_size that the guest's init mounts over it. The base image is verified
+// first either way.
+func (m *Manager) createRootfs(vm *database.VM) ([]Drive, error) {
+	verified, err := m.verifier.Verify(m.config.RootfsPath, m.config.RootfsSHA256)
+	if err != nil {
+		return nil, err
+	}
+	if !verified {
+		m.logger.Warnf("Using unverified image %s for VM %s", m.config.RootfsPath, vm.ID)
+	}
+
+	if err := os.MkdirAll(m.config.VMDiskDir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create disk directory: %w", err)
+	}
+
+	path := m.rootfsPath(vm.ID)
+	if vm.RootfsMode == RootfsModeOverlay {
+		if err := createOverlay(path, vm.DiskSize); err != nil {
+			return nil, err
+		}
+		return []Drive{
+			{DriveID: "rootfs", PathOnHost: m.config.RootfsPath, IsRootDevice: true, IsReadOnly: true},
+			{DriveID: overlayDriveID, PathOnHost: path},
+		}, nil
+	}
+
+	if err := copyImage(m.config.RootfsPath, path); err != nil {
+		return nil, err
+	}
+
+	if err := m.growRootfs(path, vm.DiskSize); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return []Drive{{DriveID: "rootfs", PathOnHost: path, IsRootDevice: true}}, nil
+}
+
+// overlayBootArgs returns the kernel boot arguments that have the guest mount
+// its overlay drive over the read-only root filesystem
+func (m *Manager) overlayBootArgs() string {
+	return "overlay_root=vdb init=" + m.config.RootfsOverlayInit
+}
+
+// createOverlay makes an empty ext4 filesystem of sizeGB in a sparse file, so
+// it takes next to no time or space until the guest writes to it
+func createOverlay(path string, sizeGB int64) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to create overlay disk: %w", err)
+	}
+	if err := f.Truncate(sizeGB << 30); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to size overlay disk: %w", err)
+	}
+	f.Close()
+
+	// Lazy init leaves the inode tables and journal for the guest kernel to
+	// zero, so the file stays sparse
+	mkfs := exec.Command("mkfs.ext4", "-q", "-F", "-E", "lazy_itable_init=1,lazy_journal_init=1", path)
+	if output, err := mkfs.CombinedOutput(); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to format overlay disk: %w: %s", err, output)
+	}
+	return nil
+}
+
+// copyImage copies src to dst, sharing blocks with a reflink where the
+// filesystem allows it and otherwise skipping runs of zeros so dst stays
+// sparse. dst only appears once it is complete.
+func copyImage(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open base image: %w", err)
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create VM disk: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, tmp.Fd(), ficlone, in.Fd()); errno != 0 {
+		if err := sparseCopy(tmp, in); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to copy base image: %w", err)
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write VM disk: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), dst)
+}
+
+// sparseCopyBlock is the unit sparseCopy checks for zeros
+const sparseCopyBlock = 64 * 1024
+
+// sparseCopy copies src to dst, seeking over blocks of zeros instead of
+// writing them
+func sparseCopy(dst *os.File, src io.Reader) error {
+	buf := make([]byte, sparseCopyBlock)
+	var size int64
+	for {
+		n, err := io.ReadFull(src, buf)
+		if n > 0 {
+			if isZero(buf[:n]) {
+				if _, err := dst.Seek(int64(n), io.SeekCurrent); err != nil {
+					return err
+				}
+			} else if _, err := dst.Write(buf[:n]); err != nil {
+				return err
+			}
+			size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// A trailing hole needs the size set explicitly
+	return dst.Truncate(size)
+}
+
+// isZero reports whether b is all zero bytes
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// growRootfs extends a disk image to sizeGB, sparsely, and grows its ext4
+// filesystem to match. Images already that large are left alone.
+func (m *Manager) growRootfs(path string, sizeGB int64) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	size := sizeGB << 30
+	if size <= info.Size() {
+		return nil
+	}
+
+	if err := os.Truncate(path, size); err != nil {
+		return fmt.Errorf("failed to grow VM disk to %d GB: %w", sizeGB, err)
+	}
+
+	// Without resize2fs the guest sees the old filesystem size on a bigger disk
+	if _, err := exec.LookPath("resize2fs"); err != nil {
+		m.logger.Warnf("resize2fs not found; the filesystem in %s keeps its original size", path)
+		return nil
+	}
+	if output, err := exec.Command("resize2fs", "-f", path).CombinedOutput(); err != nil {
+		m.logger.Warnf("Failed to grow the filesystem in %s: %v: %s", path, err, output)
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+)
+
+// Drift is one difference between what the orchestrator expects a VM's
+// Firecracker config to be and what the config file on disk, or the running
+// VMM, reports
+type Drift struct {
+	// Source is "spec" when the file disagrees with the VM's persisted spec,
+	// "generated" when it differs from the config the orchestrator last wrote,
+	// or "runtime" when the running VMM's machine config differs from the spec
+	Source   string `json:"source"`
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// driftStore keeps the result of the latest drift check per VM
+type driftStore struct {
+	mu      sync.Mutex
+	drifted map[string][]Drift
+}
+
+// CheckDrift compares a VM's config file with its persisted spec and with the
+// config generated for it. The file is rewritten with the config sent over the
+// API socket on every start, so it records what the VMM last booted with. For
+// a running VM the vCPUs and memory the VMM reports are compared too.
+func (m *Manager) CheckDrift(vm *database.VM) ([]Drift, error) {
+	fcVM, exists := m.getVM(vm.ID)
+	if !exists {
+		return nil, fmt.Errorf("VM %s not found in manager", vm.ID)
+	}
+
+	data, err := os.ReadFile(m.configPath(vm.ID))
+	if os.IsNotExist(err) {
+		return []Drift{{Source: "generated", Field: "config_file", Expected: "present", Actual: "missing"}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read VM config: %w", err)
+	}
+
+	var onDisk VMConfig
+	if err := json.Unmarshal(data, &onDisk); err != nil {
+		return []Drift{{Source: "generated", Field: "config_file", Expected: "valid JSON", Actual: err.Error()}}, nil
+	}
+
+	rootfs := m.rootfsPath(vm.ID)
+	if vm.RootfsMode == RootfsModeOverlay {
+		rootfs = m.config.RootfsPath
+	}
+	drifts := specDrift(vm, &onDisk, m.config.KernelPath, rootfs)
+
+	generated, err := json.Marshal(fcVM.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal VM config: %w", err)
+	}
+	sectionDrift, err := configSectionDrift(generated, data)
+	if err != nil {
+		return nil, err
+	}
+
+	drifts = append(drifts, sectionDrift...)
+	return append(drifts, m.runtimeDrift(vm)...), nil
+}
+
+// runtimeDrift compares the machine config a running VM's VMM reports with the
+// VM's spec. Stopped VMs and VMMs that don't answer report nothing.
+func (m *Manager) runtimeDrift(vm *database.VM) []Drift {
+	client, err := m.APIClient(vm.ID)
+	if err != nil {
+		return nil
+	}
+	machine, err := client.GetMachineConfig(context.Background())
+	if err != nil {
+		m.logger.Debugf("Skipping runtime drift check of VM %s: %v", vm.ID, err)
+		return nil
+	}
+
+	var drifts []Drift
+	check := func(field, expected, actual string) {
+		if expected != actual {
+			drifts = append(drifts, Drift{Source: "runtime", Field: field, Expected: expected, Actual: actual})
+		}
+	}
+	check("machine-config.vcpu_count", strconv.Itoa(vm.CPUs), strconv.Itoa(machine.VCPUCount))
+	check("machine-config.mem_size_mib", strconv.FormatInt(vm.Memory, 10), strconv.FormatInt(machine.MemSizeMib, 10))
+	return drifts
+}
+
+// specDrift compares the fields of a config file that derive directly from the VM spec
+func specDrift(vm *database.VM, onDisk *VMConfig, kernelPath, rootfsPath string) []Drift {
+	var drifts []Drift
+	check := func(field, expected, actual string) {
+		if expected != actual {
+			drifts = append(drifts, Drift{Source: "spec", Field: field, Expected: expected, Actual: actual})
+		}
+	}
+
+	check("machine-config.vcpu_count", strconv.Itoa(vm.CPUs), strconv.Itoa(onDisk.MachineConfig.VCPUCount))
+	check("machine-config.mem_size_mib", strconv.FormatInt(vm.Memory, 10), strconv.FormatInt(onDisk.MachineConfig.MemSizeMib, 10))
+	check("boot-source.kernel_image_path", kernelPath, onDisk.BootSource.KernelImagePath)
+	check("entropy", strconv.FormatBool(vm.Entropy), strconv.FormatBool(onDisk.Entropy != nil))
+
+	rootfs := ""
+	for _, drive := range onDisk.Drives {
+		if drive.IsRootDevice {
+			rootfs = drive.PathOnHost
+		}
+	}
+	check("drives.rootfs", rootfsPath, rootfs)
+
+	return drifts
+}
+
+// configSectionDrift compares two Firecracker configs section by section,
+// ignoring formatting and key order
+func configSectionDrift(expected, actual []byte) ([]Drift, error) {
+	var want, got map[string]json.RawMessage
+	if err := json.Unmarshal(expected, &want); err != nil {
+		return nil, fmt.Errorf("failed to parse generated config: %w", err)
+	}
+	if err := json.Unmarshal(actual, &got); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	sections := make(map[string]bool)
+	for section := range want {
+		sections[section] = true
+	}
+	for section := range got {
+		sections[section] = true
+	}
+	names := make([]string, 0, len(sections))
+	for section := range sections {
+		names = append(names, section)
+	}
+	sort.Strings(names)
+
+	var drifts []Drift
+	for _, section := range names {
+		w, g := canonicalJSON(want[section]), canonicalJSON(got[section])
+		if !bytes.Equal(w, g) {
+			drifts = append(drifts, Drift{Source: "generated", Field: section, Expected: string(w), Actual: string(g)})
+		}
+	}
+	return drifts, nil
+}
+
+// canonicalJSON re-encodes a JSON value so equal values compare byte for byte
+func canonicalJSON(raw json.RawMessage) []byte {
+	if len(raw) == 0 {
+		return []byte("null")
+	}
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return raw
+	}
+	canonical, err := json.Marshal(value)
+	if err != nil {
+		return raw
+	}
+	return canonical
+}
+
+// Drift returns the drift found by the latest check of a VM
+func (m *Manager) Drift(vmID string) []Drift {
+	m.drift.mu.Lock()
+	defer m.drift.mu.Unlock()
+	return m.drift.drifted[vmID]
+}
+
+// RunDriftDetector checks every VM the manager knows for config drift each
+// interval until the context is cancelled, logging when drift appears or clears
+func (m *Manager) RunDriftDetector(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.checkAllDrift()
+		}
+	}
+}
+
+// checkAllDrift runs one drift check pass over the VMs in the manager
+func (m *Manager) checkAllDrift() {
+	m.vmsMu.RLock()
+	ids := make([]string, 0, len(m.vms))
+	for id := range m.vms {
+		ids = append(ids, id)
+	}
+	m.vmsMu.RUnlock()
+
+	for _, id := range ids {
+		vm, err := m.db.GetVM(id)
+		if err != nil {
+			continue
+		}
+		drifts, err := m.CheckDrift(vm)
+		if err != nil {
+			m.logger.Warnf("Failed to check VM %s for config drift: %v", id, err)
+			continue
+		}
+		m.RecordDrift(id, drifts)
+	}
+}
+
+// RecordDrift stores the result of a drift check, logging changes in drift
+// state. A VM that starts drifting has its drift hooks run in the background.
+func (m *Manager) RecordDrift(vmID string, drifts []Drift) {
+	m.drift.mu.Lock()
+	_, wasDrifted := m.drift.drifted[vmID]
+	if len(drifts) == 0 {
+		if wasDrifted {
+			m.logger.Infof("Config drift on VM %s cleared", vmID)
+			delete(m.drift.drifted, vmID)
+		}
+		m.drift.mu.Unlock()
+		return
+	}
+	m.drift.drifted[vmID] = drifts
+	m.drift.mu.Unlock()
+
+	if wasDrifted {
+		return
+	}
+	fields := make([]string, 0, len(drifts))
+	for _, drift := range drifts {
+		m.logger.Warnf("Config drift detected on VM %s: %s %s is %s, expected %s", vmID, drift.Source, drift.Field, drift.Actual, drift.Expected)
+		fields = append(fields, drift.Source+" "+drift.Field)
+	}
+
+	vm, err := m.db.GetVM(vmID)
+	if err != nil {
+		m.logger.Errorf("Failed to get VM %s after config drift: %v", vmID, err)
+		return
+	}
+	go func() {
+		if err := m.runHooksWithReason(vm, HookDrift, "config drift in "+strings.Join(fields, ", ")); err != nil {
+			m.logger.Warnf("VM %s %v", vmID, err)
+		}
+	}()
+}
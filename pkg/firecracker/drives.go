@@ -0,0 +1,163 @@
+package firecracker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrVMRunning is returned for operations that require the VM to be stopped
+	ErrVMRunning = errors.New("VM is running")
+	// ErrDriveConflict is returned when a drive attachment would violate exclusivity
+	ErrDriveConflict = errors.New("drive conflict")
+	// ErrDriveNotFound is returned when a VM has no drive with the given ID
+	ErrDriveNotFound = errors.New("drive not found")
+	// ErrInvalidDrive is returned for drive IDs and paths that can't be attached
+	ErrInvalidDrive = errors.New("invalid drive")
+)
+
+// validDriveID matches the drive IDs Firecracker accepts, which are also safe
+// to use in file names
+var validDriveID = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// AttachDrive attaches a secondary drive to a stopped VM. Read-only drives may be
+// shared by any number of VMs, while a writable drive must be attached exclusively.
+func (m *Manager) AttachDrive(vmID string, drive *database.DriveAttachment) error {
+	m.drivesMu.Lock()
+	defer m.drivesMu.Unlock()
+
+	fcVM, exists := m.getVM(vmID)
+	if !exists {
+		return fmt.Errorf("VM %s not found in manager", vmID)
+	}
+	if fcVM.Process.Load() != nil {
+		return fmt.Errorf("cannot attach drive to VM %s: %w", vmID, ErrVMRunning)
+	}
+
+	if !validDriveID.MatchString(drive.DriveID) {
+		return fmt.Errorf("drive ID %q may only contain letters, digits and _: %w", drive.DriveID, ErrInvalidDrive)
+	}
+
+	// Links are resolved first so the exclusivity check below sees through them
+	path, err := m.resolveVolumePath(drive.PathOnHost)
+	if err != nil {
+		return err
+	}
+	drive.PathOnHost = path
+
+	if drive.DriveID == rescueDriveID {
+		return fmt.Errorf("drive ID %s is reserved for rescue mode: %w", rescueDriveID, ErrDriveConflict)
+	}
+	for _, existing := range fcVM.Config.Drives {
+		if existing.DriveID == drive.DriveID {
+			return fmt.Errorf("VM %s already has a drive %s: %w", vmID, drive.DriveID, ErrDriveConflict)
+		}
+	}
+
+	attachments, err := m.db.ListDriveAttachmentsByPath(path)
+	if err != nil {
+		return fmt.Errorf("failed to check drive attachments: %w", err)
+	}
+	for _, existing := range attachments {
+		if !drive.ReadOnly || !existing.ReadOnly {
+			return fmt.Errorf("%s is attached to VM %s (read_only=%t): %w", path, existing.VMID, existing.ReadOnly, ErrDriveConflict)
+		}
+	}
+
+	drive.ID = uuid.New().String()
+	drive.VMID = vmID
+	if err := m.db.CreateDriveAttachment(drive); err != nil {
+		return fmt.Errorf("failed to save drive attachment: %w", err)
+	}
+
+	fcVM.Config.Drives = append(fcVM.Config.Drives, Drive{
+		DriveID:      drive.DriveID,
+		PathOnHost:   drive.PathOnHost,
+		IsRootDevice: false,
+		IsReadOnly:   drive.ReadOnly,
+	})
+	if err := m.writeConfig(vmID, fcVM.Config); err != nil {
+		return err
+	}
+
+	m.logger.Infof("Attached drive %s (%s, read_only=%t) to VM %s", drive.DriveID, drive.PathOnHost, drive.ReadOnly, vmID)
+	return nil
+}
+
+// resolveVolumePath returns the real path of an image to attach as a drive,
+// which must be a file inside VOLUMES_DIR once symlinks are resolved
+func (m *Manager) resolveVolumePath(pathOnHost string) (string, error) {
+	if m.config.VolumesDir == "" {
+		return "", fmt.Errorf("VOLUMES_DIR is not set: %w", ErrInvalidDrive)
+	}
+	dir, err := filepath.EvalSymlinks(m.config.VolumesDir)
+	if err != nil {
+		return "", fmt.Errorf("VOLUMES_DIR %s: %v: %w", m.config.VolumesDir, err, ErrInvalidDrive)
+	}
+
+	path, err := filepath.EvalSymlinks(pathOnHost)
+	if err != nil {
+		return "", fmt.Errorf("drive path %s is not accessible: %v: %w", pathOnHost, err, ErrInvalidDrive)
+	}
+	if !pathWithin(dir, path) {
+		return "", fmt.Errorf("drive path %s is outside VOLUMES_DIR %s: %w", pathOnHost, m.config.VolumesDir, ErrInvalidDrive)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return "", fmt.Errorf("drive path %s is not a file: %w", pathOnHost, ErrInvalidDrive)
+	}
+	return path, nil
+}
+
+// pathWithin reports whether path is below dir; both must already be clean
+// absolute paths with symlinks resolved
+func pathWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// DetachDrive removes a secondary drive from a stopped VM
+func (m *Manager) DetachDrive(vmID, driveID string) error {
+	m.drivesMu.Lock()
+	defer m.drivesMu.Unlock()
+
+	fcVM, exists := m.getVM(vmID)
+	if !exists {
+		return fmt.Errorf("VM %s not found in manager", vmID)
+	}
+	if fcVM.Process.Load() != nil {
+		return fmt.Errorf("cannot detach drive from VM %s: %w", vmID, ErrVMRunning)
+	}
+
+	drives := make([]Drive, 0, len(fcVM.Config.Drives))
+	found := false
+	for _, drive := range fcVM.Config.Drives {
+		if drive.DriveID == driveID && !drive.IsRootDevice && drive.DriveID != overlayDriveID {
+			found = true
+			continue
+		}
+		drives = append(drives, drive)
+	}
+	if !found {
+		return fmt.Errorf("VM %s has no drive %s: %w", vmID, driveID, ErrDriveNotFound)
+	}
+
+	if err := m.db.DeleteDriveAttachment(vmID, driveID); err != nil {
+		return fmt.Errorf("failed to delete drive attachment: %w", err)
+	}
+
+	fcVM.Config.Drives = drives
+	if err := m.writeConfig(vmID, fcVM.Config); err != nil {
+		return err
+	}
+
+	m.logger.Infof("Detached drive %s from VM %s", driveID, vmID)
+	return nil
+}
@@ -0,0 +1,202 @@
+package firecracker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/google/uuid"
+)
+
+// ErrDiskCopyNotAllowed is returned when a VM's disks could still be changing
+var ErrDiskCopyNotAllowed = errors.New("VM must be stopped or quarantined to copy its disks")
+
+// StartDiskCopy copies every drive of a stopped or quarantined VM in the
+// background, recording a SHA-256 checksum of each copy
+func (m *Manager) StartDiskCopy(vmID string) (*database.DiskCopy, error) {
+	vm, err := m.db.GetVM(vmID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VM from database: %w", err)
+	}
+
+	fcVM, exists := m.getVM(vmID)
+	if !exists {
+		return nil, fmt.Errorf("VM %s not found in manager", vmID)
+	}
+
+	running := fcVM.Process.Load() != nil
+	if running && !vm.Quarantined {
+		return nil, ErrDiskCopyNotAllowed
+	}
+
+	diskCopy := &database.DiskCopy{
+		ID:        uuid.New().String(),
+		VMID:      vmID,
+		Status:    database.DiskCopyRunning,
+		Live:      running,
+		Drives:    []database.DiskCopyDrive{},
+		StartedAt: time.Now(),
+	}
+
+	// Drive IDs name the image files, so one that isn't a plain identifier
+	// could write outside the copy's directory
+	for _, drive := range fcVM.Config.Drives {
+		if _, err := m.DiskCopyImagePath(diskCopy.ID, drive.DriveID); err != nil {
+			return nil, err
+		}
+		diskCopy.Drives = append(diskCopy.Drives, database.DiskCopyDrive{
+			DriveID: drive.DriveID,
+			Source:  drive.PathOnHost,
+		})
+	}
+
+	if err := os.MkdirAll(m.diskCopyDir(diskCopy.ID), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create disk copy directory: %w", err)
+	}
+	if err := m.db.CreateDiskCopy(diskCopy); err != nil {
+		os.RemoveAll(m.diskCopyDir(diskCopy.ID))
+		return nil, fmt.Errorf("failed to save disk copy: %w", err)
+	}
+
+	m.logger.Infof("Started disk copy %s of VM %s (%d drives)", diskCopy.ID, vmID, len(diskCopy.Drives))
+
+	// The goroutine works on its own copy of the record; readers go to the database
+	progress := *diskCopy
+	progress.Drives = append([]database.DiskCopyDrive(nil), diskCopy.Drives...)
+	go m.runDiskCopy(&progress)
+
+	return diskCopy, nil
+}
+
+// runDiskCopy copies the drives of a disk copy one by one, saving its
+// progress after each
+func (m *Manager) runDiskCopy(diskCopy *database.DiskCopy) {
+	for i := range diskCopy.Drives {
+		drive := diskCopy.Drives[i]
+		path, _ := m.DiskCopyImagePath(diskCopy.ID, drive.DriveID)
+		size, sum, err := copyFileWithChecksum(drive.Source, path)
+		if err != nil {
+			now := time.Now()
+			diskCopy.Status = database.DiskCopyFailed
+			diskCopy.Error = fmt.Sprintf("drive %s: %v", drive.DriveID, err)
+			diskCopy.FinishedAt = &now
+			m.logger.Warnf("Disk copy %s failed: %s", diskCopy.ID, diskCopy.Error)
+			if err := m.db.UpdateDiskCopy(diskCopy); err != nil {
+				m.logger.Errorf("Failed to save disk copy %s: %v", diskCopy.ID, err)
+			}
+			return
+		}
+
+		diskCopy.Drives[i].SizeBytes = size
+		diskCopy.Drives[i].SHA256 = sum
+		if err := m.db.UpdateDiskCopy(diskCopy); err != nil {
+			m.logger.Errorf("Failed to save disk copy %s: %v", diskCopy.ID, err)
+		}
+	}
+
+	now := time.Now()
+	diskCopy.Status = database.DiskCopyCompleted
+	diskCopy.FinishedAt = &now
+	if err := m.db.UpdateDiskCopy(diskCopy); err != nil {
+		m.logger.Errorf("Failed to save disk copy %s: %v", diskCopy.ID, err)
+		return
+	}
+	m.logger.Infof("Disk copy %s of VM %s completed", diskCopy.ID, diskCopy.VMID)
+}
+
+// diskCopyDir is where the images of a disk copy are kept
+func (m *Manager) diskCopyDir(copyID string) string {
+	return filepath.Join(m.config.SocketDir, "forensics", copyID)
+}
+
+// DiskCopyImagePath returns the file a drive of a disk copy is copied to,
+// refusing drive IDs that could name a file outside the copy's directory
+func (m *Manager) DiskCopyImagePath(copyID, driveID string) (string, error) {
+	if !validDriveID.MatchString(driveID) {
+		return "", fmt.Errorf("invalid drive ID %q", driveID)
+	}
+	dir := m.diskCopyDir(copyID)
+	path := filepath.Join(dir, driveID+".img")
+	if filepath.Dir(path) != dir {
+		return "", fmt.Errorf("invalid drive ID %q", driveID)
+	}
+	return path, nil
+}
+
+// copyFileWithChecksum copies a file and returns its size and SHA-256 checksum
+func copyFileWithChecksum(src, dst string) (int64, string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, "", err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, "", err
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, hash), in)
+	if err != nil {
+		out.Close()
+		return 0, "", err
+	}
+	if err := out.Close(); err != nil {
+		return 0, "", err
+	}
+
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// GetDiskCopy returns a disk copy of a VM
+func (m *Manager) GetDiskCopy(vmID, copyID string) (*database.DiskCopy, bool) {
+	diskCopy, err := m.db.GetDiskCopy(copyID)
+	if err != nil || diskCopy.VMID != vmID {
+		return nil, false
+	}
+	return diskCopy, true
+}
+
+// ListDiskCopies returns the disk copies of a VM, oldest first
+func (m *Manager) ListDiskCopies(vmID string) ([]*database.DiskCopy, error) {
+	return m.db.ListDiskCopiesByVM(vmID)
+}
+
+// DeleteDiskCopy removes a finished disk copy and its images
+func (m *Manager) DeleteDiskCopy(vmID, copyID string) (bool, error) {
+	diskCopy, exists := m.GetDiskCopy(vmID, copyID)
+	if !exists {
+		return false, nil
+	}
+	if diskCopy.Status == database.DiskCopyRunning {
+		return true, errors.New("disk copy is still running")
+	}
+
+	if err := os.RemoveAll(m.diskCopyDir(copyID)); err != nil {
+		return true, fmt.Errorf("failed to remove disk copy: %w", err)
+	}
+	if err := m.db.DeleteDiskCopy(copyID); err != nil {
+		return true, fmt.Errorf("failed to delete disk copy: %w", err)
+	}
+	return true, nil
+}
+
+// failInterruptedDiskCopies marks copies that were running when the
+// orchestrator last stopped as failed; their images are incomplete
+func (m *Manager) failInterruptedDiskCopies() {
+	count, err := m.db.FailRunningDiskCopies("interrupted by an orchestrator restart")
+	if err != nil {
+		m.logger.Errorf("Failed to mark interrupted disk copies: %v", err)
+		return
+	}
+	if count > 0 {
+		m.logger.Warnf("Marked %d interrupted disk copies as failed", count)
+	}
+}
@@ -0,0 +1,237 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+)
+
+// Lifecycle hook events
+const (
+	HookPreStart  = "pre-start"
+	HookPostStart = "post-start"
+	HookPreStop   = "pre-stop"
+	HookIdle      = "idle"     // the VM has been idle for IDLE_AFTER_HOURS
+	HookCrash     = "crash"    // the guest kernel panicked
+	HookDegraded  = "degraded" // the guest logged an oops or the OOM killer ran
+	HookDrift     = "drift"    // the VM's config file or running VMM no longer matches its spec
+)
+
+// Lifecycle hook types
+const (
+	HookTypeExec    = "exec"    // run a script on the host
+	HookTypeWebhook = "webhook" // POST the event to a URL
+)
+
+// Hook timeouts
+const (
+	DefaultHookTimeout = 30 * time.Second
+	MaxHookTimeout     = 5 * time.Minute
+)
+
+// ErrHookFailed is returned when a pre-start hook fails and the VM is not started
+var ErrHookFailed = errors.New("lifecycle hook failed")
+
+// ErrHookNotAllowed is returned for exec hooks outside HOOKS_DIR and webhooks
+// aimed at addresses they may not reach
+var ErrHookNotAllowed = errors.New("hook target not allowed")
+
+// hookPayload is the JSON body sent to webhooks
+type hookPayload struct {
+	Event     string    `json:"event"`
+	VMID      string    `json:"vm_id"`
+	VMName    string    `json:"vm_name"`
+	IPAddress string    `json:"ip_address"`
+	Reason    string    `json:"reason,omitempty"` // what triggered crash and degraded events
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// runHooks runs a VM's hooks for an event in registration order, stopping at the
+// first failure
+func (m *Manager) runHooks(vm *database.VM, event string) error {
+	return m.runHooksWithReason(vm, event, "")
+}
+
+// runHooksWithReason runs a VM's hooks for an event, telling them why it fired
+func (m *Manager) runHooksWithReason(vm *database.VM, event, reason string) error {
+	hooks, err := m.db.ListLifecycleHooksByVM(vm.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load lifecycle hooks: %w", err)
+	}
+
+	for _, hook := range hooks {
+		if hook.Event != event {
+			continue
+		}
+
+		timeout := time.Duration(hook.TimeoutSeconds) * time.Second
+		if timeout <= 0 || timeout > MaxHookTimeout {
+			timeout = DefaultHookTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+
+		switch hook.Type {
+		case HookTypeExec:
+			err = m.runExecHook(ctx, hook, vm, reason)
+		case HookTypeWebhook:
+			err = m.runWebhook(ctx, hook, vm, reason)
+		default:
+			err = fmt.Errorf("unknown hook type %q", hook.Type)
+		}
+		cancel()
+
+		if err != nil {
+			return fmt.Errorf("%s hook %s for VM %s: %v: %w", event, hook.ID, vm.ID, err, ErrHookFailed)
+		}
+		m.logger.Infof("Ran %s %s hook %s for VM %s", event, hook.Type, hook.ID, vm.ID)
+	}
+
+	return nil
+}
+
+// ResolveExecHook returns the real path of an exec hook's script, which must be
+// an executable file inside HOOKS_DIR once symlinks are resolved. Hooks run as
+// the orchestrator, so anything else is refused.
+func (m *Manager) ResolveExecHook(target string) (string, error) {
+	if m.config.HooksDir == "" {
+		return "", fmt.Errorf("exec hooks are disabled because HOOKS_DIR is not set: %w", ErrHookNotAllowed)
+	}
+	if !filepath.IsAbs(target) {
+		return "", fmt.Errorf("%s is not an absolute path: %w", target, ErrHookNotAllowed)
+	}
+
+	dir, err := filepath.EvalSymlinks(m.config.HooksDir)
+	if err != nil {
+		return "", fmt.Errorf("HOOKS_DIR %s: %v: %w", m.config.HooksDir, err, ErrHookNotAllowed)
+	}
+	path, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v: %w", target, err, ErrHookNotAllowed)
+	}
+	if !pathWithin(dir, path) {
+		return "", fmt.Errorf("%s is outside HOOKS_DIR %s: %w", target, m.config.HooksDir, ErrHookNotAllowed)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Mode()&0111 == 0 {
+		return "", fmt.Errorf("%s is not an executable file: %w", target, ErrHookNotAllowed)
+	}
+	return path, nil
+}
+
+// runExecHook runs a host-side script with the VM described in its environment.
+// The target is resolved again here, so a script swapped for a symlink out of
+// HOOKS_DIR after it was registered still doesn't run.
+func (m *Manager) runExecHook(ctx context.Context, hook *database.LifecycleHook, vm *database.VM, reason string) error {
+	path, err := m.ResolveExecHook(hook.Target)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Env = append(os.Environ(),
+		"HOOK_EVENT="+hook.Event,
+		"VM_ID="+vm.ID,
+		"VM_NAME="+vm.Name,
+		"VM_IP_ADDRESS="+vm.IPAddress,
+		"HOOK_REASON="+reason,
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if len(output) > 512 {
+			output = output[len(output)-512:]
+		}
+		return fmt.Errorf("%s: %w: %s", hook.Target, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// runWebhook POSTs the event to a URL and expects a 2xx response
+func (m *Manager) runWebhook(ctx context.Context, hook *database.LifecycleHook, vm *database.VM, reason string) error {
+	body, err := json.Marshal(hookPayload{
+		Event:     hook.Event,
+		VMID:      vm.ID,
+		VMName:    vm.Name,
+		IPAddress: vm.IPAddress,
+		Reason:    reason,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.Target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.hookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", hook.Target, resp.Status)
+	}
+	return nil
+}
+
+// CheckHookAddress reports whether a webhook hook may connect to ip. Loopback,
+// link-local (which includes cloud metadata endpoints), multicast and
+// unspecified addresses are always refused; private and shared address space,
+// which covers the VM subnet, only with HOOK_ALLOW_PRIVATE_NETWORK.
+func CheckHookAddress(ip net.IP, allowPrivate bool) error {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("%s: %w", ip, ErrHookNotAllowed)
+	}
+	if !allowPrivate && (ip.IsPrivate() || sharedAddressSpace.Contains(ip)) {
+		return fmt.Errorf("%s is a private address: %w", ip, ErrHookNotAllowed)
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, RFC 6598
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// newHookClient returns the HTTP client webhook hooks are sent with. Addresses
+// are checked after DNS resolution, on every connection, so neither a hostname
+// resolving to an internal address nor a redirect to one gets through, and
+// proxies from the environment are ignored for the same reason.
+func newHookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return fmt.Errorf("%s: %w", host, ErrHookNotAllowed)
+			}
+			return CheckHookAddress(ip, allowPrivate)
+		},
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
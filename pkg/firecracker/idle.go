@@ -0,0 +1,174 @@
+package firecracker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Idle actions, taken on VMs that stay below the idle thresholds for IDLE_AFTER_HOURS
+const (
+	IdleActionNone   = "none"   // never act on the VM
+	IdleActionNotify = "notify" // run the VM's idle hooks
+	IdleActionStop   = "stop"   // run the idle hooks, then stop the VM
+	IdleActionDelete = "delete" // run the idle hooks, then delete the VM
+)
+
+// clockTicksPerSecond is the kernel's USER_HZ, the unit of CPU times in /proc
+const clockTicksPerSecond = 100
+
+// ValidIdleAction reports whether action is one of the idle actions
+func ValidIdleAction(action string) bool {
+	switch action {
+	case IdleActionNone, IdleActionNotify, IdleActionStop, IdleActionDelete:
+		return true
+	}
+	return false
+}
+
+// Activity is a running VM's resource use between its last two samples
+type Activity struct {
+	VMID       string     `json:"vm_id"`
+	CPUPercent float64    `json:"cpu_percent"`              // of one host CPU
+	NetworkBPS float64    `json:"network_bytes_per_second"` // received and transmitted
+	IdleSince  *time.Time `json:"idle_since,omitempty"`     // set while the VM is below the idle thresholds
+	SampledAt  time.Time  `json:"sampled_at"`
+}
+
+// activityCounters are the cumulative counters activity is derived from
+type activityCounters struct {
+	cpuTicks uint64
+	netBytes uint64
+	at       time.Time
+}
+
+// activityTarget is the process and TAP device of a running VM to sample
+type activityTarget struct {
+	pid int
+	tap string
+}
+
+type activityStore struct {
+	mu       sync.Mutex
+	counters map[string]activityCounters
+	activity map[string]*Activity
+}
+
+// SampleActivity measures the CPU and network use of every running VM since the
+// previous call and returns it. A VM is idle from the first sample below both
+// IDLE_CPU_PERCENT and IDLE_NETWORK_BYTES_PER_SECOND until a sample above either;
+// its first sample after starting only sets a baseline.
+func (m *Manager) SampleActivity() []Activity {
+	m.vmsMu.RLock()
+	procs := make(map[string]activityTarget, len(m.vms))
+	for id, fcVM := range m.vms {
+		if process := fcVM.Process.Load(); process != nil {
+			procs[id] = activityTarget{pid: process.Pid, tap: fcVM.TAPDevice}
+		}
+	}
+	m.vmsMu.RUnlock()
+
+	m.activity.mu.Lock()
+	defer m.activity.mu.Unlock()
+
+	for id := range m.activity.counters {
+		if _, running := procs[id]; !running {
+			delete(m.activity.counters, id)
+			delete(m.activity.activity, id)
+		}
+	}
+
+	samples := make([]Activity, 0, len(procs))
+	for id, target := range procs {
+		cpuTicks, err := readCPUTicks(target.pid)
+		if err != nil {
+			m.logger.Debugf("Skipping activity sample of VM %s: %v", id, err)
+			continue
+		}
+		net, err := readTAPStats(target.tap)
+		if err != nil {
+			m.logger.Debugf("Skipping activity sample of VM %s: %v", id, err)
+			continue
+		}
+
+		now := time.Now()
+		current := activityCounters{cpuTicks: cpuTicks, netBytes: net.RxBytes + net.TxBytes, at: now}
+		previous, seen := m.activity.counters[id]
+		m.activity.counters[id] = current
+		if !seen || current.cpuTicks < previous.cpuTicks || current.netBytes < previous.netBytes {
+			continue
+		}
+
+		elapsed := now.Sub(previous.at).Seconds()
+		if elapsed <= 0 {
+			continue
+		}
+		activity := &Activity{
+			VMID:       id,
+			CPUPercent: float64(current.cpuTicks-previous.cpuTicks) / clockTicksPerSecond / elapsed * 100,
+			NetworkBPS: float64(current.netBytes-previous.netBytes) / elapsed,
+			SampledAt:  now,
+		}
+		if activity.CPUPercent < float64(m.config.IdleCPUPercent) && activity.NetworkBPS < float64(m.config.IdleNetworkBPS) {
+			activity.IdleSince = &previous.at
+			if last, ok := m.activity.activity[id]; ok && last.IdleSince != nil {
+				activity.IdleSince = last.IdleSince
+			}
+		}
+		m.activity.activity[id] = activity
+		samples = append(samples, *activity)
+	}
+
+	return samples
+}
+
+// VMActivity returns a VM's activity as of the last sample
+func (m *Manager) VMActivity(vmID string) (*Activity, bool) {
+	m.activity.mu.Lock()
+	defer m.activity.mu.Unlock()
+
+	activity, ok := m.activity.activity[vmID]
+	if !ok {
+		return nil, false
+	}
+	copied := *activity
+	return &copied, true
+}
+
+// RunIdleHooks runs a VM's idle hooks to tell its owner it has been idle
+func (m *Manager) RunIdleHooks(vmID string) error {
+	vm, err := m.db.GetVM(vmID)
+	if err != nil {
+		return fmt.Errorf("failed to get VM from database: %w", err)
+	}
+	return m.runHooks(vm, HookIdle)
+}
+
+// readCPUTicks returns the user and system CPU time a process has used, in clock ticks
+func readCPUTicks(pid int) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, err
+	}
+
+	// utime and stime are the 14th and 15th fields; the parenthesised command
+	// name may contain spaces, so fields are counted from its closing parenthesis
+	stat := string(data)
+	fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("malformed stat for process %d", pid)
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return utime + stime, nil
+}
@@ -0,0 +1,67 @@
+package firecracker
+
+import (
+	"fmt"
+	"os"
+)
+
+// Image roles
+const (
+	ImageKernel       = "kernel"
+	ImageRootfs       = "rootfs"
+	ImageRescueKernel = "rescue-kernel"
+	ImageRescueRootfs = "rescue-rootfs"
+)
+
+// Image is a kernel or root filesystem VMs are booted from
+type Image struct {
+	Role      string `json:"role"`
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"size_bytes"`
+	Verified  bool   `json:"verified"`        // checksum or signature checked
+	Error     string `json:"error,omitempty"` // why the image can't be used
+}
+
+// Images describes the configured images, checking each the way a boot would.
+// Verification results are cached, so only changed images are hashed again.
+func (m *Manager) Images() []Image {
+	images := []Image{
+		{Role: ImageKernel, Path: m.config.KernelPath},
+		{Role: ImageRootfs, Path: m.config.RootfsPath},
+	}
+	if m.config.RescueRootfsPath != "" {
+		images = append(images,
+			Image{Role: ImageRescueKernel, Path: m.rescueKernelPath()},
+			Image{Role: ImageRescueRootfs, Path: m.config.RescueRootfsPath})
+	}
+
+	expected := map[string]string{
+		ImageKernel: m.config.KernelSHA256,
+		ImageRootfs: m.config.RootfsSHA256,
+	}
+	for i := range images {
+		image := &images[i]
+		info, err := os.Stat(image.Path)
+		if err != nil {
+			image.Error = err.Error()
+			continue
+		}
+		image.SizeBytes = info.Size()
+		if image.Verified, err = m.verifier.Verify(image.Path, expected[image.Role]); err != nil {
+			image.Error = err.Error()
+		}
+	}
+	return images
+}
+
+// CheckImages returns an error wrapping ErrImageUnverified if a new VM could
+// not be created and booted from the configured kernel and root filesystem
+func (m *Manager) CheckImages() error {
+	for _, image := range m.Images() {
+		if image.Role != ImageKernel && image.Role != ImageRootfs || image.Error == "" {
+			continue
+		}
+		return fmt.Errorf("%s %s: %s: %w", image.Role, image.Path, image.Error, ErrImageUnverified)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package firecracker
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/ipam"
+)
+
+var (
+	// ErrInvalidIP is returned for addresses that can't be assigned to a guest
+	ErrInvalidIP = ipam.ErrInvalidIP
+	// ErrIPInUse is returned when an address is already assigned to another VM
+	ErrIPInUse = ipam.ErrIPInUse
+)
+
+// ipPool returns the address pool configured by VM_SUBNET and VM_IP_POOL
+func (m *Manager) ipPool() (*ipam.Pool, error) {
+	return ipam.New(m.db, m.config.VMSubnet, m.config.VMIPPool)
+}
+
+// assignIPAddress gives a VM its requested static address, or the next free
+// one, and leases and persists it before releasing the lock so concurrent
+// creates can't clash
+func (m *Manager) assignIPAddress(vm *database.VM) error {
+	m.ipMu.Lock()
+	defer m.ipMu.Unlock()
+
+	pool, err := m.ipPool()
+	if err != nil {
+		return err
+	}
+
+	if vm.IPAddress != "" {
+		if err := m.validateStaticIP(pool, vm.ID, vm.IPAddress); err != nil {
+			return err
+		}
+	} else {
+		reserved, err := m.reservedIPAddresses()
+		if err != nil {
+			return err
+		}
+		ip, err := pool.Next(reserved)
+		if err != nil {
+			return err
+		}
+		vm.IPAddress = ip
+	}
+
+	if err := pool.Lease(vm.ID, vm.IPAddress); err != nil {
+		return err
+	}
+	if err := m.db.UpdateVM(vm); err != nil {
+		if releaseErr := pool.Release(vm.ID); releaseErr != nil {
+			m.logger.Warnf("Failed to release IP address of VM %s: %v", vm.ID, releaseErr)
+		}
+		return fmt.Errorf("failed to save VM IP address: %w", err)
+	}
+
+	return nil
+}
+
+// LeaseIPAddress leases a VM's static address ahead of its creation, so a
+// concurrent request for the same address fails with ErrIPInUse up front
+// rather than when the VM is set up. VMs without a static address are left
+// for CreateVM.
+func (m *Manager) LeaseIPAddress(vm *database.VM) error {
+	if vm.IPAddress == "" {
+		return nil
+	}
+	return m.assignIPAddress(vm)
+}
+
+// releaseIPAddress frees the addresses leased to a VM
+func (m *Manager) releaseIPAddress(vmID string) error {
+	m.ipMu.Lock()
+	defer m.ipMu.Unlock()
+
+	if err := m.db.DeleteIPLeasesByVM(vmID); err != nil {
+		return fmt.Errorf("failed to release IP addresses of VM %s: %w", vmID, err)
+	}
+	return nil
+}
+
+// generateIPAddress returns the first free address in the pool, passing over
+// the skipped ones. The caller holds ipMu.
+func (m *Manager) generateIPAddress(skip map[string]bool) (string, error) {
+	pool, err := m.ipPool()
+	if err != nil {
+		return "", err
+	}
+	return pool.Next(skip)
+}
+
+// ValidateStaticIP checks that a requested guest address lies inside the VM
+// subnet, is not the network, gateway or broadcast address, and is neither
+// leased to another VM nor reserved
+func (m *Manager) ValidateStaticIP(vmID, ip string) error {
+	pool, err := m.ipPool()
+	if err != nil {
+		return err
+	}
+	return m.validateStaticIP(pool, vmID, ip)
+}
+
+// validateStaticIP is ValidateStaticIP against an already built pool
+func (m *Manager) validateStaticIP(pool *ipam.Pool, vmID, ip string) error {
+	if err := pool.Check(vmID, ip); err != nil {
+		return err
+	}
+
+	reserved, err := m.reservedIPAddresses()
+	if err != nil {
+		return err
+	}
+	if reserved[net.ParseIP(ip).To4().String()] {
+		return fmt.Errorf("%s is held by a reservation: %w", ip, ErrIPInUse)
+	}
+
+	return nil
+}
+
+// reconcileIPLeases makes the lease table match the VMs on record: VMs that
+// predate IPAM get leases for their addresses, leases of VMs that are gone are
+// dropped, and addresses claimed by more than one VM are reported
+func (m *Manager) reconcileIPLeases(vms []*database.VM) {
+	m.ipMu.Lock()
+	defer m.ipMu.Unlock()
+
+	pool, err := m.ipPool()
+	if err != nil {
+		m.logger.Errorf("Failed to reconcile IP leases: %v", err)
+		return
+	}
+
+	known := make(map[string]bool, len(vms))
+	for _, vm := range vms {
+		known[vm.ID] = true
+		if vm.IPAddress == "" {
+			continue
+		}
+		if err := pool.Lease(vm.ID, vm.IPAddress); err != nil {
+			m.logger.Warnf("IP address conflict for VM %s: %v", vm.ID, err)
+		}
+	}
+
+	leases, err := m.db.ListIPLeases()
+	if err != nil {
+		m.logger.Errorf("Failed to list IP leases: %v", err)
+		return
+	}
+	for _, lease := range leases {
+		if known[lease.VMID] {
+			continue
+		}
+		if err := pool.Release(lease.VMID); err != nil {
+			m.logger.Warnf("Failed to release stale IP lease %s: %v", lease.IP, err)
+			continue
+		}
+		m.logger.Infof("Released stale IP lease %s of deleted VM %s", lease.IP, lease.VMID)
+	}
+}
+
+// Network describes a network VMs can be attached to and how many of its
+// guest addresses are taken. Every VM is on the default network for now.
+type Network struct {
+	Name      string `json:"name"`
+	Bridge    string `json:"bridge"`
+	Subnet    string `json:"subnet"`
+	Gateway   string `json:"gateway"`
+	PoolStart string `json:"pool_start"` // first address assigned automatically
+	PoolEnd   string `json:"pool_end"`   // last address assigned automatically
+	Addresses int    `json:"addresses"`  // addresses in the pool range
+	Assigned  int    `json:"assigned"`   // leased addresses in the pool range
+	Reserved  int    `json:"reserved"`   // held by reservations and not yet assigned
+	Free      int    `json:"free"`
+}
+
+// Networks lists the networks VMs can be attached to
+func (m *Manager) Networks() ([]Network, error) {
+	m.ipMu.Lock()
+	defer m.ipMu.Unlock()
+
+	pool, err := m.ipPool()
+	if err != nil {
+		return nil, err
+	}
+
+	leases, err := m.db.ListIPLeases()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IP leases: %w", err)
+	}
+	reserved, err := m.reservedIPAddresses()
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := pool.Range()
+	network := Network{
+		Name:      "default",
+		Bridge:    m.config.BridgeName,
+		Subnet:    pool.Subnet().String(),
+		Gateway:   pool.Gateway().String(),
+		PoolStart: start.String(),
+		PoolEnd:   end.String(),
+		Addresses: pool.Size(),
+	}
+	for _, lease := range leases {
+		delete(reserved, lease.IP)
+		if pool.Contains(lease.IP) {
+			network.Assigned++
+		}
+	}
+	for ip := range reserved {
+		if pool.Contains(ip) {
+			network.Reserved++
+		}
+	}
+	network.Free = network.Addresses - network.Assigned - network.Reserved
+	if network.Free < 0 {
+		network.Free = 0
+	}
+	return []Network{network}, nil
+}
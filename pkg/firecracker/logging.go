@@ -0,0 +1,109 @@
+package firecracker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+)
+
+// LoggerConfig configures Firecracker's own (VMM) log
+type LoggerConfig struct {
+	LogPath       string `json:"log_path"`
+	Level         string `json:"level"`
+	ShowLevel     bool   `json:"show_level"`
+	ShowLogOrigin bool   `json:"show_log_origin"`
+}
+
+// logLevels are the levels Firecracker accepts, keyed by lower-case name
+var logLevels = map[string]string{
+	"off":     "Off",
+	"error":   "Error",
+	"warning": "Warning",
+	"info":    "Info",
+	"debug":   "Debug",
+	"trace":   "Trace",
+}
+
+// NormalizeLogLevel returns the Firecracker spelling of a log level, or false if
+// the level is unknown
+func NormalizeLogLevel(level string) (string, bool) {
+	normalized, ok := logLevels[strings.ToLower(level)]
+	return normalized, ok
+}
+
+// vmLogDir returns the directory holding a VM's logs
+func (m *Manager) vmLogDir(vmID string) string {
+	return filepath.Join(m.config.VMLogDir, vmID)
+}
+
+// vmmLogPath returns the path of a VM's Firecracker log
+func (m *Manager) vmmLogPath(vmID string) string {
+	return filepath.Join(m.vmLogDir(vmID), "firecracker.log")
+}
+
+// configureLogger points Firecracker's logger at the VM's log directory with the
+// VM's level and formatting. It runs again on every start so changes take effect.
+func (m *Manager) configureLogger(vm *database.VM, vmConfig *VMConfig) error {
+	level := vm.LogLevel
+	if level == "" {
+		level = m.config.FirecrackerLogLevel
+	}
+	level, ok := NormalizeLogLevel(level)
+	if !ok {
+		level = "Warning"
+	}
+
+	if err := os.MkdirAll(m.vmLogDir(vm.ID), 0750); err != nil {
+		return fmt.Errorf("failed to create VM log directory: %w", err)
+	}
+	// Firecracker appends to the log but doesn't create it
+	f, err := os.OpenFile(m.vmmLogPath(vm.ID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to create Firecracker log: %w", err)
+	}
+	f.Close()
+
+	vmConfig.Logger = &LoggerConfig{
+		LogPath:       m.vmmLogPath(vm.ID),
+		Level:         level,
+		ShowLevel:     vm.LogShowLevel,
+		ShowLogOrigin: vm.LogShowOrigin,
+	}
+	return nil
+}
+
+// TailVMMLog returns the last lines of a VM's Firecracker log
+func (m *Manager) TailVMMLog(vmID string, lines int) ([]string, error) {
+	return tailFile(m.vmmLogPath(vmID), lines)
+}
+
+// tailFile returns the last lines of a log file, or none if it doesn't exist yet
+func tailFile(path string, lines int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("failed to open %s: %w", filepath.Base(path), err)
+	}
+	defer f.Close()
+
+	tail := make([]string, 0, lines)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(tail) == lines {
+			tail = tail[1:]
+		}
+		tail = append(tail, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+	}
+
+	return tail, nil
+}
@@ -0,0 +1,104 @@
+package firecracker
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+)
+
+// macAttempts is how many random addresses are tried before giving up
+const macAttempts = 32
+
+// ValidMACPrefix reports whether prefix is a three-octet OUI usable for guest
+// NICs: a unicast address, since multicast ones can't be assigned to an interface
+func ValidMACPrefix(prefix string) bool {
+	mac, err := net.ParseMAC(prefix + ":00:00:00")
+	return err == nil && len(mac) == 6 && mac[0]&0x01 == 0
+}
+
+// assignMACAddress gives a VM a MAC address under MAC_PREFIX that no other
+// VM holds, and persists it. VMs that already have one keep it.
+func (m *Manager) assignMACAddress(vm *database.VM) error {
+	if vm.MACAddress != "" {
+		return nil
+	}
+
+	m.macMu.Lock()
+	defer m.macMu.Unlock()
+
+	mac, err := m.generateMACAddress(vm.ID)
+	if err != nil {
+		return err
+	}
+	vm.MACAddress = mac
+	if err := m.db.UpdateVM(vm); err != nil {
+		return fmt.Errorf("failed to save VM MAC address: %w", err)
+	}
+	return nil
+}
+
+// generateMACAddress picks a random address under MAC_PREFIX that no VM other
+// than vmID holds. The caller holds macMu.
+func (m *Manager) generateMACAddress(vmID string) (string, error) {
+	prefix, err := net.ParseMAC(m.config.MACPrefix + ":00:00:00")
+	if err != nil {
+		return "", fmt.Errorf("invalid MAC prefix %s: %w", m.config.MACPrefix, err)
+	}
+
+	for i := 0; i < macAttempts; i++ {
+		mac := make(net.HardwareAddr, 6)
+		copy(mac, prefix[:3])
+		if _, err := rand.Read(mac[3:]); err != nil {
+			return "", fmt.Errorf("failed to generate MAC address: %w", err)
+		}
+
+		existing, err := m.db.GetVMByMACAddress(mac.String())
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && existing.ID == vmID) {
+			return mac.String(), nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to check MAC address %s: %w", mac, err)
+		}
+	}
+	return "", fmt.Errorf("no free MAC address found under %s", m.config.MACPrefix)
+}
+
+// restoreMACAddress records the MAC address of a VM that predates stored
+// addresses, taking it from its config file. Addresses the old scheme handed
+// out twice are replaced, in the config file too, so the VM gets the new one
+// on its next boot.
+func (m *Manager) restoreMACAddress(vm *database.VM, vmConfig *VMConfig) error {
+	if vm.MACAddress != "" || len(vmConfig.NetworkIfaces) == 0 {
+		return nil
+	}
+
+	m.macMu.Lock()
+	defer m.macMu.Unlock()
+
+	mac := vmConfig.NetworkIfaces[0].GuestMAC
+	existing, err := m.db.GetVMByMACAddress(mac)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check MAC address %s: %w", mac, err)
+	}
+	if err == nil && existing.ID != vm.ID {
+		if mac, err = m.generateMACAddress(vm.ID); err != nil {
+			return err
+		}
+		m.logger.Warnf("VM %s shares MAC address %s with VM %s; it gets %s from its next boot",
+			vm.ID, vmConfig.NetworkIfaces[0].GuestMAC, existing.ID, mac)
+		vmConfig.NetworkIfaces[0].GuestMAC = mac
+		if err := m.writeConfig(vm.ID, vmConfig); err != nil {
+			return err
+		}
+	}
+
+	vm.MACAddress = mac
+	if err := m.db.UpdateVM(vm); err != nil {
+		return fmt.Errorf("failed to save VM MAC address: %w", err)
+	}
+	return nil
+}
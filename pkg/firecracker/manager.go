@@ -1,24 +1,72 @@
 package firecracker
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/abhaybhargav/firecracker-orchestrator/internal/config"
 	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
 	"github.com/sirupsen/logrus"
 )
 
+// ErrVMDeleting is returned when starting a VM whose deletion is under way
+var ErrVMDeleting = errors.New("VM is being deleted")
+
+// Errors returned when pausing or resuming a VM in the wrong state
+var (
+	ErrVMNotRunning = errors.New("VM is not running")
+	ErrVMNotPaused  = errors.New("VM is not paused")
+)
+
+// DefaultBootArgs are the kernel boot arguments used when a VM has no boot profile
+const DefaultBootArgs = "console=ttyS0 reboot=k panic=1 pci=off"
+
 // Manager handles Firecracker VM lifecycle
 type Manager struct {
 	config   *config.Config
 	db       *database.Database
 	logger   *logrus.Logger
 	vms      map[string]*FirecrackerVM
-	tapIndex int
+	vmsMu    sync.RWMutex
+	verifier *imageVerifier
+	// hookClient sends webhook hooks; it refuses internal addresses
+	hookClient *http.Client
+
+	// netHistory keeps recent network samples per VM for the metrics API
+	netHistory map[string][]NetworkStats
+	netMu      sync.Mutex
+
+	captures captureStore
+	drift    driftStore
+	activity activityStore
+	outputs  outputStore
+	cpuStats cpuStatsStore
+
+	// drivesMu serialises drive attachment so exclusivity checks can't race
+	drivesMu sync.Mutex
+	// ipMu serialises IP assignment until the address is persisted, and
+	// reservation accounting along with it
+	ipMu sync.Mutex
+	// macMu serialises MAC assignment until the address is persisted
+	macMu sync.Mutex
+	// tapMu serialises TAP device naming until the name is persisted
+	tapMu sync.Mutex
+	// sgMu serialises security group changes to nftables
+	sgMu sync.Mutex
+	// forwardsMu serialises port forward changes so host ports can't clash
+	forwardsMu sync.Mutex
+	// restartMu guards the restart state of every FirecrackerVM
+	restartMu sync.Mutex
 }
 
 // FirecrackerVM represents a running Firecracker VM
@@ -26,8 +74,23 @@ type FirecrackerVM struct {
 	ID         string
 	SocketPath string
 	TAPDevice  string
-	Process    *os.Process
 	Config     *VMConfig
+
+	// Process is the running Firecracker process, nil when the VM is stopped.
+	// It is set and cleared from API requests, supervisors and restarts
+	// alike, so stop paths clear it with CompareAndSwap to claim the process.
+	Process atomic.Pointer[os.Process]
+
+	// launchMu serialises starts and rescues, from checking that no process
+	// runs to storing the new one, so two can't launch over each other
+	launchMu sync.Mutex
+
+	breaker *circuitBreaker // health of the running process's API socket
+
+	// Restart state, guarded by Manager.restartMu
+	launchedAt   time.Time   // when the last process was started
+	backoff      int         // restarts in a row since the VM last stayed up
+	restartTimer *time.Timer // pending restart after the process exited
 }
 
 // VMConfig represents Firecracker VM configuration
@@ -36,6 +99,9 @@ type VMConfig struct {
 	Drives        []Drive        `json:"drives"`
 	MachineConfig MachineConfig  `json:"machine-config"`
 	NetworkIfaces []NetworkIface `json:"network-interfaces"`
+	Entropy       *Entropy       `json:"entropy,omitempty"`
+	MmdsConfig    *MmdsConfig    `json:"mmds-config,omitempty"`
+	Logger        *LoggerConfig  `json:"logger,omitempty"`
 }
 
 type BootSource struct {
@@ -48,6 +114,8 @@ type Drive struct {
 	PathOnHost   string `json:"path_on_host"`
 	IsRootDevice bool   `json:"is_root_device"`
 	IsReadOnly   bool   `json:"is_read_only"`
+
+	RateLimiter *RateLimiter `json:"rate_limiter,omitempty"`
 }
 
 type MachineConfig struct {
@@ -59,16 +127,30 @@ type NetworkIface struct {
 	IfaceID     string `json:"iface_id"`
 	GuestMAC    string `json:"guest_mac"`
 	HostDevName string `json:"host_dev_name"`
+
+	RxRateLimiter *RateLimiter `json:"rx_rate_limiter,omitempty"`
+	TxRateLimiter *RateLimiter `json:"tx_rate_limiter,omitempty"`
 }
 
+// Entropy configures the virtio-rng device that feeds the guest's entropy pool
+type Entropy struct{}
+
 // NewManager creates a new Firecracker manager
 func NewManager(config *config.Config, db *database.Database, logger *logrus.Logger) *Manager {
 	return &Manager{
-		config:   config,
-		db:       db,
-		logger:   logger,
-		vms:      make(map[string]*FirecrackerVM),
-		tapIndex: 0,
+		config:     config,
+		db:         db,
+		logger:     logger,
+		vms:        make(map[string]*FirecrackerVM),
+		verifier:   newImageVerifier(config.MinisignPublicKey, config.StrictImageVerify),
+		hookClient: newHookClient(config.HookPrivateNetwork),
+
+		netHistory: make(map[string][]NetworkStats),
+		captures:   captureStore{captures: make(map[string]*Capture)},
+		drift:      driftStore{drifted: make(map[string][]Drift)},
+		activity:   activityStore{counters: make(map[string]activityCounters), activity: make(map[string]*Activity)},
+		outputs:    outputStore{buffers: make(map[string]*ringBuffer)},
+		cpuStats:   cpuStatsStore{stats: make(map[string]*CPUStats)},
 	}
 }
 
@@ -84,32 +166,62 @@ func (m *Manager) CreateVM(vm *database.VM) error {
 	// Generate unique socket path
 	socketPath := filepath.Join(m.config.SocketDir, fmt.Sprintf("%s.sock", vm.ID))
 
+	// Resolve kernel boot arguments
+	vm.RootfsMode = m.RootfsMode(vm.RootfsMode)
+	bootArgs, err := m.bootArgs(vm)
+	if err != nil {
+		return err
+	}
+
+	// Assign IP address
+	if err := m.assignIPAddress(vm); err != nil {
+		return err
+	}
+
+	// Assign MAC address
+	if err := m.assignMACAddress(vm); err != nil {
+		return err
+	}
+
+	// Give the VM its own root filesystem so VMs can't corrupt each other's
+	drives, err := m.createRootfs(vm)
+	if err != nil {
+		return err
+	}
+
 	// Create TAP device
-	tapDevice := fmt.Sprintf("%s%d", m.config.TAPDeviceBase, m.tapIndex)
-	m.tapIndex++
+	if err := m.assignTAPDevice(vm); err != nil {
+		os.Remove(m.rootfsPath(vm.ID))
+		return err
+	}
+	tapDevice := vm.TAPDevice
 
 	if err := m.createTAPDevice(tapDevice); err != nil {
+		os.Remove(m.rootfsPath(vm.ID))
 		return fmt.Errorf("failed to create TAP device: %w", err)
 	}
 
-	// Assign IP address
-	ipAddr := m.generateIPAddress()
-	vm.IPAddress = ipAddr
+	// Anything failing from here on leaves no device or files behind
+	created := false
+	defer func() {
+		if created {
+			return
+		}
+		if err := m.deleteTAPDevice(tapDevice); err != nil {
+			m.logger.Warnf("Failed to delete TAP device %s of VM %s: %v", tapDevice, vm.ID, err)
+		}
+		os.Remove(m.rootfsPath(vm.ID))
+		os.Remove(m.metadataPath(vm.ID))
+		os.Remove(m.configPath(vm.ID))
+	}()
 
 	// Create VM configuration
 	vmConfig := &VMConfig{
 		BootSource: BootSource{
 			KernelImagePath: m.config.KernelPath,
-			BootArgs:        "console=ttyS0 reboot=k panic=1 pci=off",
-		},
-		Drives: []Drive{
-			{
-				DriveID:      "rootfs",
-				PathOnHost:   m.config.RootfsPath,
-				IsRootDevice: true,
-				IsReadOnly:   false,
-			},
+			BootArgs:        bootArgs,
 		},
+		Drives: drives,
 		MachineConfig: MachineConfig{
 			VCPUCount:  vm.CPUs,
 			MemSizeMib: vm.Memory,
@@ -117,25 +229,37 @@ func (m *Manager) CreateVM(vm *database.VM) error {
 		NetworkIfaces: []NetworkIface{
 			{
 				IfaceID:     "eth0",
-				GuestMAC:    m.generateMACAddress(),
+				GuestMAC:    vm.MACAddress,
 				HostDevName: tapDevice,
 			},
 		},
 	}
 
-	// Save configuration to file
-	configPath := filepath.Join(m.config.SocketDir, fmt.Sprintf("%s-config.json", vm.ID))
-	configData, err := json.MarshalIndent(vmConfig, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal VM config: %w", err)
+	if vm.Entropy {
+		vmConfig.Entropy = &Entropy{}
 	}
 
-	if err := os.WriteFile(configPath, configData, 0644); err != nil {
-		return fmt.Errorf("failed to write VM config: %w", err)
+	if err := m.applyRateLimits(vm, vmConfig); err != nil {
+		return err
+	}
+
+	if err := m.configureLogger(vm, vmConfig); err != nil {
+		return err
+	}
+
+	if err := m.configureMetadata(vm, vmConfig); err != nil {
+		return err
+	}
+
+	// Save configuration to file
+	if err := m.writeConfig(vm.ID, vmConfig); err != nil {
+		return err
 	}
 
 	// Update VM status
 	vm.Status = "created"
+	vm.StatusReason = ""
+	vm.SocketPath = socketPath
 	if err := m.db.UpdateVM(vm); err != nil {
 		return fmt.Errorf("failed to update VM in database: %w", err)
 	}
@@ -147,54 +271,269 @@ func (m *Manager) CreateVM(vm *database.VM) error {
 		TAPDevice:  tapDevice,
 		Config:     vmConfig,
 	}
+	m.vmsMu.Lock()
 	m.vms[vm.ID] = fcVM
+	m.vmsMu.Unlock()
+	created = true
 
 	m.logger.Infof("VM %s created successfully", vm.ID)
 	return nil
 }
 
-// StartVM starts a Firecracker VM
+// StartVM starts a Firecracker VM, dropping any pending restart
 func (m *Manager) StartVM(vmID string) error {
+	if fcVM, exists := m.getVM(vmID); exists {
+		m.resetRestart(fcVM)
+	}
+	return m.startVM(vmID, 0)
+}
+
+// startVM boots a VM's Firecracker process, recording restarts as the number
+// of times its restart policy has started it again
+func (m *Manager) startVM(vmID string, restarts int) error {
 	m.logger.Infof("Starting VM: %s", vmID)
 
 	vm, err := m.db.GetVM(vmID)
 	if err != nil {
 		return fmt.Errorf("failed to get VM from database: %w", err)
 	}
+	if vm.Status == "deleting" {
+		return ErrVMDeleting
+	}
+	if vm.Status == "rescue" {
+		return fmt.Errorf("cannot start VM %s: %w", vmID, ErrVMInRescue)
+	}
 
-	fcVM, exists := m.vms[vmID]
+	fcVM, exists := m.getVM(vmID)
 	if !exists {
 		return fmt.Errorf("VM %s not found in manager", vmID)
 	}
+	fcVM.launchMu.Lock()
+	defer fcVM.launchMu.Unlock()
+	if fcVM.Process.Load() != nil {
+		return fmt.Errorf("cannot start VM %s: %w", vmID, ErrVMRunning)
+	}
+
+	if err := m.verifyImages(fcVM); err != nil {
+		return err
+	}
+
+	if err := m.runHooks(vm, HookPreStart); err != nil {
+		return err
+	}
+
+	// The spec may have changed since the config was generated
+	if err := m.applySpec(vm, fcVM.Config); err != nil {
+		return err
+	}
 
-	// Start Firecracker process
-	cmd := exec.Command(
-		m.config.FirecrackerBinary,
-		"--api-sock", fcVM.SocketPath,
-		"--config-file", filepath.Join(m.config.SocketDir, fmt.Sprintf("%s-config.json", vmID)),
-	)
+	if err := m.applyRateLimits(vm, fcVM.Config); err != nil {
+		return err
+	}
 
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	if err := m.configureLogger(vm, fcVM.Config); err != nil {
+		return err
+	}
 
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start Firecracker: %w", err)
+	// Refresh metadata so guests see the current fleet at boot
+	if err := m.configureMetadata(vm, fcVM.Config); err != nil {
+		return err
+	}
+	if err := m.writeConfig(vmID, fcVM.Config); err != nil {
+		return err
 	}
 
-	fcVM.Process = cmd.Process
+	cmd, err := m.launch(vm, fcVM, fcVM.Config)
+	if err != nil {
+		return err
+	}
 
 	// Update VM status
 	vm.Status = "running"
+	vm.StatusReason = ""
+	vm.Degraded = false
+	vm.DegradedReason = ""
+	vm.RestartCount = restarts
 	if err := m.db.UpdateVM(vm); err != nil {
 		return fmt.Errorf("failed to update VM status: %w", err)
 	}
+	m.supervise(vmID, fcVM, cmd)
+
+	// The VM is already running, so a failing post-start hook is only reported
+	if err := m.runHooks(vm, HookPostStart); err != nil {
+		m.logger.Warnf("VM %s started but %v", vmID, err)
+	}
 
 	m.logger.Infof("VM %s started successfully with PID %d", vmID, cmd.Process.Pid)
 	return nil
 }
 
-// StopVM stops a Firecracker VM
-func (m *Manager) StopVM(vmID string) error {
+// launch starts a Firecracker process for a VM and boots it with vmConfig,
+// leaving the process in fcVM and its PID in vm for the caller to save
+func (m *Manager) launch(vm *database.VM, fcVM *FirecrackerVM, vmConfig *VMConfig) (*exec.Cmd, error) {
+	// Stopping a VM deletes its TAP device
+	createdTAP := false
+	if _, err := os.Stat(filepath.Join("/sys/class/net", fcVM.TAPDevice)); os.IsNotExist(err) {
+		if err := m.createTAPDevice(fcVM.TAPDevice); err != nil {
+			return nil, err
+		}
+		createdTAP = true
+	}
+
+	// Anything failing from here on leaves no rules behind for a VM that
+	// isn't running
+	launched := false
+	defer func() {
+		if launched {
+			return
+		}
+		m.removeNATRules(func(id string) bool { return id == vm.ID })
+		m.sgMu.Lock()
+		if err := m.removeSecurityGroupChains(fcVM.TAPDevice); err != nil {
+			m.logger.Warnf("VM %s: %v", vm.ID, err)
+		}
+		m.sgMu.Unlock()
+		if createdTAP {
+			if err := m.deleteTAPDevice(fcVM.TAPDevice); err != nil {
+				m.logger.Warnf("Failed to delete TAP device %s of VM %s: %v", fcVM.TAPDevice, vm.ID, err)
+			}
+		}
+	}()
+
+	// Cut the network before the guest can send anything
+	if vm.Quarantined {
+		if err := m.setTAPIsolated(fcVM.TAPDevice, true); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.addNATRule(vm.ID, vm.IPAddress); err != nil {
+		m.logger.Warnf("VM %s won't reach the outside: %v", vm.ID, err)
+	}
+	// Unlike NAT, a VM that can't be firewalled must not boot
+	if err := m.applySecurityGroups(vm.ID, fcVM.TAPDevice); err != nil {
+		return nil, err
+	}
+
+	// Start Firecracker with only its API socket; the VM is configured and
+	// booted over the socket, which stays open for runtime control. A socket
+	// left behind by a previous run would make Firecracker refuse to start.
+	os.Remove(fcVM.SocketPath)
+	cmd := m.firecrackerCommand(vm.ID, "--api-sock", fcVM.SocketPath)
+
+	started, err := m.attachOutput(cmd, vm.ID, m.OutputMode(vm.OutputMode))
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.Start()
+	started()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start Firecracker: %w", err)
+	}
+
+	if err := m.bootVM(vm.ID, NewClient(fcVM.SocketPath), vmConfig); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, err
+	}
+
+	// The breaker is set first so whoever sees the process sees it too
+	fcVM.breaker = &circuitBreaker{}
+	fcVM.Process.Store(cmd.Process)
+	vm.PID = cmd.Process.Pid
+
+	m.restartMu.Lock()
+	fcVM.launchedAt = time.Now()
+	m.restartMu.Unlock()
+	launched = true
+	return cmd, nil
+}
+
+// bootVM waits for a new Firecracker process's API socket, sends it the VM's
+// configuration and starts the guest
+func (m *Manager) bootVM(vmID string, client *Client, vmConfig *VMConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), apiSocketTimeout)
+	defer cancel()
+	if err := client.WaitReady(ctx); err != nil {
+		return fmt.Errorf("failed to start Firecracker for VM %s: %w", vmID, err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	steps := []func() error{
+		func() error {
+			if vmConfig.Logger == nil {
+				return nil
+			}
+			return client.PutLogger(ctx, *vmConfig.Logger)
+		},
+		func() error { return client.PutMachineConfig(ctx, vmConfig.MachineConfig) },
+		func() error { return client.PutBootSource(ctx, vmConfig.BootSource) },
+	}
+	for _, drive := range vmConfig.Drives {
+		drive := drive
+		steps = append(steps, func() error { return client.PutDrive(ctx, drive) })
+	}
+	for _, iface := range vmConfig.NetworkIfaces {
+		iface := iface
+		steps = append(steps, func() error { return client.PutNetworkInterface(ctx, iface) })
+	}
+	if vmConfig.Entropy != nil {
+		steps = append(steps, func() error { return client.PutEntropy(ctx) })
+	}
+	if vmConfig.MmdsConfig != nil {
+		steps = append(steps,
+			func() error { return client.PutMmdsConfig(ctx, *vmConfig.MmdsConfig) },
+			func() error {
+				data, err := os.ReadFile(m.metadataPath(vmID))
+				if err != nil {
+					return fmt.Errorf("failed to read VM metadata: %w", err)
+				}
+				// Written in plaintext unless ENCRYPT_VM_FILES is set
+				metadata, err := m.db.Keyring().Open(string(data))
+				if err != nil {
+					return fmt.Errorf("failed to decrypt VM metadata: %w", err)
+				}
+				return client.PutMmds(ctx, []byte(metadata))
+			})
+	}
+	steps = append(steps, func() error { return client.Action(ctx, ActionInstanceStart) })
+
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return fmt.Errorf("failed to boot VM %s: %w", vmID, err)
+		}
+	}
+	return nil
+}
+
+// APIClient returns a client for a running VM's Firecracker API socket. Calls
+// fail fast with ErrCircuitOpen while the socket has stopped responding.
+func (m *Manager) APIClient(vmID string) (*Client, error) {
+	fcVM, exists := m.getVM(vmID)
+	if !exists {
+		return nil, fmt.Errorf("VM %s not found in manager", vmID)
+	}
+	if fcVM.Process.Load() == nil {
+		return nil, fmt.Errorf("VM %s is not running", vmID)
+	}
+	return newClient(fcVM.SocketPath, fcVM.breaker), nil
+}
+
+// StopVM stops a Firecracker VM. Unless force is set the guest is first asked
+// to shut down with Ctrl-Alt-Del and given STOP_TIMEOUT to do so.
+func (m *Manager) StopVM(vmID string, force bool) error {
+	var grace time.Duration
+	if !force {
+		grace = time.Duration(m.config.StopTimeoutSeconds) * time.Second
+	}
+	return m.stopVM(context.Background(), vmID, grace)
+}
+
+// stopVM stops a VM, giving its guest up to grace, or until ctx ends, to shut
+// down before the Firecracker process is killed
+func (m *Manager) stopVM(ctx context.Context, vmID string, grace time.Duration) error {
 	m.logger.Infof("Stopping VM: %s", vmID)
 
 	vm, err := m.db.GetVM(vmID)
@@ -202,17 +541,27 @@ func (m *Manager) StopVM(vmID string) error {
 		return fmt.Errorf("failed to get VM from database: %w", err)
 	}
 
-	fcVM, exists := m.vms[vmID]
+	fcVM, exists := m.getVM(vmID)
 	if !exists {
 		return fmt.Errorf("VM %s not found in manager", vmID)
 	}
+	m.cancelRestart(fcVM)
+
+	if process := fcVM.Process.Load(); process != nil && fcVM.Process.CompareAndSwap(process, nil) {
+		// A failing pre-stop hook must not leave a VM that can't be stopped
+		if err := m.runHooks(vm, HookPreStop); err != nil {
+			m.logger.Warnf("Stopping VM %s anyway: %v", vmID, err)
+		}
 
-	if fcVM.Process != nil {
-		if err := fcVM.Process.Kill(); err != nil {
-			m.logger.Warnf("Failed to kill VM process: %v", err)
+		// A paused guest can't react to Ctrl-Alt-Del
+		if vm.Status == "paused" {
+			grace = 0
 		}
-		fcVM.Process = nil
+
+		// Cleared first so the supervisor knows the exit was asked for
+		m.stopProcess(ctx, fcVM, process, grace)
 	}
+	m.removeRescueDisk(vmID)
 
 	// Clean up TAP device
 	if err := m.deleteTAPDevice(fcVM.TAPDevice); err != nil {
@@ -221,6 +570,8 @@ func (m *Manager) StopVM(vmID string) error {
 
 	// Update VM status
 	vm.Status = "stopped"
+	vm.StatusReason = ""
+	vm.PID = 0
 	if err := m.db.UpdateVM(vm); err != nil {
 		return fmt.Errorf("failed to update VM status: %w", err)
 	}
@@ -229,29 +580,123 @@ func (m *Manager) StopVM(vmID string) error {
 	return nil
 }
 
+// PauseVM freezes a running VM's vCPUs; its memory stays resident so it can
+// be resumed where it left off
+func (m *Manager) PauseVM(vmID string) error {
+	return m.setVMState(vmID, "running", StatePaused, "paused", ErrVMNotRunning)
+}
+
+// ResumeVM unfreezes a paused VM
+func (m *Manager) ResumeVM(vmID string) error {
+	return m.setVMState(vmID, "paused", StateResumed, "running", ErrVMNotPaused)
+}
+
+// setVMState moves a VM from status from to status to by sending state to its
+// Firecracker API socket, returning wrongState if it isn't in status from
+func (m *Manager) setVMState(vmID, from, state, to string, wrongState error) error {
+	vm, err := m.db.GetVM(vmID)
+	if err != nil {
+		return fmt.Errorf("failed to get VM from database: %w", err)
+	}
+	if vm.Status != from {
+		return fmt.Errorf("%w (status %s)", wrongState, vm.Status)
+	}
+
+	client, err := m.APIClient(vmID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), apiSocketTimeout)
+	defer cancel()
+	if err := client.SetState(ctx, state); err != nil {
+		return fmt.Errorf("failed to set VM %s %s: %w", vmID, strings.ToLower(state), err)
+	}
+
+	vm.Status = to
+	vm.StatusReason = ""
+	if err := m.db.UpdateVM(vm); err != nil {
+		return fmt.Errorf("failed to update VM status: %w", err)
+	}
+
+	m.logger.Infof("VM %s %s", vmID, strings.ToLower(state))
+	return nil
+}
+
 // DeleteVM deletes a Firecracker VM
 func (m *Manager) DeleteVM(vmID string) error {
 	m.logger.Infof("Deleting VM: %s", vmID)
 
 	// Stop VM first if running
-	if fcVM, exists := m.vms[vmID]; exists {
-		if fcVM.Process != nil {
-			if err := m.StopVM(vmID); err != nil {
+	if fcVM, exists := m.getVM(vmID); exists {
+		m.cancelRestart(fcVM)
+		if fcVM.Process.Load() != nil {
+			// The guest's disk goes with it, so there's nothing to shut down cleanly
+			if err := m.StopVM(vmID, true); err != nil {
 				m.logger.Warnf("Failed to stop VM during deletion: %v", err)
 			}
 		}
 
 		// Clean up files
 		socketPath := fcVM.SocketPath
-		configPath := filepath.Join(m.config.SocketDir, fmt.Sprintf("%s-config.json", vmID))
-
 		os.Remove(socketPath)
-		os.Remove(configPath)
+		os.Remove(m.configPath(vmID))
+		os.Remove(m.metadataPath(vmID))
+		os.RemoveAll(m.vmLogDir(vmID))
 
+		m.vmsMu.Lock()
 		delete(m.vms, vmID)
+		m.vmsMu.Unlock()
+
+		m.netMu.Lock()
+		delete(m.netHistory, vmID)
+		m.netMu.Unlock()
+
+		m.deleteCaptures(vmID)
+		m.deleteOutput(vmID)
+		m.RecordDrift(vmID, nil)
+
+		// Free the TAP device's name for the next VM
+		if fcVM.TAPDevice != "" {
+			m.sgMu.Lock()
+			if err := m.removeSecurityGroupChains(fcVM.TAPDevice); err != nil {
+				m.logger.Warnf("VM %s: %v", vmID, err)
+			}
+			m.sgMu.Unlock()
+		}
+		if fcVM.TAPDevice != "" && tapDeviceExists(fcVM.TAPDevice) {
+			if err := m.deleteTAPDevice(fcVM.TAPDevice); err != nil {
+				m.logger.Warnf("Failed to delete TAP device %s of VM %s: %v", fcVM.TAPDevice, vmID, err)
+			}
+		}
+	}
+	m.removeNATRules(func(id string) bool { return id == vmID })
+	if err := m.removeVMPortForwards(vmID); err != nil {
+		return err
+	}
+
+	// The disk (or overlay) outlives the manager's record of the VM across restarts
+	if err := os.Remove(m.rootfsPath(vmID)); err != nil && !os.IsNotExist(err) {
+		m.logger.Warnf("Failed to delete disk of VM %s: %v", vmID, err)
 	}
+	m.removeRescueDisk(vmID)
 
 	// Remove from database
+	if err := m.db.DeleteGuestInfo(vmID); err != nil {
+		return fmt.Errorf("failed to delete VM guest info from database: %w", err)
+	}
+	if err := m.db.DeleteDriveAttachmentsByVM(vmID); err != nil {
+		return fmt.Errorf("failed to delete VM drives from database: %w", err)
+	}
+	if err := m.db.DeleteLifecycleHooksByVM(vmID); err != nil {
+		return fmt.Errorf("failed to delete VM hooks from database: %w", err)
+	}
+	if err := m.releaseIPAddress(vmID); err != nil {
+		return err
+	}
+	if err := m.db.DetachVMSecurityGroups(vmID); err != nil {
+		return fmt.Errorf("failed to detach VM security groups: %w", err)
+	}
 	if err := m.db.DeleteVM(vmID); err != nil {
 		return fmt.Errorf("failed to delete VM from database: %w", err)
 	}
@@ -270,17 +715,142 @@ func (m *Manager) GetVM(vmID string) (*database.VM, error) {
 	return m.db.GetVM(vmID)
 }
 
-// createTAPDevice creates a TAP network device
+// getVM looks up a VM tracked by the manager
+func (m *Manager) getVM(vmID string) (*FirecrackerVM, bool) {
+	m.vmsMu.RLock()
+	defer m.vmsMu.RUnlock()
+	fcVM, exists := m.vms[vmID]
+	return fcVM, exists
+}
+
+// verifyImages checks the kernel and root filesystem a VM boots from
+func (m *Manager) verifyImages(fcVM *FirecrackerVM) error {
+	// The VM's own root filesystem changes as it runs; the base it was copied
+	// from is what can be verified
+	expected := map[string]string{
+		fcVM.Config.BootSource.KernelImagePath: m.config.KernelSHA256,
+		m.config.RootfsPath:                    m.config.RootfsSHA256,
+	}
+
+	for path, sha256 := range expected {
+		verified, err := m.verifier.Verify(path, sha256)
+		if err != nil {
+			return err
+		}
+		if !verified {
+			m.logger.Warnf("Booting VM %s from unverified image %s", fcVM.ID, path)
+		}
+	}
+
+	return nil
+}
+
+// configPath returns the path of the Firecracker config file for a VM
+func (m *Manager) configPath(vmID string) string {
+	return filepath.Join(m.config.SocketDir, fmt.Sprintf("%s-config.json", vmID))
+}
+
+// writeConfig saves a VM's Firecracker configuration to its config file
+func (m *Manager) writeConfig(vmID string, vmConfig *VMConfig) error {
+	configData, err := json.MarshalIndent(vmConfig, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal VM config: %w", err)
+	}
+
+	if err := os.WriteFile(m.configPath(vmID), configData, 0644); err != nil {
+		return fmt.Errorf("failed to write VM config: %w", err)
+	}
+
+	return nil
+}
+
+// bootArgs returns the kernel boot arguments for a VM: its boot profile's or
+// the defaults, plus those its root filesystem mode and Ignition config need
+func (m *Manager) bootArgs(vm *database.VM) (string, error) {
+	bootArgs, err := m.resolveBootArgs(vm)
+	if err != nil {
+		return "", err
+	}
+	if vm.RootfsMode == RootfsModeOverlay {
+		bootArgs += " " + m.overlayBootArgs()
+	}
+	if vm.Ignition != "" {
+		bootArgs += " " + ignitionBootArgs
+	}
+	return bootArgs, nil
+}
+
+// applySpec updates the parts of a VM's Firecracker config that follow
+// settings which can change after the VM was created
+func (m *Manager) applySpec(vm *database.VM, vmConfig *VMConfig) error {
+	bootArgs, err := m.bootArgs(vm)
+	if err != nil {
+		return err
+	}
+	vmConfig.BootSource.BootArgs = bootArgs
+
+	vmConfig.MachineConfig.VCPUCount = vm.CPUs
+	vmConfig.MachineConfig.MemSizeMib = vm.Memory
+
+	vmConfig.Entropy = nil
+	if vm.Entropy {
+		vmConfig.Entropy = &Entropy{}
+	}
+	return nil
+}
+
+// RefreshConfig applies a stopped VM's changed spec to its Firecracker config
+// and rewrites the config file. A running VM's file records what its VMM
+// booted with, so it is left alone and ErrVMRunning returned.
+func (m *Manager) RefreshConfig(vm *database.VM) error {
+	fcVM, exists := m.getVM(vm.ID)
+	if !exists {
+		return fmt.Errorf("VM %s not found in manager", vm.ID)
+	}
+	if fcVM.Process.Load() != nil {
+		return fmt.Errorf("cannot change the config of VM %s: %w", vm.ID, ErrVMRunning)
+	}
+	if err := m.applySpec(vm, fcVM.Config); err != nil {
+		return err
+	}
+	return m.writeConfig(vm.ID, fcVM.Config)
+}
+
+// Running reports whether a VM's Firecracker process is running
+func (m *Manager) Running(vmID string) bool {
+	fcVM, exists := m.getVM(vmID)
+	return exists && fcVM.Process.Load() != nil
+}
+
+// resolveBootArgs returns the kernel boot arguments for a VM, honouring its boot profile
+func (m *Manager) resolveBootArgs(vm *database.VM) (string, error) {
+	if vm.BootProfile == "" {
+		return DefaultBootArgs, nil
+	}
+
+	profile, err := m.db.GetBootProfile(vm.BootProfile)
+	if err != nil {
+		return "", fmt.Errorf("failed to load boot profile %s: %w", vm.BootProfile, err)
+	}
+
+	return profile.BootArgs, nil
+}
+
+// createTAPDevice creates a TAP network device on the VM bridge
 func (m *Manager) createTAPDevice(name string) error {
-	// Note: This is a simplified implementation
-	// In production, you'd want more sophisticated networking setup
 	cmd := exec.Command("ip", "tuntap", "add", "dev", name, "mode", "tap")
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to create TAP device %s: %w", name, err)
 	}
 
+	if err := m.attachTAPDevice(name); err != nil {
+		m.deleteTAPDevice(name)
+		return err
+	}
+
 	cmd = exec.Command("ip", "link", "set", "dev", name, "up")
 	if err := cmd.Run(); err != nil {
+		m.deleteTAPDevice(name)
 		return fmt.Errorf("failed to bring up TAP device %s: %w", name, err)
 	}
 
@@ -292,15 +862,3 @@ func (m *Manager) deleteTAPDevice(name string) error {
 	cmd := exec.Command("ip", "link", "delete", name)
 	return cmd.Run()
 }
-
-// generateIPAddress generates a unique IP address for the VM
-func (m *Manager) generateIPAddress() string {
-	// Simple implementation - in production you'd want a proper IP pool manager
-	return fmt.Sprintf("192.168.100.%d", 10+len(m.vms))
-}
-
-// generateMACAddress generates a unique MAC address for the VM
-func (m *Manager) generateMACAddress() string {
-	// Simple implementation - generates a locally administered MAC
-	return fmt.Sprintf("02:00:00:00:00:%02x", len(m.vms)+1)
-}
@@ -0,0 +1,124 @@
+package firecracker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// networkHistorySize is how many samples are kept per VM (an hour at the default interval)
+const networkHistorySize = 360
+
+// NetworkStats are a VM's network counters from the guest's point of view. They
+// are read from the host side of the TAP device, where directions are reversed.
+type NetworkStats struct {
+	TAPDevice string    `json:"tap_device"`
+	RxBytes   uint64    `json:"rx_bytes"`
+	TxBytes   uint64    `json:"tx_bytes"`
+	RxPackets uint64    `json:"rx_packets"`
+	TxPackets uint64    `json:"tx_packets"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// NetworkStats reads the current network counters of a running VM
+func (m *Manager) NetworkStats(vmID string) (*NetworkStats, error) {
+	fcVM, exists := m.getVM(vmID)
+	if !exists {
+		return nil, fmt.Errorf("VM %s not found in manager", vmID)
+	}
+
+	return readTAPStats(fcVM.TAPDevice)
+}
+
+// NetworkHistory returns the recent network samples recorded for a VM, oldest first
+func (m *Manager) NetworkHistory(vmID string) []NetworkStats {
+	m.netMu.Lock()
+	defer m.netMu.Unlock()
+
+	history := make([]NetworkStats, len(m.netHistory[vmID]))
+	copy(history, m.netHistory[vmID])
+	return history
+}
+
+// AllNetworkStats reads the current network counters of every running VM
+func (m *Manager) AllNetworkStats() map[string]*NetworkStats {
+	m.vmsMu.RLock()
+	taps := make(map[string]string, len(m.vms))
+	for id, fcVM := range m.vms {
+		if fcVM.Process.Load() != nil {
+			taps[id] = fcVM.TAPDevice
+		}
+	}
+	m.vmsMu.RUnlock()
+
+	stats := make(map[string]*NetworkStats, len(taps))
+	for id, tap := range taps {
+		if s, err := readTAPStats(tap); err == nil {
+			stats[id] = s
+		}
+	}
+	return stats
+}
+
+// RunNetworkSampler records network counters and vCPU thread statistics for
+// running VMs every interval until the context is cancelled
+func (m *Manager) RunNetworkSampler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			samples := m.AllNetworkStats()
+
+			m.netMu.Lock()
+			for id, sample := range samples {
+				history := append(m.netHistory[id], *sample)
+				if len(history) > networkHistorySize {
+					history = history[len(history)-networkHistorySize:]
+				}
+				m.netHistory[id] = history
+			}
+			m.netMu.Unlock()
+
+			m.sampleCPUStats()
+		}
+	}
+}
+
+// readTAPStats reads a TAP device's counters from sysfs, swapping rx and tx so
+// they describe the guest's traffic
+func readTAPStats(tap string) (*NetworkStats, error) {
+	dir := filepath.Join("/sys/class/net", tap, "statistics")
+
+	read := func(name string) (uint64, error) {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return 0, fmt.Errorf("failed to read %s statistics for %s: %w", name, tap, err)
+		}
+		return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	}
+
+	stats := &NetworkStats{TAPDevice: tap, Timestamp: time.Now()}
+	var err error
+	if stats.TxBytes, err = read("rx_bytes"); err != nil {
+		return nil, err
+	}
+	if stats.RxBytes, err = read("tx_bytes"); err != nil {
+		return nil, err
+	}
+	if stats.TxPackets, err = read("rx_packets"); err != nil {
+		return nil, err
+	}
+	if stats.RxPackets, err = read("tx_packets"); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,173 @@
+package firecracker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+)
+
+// MMDSAddress is the link-local address the guest reaches the metadata service on
+const MMDSAddress = "169.254.169.254"
+
+// ignitionBootArgs point Ignition's metal provider at the config served over MMDS
+const ignitionBootArgs = "ignition.firstboot ignition.platform.id=metal ignition.config.url=http://" + MMDSAddress + "/ignition"
+
+// MmdsConfig exposes the microVM metadata service on guest network interfaces
+type MmdsConfig struct {
+	Version           string   `json:"version"`
+	NetworkInterfaces []string `json:"network_interfaces"`
+}
+
+// metadataPath returns the path of the MMDS contents file for a VM
+func (m *Manager) metadataPath(vmID string) string {
+	return filepath.Join(m.config.SocketDir, fmt.Sprintf("%s-metadata.json", vmID))
+}
+
+// buildMetadata returns the MMDS contents for a VM, or nil if it has none
+func (m *Manager) buildMetadata(vm *database.VM) (map[string]interface{}, error) {
+	metadata := make(map[string]interface{})
+
+	// String leaves are served verbatim, so Ignition can fetch /ignition directly
+	if vm.Ignition != "" {
+		metadata["ignition"] = vm.Ignition
+	}
+
+	// The guest agent exports these into container runtimes and login shells
+	if vm.Env != "" {
+		var env map[string]string
+		if err := json.Unmarshal([]byte(vm.Env), &env); err != nil {
+			return nil, fmt.Errorf("invalid stored env for VM %s: %w", vm.ID, err)
+		}
+		metadata["env"] = env
+	}
+
+	if m.config.InjectHosts {
+		hosts, err := m.buildHostsFile()
+		if err != nil {
+			return nil, err
+		}
+		metadata["hosts"] = hosts
+	}
+
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	return metadata, nil
+}
+
+// buildHostsFile renders /etc/hosts entries for the gateway and every VM with an
+// address, so guests can resolve each other by name without a DNS server
+func (m *Manager) buildHostsFile() (string, error) {
+	pool, err := m.ipPool()
+	if err != nil {
+		return "", err
+	}
+
+	vms, err := m.db.ListVMs()
+	if err != nil {
+		return "", fmt.Errorf("failed to list VMs for hosts file: %w", err)
+	}
+
+	var hosts strings.Builder
+	hosts.WriteString("# Managed by firecracker-orchestrator\n")
+	fmt.Fprintf(&hosts, "%s\torchestrator\n", pool.Gateway())
+
+	for _, vm := range vms {
+		if vm.IPAddress == "" || !validHostname(vm.Name) {
+			continue
+		}
+		fmt.Fprintf(&hosts, "%s\t%s\n", vm.IPAddress, vm.Name)
+	}
+
+	return hosts.String(), nil
+}
+
+// validHostname reports whether a VM name can be used as a hosts file entry
+func validHostname(name string) bool {
+	if name == "" || len(name) > 63 || name[0] == '-' || name[len(name)-1] == '-' {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// configureMetadata enables MMDS for a VM and writes its contents file, which is
+// loaded with --metadata when the VM starts. It runs again on every start so
+// fleet-wide data such as the hosts file is current at boot.
+func (m *Manager) configureMetadata(vm *database.VM, vmConfig *VMConfig) error {
+	metadata, err := m.buildMetadata(vm)
+	if err != nil {
+		return err
+	}
+	if metadata == nil {
+		os.Remove(m.metadataPath(vm.ID))
+		vmConfig.MmdsConfig = nil
+		return nil
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal VM metadata: %w", err)
+	}
+
+	if m.config.EncryptVMFiles {
+		sealed, err := m.db.Keyring().Seal(string(data))
+		if err != nil {
+			return fmt.Errorf("failed to encrypt VM metadata: %w", err)
+		}
+		data = []byte(sealed)
+	}
+
+	if err := os.WriteFile(m.metadataPath(vm.ID), data, 0600); err != nil {
+		return fmt.Errorf("failed to write VM metadata: %w", err)
+	}
+
+	// V1 serves requests without a session token, which Ignition's HTTP fetcher can't obtain
+	vmConfig.MmdsConfig = &MmdsConfig{
+		Version:           "V1",
+		NetworkInterfaces: []string{"eth0"},
+	}
+
+	return nil
+}
+
+// PushMetadata replaces the MMDS contents of a running VM with its current
+// metadata, so changes such as new env reach the guest without a restart. It
+// returns false when the VM isn't running or booted without MMDS, in which
+// case the metadata is loaded on its next start.
+func (m *Manager) PushMetadata(vm *database.VM) (bool, error) {
+	fcVM, exists := m.getVM(vm.ID)
+	if !exists || fcVM.Process.Load() == nil || fcVM.Config.MmdsConfig == nil {
+		return false, nil
+	}
+
+	metadata, err := m.buildMetadata(vm)
+	if err != nil {
+		return false, err
+	}
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal VM metadata: %w", err)
+	}
+
+	client, err := m.APIClient(vm.ID)
+	if err != nil {
+		return false, err
+	}
+	if err := client.PutMmds(context.Background(), data); err != nil {
+		return false, fmt.Errorf("failed to update MMDS of VM %s: %w", vm.ID, err)
+	}
+	return true, nil
+}
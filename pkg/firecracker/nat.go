@@ -0,0 +1,174 @@
+package firecracker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// natChain holds the orchestrator's forwarding rules, each tagged with the VM
+// or port forward it belongs to, so they can be told apart from the host's
+// own and removed with their owner
+const natChain = "FC-ORCH-FORWARD"
+
+// natComment tags the forwarding rule of a VM
+func natComment(vmID string) string {
+	return "fc-vm:" + vmID
+}
+
+// EnsureNAT lets guests reach the outside world when ENABLE_NAT is set: it
+// turns on IPv4 forwarding, masquerades traffic from the VM subnet leaving
+// through the uplink, and hooks the orchestrator's forwarding chain into
+// FORWARD. Rules that already exist are left alone.
+func (m *Manager) EnsureNAT() error {
+	if !m.config.EnableNAT {
+		return nil
+	}
+
+	uplink, err := m.uplinkInterface()
+	if err != nil {
+		return err
+	}
+	pool, err := m.ipPool()
+	if err != nil {
+		return err
+	}
+	subnet := pool.Subnet().String()
+
+	if err := ensureForwardChain(); err != nil {
+		return err
+	}
+	masquerade := []string{"-s", subnet, "-o", uplink, "-j", "MASQUERADE"}
+	if err := ensureIPTablesRule([]string{"-t", "nat"}, "POSTROUTING", masquerade); err != nil {
+		return err
+	}
+
+	m.logger.Infof("Masquerading %s out of %s", subnet, uplink)
+	return nil
+}
+
+// addNATRule lets a VM's traffic be forwarded from the bridge to the uplink
+func (m *Manager) addNATRule(vmID, ip string) error {
+	if !m.config.EnableNAT || ip == "" {
+		return nil
+	}
+
+	uplink, err := m.uplinkInterface()
+	if err != nil {
+		return err
+	}
+	spec := []string{"-i", m.config.BridgeName, "-o", uplink, "-s", ip, "-m", "comment", "--comment", natComment(vmID), "-j", "ACCEPT"}
+	return ensureIPTablesRule([]string{"-t", "filter"}, natChain, spec)
+}
+
+// removeNATRules deletes the forwarding rules of the VMs for which remove
+// returns true
+func (m *Manager) removeNATRules(remove func(vmID string) bool) {
+	if !m.config.EnableNAT {
+		return
+	}
+	m.removeTaggedRules("filter", natChain, "fc-vm:", remove)
+}
+
+// ensureForwardChain turns on IPv4 forwarding, creates the orchestrator's
+// forwarding chain, hooks it into FORWARD and lets replies to forwarded
+// connections back through
+func ensureForwardChain() error {
+	if err := os.WriteFile("/proc/sys/net/ipv4/ip_forward", []byte("1"), 0644); err != nil {
+		return fmt.Errorf("failed to enable IPv4 forwarding: %w", err)
+	}
+
+	// -N fails when the chain exists, which is fine
+	runIPTables("-t", "filter", "-N", natChain)
+	if err := ensureIPTablesRule([]string{"-t", "filter"}, "FORWARD", []string{"-j", natChain}); err != nil {
+		return err
+	}
+	established := []string{"-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT"}
+	return ensureIPTablesRule([]string{"-t", "filter"}, natChain, established)
+}
+
+// removeTaggedRules deletes the rules in chain whose comment is prefix
+// followed by an ID for which remove returns true
+func (m *Manager) removeTaggedRules(table, chain, prefix string, remove func(id string) bool) {
+	output, err := exec.Command("iptables", "-t", table, "-S", chain).Output()
+	if err != nil {
+		// The chain doesn't exist until the first rule needs it
+		m.logger.Debugf("Failed to list %s rules: %v", chain, err)
+		return
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "-A" {
+			continue
+		}
+		for i := range fields {
+			fields[i] = strings.Trim(fields[i], `"`)
+		}
+		id := ""
+		for i, field := range fields {
+			if field == "--comment" && i+1 < len(fields) && strings.HasPrefix(fields[i+1], prefix) {
+				id = strings.TrimPrefix(fields[i+1], prefix)
+			}
+		}
+		if id == "" || !remove(id) {
+			continue
+		}
+
+		fields[0] = "-D"
+		if err := runIPTables(append([]string{"-t", table}, fields...)...); err != nil {
+			m.logger.Warnf("Failed to remove %s rule %s%s: %v", chain, prefix, id, err)
+		}
+	}
+}
+
+// uplinkInterface returns UPLINK_INTERFACE, or the interface of the default route
+func (m *Manager) uplinkInterface() (string, error) {
+	if m.config.UplinkInterface != "" {
+		return m.config.UplinkInterface, nil
+	}
+
+	file, err := os.Open("/proc/net/route")
+	if err != nil {
+		return "", fmt.Errorf("failed to read routes: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Interface, destination, gateway...; the default route goes to 0.0.0.0
+		if len(fields) > 1 && fields[1] == "00000000" {
+			return fields[0], nil
+		}
+	}
+	return "", fmt.Errorf("no default route to find the uplink interface by; set UPLINK_INTERFACE")
+}
+
+// ensureIPTablesRule appends a rule to chain unless an identical one exists
+func ensureIPTablesRule(table []string, chain string, spec []string) error {
+	check := append(append(append([]string{}, table...), "-C", chain), spec...)
+	if exec.Command("iptables", check...).Run() == nil {
+		return nil
+	}
+	add := append(append(append([]string{}, table...), "-A", chain), spec...)
+	if err := runIPTables(add...); err != nil {
+		return fmt.Errorf("failed to add %s rule: %w", chain, err)
+	}
+	return nil
+}
+
+// runIPTables runs iptables, folding its output into the error
+func runIPTables(args ...string) error {
+	output, err := exec.Command("iptables", args...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
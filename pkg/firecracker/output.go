@@ -0,0 +1,185 @@
+package firecracker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Output modes, deciding where a VM's Firecracker stdout and stderr go. With
+// console=ttyS0 in the boot arguments, that is the guest's serial console.
+const (
+	OutputModeDiscard = "discard" // drop it
+	OutputModeFile    = "file"    // append it to console.log in the VM's log directory
+	OutputModeBuffer  = "buffer"  // keep the most recent VM_OUTPUT_BUFFER_KB in memory
+)
+
+// ErrOutputDiscarded is returned when reading the output of a VM that discards it
+var ErrOutputDiscarded = errors.New("VM output is discarded")
+
+// ValidOutputMode reports whether mode is one of the output modes
+func ValidOutputMode(mode string) bool {
+	switch mode {
+	case OutputModeDiscard, OutputModeFile, OutputModeBuffer:
+		return true
+	}
+	return false
+}
+
+// ringBuffer keeps the last size bytes written to it
+type ringBuffer struct {
+	mu   sync.Mutex
+	data []byte
+	size int
+}
+
+func (b *ringBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(p)
+	if len(p) >= b.size {
+		p = p[len(p)-b.size:]
+		b.data = append(b.data[:0], p...)
+		return n, nil
+	}
+	if overflow := len(b.data) + len(p) - b.size; overflow > 0 {
+		b.data = append(b.data[:0], b.data[overflow:]...)
+	}
+	b.data = append(b.data, p...)
+	return n, nil
+}
+
+// lines returns the buffered output split into lines, dropping the first if
+// the buffer has wrapped into the middle of it
+func (b *ringBuffer) lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data := b.data
+	if len(data) == b.size {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(text, "\n")
+}
+
+// outputStore holds the output buffers of VMs in buffer mode, kept after a VM
+// stops so its last output can still be read
+type outputStore struct {
+	mu      sync.Mutex
+	buffers map[string]*ringBuffer
+}
+
+// OutputMode returns the output mode in effect for a VM with the given
+// override, falling back to VM_OUTPUT_MODE
+func (m *Manager) OutputMode(override string) string {
+	if override != "" {
+		return override
+	}
+	if ValidOutputMode(m.config.VMOutputMode) {
+		return m.config.VMOutputMode
+	}
+	return OutputModeFile
+}
+
+// consolePath returns the path of a VM's console log in file mode
+func (m *Manager) consolePath(vmID string) string {
+	return filepath.Join(m.vmLogDir(vmID), "console.log")
+}
+
+// attachOutput connects a Firecracker command's stdout and stderr to the VM's
+// output destination through a console scanner that watches for guest
+// failures. The returned function must be called once cmd.Start has returned,
+// whether or not it succeeded; the output is closed when the process exits.
+func (m *Manager) attachOutput(cmd *exec.Cmd, vmID, mode string) (func(), error) {
+	var out io.Writer
+	var file *os.File
+	switch mode {
+	case OutputModeDiscard:
+	case OutputModeBuffer:
+		size := m.config.VMOutputBufferKB * 1024
+		if size <= 0 {
+			size = 256 * 1024
+		}
+		buffer := &ringBuffer{size: size}
+		m.outputs.mu.Lock()
+		m.outputs.buffers[vmID] = buffer
+		m.outputs.mu.Unlock()
+		out = buffer
+	default:
+		if err := os.MkdirAll(m.vmLogDir(vmID), 0750); err != nil {
+			return nil, fmt.Errorf("failed to create VM log directory: %w", err)
+		}
+		f, err := os.OpenFile(m.consolePath(vmID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open console log: %w", err)
+		}
+		out, file = f, f
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		if file != nil {
+			file.Close()
+		}
+		return nil, fmt.Errorf("failed to create output pipe: %w", err)
+	}
+	cmd.Stdout = w
+	cmd.Stderr = w
+
+	scanner := newConsoleScanner(out, func(failure, excerpt string) {
+		m.guestFailed(vmID, failure, excerpt)
+	})
+	return func() {
+		// Only the process holds the write end now, so the copy ends when it exits
+		w.Close()
+		go func() {
+			io.Copy(scanner, r)
+			r.Close()
+			if file != nil {
+				file.Close()
+			}
+		}()
+	}, nil
+}
+
+// TailOutput returns the last lines of a VM's console output, from its
+// console log or its in-memory buffer depending on its output mode
+func (m *Manager) TailOutput(vmID, mode string, lines int) ([]string, error) {
+	switch mode {
+	case OutputModeDiscard:
+		return nil, ErrOutputDiscarded
+	case OutputModeBuffer:
+		m.outputs.mu.Lock()
+		buffer := m.outputs.buffers[vmID]
+		m.outputs.mu.Unlock()
+		if buffer == nil {
+			return []string{}, nil
+		}
+		all := buffer.lines()
+		if len(all) > lines {
+			all = all[len(all)-lines:]
+		}
+		return all, nil
+	}
+	return tailFile(m.consolePath(vmID), lines)
+}
+
+// deleteOutput drops a deleted VM's output buffer
+func (m *Manager) deleteOutput(vmID string) {
+	m.outputs.mu.Lock()
+	delete(m.outputs.buffers, vmID)
+	m.outputs.mu.Unlock()
+}
@@ -0,0 +1,221 @@
+package firecracker
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/google/uuid"
+)
+
+// dnatChain holds the DNAT rules of port forwards, reached from PREROUTING
+// for traffic arriving at the host's own addresses. It isn't reached from
+// OUTPUT, so connections the host itself makes are never redirected.
+const dnatChain = "FC-ORCH-DNAT"
+
+// dnatJump sends traffic addressed to the host into dnatChain
+var dnatJump = []string{"-m", "addrtype", "--dst-type", "LOCAL", "-j", dnatChain}
+
+// reservedHostPorts are never published, whatever the allowed range
+var reservedHostPorts = map[int]string{22: "SSH", 67: "DHCP"}
+
+var (
+	// ErrPortInUse is returned when a host port is already forwarded or a
+	// host process listens on it
+	ErrPortInUse = errors.New("host port in use")
+	// ErrPortNotAllowed is returned for host ports outside PORT_FORWARD_MIN
+	// to PORT_FORWARD_MAX, reserved ones and the orchestrator's own
+	ErrPortNotAllowed = errors.New("host port not allowed")
+	// ErrPortForwardNotFound is returned when a VM has no port forward with the given ID
+	ErrPortForwardNotFound = errors.New("port forward not found")
+)
+
+// portForwardComment tags the rules of a port forward
+func portForwardComment(id string) string {
+	return "fc-pf:" + id
+}
+
+// AddPortForward publishes a host port to a port on a VM's guest address and
+// persists the forward so it is reapplied after a restart
+func (m *Manager) AddPortForward(vmID string, pf *database.PortForward) error {
+	m.forwardsMu.Lock()
+	defer m.forwardsMu.Unlock()
+
+	vm, err := m.db.GetVM(vmID)
+	if err != nil {
+		return fmt.Errorf("failed to get VM from database: %w", err)
+	}
+	if vm.IPAddress == "" {
+		return fmt.Errorf("VM %s has no IP address yet", vmID)
+	}
+
+	if err := m.checkHostPort(pf.HostPort); err != nil {
+		return err
+	}
+	existing, err := m.db.GetPortForwardByHostPort(pf.Protocol, pf.HostPort)
+	if err == nil {
+		return fmt.Errorf("%s port %d is forwarded to VM %s: %w", pf.Protocol, pf.HostPort, existing.VMID, ErrPortInUse)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check port forwards: %w", err)
+	}
+	// The DNAT rule would take the port's traffic from whatever listens on it
+	if hostPortBound(pf.Protocol, pf.HostPort) {
+		return fmt.Errorf("%s port %d is in use on the host: %w", pf.Protocol, pf.HostPort, ErrPortInUse)
+	}
+
+	pf.ID = uuid.New().String()
+	pf.VMID = vmID
+	if err := m.applyPortForward(pf, vm.IPAddress); err != nil {
+		m.removePortForwardRules(func(id string) bool { return id == pf.ID })
+		return err
+	}
+	if err := m.db.CreatePortForward(pf); err != nil {
+		m.removePortForwardRules(func(id string) bool { return id == pf.ID })
+		return fmt.Errorf("failed to save port forward: %w", err)
+	}
+
+	m.logger.Infof("Forwarding %s port %d to %s:%d of VM %s", pf.Protocol, pf.HostPort, vm.IPAddress, pf.GuestPort, vmID)
+	return nil
+}
+
+// checkHostPort rejects host ports outside the allowed range, reserved ones
+// and the orchestrator's own, whichever protocol is forwarded
+func (m *Manager) checkHostPort(port int) error {
+	if port == m.config.Port {
+		return fmt.Errorf("port %d is the orchestrator's own: %w", port, ErrPortNotAllowed)
+	}
+	if service, reserved := reservedHostPorts[port]; reserved {
+		return fmt.Errorf("port %d is reserved for %s: %w", port, service, ErrPortNotAllowed)
+	}
+	if port < m.config.ForwardPortMin || port > m.config.ForwardPortMax {
+		return fmt.Errorf("port %d is outside %d-%d: %w", port, m.config.ForwardPortMin, m.config.ForwardPortMax, ErrPortNotAllowed)
+	}
+	return nil
+}
+
+// hostPortBound reports whether a host process already listens on port
+func hostPortBound(protocol string, port int) bool {
+	addr := ":" + strconv.Itoa(port)
+	var err error
+	if protocol == "udp" {
+		var conn net.PacketConn
+		if conn, err = net.ListenPacket("udp", addr); err == nil {
+			conn.Close()
+		}
+	} else {
+		var listener net.Listener
+		if listener, err = net.Listen("tcp", addr); err == nil {
+			listener.Close()
+		}
+	}
+	return errors.Is(err, syscall.EADDRINUSE)
+}
+
+// RemovePortForward stops publishing a host port to a VM
+func (m *Manager) RemovePortForward(vmID, id string) error {
+	m.forwardsMu.Lock()
+	defer m.forwardsMu.Unlock()
+
+	deleted, err := m.db.DeletePortForward(vmID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete port forward: %w", err)
+	}
+	if !deleted {
+		return fmt.Errorf("VM %s has no port forward %s: %w", vmID, id, ErrPortForwardNotFound)
+	}
+	m.removePortForwardRules(func(forwardID string) bool { return forwardID == id })
+
+	m.logger.Infof("Removed port forward %s of VM %s", id, vmID)
+	return nil
+}
+
+// removeVMPortForwards drops the port forwards of a VM being deleted
+func (m *Manager) removeVMPortForwards(vmID string) error {
+	m.forwardsMu.Lock()
+	defer m.forwardsMu.Unlock()
+
+	forwards, err := m.db.ListPortForwardsByVM(vmID)
+	if err != nil {
+		return fmt.Errorf("failed to list port forwards: %w", err)
+	}
+	if len(forwards) == 0 {
+		return nil
+	}
+	ids := make(map[string]bool, len(forwards))
+	for _, pf := range forwards {
+		ids[pf.ID] = true
+	}
+	m.removePortForwardRules(func(id string) bool { return ids[id] })
+
+	if err := m.db.DeletePortForwardsByVM(vmID); err != nil {
+		return fmt.Errorf("failed to delete port forwards: %w", err)
+	}
+	return nil
+}
+
+// restorePortForwards reapplies every stored port forward, whose rules are
+// lost when the host reboots, and removes rules of forwards that are gone
+func (m *Manager) restorePortForwards(vms []*database.VM) {
+	m.forwardsMu.Lock()
+	defer m.forwardsMu.Unlock()
+
+	forwards, err := m.db.ListPortForwards()
+	if err != nil {
+		m.logger.Errorf("Failed to list port forwards: %v", err)
+		return
+	}
+
+	// Earlier versions also redirected the host's own connections
+	runIPTables(append([]string{"-t", "nat", "-D", "OUTPUT"}, dnatJump...)...)
+
+	ips := make(map[string]string, len(vms))
+	for _, vm := range vms {
+		ips[vm.ID] = vm.IPAddress
+	}
+	known := make(map[string]bool, len(forwards))
+	for _, pf := range forwards {
+		known[pf.ID] = true
+		if ips[pf.VMID] == "" {
+			continue
+		}
+		if err := m.applyPortForward(pf, ips[pf.VMID]); err != nil {
+			m.logger.Warnf("Failed to restore port forward %s of VM %s: %v", pf.ID, pf.VMID, err)
+		}
+	}
+	m.removePortForwardRules(func(id string) bool { return !known[id] })
+}
+
+// applyPortForward adds the DNAT rule of a port forward, and the rule letting
+// the translated traffic through to the guest. Existing rules are left alone.
+func (m *Manager) applyPortForward(pf *database.PortForward, ip string) error {
+	// -N fails when the chain exists, which is fine
+	runIPTables("-t", "nat", "-N", dnatChain)
+	if err := ensureIPTablesRule([]string{"-t", "nat"}, "PREROUTING", dnatJump); err != nil {
+		return err
+	}
+	if err := ensureForwardChain(); err != nil {
+		return err
+	}
+
+	tag := portForwardComment(pf.ID)
+	dnat := []string{"-p", pf.Protocol, "--dport", strconv.Itoa(pf.HostPort), "-m", "comment", "--comment", tag,
+		"-j", "DNAT", "--to-destination", fmt.Sprintf("%s:%d", ip, pf.GuestPort)}
+	if err := ensureIPTablesRule([]string{"-t", "nat"}, dnatChain, dnat); err != nil {
+		return err
+	}
+	accept := []string{"-d", ip, "-p", pf.Protocol, "--dport", strconv.Itoa(pf.GuestPort), "-m", "conntrack", "--ctstate", "DNAT",
+		"-m", "comment", "--comment", tag, "-j", "ACCEPT"}
+	return ensureIPTablesRule([]string{"-t", "filter"}, natChain, accept)
+}
+
+// removePortForwardRules deletes the rules of the port forwards for which
+// remove returns true
+func (m *Manager) removePortForwardRules(remove func(id string) bool) {
+	m.removeTaggedRules("nat", dnatChain, "fc-pf:", remove)
+	m.removeTaggedRules("filter", natChain, "fc-pf:", remove)
+}
@@ -0,0 +1,190 @@
+package firecracker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// DefaultKVMDevice is where Firecracker opens KVM
+const DefaultKVMDevice = "/dev/kvm"
+
+// kvmMajor and kvmMinor identify the KVM character device
+const (
+	kvmMajor = 10
+	kvmMinor = 232
+)
+
+// PreflightCheck is the result of one host readiness check
+type PreflightCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message"`
+}
+
+// Preflight checks that the host can run Firecracker VMs
+func (m *Manager) Preflight() []PreflightCheck {
+	checks := []PreflightCheck{
+		m.checkKVM(),
+		checkExecutable("firecracker", m.config.FirecrackerBinary),
+		checkReadable("kernel", m.config.KernelPath),
+		checkReadable("rootfs", m.config.RootfsPath),
+		checkTUN(),
+		checkWritableDir("socket_dir", m.config.SocketDir),
+	}
+	if m.config.SystemdScope {
+		checks = append(checks, checkExecutable("systemd_run", "systemd-run"))
+	}
+	return checks
+}
+
+// checkKVM verifies the configured KVM device is a KVM character device that the
+// orchestrator can open read-write
+func (m *Manager) checkKVM() PreflightCheck {
+	check := PreflightCheck{Name: "kvm"}
+	path := m.config.KVMDevice
+
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		check.Message = fmt.Sprintf("%s: %v; load the kvm module or set KVM_DEVICE to where the device is exposed", path, err)
+		return check
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFCHR {
+		check.Message = fmt.Sprintf("%s is not a character device", path)
+		return check
+	}
+	if major, minor := (st.Rdev>>8)&0xfff, (st.Rdev&0xff)|((st.Rdev>>12)&0xfff00); major != kvmMajor || minor != kvmMinor {
+		check.Message = fmt.Sprintf("%s is device %d:%d, not KVM (%d:%d)", path, major, minor, kvmMajor, kvmMinor)
+		return check
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		check.Message = fmt.Sprintf("cannot open %s read-write: %v (owner uid %d, gid %d, mode %o)%s",
+			path, err, st.Uid, st.Gid, st.Mode&0777, kvmAccessHint(st.Gid))
+		return check
+	}
+	f.Close()
+
+	check.OK = true
+	check.Message = fmt.Sprintf("%s is accessible", path)
+	if path != DefaultKVMDevice {
+		check.Message += fmt.Sprintf(" and is bind-mounted over %s for each VM", DefaultKVMDevice)
+	}
+	return check
+}
+
+// kvmAccessHint suggests why a KVM device that exists can't be opened
+func kvmAccessHint(gid uint32) string {
+	if inUserNamespace() {
+		return "; running in a user namespace, so the device's owner may be unmapped: pass it through with a mapped group or run the orchestrator in the host user namespace"
+	}
+
+	groups, err := os.Getgroups()
+	if err == nil {
+		for _, g := range groups {
+			if uint32(g) == gid {
+				return ""
+			}
+		}
+	}
+	return fmt.Sprintf("; add the orchestrator user to group %d", gid)
+}
+
+// inUserNamespace reports whether the process runs in a non-initial user namespace
+func inUserNamespace() bool {
+	f, err := os.Open("/proc/self/uid_map")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return false
+	}
+	// The initial namespace maps the full uid range onto itself
+	fields := strings.Fields(scanner.Text())
+	return !(len(fields) == 3 && fields[0] == "0" && fields[1] == "0" && fields[2] == "4294967295")
+}
+
+func checkExecutable(name, path string) PreflightCheck {
+	check := PreflightCheck{Name: name}
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		check.Message = fmt.Sprintf("%s is not executable: %v", path, err)
+		return check
+	}
+	check.OK = true
+	check.Message = resolved
+	return check
+}
+
+func checkReadable(name, path string) PreflightCheck {
+	check := PreflightCheck{Name: name}
+	f, err := os.Open(path)
+	if err != nil {
+		check.Message = err.Error()
+		return check
+	}
+	f.Close()
+	check.OK = true
+	check.Message = path
+	return check
+}
+
+func checkTUN() PreflightCheck {
+	check := PreflightCheck{Name: "tun"}
+	f, err := os.OpenFile("/dev/net/tun", os.O_RDWR, 0)
+	if err != nil {
+		check.Message = fmt.Sprintf("TAP devices can't be created: %v", err)
+		return check
+	}
+	f.Close()
+	check.OK = true
+	check.Message = "/dev/net/tun is accessible"
+	return check
+}
+
+func checkWritableDir(name, dir string) PreflightCheck {
+	check := PreflightCheck{Name: name}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		check.Message = err.Error()
+		return check
+	}
+	f, err := os.CreateTemp(dir, ".preflight-*")
+	if err != nil {
+		check.Message = fmt.Sprintf("%s is not writable: %v", dir, err)
+		return check
+	}
+	f.Close()
+	os.Remove(f.Name())
+	check.OK = true
+	check.Message = filepath.Clean(dir)
+	return check
+}
+
+// firecrackerCommand builds the command that launches a VM's Firecracker.
+// Firecracker always opens /dev/kvm, so an alternate KVM device is bind-mounted
+// over it in a private mount namespace for the VM. With SYSTEMD_SCOPE the
+// command runs in a transient systemd scope.
+func (m *Manager) firecrackerCommand(vmID string, args ...string) *exec.Cmd {
+	cmd := exec.Command(m.config.FirecrackerBinary, args...)
+	if m.config.KVMDevice != "" && m.config.KVMDevice != DefaultKVMDevice {
+		wrapper := append([]string{
+			"--mount", "--propagation", "private", "--",
+			"sh", "-c", `mount --bind "$0" ` + DefaultKVMDevice + ` && exec "$@"`,
+			m.config.KVMDevice, m.config.FirecrackerBinary,
+		}, args...)
+		cmd = exec.Command("unshare", wrapper...)
+	}
+
+	if m.config.SystemdScope {
+		cmd = m.systemdScope(vmID, cmd)
+	}
+	return cmd
+}
@@ -0,0 +1,76 @@
+package firecracker
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// Quarantine cuts a VM's network while leaving it running for forensics. The
+// flag is persisted, so the network stays cut if the VM is started again.
+func (m *Manager) Quarantine(vmID, reason string) error {
+	vm, err := m.db.GetVM(vmID)
+	if err != nil {
+		return fmt.Errorf("failed to get VM from database: %w", err)
+	}
+
+	if fcVM, exists := m.getVM(vmID); exists && fcVM.Process.Load() != nil {
+		if err := m.setTAPIsolated(fcVM.TAPDevice, true); err != nil {
+			return err
+		}
+	}
+
+	vm.Quarantined = true
+	vm.QuarantineReason = reason
+	if err := m.db.UpdateVM(vm); err != nil {
+		return fmt.Errorf("failed to update VM in database: %w", err)
+	}
+
+	m.logger.Warnf("VM %s quarantined: %s", vmID, reason)
+	return nil
+}
+
+// Unquarantine restores a quarantined VM's network
+func (m *Manager) Unquarantine(vmID string) error {
+	vm, err := m.db.GetVM(vmID)
+	if err != nil {
+		return fmt.Errorf("failed to get VM from database: %w", err)
+	}
+
+	if fcVM, exists := m.getVM(vmID); exists && fcVM.Process.Load() != nil {
+		if err := m.setTAPIsolated(fcVM.TAPDevice, false); err != nil {
+			return err
+		}
+	}
+
+	vm.Quarantined = false
+	vm.QuarantineReason = ""
+	if err := m.db.UpdateVM(vm); err != nil {
+		return fmt.Errorf("failed to update VM in database: %w", err)
+	}
+
+	m.logger.Warnf("VM %s released from quarantine", vmID)
+	return nil
+}
+
+// setTAPIsolated takes a TAP device off any bridge and down, so frames from the
+// guest go nowhere, or puts it back on the VM bridge and up. Firecracker keeps
+// running either way.
+func (m *Manager) setTAPIsolated(tap string, isolated bool) error {
+	if !isolated {
+		if err := m.attachTAPDevice(tap); err != nil {
+			return err
+		}
+		if err := exec.Command("ip", "link", "set", "dev", tap, "up").Run(); err != nil {
+			return fmt.Errorf("failed to bring up TAP device %s: %w", tap, err)
+		}
+		return nil
+	}
+
+	if err := exec.Command("ip", "link", "set", "dev", tap, "nomaster").Run(); err != nil {
+		return fmt.Errorf("failed to detach TAP device %s: %w", tap, err)
+	}
+	if err := exec.Command("ip", "link", "set", "dev", tap, "down").Run(); err != nil {
+		return fmt.Errorf("failed to take down TAP device %s: %w", tap, err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package firecracker
+
+import (
+	"fmt"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+)
+
+// RateLimiter limits a device with token buckets for bandwidth (bytes) and/or operations
+type RateLimiter struct {
+	Bandwidth *TokenBucket `json:"bandwidth,omitempty"`
+	Ops       *TokenBucket `json:"ops,omitempty"`
+}
+
+// TokenBucket allows Size tokens per RefillTime milliseconds
+type TokenBucket struct {
+	Size       int64 `json:"size"`
+	RefillTime int64 `json:"refill_time"`
+}
+
+// applyRateLimits sets the network and drive rate limiters of a VM's sizing profile
+// on its configuration. It runs again on every start so profile changes take effect.
+func (m *Manager) applyRateLimits(vm *database.VM, vmConfig *VMConfig) error {
+	var netLimit, diskLimit *RateLimiter
+
+	if vm.Profile != "" {
+		profile, err := m.db.GetSizingProfile(vm.Profile)
+		if err != nil {
+			return fmt.Errorf("failed to get sizing profile %s: %w", vm.Profile, err)
+		}
+		if profile.NetBandwidthMbps > 0 {
+			netLimit = &RateLimiter{Bandwidth: &TokenBucket{Size: profile.NetBandwidthMbps * 1000 * 1000 / 8, RefillTime: 1000}}
+		}
+		if profile.DiskIOPS > 0 {
+			diskLimit = &RateLimiter{Ops: &TokenBucket{Size: profile.DiskIOPS, RefillTime: 1000}}
+		}
+	}
+
+	for i := range vmConfig.NetworkIfaces {
+		vmConfig.NetworkIfaces[i].RxRateLimiter = netLimit
+		vmConfig.NetworkIfaces[i].TxRateLimiter = netLimit
+	}
+	for i := range vmConfig.Drives {
+		vmConfig.Drives[i].RateLimiter = diskLimit
+	}
+
+	return nil
+}
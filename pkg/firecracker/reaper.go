@@ -0,0 +1,107 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RunZombieReaper collects exited children that nobody waits for until the
+// context is cancelled. Firecracker processes are waited on by their
+// supervisors, but as PID 1 in a container the orchestrator also inherits
+// orphaned processes.
+//
+// A zombie is only reaped once it has outlived a whole interval, so processes
+// run through exec.Cmd, whose Wait collects them right away, are left alone.
+func (m *Manager) RunZombieReaper(ctx context.Context, interval time.Duration) {
+	if os.Getpid() == 1 {
+		m.logger.Info("Running as PID 1; orphaned processes will be reaped")
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	seen := map[int]bool{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		zombies := zombieChildren()
+		for pid := range zombies {
+			if !seen[pid] {
+				continue
+			}
+			var status syscall.WaitStatus
+			if reaped, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil); err != nil || reaped != pid {
+				continue
+			}
+			if vmID := m.vmByPID(pid); vmID != "" {
+				m.logger.Infof("Reaped Firecracker process %d of VM %s (%s)", pid, vmID, describeWaitStatus(status))
+			} else {
+				m.logger.Debugf("Reaped process %d (%s)", pid, describeWaitStatus(status))
+			}
+		}
+		seen = zombies
+	}
+}
+
+// zombieChildren returns the PIDs of this process's children that have exited
+// but not been waited for
+func zombieChildren() map[int]bool {
+	self := os.Getpid()
+	zombies := map[int]bool{}
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return zombies
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// The command name is parenthesised and may contain spaces, so the
+		// state and parent PID are read after its closing parenthesis
+		stat := string(data)
+		fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+		if len(fields) < 2 || fields[0] != "Z" {
+			continue
+		}
+		if ppid, err := strconv.Atoi(fields[1]); err == nil && ppid == self {
+			zombies[pid] = true
+		}
+	}
+	return zombies
+}
+
+// vmByPID returns the ID of the VM whose Firecracker process has the given PID
+func (m *Manager) vmByPID(pid int) string {
+	m.vmsMu.RLock()
+	defer m.vmsMu.RUnlock()
+
+	for id, fcVM := range m.vms {
+		if process := fcVM.Process.Load(); process != nil && process.Pid == pid {
+			return id
+		}
+	}
+	return ""
+}
+
+// describeWaitStatus explains how a reaped process ended
+func describeWaitStatus(status syscall.WaitStatus) string {
+	if status.Signaled() {
+		return "killed by signal " + strconv.Itoa(int(status.Signal())) + " (" + status.Signal().String() + ")"
+	}
+	return "exit status " + strconv.Itoa(status.ExitStatus())
+}
@@ -0,0 +1,215 @@
+package firecracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+)
+
+// activeStatuses are the statuses of VMs that have a Firecracker process
+var activeStatuses = map[string]bool{
+	"running": true,
+	"paused":  true,
+	"rescue":  true,
+}
+
+// Reconcile rebuilds the manager's view of its VMs from the database after a
+// restart. Run it at startup, before anything uses the manager.
+//
+//   - VMs whose Firecracker process is still alive are adopted, so they can be
+//     stopped, paused and inspected as before; their console output from
+//     before the restart is kept but new output is lost
+//   - VMs whose process died are marked crashed, and their sockets removed
+//   - VMs without a config file (never fully created) are left to their jobs
+//   - TAP devices of adopted VMs are put on the VM bridge, unless quarantined
+//   - TAP devices and API sockets no VM owns are removed, and so are the
+//     forwarding rules of VMs that no longer exist
+//   - Port forwards are reapplied, since their rules don't survive a reboot
+//   - IP leases are backfilled for VMs that predate them and dropped for VMs
+//     that no longer exist; addresses held by two VMs are logged
+func (m *Manager) Reconcile() error {
+	vms, err := m.db.ListVMs()
+	if err != nil {
+		return fmt.Errorf("failed to list VMs: %w", err)
+	}
+
+	taps := make(map[string]bool)
+	sockets := make(map[string]bool)
+	adopted := 0
+	for _, vm := range vms {
+		fcVM, err := m.restoreVM(vm)
+		if err != nil {
+			m.logger.Warnf("Failed to restore VM %s: %v", vm.ID, err)
+			continue
+		}
+		if fcVM == nil {
+			continue
+		}
+		taps[fcVM.TAPDevice] = true
+
+		if vm.PID > 0 && firecrackerAlive(vm.PID, fcVM.SocketPath) {
+			process, _ := os.FindProcess(vm.PID)
+			fcVM.breaker = &circuitBreaker{}
+			fcVM.Process.Store(process)
+			m.superviseAdopted(vm.ID, fcVM, process)
+			// TAP devices made before VMs were bridged are attached now
+			if !vm.Quarantined {
+				if err := m.attachTAPDevice(fcVM.TAPDevice); err != nil {
+					m.logger.Warnf("VM %s: %v", vm.ID, err)
+				}
+			}
+			if err := m.addNATRule(vm.ID, vm.IPAddress); err != nil {
+				m.logger.Warnf("VM %s won't reach the outside: %v", vm.ID, err)
+			}
+			if err := m.applySecurityGroups(vm.ID, fcVM.TAPDevice); err != nil {
+				m.logger.Errorf("VM %s is running without its security groups: %v", vm.ID, err)
+			}
+			sockets[fcVM.SocketPath] = true
+			adopted++
+			m.logger.Infof("Adopted Firecracker process %d of VM %s", vm.PID, vm.ID)
+		} else if vm.PID > 0 || activeStatuses[vm.Status] {
+			if err := m.settleDeadVM(vm); err != nil {
+				m.logger.Errorf("Failed to settle VM %s: %v", vm.ID, err)
+			}
+		}
+
+		m.vmsMu.Lock()
+		m.vms[vm.ID] = fcVM
+		m.vmsMu.Unlock()
+	}
+
+	m.removeOrphanTAPs(taps)
+	m.removeOrphanSockets(sockets)
+	m.reconcileIPLeases(vms)
+
+	known := make(map[string]bool, len(vms))
+	for _, vm := range vms {
+		known[vm.ID] = true
+	}
+	m.removeNATRules(func(id string) bool { return !known[id] })
+	m.restorePortForwards(vms)
+	m.failInterruptedDiskCopies()
+
+	m.logger.Infof("Reconciled %d VMs, %d still running", len(m.vms), adopted)
+	return nil
+}
+
+// restoreVM rebuilds the manager's record of a VM from its database row and
+// config file. It returns nil for VMs that never got a config file.
+func (m *Manager) restoreVM(vm *database.VM) (*FirecrackerVM, error) {
+	data, err := os.ReadFile(m.configPath(vm.ID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var vmConfig VMConfig
+	if err := json.Unmarshal(data, &vmConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if err := m.restoreMACAddress(vm, &vmConfig); err != nil {
+		m.logger.Warnf("Failed to restore MAC address of VM %s: %v", vm.ID, err)
+	}
+
+	// VMs created before the socket and TAP device were stored have them only
+	// in their config file and the socket naming scheme
+	socketPath := vm.SocketPath
+	if socketPath == "" {
+		socketPath = filepath.Join(m.config.SocketDir, vm.ID+".sock")
+	}
+	tapDevice := vm.TAPDevice
+	if tapDevice == "" && len(vmConfig.NetworkIfaces) > 0 {
+		tapDevice = vmConfig.NetworkIfaces[0].HostDevName
+		// Record it so the name isn't handed to another VM
+		vm.TAPDevice = tapDevice
+		if err := m.db.UpdateVM(vm); err != nil {
+			m.logger.Warnf("Failed to save TAP device of VM %s: %v", vm.ID, err)
+		}
+	}
+
+	return &FirecrackerVM{
+		ID:         vm.ID,
+		SocketPath: socketPath,
+		TAPDevice:  tapDevice,
+		Config:     &vmConfig,
+	}, nil
+}
+
+// settleDeadVM records that a VM's Firecracker process is gone
+func (m *Manager) settleDeadVM(vm *database.VM) error {
+	m.removeRescueDisk(vm.ID)
+
+	if activeStatuses[vm.Status] {
+		m.logger.Warnf("VM %s was %s but its Firecracker process is gone; marking it crashed", vm.ID, vm.Status)
+		now := time.Now()
+		vm.Status = "crashed"
+		vm.StatusReason = "Firecracker process exited while the orchestrator was down"
+		vm.ExitCode = -1
+		vm.ExitedAt = &now
+	}
+	vm.PID = 0
+	return m.db.UpdateVM(vm)
+}
+
+// firecrackerAlive reports whether pid is a live Firecracker process serving
+// socketPath, so a PID reused by another process isn't adopted
+func firecrackerAlive(pid int, socketPath string) bool {
+	cmdline, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		return false
+	}
+	// Zombies have an empty command line
+	for _, arg := range strings.Split(string(cmdline), "\x00") {
+		if arg == socketPath {
+			return true
+		}
+	}
+	return false
+}
+
+// removeOrphanTAPs deletes TAP devices named like the orchestrator's that no
+// VM owns
+func (m *Manager) removeOrphanTAPs(owned map[string]bool) {
+	entries, err := os.ReadDir("/sys/class/net")
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if _, err := strconv.Atoi(strings.TrimPrefix(name, m.config.TAPDeviceBase)); err != nil || !strings.HasPrefix(name, m.config.TAPDeviceBase) {
+			continue
+		}
+		if owned[name] {
+			continue
+		}
+		if err := m.deleteTAPDevice(name); err != nil {
+			m.logger.Warnf("Failed to delete orphaned TAP device %s: %v", name, err)
+			continue
+		}
+		m.logger.Infof("Deleted orphaned TAP device %s", name)
+	}
+}
+
+// removeOrphanSockets deletes API sockets in SOCKET_DIR that no running VM
+// is serving
+func (m *Manager) removeOrphanSockets(live map[string]bool) {
+	paths, err := filepath.Glob(filepath.Join(m.config.SocketDir, "*.sock"))
+	if err != nil {
+		return
+	}
+	for _, path := range paths {
+		if live[path] {
+			continue
+		}
+		if err := os.Remove(path); err == nil {
+			m.logger.Infof("Removed stale API socket %s", path)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package firecracker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Errors returned when booting a VM into rescue mode
+var (
+	ErrRescueUnavailable = errors.New("rescue mode is not configured")
+	ErrVMInRescue        = errors.New("VM is in rescue mode")
+)
+
+// rescueDriveID is the drive the rescue system boots from. The VM's own drives
+// follow it, so the guest sees the original root filesystem as /dev/vdb.
+const rescueDriveID = "rescue"
+
+// rescuePath returns the path of the rescue system disk of a VM in rescue mode
+func (m *Manager) rescuePath(vmID string) string {
+	return filepath.Join(m.config.VMDiskDir, vmID+"-rescue.ext4")
+}
+
+// RescueVM boots a stopped VM once from the rescue kernel and root filesystem,
+// with its own drives attached after the rescue disk and none of them the root
+// device. The VM's configuration is left alone, so stopping and starting it
+// boots it normally again. Lifecycle hooks are not run.
+func (m *Manager) RescueVM(vmID string) error {
+	m.logger.Infof("Booting VM %s into rescue mode", vmID)
+
+	if m.config.RescueRootfsPath == "" {
+		return ErrRescueUnavailable
+	}
+
+	vm, err := m.db.GetVM(vmID)
+	if err != nil {
+		return fmt.Errorf("failed to get VM from database: %w", err)
+	}
+	if vm.Status == "deleting" {
+		return ErrVMDeleting
+	}
+
+	fcVM, exists := m.getVM(vmID)
+	if !exists {
+		return fmt.Errorf("VM %s not found in manager", vmID)
+	}
+	fcVM.launchMu.Lock()
+	defer fcVM.launchMu.Unlock()
+	if fcVM.Process.Load() != nil {
+		return fmt.Errorf("cannot rescue VM %s: %w", vmID, ErrVMRunning)
+	}
+	m.cancelRestart(fcVM)
+
+	kernel := m.rescueKernelPath()
+	for _, path := range []string{kernel, m.config.RescueRootfsPath} {
+		verified, err := m.verifier.Verify(path, "")
+		if err != nil {
+			return err
+		}
+		if !verified {
+			m.logger.Warnf("Rescuing VM %s from unverified image %s", vmID, path)
+		}
+	}
+
+	if err := m.applyRateLimits(vm, fcVM.Config); err != nil {
+		return err
+	}
+	if err := m.configureLogger(vm, fcVM.Config); err != nil {
+		return err
+	}
+	if err := m.configureMetadata(vm, fcVM.Config); err != nil {
+		return err
+	}
+
+	// The rescue system gets a disk of its own, so whatever is done in it
+	// doesn't reach the image other rescues start from
+	if err := os.MkdirAll(m.config.VMDiskDir, 0750); err != nil {
+		return fmt.Errorf("failed to create disk directory: %w", err)
+	}
+	if err := copyImage(m.config.RescueRootfsPath, m.rescuePath(vmID)); err != nil {
+		return err
+	}
+
+	rescueConfig := *fcVM.Config
+	rescueConfig.BootSource = BootSource{KernelImagePath: kernel, BootArgs: DefaultBootArgs}
+	rescueConfig.Drives = []Drive{{
+		DriveID:      rescueDriveID,
+		PathOnHost:   m.rescuePath(vmID),
+		IsRootDevice: true,
+	}}
+	for _, drive := range fcVM.Config.Drives {
+		drive.IsRootDevice = false
+		rescueConfig.Drives = append(rescueConfig.Drives, drive)
+	}
+
+	cmd, err := m.launch(vm, fcVM, &rescueConfig)
+	if err != nil {
+		m.removeRescueDisk(vmID)
+		return err
+	}
+
+	vm.Status = "rescue"
+	vm.StatusReason = ""
+	if err := m.db.UpdateVM(vm); err != nil {
+		return fmt.Errorf("failed to update VM status: %w", err)
+	}
+	m.supervise(vmID, fcVM, cmd)
+
+	m.logger.Infof("VM %s booted into rescue mode with PID %d", vmID, cmd.Process.Pid)
+	return nil
+}
+
+// rescueKernelPath returns the kernel rescue boots use
+func (m *Manager) rescueKernelPath() string {
+	if m.config.RescueKernelPath != "" {
+		return m.config.RescueKernelPath
+	}
+	return m.config.KernelPath
+}
+
+// removeRescueDisk deletes the rescue system disk of a VM, if it has one
+func (m *Manager) removeRescueDisk(vmID string) {
+	if err := os.Remove(m.rescuePath(vmID)); err != nil && !os.IsNotExist(err) {
+		m.logger.Warnf("Failed to delete rescue disk of VM %s: %v", vmID, err)
+	}
+}
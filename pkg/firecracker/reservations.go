@@ -0,0 +1,276 @@
+package firecracker
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+)
+
+var (
+	// ErrInsufficientCapacity is returned when the host can't hold back what is asked for
+	ErrInsufficientCapacity = errors.New("insufficient host capacity")
+	// ErrReservationNotFound is returned for unknown or expired reservations
+	ErrReservationNotFound = errors.New("reservation not found or expired")
+	// ErrReservationExceeded is returned when a VM needs more than a reservation has left
+	ErrReservationExceeded = errors.New("VM does not fit in what is left of the reservation")
+)
+
+// CreateReservation holds back memory, CPUs and ipCount guest addresses until
+// the reservation expires, failing when the host's configured capacity can't
+// cover it on top of existing VMs and reservations
+func (m *Manager) CreateReservation(r *database.Reservation, ipCount int) error {
+	m.ipMu.Lock()
+	defer m.ipMu.Unlock()
+
+	if err := m.checkCapacity(r.Memory, r.CPUs); err != nil {
+		return err
+	}
+
+	reserved, err := m.reservedIPAddresses()
+	if err != nil {
+		return err
+	}
+
+	r.IPAddresses = []string{}
+	for i := 0; i < ipCount; i++ {
+		ip, err := m.generateIPAddress(reserved)
+		if err != nil {
+			return fmt.Errorf("%v: %w", err, ErrInsufficientCapacity)
+		}
+		reserved[ip] = true
+		r.IPAddresses = append(r.IPAddresses, ip)
+	}
+
+	if err := m.db.CreateReservation(r); err != nil {
+		return fmt.Errorf("failed to save reservation: %w", err)
+	}
+
+	m.logger.Infof("Reserved %d MB, %d vCPUs and %d addresses as %s until %s",
+		r.Memory, r.CPUs, len(r.IPAddresses), r.ID, r.ExpiresAt.Format("2006-01-02T15:04:05Z07:00"))
+	return nil
+}
+
+// ReservationClaim is what a VM took out of a reservation, kept so it can be
+// given back if the VM isn't created after all. Creation jobs carry it in
+// their payload.
+type ReservationClaim struct {
+	ReservationID string `json:"reservation_id"`
+	VMID          string `json:"vm_id"`
+	Memory        int64  `json:"memory"`
+	CPUs          int    `json:"cpus"`
+	IPAddress     string `json:"ip_address,omitempty"` // taken from the reservation's addresses, if any
+}
+
+// ClaimReservation takes a VM's memory and CPUs out of a reservation, along with
+// its address: the requested one, or else the next reserved one if any are left.
+// The VM's address is set and leased in the same transaction, so no other VM
+// can take it in between. A reservation is kept when it runs out, until it
+// expires, so claims can still be returned to it.
+func (m *Manager) ClaimReservation(id string, vm *database.VM) (*ReservationClaim, error) {
+	m.ipMu.Lock()
+	defer m.ipMu.Unlock()
+
+	r, ip, reserved, err := m.takeFromReservation(id, vm.Memory, vm.CPUs, vm.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	var lease *database.IPLease
+	if ip != "" {
+		lease = &database.IPLease{IP: net.ParseIP(ip).To4().String(), VMID: vm.ID}
+	}
+	if err := m.db.ClaimReservation(r, lease); err != nil {
+		if lease != nil {
+			if held, getErr := m.db.GetIPLease(lease.IP); getErr == nil && held.VMID != vm.ID {
+				return nil, fmt.Errorf("%s is assigned to VM %s: %w", ip, held.VMID, ErrIPInUse)
+			}
+		}
+		return nil, fmt.Errorf("failed to claim reservation: %w", err)
+	}
+
+	vm.IPAddress = ip
+	claim := &ReservationClaim{ReservationID: id, VMID: vm.ID, Memory: vm.Memory, CPUs: vm.CPUs}
+	if reserved {
+		claim.IPAddress = ip
+	}
+	return claim, nil
+}
+
+// ReturnClaim gives what a VM took out of a reservation back to it and
+// releases the VM's address, for a VM whose creation failed
+func (m *Manager) ReturnClaim(claim *ReservationClaim) error {
+	m.ipMu.Lock()
+	defer m.ipMu.Unlock()
+
+	if err := m.db.ReturnToReservation(claim.ReservationID, claim.Memory, claim.CPUs, claim.VMID, claim.IPAddress); err != nil {
+		return fmt.Errorf("failed to return claim on reservation %s: %w", claim.ReservationID, err)
+	}
+	return nil
+}
+
+// CheckReservation reports whether a VM fits in what is left of a
+// reservation, as ClaimReservation would, without claiming anything. It
+// returns the address the VM would get.
+func (m *Manager) CheckReservation(id string, memory int64, cpus int, ip string) (string, error) {
+	m.ipMu.Lock()
+	defer m.ipMu.Unlock()
+
+	_, ip, _, err := m.takeFromReservation(id, memory, cpus, ip)
+	return ip, err
+}
+
+// takeFromReservation loads a reservation and takes a VM's share out of the
+// copy, leaving the caller to save it. It also reports whether the address
+// came out of the reservation. The caller holds ipMu.
+func (m *Manager) takeFromReservation(id string, memory int64, cpus int, ip string) (*database.Reservation, string, bool, error) {
+	r, err := m.db.GetReservation(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", false, ErrReservationNotFound
+	}
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to get reservation: %w", err)
+	}
+	if r.Expired() {
+		return nil, "", false, ErrReservationNotFound
+	}
+
+	if memory > r.Memory || cpus > r.CPUs {
+		return nil, "", false, fmt.Errorf("%w: %d MB and %d vCPUs left", ErrReservationExceeded, r.Memory, r.CPUs)
+	}
+
+	claimed := -1
+	for i, reservedIP := range r.IPAddresses {
+		if ip == "" || reservedIP == ip {
+			claimed = i
+			break
+		}
+	}
+	if claimed >= 0 {
+		ip = r.IPAddresses[claimed]
+		r.IPAddresses = append(r.IPAddresses[:claimed], r.IPAddresses[claimed+1:]...)
+	} else if ip != "" {
+		if err := m.ValidateStaticIP("", ip); err != nil {
+			return nil, "", false, err
+		}
+	}
+
+	r.Memory -= memory
+	r.CPUs -= cpus
+	return r, ip, claimed >= 0, nil
+}
+
+// CheckCapacity reports whether a VM outside any reservation fits in the host's
+// configured capacity alongside existing VMs and reservations
+func (m *Manager) CheckCapacity(memory int64, cpus int) error {
+	m.ipMu.Lock()
+	defer m.ipMu.Unlock()
+
+	return m.checkCapacity(memory, cpus)
+}
+
+// checkCapacity compares a request with HOST_MEMORY_MB and HOST_CPUS, counting
+// every defined VM since stopped ones can be started again. A zero limit is not
+// enforced. The caller holds ipMu.
+func (m *Manager) checkCapacity(memory int64, cpus int) error {
+	if m.config.HostMemoryMB <= 0 && m.config.HostCPUs <= 0 {
+		return nil
+	}
+
+	capacity, err := m.capacity()
+	if err != nil {
+		return err
+	}
+	usedMemory := memory + capacity.VMMemoryMB + capacity.ReservedMemoryMB
+	usedCPUs := cpus + capacity.VMCPUs + capacity.ReservedCPUs
+
+	if m.config.HostMemoryMB > 0 && usedMemory > m.config.HostMemoryMB {
+		return fmt.Errorf("%w: %d MB of %d MB would be committed", ErrInsufficientCapacity, usedMemory, m.config.HostMemoryMB)
+	}
+	if m.config.HostCPUs > 0 && usedCPUs > m.config.HostCPUs {
+		return fmt.Errorf("%w: %d of %d vCPUs would be committed", ErrInsufficientCapacity, usedCPUs, m.config.HostCPUs)
+	}
+	return nil
+}
+
+// Capacity is the host's configured capacity and how much of it VMs and
+// reservations hold. A zero limit is not enforced, and then nothing is
+// reported as available.
+type Capacity struct {
+	MemoryMB          int64  `json:"memory_mb"` // HOST_MEMORY_MB
+	CPUs              int    `json:"cpus"`      // HOST_CPUS
+	VMMemoryMB        int64  `json:"vm_memory_mb"`
+	VMCPUs            int    `json:"vm_cpus"`
+	ReservedMemoryMB  int64  `json:"reserved_memory_mb"`
+	ReservedCPUs      int    `json:"reserved_cpus"`
+	AvailableMemoryMB *int64 `json:"available_memory_mb,omitempty"`
+	AvailableCPUs     *int   `json:"available_cpus,omitempty"`
+}
+
+// Capacity reports what a new VM outside any reservation can still take
+func (m *Manager) Capacity() (*Capacity, error) {
+	m.ipMu.Lock()
+	defer m.ipMu.Unlock()
+
+	capacity, err := m.capacity()
+	if err != nil {
+		return nil, err
+	}
+	if capacity.MemoryMB > 0 {
+		available := capacity.MemoryMB - capacity.VMMemoryMB - capacity.ReservedMemoryMB
+		if available < 0 {
+			available = 0
+		}
+		capacity.AvailableMemoryMB = &available
+	}
+	if capacity.CPUs > 0 {
+		available := capacity.CPUs - capacity.VMCPUs - capacity.ReservedCPUs
+		if available < 0 {
+			available = 0
+		}
+		capacity.AvailableCPUs = &available
+	}
+	return capacity, nil
+}
+
+// capacity adds up what every defined VM and unexpired reservation holds.
+// The caller holds ipMu.
+func (m *Manager) capacity() (*Capacity, error) {
+	vms, err := m.db.ListVMs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VMs: %w", err)
+	}
+	reservations, err := m.db.ListReservations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reservations: %w", err)
+	}
+
+	capacity := &Capacity{MemoryMB: m.config.HostMemoryMB, CPUs: m.config.HostCPUs}
+	for _, vm := range vms {
+		capacity.VMMemoryMB += vm.Memory
+		capacity.VMCPUs += vm.CPUs
+	}
+	for _, r := range reservations {
+		capacity.ReservedMemoryMB += r.Memory
+		capacity.ReservedCPUs += r.CPUs
+	}
+	return capacity, nil
+}
+
+// reservedIPAddresses returns the addresses held by unexpired reservations
+func (m *Manager) reservedIPAddresses() (map[string]bool, error) {
+	reservations, err := m.db.ListReservations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reservations: %w", err)
+	}
+
+	reserved := make(map[string]bool)
+	for _, r := range reservations {
+		for _, ip := range r.IPAddresses {
+			reserved[ip] = true
+		}
+	}
+	return reserved, nil
+}
@@ -0,0 +1,156 @@
+package firecracker
+
+import (
+	"fmt"
+	"time"
+)
+
+// Restart policies
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+// ValidRestartPolicy reports whether policy is a known restart policy
+func ValidRestartPolicy(policy string) bool {
+	switch policy {
+	case RestartNever, RestartOnFailure, RestartAlways:
+		return true
+	}
+	return false
+}
+
+// RestartPolicy returns the restart policy for a VM with the given override,
+// falling back to RESTART_POLICY
+func (m *Manager) RestartPolicy(override string) string {
+	if override != "" {
+		return override
+	}
+	if ValidRestartPolicy(m.config.RestartPolicy) {
+		return m.config.RestartPolicy
+	}
+	return RestartNever
+}
+
+// planRestart decides whether a VM whose process exited on its own is started
+// again, and after how long. on-failure restarts crashed VMs and always also
+// restarts guests that shut themselves down. Delays start at
+// RESTART_BACKOFF_SECONDS and double with each restart in a row, up to
+// RESTART_BACKOFF_MAX_SECONDS; a VM that stayed up for
+// RESTART_BACKOFF_RESET_SECONDS starts over. It returns a note for the VM's
+// status reason either way.
+func (m *Manager) planRestart(fcVM *FirecrackerVM, status, policy string) (time.Duration, string, bool) {
+	switch m.RestartPolicy(policy) {
+	case RestartAlways:
+	case RestartOnFailure:
+		if status != "crashed" {
+			return 0, "", false
+		}
+	default:
+		return 0, "", false
+	}
+
+	m.restartMu.Lock()
+	defer m.restartMu.Unlock()
+
+	if time.Since(fcVM.launchedAt) >= time.Duration(m.config.RestartResetSeconds)*time.Second {
+		fcVM.backoff = 0
+	}
+	if max := m.config.RestartMaxAttempts; max > 0 && fcVM.backoff >= max {
+		return 0, fmt.Sprintf("not restarted after %d attempts", fcVM.backoff), false
+	}
+
+	delay := time.Duration(m.config.RestartDelaySeconds) * time.Second
+	maxDelay := time.Duration(m.config.RestartMaxDelay) * time.Second
+	for i := 0; i < fcVM.backoff && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	fcVM.backoff++
+	return delay, fmt.Sprintf("restarting in %s", delay), true
+}
+
+// scheduleRestart starts a VM again after delay, unless it is started,
+// stopped, rescued or deleted through the API first
+func (m *Manager) scheduleRestart(vmID string, fcVM *FirecrackerVM, delay time.Duration) {
+	m.restartMu.Lock()
+	defer m.restartMu.Unlock()
+
+	if fcVM.restartTimer != nil {
+		fcVM.restartTimer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		m.restartMu.Lock()
+		if fcVM.restartTimer != timer {
+			m.restartMu.Unlock()
+			return
+		}
+		fcVM.restartTimer = nil
+		// Counts from the attempt, so failed restarts back off too
+		fcVM.launchedAt = time.Now()
+		m.restartMu.Unlock()
+
+		m.restart(vmID, fcVM)
+	})
+	fcVM.restartTimer = timer
+}
+
+// cancelRestart drops a VM's pending restart, if it has one
+func (m *Manager) cancelRestart(fcVM *FirecrackerVM) {
+	m.restartMu.Lock()
+	defer m.restartMu.Unlock()
+
+	if fcVM.restartTimer != nil {
+		fcVM.restartTimer.Stop()
+		fcVM.restartTimer = nil
+	}
+}
+
+// resetRestart drops a VM's pending restart and starts its backoff over, for
+// a VM started through the API
+func (m *Manager) resetRestart(fcVM *FirecrackerVM) {
+	m.cancelRestart(fcVM)
+
+	m.restartMu.Lock()
+	fcVM.backoff = 0
+	m.restartMu.Unlock()
+}
+
+// restart starts a VM again after its process exited. A failed attempt is
+// recorded and counts towards the backoff like a crash.
+func (m *Manager) restart(vmID string, fcVM *FirecrackerVM) {
+	vm, err := m.db.GetVM(vmID)
+	if err != nil {
+		m.logger.Errorf("Failed to get VM %s to restart it: %v", vmID, err)
+		return
+	}
+
+	m.logger.Infof("Restarting VM %s (restart %d)", vmID, vm.RestartCount+1)
+	startErr := m.startVM(vmID, vm.RestartCount+1)
+	if startErr == nil {
+		return
+	}
+	m.logger.Errorf("Failed to restart VM %s: %v", vmID, startErr)
+
+	if vm, err = m.db.GetVM(vmID); err != nil || vm.Status == "deleting" || fcVM.Process.Load() != nil {
+		return
+	}
+	delay, note, again := m.planRestart(fcVM, "crashed", vm.RestartPolicy)
+	vm.Status = "crashed"
+	vm.StatusReason = "restart failed: " + startErr.Error()
+	if note != "" {
+		vm.StatusReason += "; " + note
+	}
+	vm.RestartCount++
+	if err := m.db.UpdateVM(vm); err != nil {
+		m.logger.Errorf("Failed to record failed restart of VM %s: %v", vmID, err)
+		return
+	}
+	if again {
+		m.scheduleRestart(vmID, fcVM, delay)
+	}
+}
@@ -0,0 +1,256 @@
+package firecracker
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+)
+
+// sgTable is the nftables table security groups are rendered into. It is a
+// bridge family table so traffic between guests on the bridge is filtered as
+// well as traffic to the host and the outside.
+const sgTable = "bridge fc_orch_sg"
+
+// ErrInvalidSecurityGroupRule is returned for rules that can't be rendered
+var ErrInvalidSecurityGroupRule = errors.New("invalid security group rule")
+
+// ValidateSecurityGroupRules checks rules and normalises them in place:
+// directions and protocols are lowercased, an empty CIDR becomes 0.0.0.0/0
+// and bare addresses become /32 networks
+func ValidateSecurityGroupRules(rules []database.SecurityGroupRule) error {
+	for i := range rules {
+		rule := &rules[i]
+		rule.Direction = strings.ToLower(rule.Direction)
+		rule.Protocol = strings.ToLower(rule.Protocol)
+
+		if rule.Direction != "ingress" && rule.Direction != "egress" {
+			return fmt.Errorf("rule %d: direction must be ingress or egress: %w", i+1, ErrInvalidSecurityGroupRule)
+		}
+		switch rule.Protocol {
+		case "tcp", "udp":
+		case "icmp", "all":
+			if rule.PortFrom != 0 || rule.PortTo != 0 {
+				return fmt.Errorf("rule %d: ports only apply to tcp and udp: %w", i+1, ErrInvalidSecurityGroupRule)
+			}
+		default:
+			return fmt.Errorf("rule %d: protocol must be tcp, udp, icmp or all: %w", i+1, ErrInvalidSecurityGroupRule)
+		}
+
+		if rule.CIDR == "" {
+			rule.CIDR = "0.0.0.0/0"
+		}
+		if !strings.Contains(rule.CIDR, "/") {
+			rule.CIDR += "/32"
+		}
+		_, network, err := net.ParseCIDR(rule.CIDR)
+		if err != nil || network.IP.To4() == nil {
+			return fmt.Errorf("rule %d: %q is not an IPv4 CIDR: %w", i+1, rule.CIDR, ErrInvalidSecurityGroupRule)
+		}
+		rule.CIDR = network.String()
+
+		if rule.PortFrom < 0 || rule.PortFrom > 65535 || rule.PortTo < 0 || rule.PortTo > 65535 {
+			return fmt.Errorf("rule %d: ports must be between 1 and 65535: %w", i+1, ErrInvalidSecurityGroupRule)
+		}
+		if rule.PortTo != 0 && (rule.PortFrom == 0 || rule.PortTo < rule.PortFrom) {
+			return fmt.Errorf("rule %d: port_to needs a port_from no greater than it: %w", i+1, ErrInvalidSecurityGroupRule)
+		}
+	}
+	return nil
+}
+
+// ApplySecurityGroups renders a VM's security groups into its TAP device's
+// chains, replacing what was there. VMs that were never set up have no TAP
+// device yet; theirs are applied when they start.
+func (m *Manager) ApplySecurityGroups(vmID string) error {
+	fcVM, exists := m.getVM(vmID)
+	if !exists || fcVM.TAPDevice == "" {
+		return nil
+	}
+	return m.applySecurityGroups(vmID, fcVM.TAPDevice)
+}
+
+// ApplySecurityGroup reapplies the rules of every VM a security group is
+// attached to, e.g. after the group's rules changed
+func (m *Manager) ApplySecurityGroup(groupID string) error {
+	vmIDs, err := m.db.ListSecurityGroupVMs(groupID)
+	if err != nil {
+		return fmt.Errorf("failed to list VMs of security group %s: %w", groupID, err)
+	}
+
+	var failed []string
+	for _, vmID := range vmIDs {
+		if err := m.ApplySecurityGroups(vmID); err != nil {
+			m.logger.Errorf("Failed to apply security groups of VM %s: %v", vmID, err)
+			failed = append(failed, vmID)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to apply security groups of VMs %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// applySecurityGroups renders the security groups of a VM into the chains of
+// its TAP device in one nft transaction. A VM without groups has its chains
+// removed, leaving its traffic unfiltered.
+func (m *Manager) applySecurityGroups(vmID, tap string) error {
+	groups, err := m.db.ListVMSecurityGroups(vmID)
+	if err != nil {
+		return fmt.Errorf("failed to list security groups of VM %s: %w", vmID, err)
+	}
+
+	m.sgMu.Lock()
+	defer m.sgMu.Unlock()
+
+	if len(groups) == 0 {
+		return m.removeSecurityGroupChains(tap)
+	}
+	if err := ensureSecurityGroupTable(); err != nil {
+		return err
+	}
+	if err := runNFT(renderSecurityGroupChains(tap, groups)); err != nil {
+		return fmt.Errorf("failed to apply security groups to %s: %w", tap, err)
+	}
+	return nil
+}
+
+// removeSecurityGroupChains drops the chains of a TAP device, if any. The
+// caller holds sgMu.
+func (m *Manager) removeSecurityGroupChains(tap string) error {
+	if !securityGroupTableExists() {
+		return nil
+	}
+
+	in, out := securityGroupChains(tap)
+	var script strings.Builder
+	// Adding first makes the deletes succeed whether or not the chains exist
+	for _, c := range []struct{ chain, set string }{{in, "sg_in"}, {out, "sg_out"}} {
+		fmt.Fprintf(&script, "add chain %s %s\n", sgTable, c.chain)
+		fmt.Fprintf(&script, "add element %s %s { %q : jump %s }\n", sgTable, c.set, tap, c.chain)
+		fmt.Fprintf(&script, "delete element %s %s { %q }\n", sgTable, c.set, tap)
+		fmt.Fprintf(&script, "flush chain %s %s\n", sgTable, c.chain)
+		fmt.Fprintf(&script, "delete chain %s %s\n", sgTable, c.chain)
+	}
+	if err := runNFT(script.String()); err != nil {
+		return fmt.Errorf("failed to remove security groups from %s: %w", tap, err)
+	}
+	return nil
+}
+
+// securityGroupChains names the chains filtering traffic to and from a TAP device
+func securityGroupChains(tap string) (string, string) {
+	return "sg-in-" + tap, "sg-out-" + tap
+}
+
+// renderSecurityGroupChains builds the nft script that fills a TAP device's
+// chains and maps the device to them. Allowed traffic returns so a packet
+// between two filtered guests still meets the receiver's ingress rules; the
+// rest is dropped. Replies to allowed traffic, ARP and DHCP with the
+// orchestrator always pass.
+func renderSecurityGroupChains(tap string, groups []*database.SecurityGroup) string {
+	in, out := securityGroupChains(tap)
+
+	var script strings.Builder
+	for _, chain := range []string{in, out} {
+		fmt.Fprintf(&script, "add chain %s %s\n", sgTable, chain)
+		fmt.Fprintf(&script, "flush chain %s %s\n", sgTable, chain)
+		fmt.Fprintf(&script, "add rule %s %s ct state established,related return\n", sgTable, chain)
+		fmt.Fprintf(&script, "add rule %s %s ether type arp return\n", sgTable, chain)
+	}
+	fmt.Fprintf(&script, "add rule %s %s udp sport 67 udp dport 68 return\n", sgTable, in)
+	fmt.Fprintf(&script, "add rule %s %s udp sport 68 udp dport 67 return\n", sgTable, out)
+
+	for _, group := range groups {
+		for _, rule := range group.Rules {
+			chain := out
+			if rule.Direction == "ingress" {
+				chain = in
+			}
+			fmt.Fprintf(&script, "add rule %s %s %s\n", sgTable, chain, renderSecurityGroupRule(rule))
+		}
+	}
+
+	fmt.Fprintf(&script, "add rule %s %s drop\n", sgTable, in)
+	fmt.Fprintf(&script, "add rule %s %s drop\n", sgTable, out)
+	fmt.Fprintf(&script, "add element %s sg_in { %q : jump %s }\n", sgTable, tap, in)
+	fmt.Fprintf(&script, "add element %s sg_out { %q : jump %s }\n", sgTable, tap, out)
+	return script.String()
+}
+
+// renderSecurityGroupRule renders a validated rule as an nft rule body
+func renderSecurityGroupRule(rule database.SecurityGroupRule) string {
+	// The peer is the source of ingress traffic and the destination of egress
+	addr := "daddr"
+	if rule.Direction == "ingress" {
+		addr = "saddr"
+	}
+	parts := []string{"ip", addr, rule.CIDR}
+	if rule.CIDR == "0.0.0.0/0" {
+		parts = []string{"ether", "type", "ip"}
+	}
+
+	switch rule.Protocol {
+	case "tcp", "udp":
+		if rule.PortFrom == 0 {
+			parts = append(parts, "ip", "protocol", rule.Protocol)
+			break
+		}
+		ports := fmt.Sprint(rule.PortFrom)
+		if rule.PortTo > rule.PortFrom {
+			ports = fmt.Sprintf("%d-%d", rule.PortFrom, rule.PortTo)
+		}
+		parts = append(parts, rule.Protocol, "dport", ports)
+	case "icmp":
+		parts = append(parts, "ip", "protocol", "icmp")
+	}
+
+	return strings.Join(append(parts, "return"), " ")
+}
+
+// ensureSecurityGroupTable creates the security group table on first use: a
+// map from TAP device to its chains for each direction, consulted by the
+// bridge's forward, input and output hooks
+func ensureSecurityGroupTable() error {
+	if securityGroupTableExists() {
+		return nil
+	}
+
+	script := fmt.Sprintf(`add table %[1]s
+add map %[1]s sg_in { type ifname : verdict; }
+add map %[1]s sg_out { type ifname : verdict; }
+add chain %[1]s forward { type filter hook forward priority 0; policy accept; }
+add rule %[1]s forward iifname vmap @sg_out
+add rule %[1]s forward oifname vmap @sg_in
+add chain %[1]s input { type filter hook input priority 0; policy accept; }
+add rule %[1]s input iifname vmap @sg_out
+add chain %[1]s output { type filter hook output priority 0; policy accept; }
+add rule %[1]s output oifname vmap @sg_in
+`, sgTable)
+	if err := runNFT(script); err != nil {
+		return fmt.Errorf("failed to create nftables table for security groups: %w", err)
+	}
+	return nil
+}
+
+// securityGroupTableExists reports whether the security group table is set up
+func securityGroupTableExists() bool {
+	return exec.Command("nft", "list", "table", "bridge", "fc_orch_sg").Run() == nil
+}
+
+// runNFT runs an nft script as one transaction, folding nft's output into the error
+func runNFT(script string) error {
+	cmd := exec.Command("nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(script)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
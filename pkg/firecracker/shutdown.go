@@ -0,0 +1,98 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+)
+
+// What happens to running VMs when the orchestrator shuts down
+const (
+	ShutdownStop   = "stop"   // stop them as StopVM would, running pre-stop hooks
+	ShutdownDetach = "detach" // leave them running, e.g. in their own systemd scopes
+)
+
+// ValidShutdownPolicy reports whether policy is one of the shutdown policies
+func ValidShutdownPolicy(policy string) bool {
+	return policy == ShutdownStop || policy == ShutdownDetach
+}
+
+// Shutdown settles the VMs the manager tracks before the orchestrator exits.
+// Under the stop policy every running VM is stopped, in parallel, with guests
+// given STOP_TIMEOUT to shut down; those still stopping when ctx ends are
+// killed outright. Under either policy the TAP
+// devices and API sockets of VMs that aren't left running are removed, along
+// with the rescue disks they no longer need.
+func (m *Manager) Shutdown(ctx context.Context, policy string) {
+	m.vmsMu.RLock()
+	fcVMs := make([]*FirecrackerVM, 0, len(m.vms))
+	for _, fcVM := range m.vms {
+		fcVMs = append(fcVMs, fcVM)
+	}
+	m.vmsMu.RUnlock()
+
+	// A VM waiting to be restarted stays down
+	for _, fcVM := range fcVMs {
+		m.cancelRestart(fcVM)
+	}
+
+	if policy == ShutdownStop {
+		m.stopAll(ctx, fcVMs)
+	}
+
+	detached := 0
+	for _, fcVM := range fcVMs {
+		if fcVM.Process.Load() != nil {
+			detached++
+			continue
+		}
+		// StopVM removes the TAP device of VMs it stops; this catches VMs
+		// that were created but never started
+		if err := m.deleteTAPDevice(fcVM.TAPDevice); err == nil {
+			m.logger.Debugf("Deleted TAP device %s of VM %s", fcVM.TAPDevice, fcVM.ID)
+		}
+		os.Remove(fcVM.SocketPath)
+		m.removeRescueDisk(fcVM.ID)
+	}
+
+	if detached > 0 {
+		m.logger.Infof("Left %d VMs running", detached)
+	}
+}
+
+// stopAll stops running VMs in parallel until ctx ends, then kills the rest
+func (m *Manager) stopAll(ctx context.Context, fcVMs []*FirecrackerVM) {
+	var wg sync.WaitGroup
+	for _, fcVM := range fcVMs {
+		if fcVM.Process.Load() == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(vmID string) {
+			defer wg.Done()
+			grace := time.Duration(m.config.StopTimeoutSeconds) * time.Second
+			if err := m.stopVM(ctx, vmID, grace); err != nil {
+				m.logger.Errorf("Failed to stop VM %s on shutdown: %v", vmID, err)
+			}
+		}(fcVM.ID)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		m.logger.Info("Stopped all VMs")
+	case <-ctx.Done():
+		m.logger.Warn("VMs did not stop in time; killing the rest")
+		for _, fcVM := range fcVMs {
+			if process := fcVM.Process.Load(); process != nil && fcVM.Process.CompareAndSwap(process, nil) {
+				process.Kill()
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// stopPollInterval is how often a VM asked to shut down is checked for
+const stopPollInterval = 100 * time.Millisecond
+
+// stopProcess ends a VM's Firecracker process. With a grace period the guest
+// is sent Ctrl-Alt-Del, which a guest booted with reboot=k answers by shutting
+// down and making Firecracker exit; the process is killed if it is still
+// running when grace or ctx runs out, or at once if the guest can't be asked.
+func (m *Manager) stopProcess(ctx context.Context, fcVM *FirecrackerVM, process *os.Process, grace time.Duration) {
+	if grace > 0 {
+		if m.shutdownGuest(ctx, fcVM, process, grace) {
+			m.logger.Infof("Guest of VM %s shut down", fcVM.ID)
+			return
+		}
+	}
+
+	if err := process.Kill(); err != nil {
+		m.logger.Warnf("Failed to kill VM process: %v", err)
+	}
+}
+
+// shutdownGuest sends Ctrl-Alt-Del to a VM and waits up to grace for its
+// Firecracker process to exit, reporting whether it did
+func (m *Manager) shutdownGuest(ctx context.Context, fcVM *FirecrackerVM, process *os.Process, grace time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, grace)
+	defer cancel()
+
+	client := newClient(fcVM.SocketPath, fcVM.breaker)
+	if err := client.Action(ctx, ActionSendCtrlAltDel); err != nil {
+		m.logger.Warnf("Failed to send Ctrl-Alt-Del to VM %s, killing it: %v", fcVM.ID, err)
+		return false
+	}
+
+	ticker := time.NewTicker(stopPollInterval)
+	defer ticker.Stop()
+	for {
+		// The supervisor reaps the process as soon as it exits
+		if !firecrackerAlive(process.Pid, fcVM.SocketPath) {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			m.logger.Warnf("Guest of VM %s did not shut down within %s, killing it", fcVM.ID, grace)
+			return false
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package firecracker
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// adoptedPollInterval is how often a Firecracker process adopted after a
+// restart, which can't be waited on, is checked for
+const adoptedPollInterval = 2 * time.Second
+
+// supervise waits in the background for a VM's Firecracker process to exit.
+// StopVM and DeleteVM clear fcVM.Process before killing it, so an exit that
+// finds it still set wasn't asked for and is recorded by processExited.
+func (m *Manager) supervise(vmID string, fcVM *FirecrackerVM, cmd *exec.Cmd) {
+	go func() {
+		cmd.Wait()
+		code, how := -1, "exited"
+		if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok {
+			code, how = exitCode(status), describeWaitStatus(status)
+		}
+		m.processExited(vmID, fcVM, cmd.Process, code, how)
+	}()
+}
+
+// superviseAdopted watches a Firecracker process adopted by Reconcile. It
+// belongs to an earlier orchestrator, so its exit status can't be collected.
+func (m *Manager) superviseAdopted(vmID string, fcVM *FirecrackerVM, process *os.Process) {
+	go func() {
+		ticker := time.NewTicker(adoptedPollInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if fcVM.Process.Load() != process {
+				return
+			}
+			if !firecrackerAlive(process.Pid, fcVM.SocketPath) {
+				m.processExited(vmID, fcVM, process, -1, "exited with unknown status")
+				return
+			}
+		}
+	}()
+}
+
+// processExited records the end of a Firecracker process the orchestrator
+// didn't stop. A clean exit means the guest shut itself down, and the VM is
+// marked stopped; anything else, or an exit after a guest kernel panic,
+// marks it crashed and runs its crash hooks. Either way the process's
+// socket, TAP device and rescue disk are cleaned up as StopVM would, and the
+// VM is restarted if its restart policy says so.
+func (m *Manager) processExited(vmID string, fcVM *FirecrackerVM, process *os.Process, code int, how string) {
+	if !fcVM.Process.CompareAndSwap(process, nil) {
+		return
+	}
+
+	os.Remove(fcVM.SocketPath)
+	m.removeRescueDisk(vmID)
+	if err := m.deleteTAPDevice(fcVM.TAPDevice); err != nil {
+		m.logger.Warnf("Failed to delete TAP device: %v", err)
+	}
+
+	vm, err := m.db.GetVM(vmID)
+	if err != nil {
+		m.logger.Errorf("Failed to get VM %s after its Firecracker process %s: %v", vmID, how, err)
+		return
+	}
+
+	// A guest panic already put the VM in error and ran its crash hooks
+	panicked := vm.Status == "error"
+	rescued := vm.Status == "rescue"
+	switch {
+	case panicked:
+		vm.Status = "crashed"
+	case code == 0:
+		vm.Status = "stopped"
+		vm.StatusReason = "guest shut down"
+	default:
+		vm.Status = "crashed"
+		vm.StatusReason = "Firecracker process " + how
+	}
+	now := time.Now()
+	vm.ExitCode = code
+	vm.ExitedAt = &now
+	vm.PID = 0
+
+	// A rescue boot is a one-off, so it isn't restarted
+	var delay time.Duration
+	var restart bool
+	if !rescued {
+		var note string
+		delay, note, restart = m.planRestart(fcVM, vm.Status, vm.RestartPolicy)
+		if note != "" {
+			vm.StatusReason += "; " + note
+		}
+	}
+	if err := m.db.UpdateVM(vm); err != nil {
+		m.logger.Errorf("Failed to record exit of VM %s: %v", vmID, err)
+		return
+	}
+	if restart {
+		m.scheduleRestart(vmID, fcVM, delay)
+	}
+
+	if vm.Status == "stopped" {
+		m.logger.Infof("VM %s shut down (Firecracker process %d %s)", vmID, process.Pid, how)
+		return
+	}
+	m.logger.Warnf("VM %s crashed: Firecracker process %d %s", vmID, process.Pid, how)
+	if panicked {
+		return
+	}
+	if err := m.runHooksWithReason(vm, HookCrash, vm.StatusReason); err != nil {
+		m.logger.Warnf("VM %s %v", vmID, err)
+	}
+}
+
+// exitCode returns a process's exit status, or 128 plus the signal that
+// killed it, as a shell would report it
+func exitCode(status syscall.WaitStatus) int {
+	if status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return status.ExitStatus()
+}
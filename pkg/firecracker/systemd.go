@@ -0,0 +1,36 @@
+package firecracker
+
+import (
+	"os/exec"
+)
+
+// systemdScope wraps a command in systemd-run so the VM process runs in its own
+// transient scope. systemd then accounts its CPU and memory, applies the slice's
+// limits and OOM handling, and leaves it running when the orchestrator's own
+// unit is stopped or restarted.
+func (m *Manager) systemdScope(vmID string, cmd *exec.Cmd) *exec.Cmd {
+	args := []string{
+		"--scope",
+		"--unit", scopeUnit(vmID),
+		"--description", "Firecracker VM " + vmID,
+		"--collect",
+		"--quiet",
+		"--property", "CPUAccounting=yes",
+		"--property", "MemoryAccounting=yes",
+	}
+	if m.config.SystemdSlice != "" {
+		args = append(args, "--slice", m.config.SystemdSlice)
+	}
+	args = append(args, "--")
+	args = append(args, cmd.Args...)
+
+	wrapped := exec.Command("systemd-run", args...)
+	wrapped.Env = cmd.Env
+	wrapped.Dir = cmd.Dir
+	return wrapped
+}
+
+// scopeUnit is the name of the transient scope a VM runs in
+func scopeUnit(vmID string) string {
+	return "firecracker-" + vmID + ".scope"
+}
@@ -0,0 +1,63 @@
+package firecracker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+)
+
+// assignTAPDevice names a VM's TAP device TAP_DEVICE_BASE plus the lowest
+// index no VM holds and no host interface uses, and persists it. Names are
+// freed when their VM is deleted. A VM keeps a name it already holds; a device
+// of that name left on the host, e.g. by an earlier failed create, is the
+// VM's own and is deleted so it can be made again. Only if that fails does
+// the VM get a new name.
+func (m *Manager) assignTAPDevice(vm *database.VM) error {
+	if vm.TAPDevice != "" {
+		if !tapDeviceExists(vm.TAPDevice) {
+			return nil
+		}
+		err := m.deleteTAPDevice(vm.TAPDevice)
+		if err == nil {
+			return nil
+		}
+		m.logger.Warnf("Failed to delete leftover TAP device %s of VM %s, assigning another: %v", vm.TAPDevice, vm.ID, err)
+	}
+
+	m.tapMu.Lock()
+	defer m.tapMu.Unlock()
+
+	held, err := m.db.ListTAPDevices()
+	if err != nil {
+		return fmt.Errorf("failed to list TAP devices: %w", err)
+	}
+	taken := make(map[string]bool, len(held))
+	for _, tap := range held {
+		taken[tap] = true
+	}
+
+	for i := 0; ; i++ {
+		name := fmt.Sprintf("%s%d", m.config.TAPDeviceBase, i)
+		// Linux interface names are at most 15 bytes
+		if len(name) > 15 {
+			return fmt.Errorf("no free TAP device names under %q", m.config.TAPDeviceBase)
+		}
+		if taken[name] || tapDeviceExists(name) {
+			continue
+		}
+
+		vm.TAPDevice = name
+		if err := m.db.UpdateVM(vm); err != nil {
+			return fmt.Errorf("failed to save VM TAP device: %w", err)
+		}
+		return nil
+	}
+}
+
+// tapDeviceExists reports whether the host has a network interface called name
+func tapDeviceExists(name string) bool {
+	_, err := os.Stat(filepath.Join("/sys/class/net", name))
+	return err == nil
+}
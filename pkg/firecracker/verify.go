@@ -0,0 +1,179 @@
+package firecracker
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// ErrImageUnverified is returned when an image fails or lacks verification
+var ErrImageUnverified = errors.New("image verification failed")
+
+// imageVerifier checks image checksums and minisign signatures, caching results
+// so multi-gigabyte root filesystems are only hashed again after they change
+type imageVerifier struct {
+	publicKey string
+	strict    bool
+
+	mu       sync.Mutex
+	verified map[string]imageStamp
+}
+
+// imageStamp identifies the version of a file that was verified
+type imageStamp struct {
+	size    int64
+	modTime time.Time
+	sha256  string
+}
+
+func newImageVerifier(publicKey string, strict bool) *imageVerifier {
+	return &imageVerifier{
+		publicKey: publicKey,
+		strict:    strict,
+		verified:  make(map[string]imageStamp),
+	}
+}
+
+// Verify checks an image against an expected SHA-256 checksum and, when a
+// public key is configured, its detached <path>.minisig signature. It returns
+// whether the image was verified; in strict mode an unverified image is an error.
+func (v *imageVerifier) Verify(path, expectedSHA256 string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat image %s: %w", path, err)
+	}
+
+	stamp := imageStamp{size: info.Size(), modTime: info.ModTime(), sha256: strings.ToLower(expectedSHA256)}
+
+	v.mu.Lock()
+	cached, ok := v.verified[path]
+	v.mu.Unlock()
+	if ok && cached == stamp {
+		return true, nil
+	}
+
+	verified := false
+
+	if expectedSHA256 != "" {
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return false, err
+		}
+		if sum != stamp.sha256 {
+			return false, fmt.Errorf("%s has sha256 %s, expected %s: %w", path, sum, stamp.sha256, ErrImageUnverified)
+		}
+		verified = true
+	}
+
+	if v.publicKey != "" {
+		sigPath := path + ".minisig"
+		if _, err := os.Stat(sigPath); err == nil {
+			if err := verifyMinisign(v.publicKey, path, sigPath); err != nil {
+				return false, fmt.Errorf("%s: %v: %w", path, err, ErrImageUnverified)
+			}
+			verified = true
+		}
+	}
+
+	if !verified {
+		if v.strict {
+			return false, fmt.Errorf("%s has no checksum or signature to verify: %w", path, ErrImageUnverified)
+		}
+		return false, nil
+	}
+
+	v.mu.Lock()
+	v.verified[path] = stamp
+	v.mu.Unlock()
+
+	return true, nil
+}
+
+// fileSHA256 returns the hex-encoded SHA-256 digest of a file
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyMinisign verifies a prehashed (ED) minisign signature of a file.
+// The public key is the base64 line from a minisign .pub file.
+func verifyMinisign(publicKey, path, sigPath string) error {
+	pk, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil || len(pk) != 42 || string(pk[:2]) != "Ed" {
+		return errors.New("invalid minisign public key")
+	}
+	keyID, key := pk[2:10], ed25519.PublicKey(pk[10:])
+
+	sigFile, err := os.Open(sigPath)
+	if err != nil {
+		return fmt.Errorf("failed to open signature: %w", err)
+	}
+	defer sigFile.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(sigFile)
+	for scanner.Scan() && len(lines) < 4 {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return errors.New("malformed minisign signature")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sig) != 74 {
+		return errors.New("malformed minisign signature")
+	}
+	if string(sig[:2]) != "ED" {
+		return errors.New("only prehashed (ED) minisign signatures are supported")
+	}
+	if !bytes.Equal(sig[2:10], keyID) {
+		return errors.New("signature was made with a different key")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h, _ := blake2b.New512(nil)
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	if !ed25519.Verify(key, h.Sum(nil), sig[10:]) {
+		return errors.New("signature does not match image")
+	}
+
+	globalSig, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return errors.New("malformed minisign trusted comment signature")
+	}
+	trusted := append(append([]byte{}, sig[10:]...), strings.TrimPrefix(lines[2], "trusted comment: ")...)
+	if !ed25519.Verify(key, trusted, globalSig) {
+		return errors.New("trusted comment signature does not match")
+	}
+
+	return nil
+}
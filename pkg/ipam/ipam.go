@@ -0,0 +1,218 @@
+// Package ipam hands out guest IPv4 addresses from a pool inside the VM subnet
+// and records each one as a lease in the database, so an address stays taken
+// across restarts until the VM holding it is deleted.
+package ipam
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+)
+
+var (
+	// ErrInvalidIP is returned for addresses that can't be assigned to a guest
+	ErrInvalidIP = errors.New("invalid IP address")
+	// ErrIPInUse is returned when an address is already leased to another VM
+	ErrIPInUse = errors.New("IP address in use")
+	// ErrPoolExhausted is returned when every address in the pool is taken
+	ErrPoolExhausted = errors.New("no free IP addresses left")
+)
+
+// Pool allocates addresses in a subnet. Automatic allocation draws from the
+// pool range, .10 up to the last host address unless a narrower pool CIDR is
+// configured; static addresses may be anywhere in the subnet. Pool does no
+// locking of its own, callers serialise allocations.
+type Pool struct {
+	db      *database.Database
+	subnet  *net.IPNet
+	gateway net.IP
+	first   uint32 // first address handed out automatically
+	last    uint32 // last address handed out automatically
+}
+
+// New parses subnet, whose first host address is the gateway, and the
+// optional pool CIDR, which must lie inside it. db may be nil to only check
+// the configuration.
+func New(db *database.Database, subnet, pool string) (*Pool, error) {
+	_, network, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VM subnet %s: %w", subnet, err)
+	}
+	if network.IP.To4() == nil {
+		return nil, fmt.Errorf("VM subnet %s is not IPv4", subnet)
+	}
+	ones, bits := network.Mask.Size()
+	if bits-ones < 2 {
+		return nil, fmt.Errorf("VM subnet %s has no room for guests", subnet)
+	}
+
+	base := binary.BigEndian.Uint32(network.IP.To4())
+	broadcast := base | (1<<uint(bits-ones) - 1)
+	p := &Pool{
+		db:      db,
+		subnet:  network,
+		gateway: toIP(base + 1),
+		first:   base + 10,
+		last:    broadcast - 1,
+	}
+
+	if pool != "" {
+		_, r, err := net.ParseCIDR(pool)
+		if err != nil {
+			return nil, fmt.Errorf("invalid VM IP pool %s: %w", pool, err)
+		}
+		rOnes, rBits := r.Mask.Size()
+		if r.IP.To4() == nil || rOnes < ones || !network.Contains(r.IP) {
+			return nil, fmt.Errorf("VM IP pool %s is not inside the VM subnet %s", pool, network)
+		}
+		rBase := binary.BigEndian.Uint32(r.IP.To4())
+		p.first = rBase
+		p.last = rBase | (1<<uint(rBits-rOnes) - 1)
+		// The network, gateway and broadcast addresses are never handed out
+		if p.first <= base+1 {
+			p.first = base + 2
+		}
+		if p.last >= broadcast {
+			p.last = broadcast - 1
+		}
+	}
+	if p.first > p.last {
+		return nil, fmt.Errorf("VM IP pool in %s has no usable addresses", network)
+	}
+
+	return p, nil
+}
+
+// Subnet returns the subnet addresses are assigned in
+func (p *Pool) Subnet() *net.IPNet {
+	return p.subnet
+}
+
+// Gateway returns the subnet's gateway address
+func (p *Pool) Gateway() net.IP {
+	return p.gateway
+}
+
+// Range returns the first and last address handed out automatically
+func (p *Pool) Range() (net.IP, net.IP) {
+	return toIP(p.first), toIP(p.last)
+}
+
+// Size returns how many addresses the pool range holds
+func (p *Pool) Size() int {
+	return int(p.last - p.first + 1)
+}
+
+// Contains reports whether ip is in the pool range
+func (p *Pool) Contains(ip string) bool {
+	addr := net.ParseIP(ip).To4()
+	if addr == nil {
+		return false
+	}
+	n := binary.BigEndian.Uint32(addr)
+	return n >= p.first && n <= p.last
+}
+
+// Validate checks that ip can be given to a guest: it lies inside the subnet
+// and is not the network, gateway or broadcast address
+func (p *Pool) Validate(ip string) error {
+	addr := net.ParseIP(ip).To4()
+	if addr == nil {
+		return fmt.Errorf("%s is not an IPv4 address: %w", ip, ErrInvalidIP)
+	}
+	if !p.subnet.Contains(addr) {
+		return fmt.Errorf("%s is outside the VM subnet %s: %w", ip, p.subnet, ErrInvalidIP)
+	}
+
+	network := p.subnet.IP.To4()
+	broadcast := make(net.IP, len(network))
+	for i := range network {
+		broadcast[i] = network[i] | ^p.subnet.Mask[i]
+	}
+	for _, reserved := range []net.IP{network, p.gateway, broadcast} {
+		if addr.Equal(reserved) {
+			return fmt.Errorf("%s is reserved in the VM subnet %s: %w", ip, p.subnet, ErrInvalidIP)
+		}
+	}
+	return nil
+}
+
+// Check validates ip and makes sure no VM other than vmID holds it
+func (p *Pool) Check(vmID, ip string) error {
+	if err := p.Validate(ip); err != nil {
+		return err
+	}
+
+	lease, err := p.db.GetIPLease(net.ParseIP(ip).To4().String())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check IP address %s: %w", ip, err)
+	}
+	if lease.VMID != vmID {
+		return fmt.Errorf("%s is assigned to VM %s: %w", ip, lease.VMID, ErrIPInUse)
+	}
+	return nil
+}
+
+// Next returns the first address in the pool range that is neither leased nor
+// skipped, without leasing it
+func (p *Pool) Next(skip map[string]bool) (string, error) {
+	leases, err := p.db.ListIPLeases()
+	if err != nil {
+		return "", fmt.Errorf("failed to list IP leases: %w", err)
+	}
+	leased := make(map[string]bool, len(leases))
+	for _, lease := range leases {
+		leased[lease.IP] = true
+	}
+
+	for n := p.first; n <= p.last; n++ {
+		candidate := toIP(n).String()
+		if !leased[candidate] && !skip[candidate] {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%w in %s", ErrPoolExhausted, p.subnet)
+}
+
+// Lease records that vmID holds ip. Leasing an address the VM already holds
+// is a no-op; one held by another VM fails with ErrIPInUse.
+func (p *Pool) Lease(vmID, ip string) error {
+	if err := p.Check(vmID, ip); err != nil {
+		return err
+	}
+
+	addr := net.ParseIP(ip).To4().String()
+	if _, err := p.db.GetIPLease(addr); err == nil {
+		return nil
+	}
+	if err := p.db.CreateIPLease(&database.IPLease{IP: addr, VMID: vmID}); err != nil {
+		// Lost a race with another writer of the same database
+		if lease, getErr := p.db.GetIPLease(addr); getErr == nil && lease.VMID != vmID {
+			return fmt.Errorf("%s is assigned to VM %s: %w", ip, lease.VMID, ErrIPInUse)
+		}
+		return fmt.Errorf("failed to lease IP address %s: %w", ip, err)
+	}
+	return nil
+}
+
+// Release frees every address vmID holds
+func (p *Pool) Release(vmID string) error {
+	if err := p.db.DeleteIPLeasesByVM(vmID); err != nil {
+		return fmt.Errorf("failed to release IP addresses of VM %s: %w", vmID, err)
+	}
+	return nil
+}
+
+// toIP converts a 32-bit address to net.IP
+func toIP(n uint32) net.IP {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
@@ -0,0 +1,175 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/firecracker"
+)
+
+// TypeVMBulk applies one action to a selection of VMs
+const TypeVMBulk = "vm.bulk"
+
+// Bulk actions
+const (
+	BulkStart  = "start"
+	BulkStop   = "stop"
+	BulkPause  = "pause"
+	BulkResume = "resume"
+	BulkDelete = "delete"
+)
+
+// BulkPayload is the action and the VMs it applies to, resolved when the job
+// was submitted so the selection doesn't shift while it runs
+type BulkPayload struct {
+	Action string   `json:"action"`
+	VMIDs  []string `json:"vm_ids"`
+}
+
+// BulkProgress is a bulk job's result, saved after every VM so it can be
+// followed while the job runs and resumed if the worker dies
+type BulkProgress struct {
+	Action    string            `json:"action"`
+	Total     int               `json:"total"`
+	Completed int               `json:"completed"`
+	Succeeded []string          `json:"succeeded"`
+	Skipped   []string          `json:"skipped"` // already in the state the action leads to
+	Failed    map[string]string `json:"failed"`  // VM ID to error
+}
+
+// done reports whether a VM was already handled, by this attempt or an earlier one
+func (p *BulkProgress) done(vmID string) bool {
+	if _, failed := p.Failed[vmID]; failed {
+		return true
+	}
+	for _, ids := range [][]string{p.Succeeded, p.Skipped} {
+		for _, id := range ids {
+			if id == vmID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bulkHandler runs bulk jobs one VM at a time. A VM the action fails on is
+// recorded and the rest carry on, so the job itself only fails if it is
+// interrupted.
+func bulkHandler(vmManager *firecracker.Manager, db *database.Database) Handler {
+	return func(ctx context.Context, job *database.Job) (interface{}, error) {
+		var payload BulkPayload
+		if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+
+		progress := BulkProgress{
+			Action:    payload.Action,
+			Total:     len(payload.VMIDs),
+			Succeeded: []string{},
+			Skipped:   []string{},
+		}
+		if job.Result != "" {
+			if err := json.Unmarshal([]byte(job.Result), &progress); err != nil {
+				return nil, fmt.Errorf("invalid progress: %w", err)
+			}
+		}
+		if progress.Failed == nil {
+			progress.Failed = make(map[string]string)
+		}
+
+		for _, vmID := range payload.VMIDs {
+			if progress.done(vmID) {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			skipped, err := applyBulkAction(vmManager, db, payload.Action, vmID)
+			switch {
+			case err != nil:
+				progress.Failed[vmID] = err.Error()
+			case skipped:
+				progress.Skipped = append(progress.Skipped, vmID)
+			default:
+				progress.Succeeded = append(progress.Succeeded, vmID)
+			}
+			progress.Completed++
+
+			data, err := json.Marshal(progress)
+			if err != nil {
+				return nil, err
+			}
+			if err := db.SaveJobProgress(job.ID, job.WorkerID, string(data)); err != nil {
+				return nil, fmt.Errorf("failed to save progress: %w", err)
+			}
+		}
+
+		return progress, nil
+	}
+}
+
+// applyBulkAction applies an action to one VM, reporting whether it was
+// skipped because the VM is already where the action would leave it
+func applyBulkAction(vmManager *firecracker.Manager, db *database.Database, action, vmID string) (bool, error) {
+	vm, err := db.GetVM(vmID)
+	if errors.Is(err, sql.ErrNoRows) {
+		if action == BulkDelete {
+			return true, nil
+		}
+		return false, errors.New("VM not found")
+	}
+	if err != nil {
+		return false, err
+	}
+
+	active := vm.Status == "running" || vm.Status == "paused" || vm.Status == "rescue"
+	switch action {
+	case BulkStart:
+		if active {
+			return true, nil
+		}
+		return false, vmManager.StartVM(vmID)
+	case BulkStop:
+		if !active {
+			return true, nil
+		}
+		return false, vmManager.StopVM(vmID, false)
+	case BulkPause:
+		if vm.Status == "paused" {
+			return true, nil
+		}
+		return false, vmManager.PauseVM(vmID)
+	case BulkResume:
+		if vm.Status == "running" {
+			return true, nil
+		}
+		return false, vmManager.ResumeVM(vmID)
+	case BulkDelete:
+		// The selection was checked when the job was queued, which may be a while ago
+		containerID, err := db.DedicatedContainerID(vmID)
+		if err != nil {
+			return false, err
+		}
+		if containerID != "" {
+			return false, fmt.Errorf("VM is dedicated to container %s, which it is deleted with", containerID)
+		}
+		vm.Status = "deleting"
+		vm.StatusReason = ""
+		if err := db.UpdateVM(vm); err != nil {
+			return false, err
+		}
+		if err := vmManager.DeleteVM(vmID); err != nil {
+			vm.Status = "error"
+			vm.StatusReason = "deletion failed: " + err.Error()
+			db.UpdateVM(vm)
+			return false, err
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("unknown action %q", action)
+}
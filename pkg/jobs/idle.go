@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/firecracker"
+	"github.com/sirupsen/logrus"
+)
+
+// IdlePolicy decides what happens to VMs that stop doing work
+type IdlePolicy struct {
+	After  time.Duration // how long a VM must stay idle before the action is taken
+	Action string        // notify, stop or delete; a VM's idle_action overrides it
+}
+
+// RunIdleReaper samples VM activity every interval and applies the idle policy to
+// running VMs that have been idle for policy.After, until the context is
+// cancelled. Each idle period is acted on once; quarantined VMs are left alone.
+func RunIdleReaper(ctx context.Context, db *database.Database, vmManager *firecracker.Manager, logger *logrus.Logger, policy IdlePolicy, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// handled maps VMs to the start of the idle period already acted on
+	handled := make(map[string]time.Time)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		idle := make(map[string]bool)
+		for _, activity := range vmManager.SampleActivity() {
+			if activity.IdleSince == nil {
+				continue
+			}
+			idle[activity.VMID] = true
+			if time.Since(*activity.IdleSince) < policy.After || handled[activity.VMID].Equal(*activity.IdleSince) {
+				continue
+			}
+			handled[activity.VMID] = *activity.IdleSince
+
+			vm, err := db.GetVM(activity.VMID)
+			if err != nil {
+				logger.Errorf("Failed to get idle VM %s: %v", activity.VMID, err)
+				continue
+			}
+			if vm.Status != "running" || vm.Quarantined {
+				continue
+			}
+			applyIdleAction(db, vmManager, logger, vm, policy.Action, time.Since(*activity.IdleSince))
+		}
+
+		for id := range handled {
+			if !idle[id] {
+				delete(handled, id)
+			}
+		}
+	}
+}
+
+// applyIdleAction runs the VM's idle hooks and then stops or deletes it if its
+// idle action says so
+func applyIdleAction(db *database.Database, vmManager *firecracker.Manager, logger *logrus.Logger, vm *database.VM, defaultAction string, idleFor time.Duration) {
+	action := vm.IdleAction
+	if action == "" {
+		action = defaultAction
+	}
+	if action == firecracker.IdleActionNone {
+		return
+	}
+
+	logger.Warnf("VM %s (%s) has been idle for %s; action: %s", vm.ID, vm.Name, idleFor.Round(time.Minute), action)
+	if err := vmManager.RunIdleHooks(vm.ID); err != nil {
+		logger.Warnf("Idle hooks of VM %s failed: %v", vm.ID, err)
+	}
+
+	switch action {
+	case firecracker.IdleActionStop:
+		if err := vmManager.StopVM(vm.ID, false); err != nil {
+			logger.Errorf("Failed to stop idle VM %s: %v", vm.ID, err)
+		}
+	case firecracker.IdleActionDelete:
+		vm.Status = "deleting"
+		vm.StatusReason = ""
+		if err := db.UpdateVM(vm); err != nil {
+			logger.Errorf("Failed to mark idle VM %s as deleting: %v", vm.ID, err)
+			return
+		}
+		job, err := Enqueue(db, TypeVMDelete, vm.ID, VMPayload{VMID: vm.ID}, 5)
+		if err != nil {
+			markVMError(db, logger, vm, "failed to enqueue deletion: "+err.Error())
+			return
+		}
+		logger.Infof("Idle VM %s marked for deletion by job %s", vm.ID, job.ID)
+	}
+}
@@ -0,0 +1,256 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Handler runs a job and returns a JSON-encodable result. Jobs are delivered at
+// least once, so handlers must be safe to run again after a partial attempt.
+type Handler func(ctx context.Context, job *database.Job) (interface{}, error)
+
+// DeadHandler cleans up after a job that has been dead-lettered, once its last
+// attempt failed or its worker stopped heartbeating on it
+type DeadHandler func(job *database.Job) error
+
+// Queue runs persisted jobs with a pool of workers
+type Queue struct {
+	db       *database.Database
+	logger   *logrus.Logger
+	handlers map[string]Handler
+	dead     map[string]DeadHandler
+	workerID string
+
+	pollInterval     time.Duration
+	heartbeatTimeout time.Duration
+	retention        time.Duration
+}
+
+// pruneInterval is how often finished jobs past their retention are deleted
+const pruneInterval = time.Hour
+
+// NewQueue creates a job queue. A worker that hasn't heartbeated for
+// heartbeatTimeout is presumed dead and its job is handed to another worker.
+// Succeeded and dead jobs are deleted once they are older than retention,
+// unless it is zero.
+func NewQueue(db *database.Database, logger *logrus.Logger, heartbeatTimeout, retention time.Duration) *Queue {
+	hostname, _ := os.Hostname()
+	return &Queue{
+		db:               db,
+		logger:           logger,
+		handlers:         make(map[string]Handler),
+		dead:             make(map[string]DeadHandler),
+		workerID:         fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.New().String()[:8]),
+		pollInterval:     time.Second,
+		heartbeatTimeout: heartbeatTimeout,
+		retention:        retention,
+	}
+}
+
+// Register sets the handler for a job type. Register all handlers before Run.
+func (q *Queue) Register(jobType string, handler Handler) {
+	q.handlers[jobType] = handler
+}
+
+// OnDead sets the function run when a job of a type is dead-lettered. Register
+// it along with the handler, before Run.
+func (q *Queue) OnDead(jobType string, handler DeadHandler) {
+	q.dead[jobType] = handler
+}
+
+// buried runs the dead handler of a dead-lettered job, if its type has one
+func (q *Queue) buried(job *database.Job) {
+	handler, ok := q.dead[job.Type]
+	if !ok {
+		return
+	}
+	if err := handler(job); err != nil {
+		q.logger.Errorf("Failed to clean up after dead job %s: %v", job.ID, err)
+	}
+}
+
+// Enqueue persists a new job with a JSON-encoded payload. resourceID names what
+// the job acts on so its jobs can be listed.
+func Enqueue(db *database.Database, jobType, resourceID string, payload interface{}, maxAttempts int) (*database.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job payload: %w", err)
+	}
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	job := &database.Job{
+		ID:          uuid.New().String(),
+		Type:        jobType,
+		ResourceID:  resourceID,
+		Payload:     string(data),
+		MaxAttempts: maxAttempts,
+	}
+	if err := db.CreateJob(job); err != nil {
+		return nil, fmt.Errorf("failed to enqueue job: %w", err)
+	}
+	return job, nil
+}
+
+// Run starts the given number of workers, a reaper for jobs abandoned by dead
+// workers and a pruner for old finished jobs, and blocks until the context is
+// cancelled and running jobs return
+func (q *Queue) Run(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		q.reapStaleJobs(ctx)
+	}()
+
+	if q.retention > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.pruneJobs(ctx)
+		}()
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			q.work(ctx, fmt.Sprintf("%s/%d", q.workerID, n))
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+// work claims and runs jobs until the context is cancelled
+func (q *Queue) work(ctx context.Context, workerID string) {
+	types := make([]string, 0, len(q.handlers))
+	for t := range q.handlers {
+		types = append(types, t)
+	}
+
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		job, err := q.db.ClaimJob(workerID, types)
+		if err != nil {
+			q.logger.Errorf("Failed to claim job: %v", err)
+		}
+		if job != nil {
+			q.run(ctx, workerID, job)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// run executes one claimed job, heartbeating while it runs
+func (q *Queue) run(ctx context.Context, workerID string, job *database.Job) {
+	q.logger.Infof("Running %s job %s (attempt %d/%d)", job.Type, job.ID, job.Attempts, job.MaxAttempts)
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		ticker := time.NewTicker(q.heartbeatTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-jobCtx.Done():
+				return
+			case <-ticker.C:
+				if err := q.db.HeartbeatJob(job.ID, workerID); err != nil {
+					q.logger.Warnf("Lost job %s: %v", job.ID, err)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	result, err := q.handlers[job.Type](jobCtx, job)
+	if err == nil {
+		var data []byte
+		if data, err = json.Marshal(result); err == nil {
+			if err := q.db.CompleteJob(job.ID, workerID, string(data)); err != nil {
+				q.logger.Errorf("Failed to complete job %s: %v", job.ID, err)
+			}
+			q.logger.Infof("Job %s succeeded", job.ID)
+			return
+		}
+	}
+
+	// Back off exponentially between attempts: 5s, 10s, 20s, ...
+	retryAfter := 5 * time.Second << (job.Attempts - 1)
+	if retryAfter > 10*time.Minute || retryAfter <= 0 {
+		retryAfter = 10 * time.Minute
+	}
+	if ferr := q.db.FailJob(job.ID, workerID, err.Error(), retryAfter); ferr != nil {
+		q.logger.Errorf("Failed to record failure of job %s: %v", job.ID, ferr)
+		return
+	}
+	q.logger.Warnf("Job %s failed (attempt %d/%d): %v", job.ID, job.Attempts, job.MaxAttempts, err)
+	if job.Attempts >= job.MaxAttempts {
+		q.buried(job)
+	}
+}
+
+// reapStaleJobs periodically requeues jobs whose worker stopped heartbeating
+func (q *Queue) reapStaleJobs(ctx context.Context) {
+	ticker := time.NewTicker(q.heartbeatTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, dead, err := q.db.RequeueStaleJobs(q.heartbeatTimeout)
+			if err != nil {
+				q.logger.Errorf("Failed to requeue stale jobs: %v", err)
+			} else if n > 0 {
+				q.logger.Warnf("Requeued %d jobs abandoned by their workers", n)
+			}
+			for _, job := range dead {
+				q.buried(job)
+			}
+		}
+	}
+}
+
+// pruneJobs periodically deletes finished jobs older than the retention period
+func (q *Queue) pruneJobs(ctx context.Context) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := q.db.PruneJobs(q.retention)
+		if err != nil {
+			q.logger.Errorf("Failed to prune finished jobs: %v", err)
+		} else if n > 0 {
+			q.logger.Infof("Pruned %d finished jobs older than %s", n, q.retention)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"fmt"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+// knownVMStatuses are the statuses a VM can be left in between operations
+var knownVMStatuses = map[string]bool{
+	"creating": true,
+	"created":  true,
+	"running":  true,
+	"paused":   true,
+	"rescue":   true,
+	"stopped":  true,
+	"crashed":  true,
+	"error":    true,
+	"deleting": true,
+}
+
+// vmOperationJobs maps the statuses of VMs in the middle of an operation to
+// the job type that carries the operation out
+var vmOperationJobs = map[string]string{
+	"creating": TypeVMCreate,
+	"deleting": TypeVMDelete,
+}
+
+// RecoverVMs settles VMs that a previous process left in the middle of an
+// operation, so no record stays wedged. Run it at startup, before the queue.
+//
+//   - creating or deleting, with a job still queued or running: left to the job
+//   - creating or deleting, with a dead job: marked error with the job's last error
+//   - creating or deleting, with no job (a synchronous create that was cut off,
+//     or a delete that was marked but never enqueued): resumed as a job
+//   - any status this version doesn't know: marked error
+func RecoverVMs(db *database.Database, logger *logrus.Logger) error {
+	vms, err := db.ListVMs()
+	if err != nil {
+		return fmt.Errorf("failed to list VMs: %w", err)
+	}
+
+	for _, vm := range vms {
+		if !knownVMStatuses[vm.Status] {
+			markVMError(db, logger, vm, fmt.Sprintf("left in unknown status %q", vm.Status))
+			continue
+		}
+		jobType, inFlight := vmOperationJobs[vm.Status]
+		if !inFlight {
+			continue
+		}
+
+		latest, _, err := db.ListJobs(database.JobFilter{Type: jobType, ResourceID: vm.ID, Limit: 1})
+		if err != nil {
+			return fmt.Errorf("failed to list jobs of VM %s: %w", vm.ID, err)
+		}
+
+		switch {
+		case len(latest) == 0 || latest[0].Status == database.JobSucceeded:
+			job, err := Enqueue(db, jobType, vm.ID, VMPayload{VMID: vm.ID}, 3)
+			if err != nil {
+				markVMError(db, logger, vm, "interrupted "+jobType+" could not be resumed: "+err.Error())
+				continue
+			}
+			logger.Infof("Resuming interrupted %s of VM %s as job %s", jobType, vm.ID, job.ID)
+		case latest[0].Status == database.JobDead:
+			markVMError(db, logger, vm, jobType+" job failed: "+latest[0].LastError)
+		default:
+			logger.Infof("VM %s is still being handled by %s job %s", vm.ID, jobType, latest[0].ID)
+		}
+	}
+
+	return nil
+}
+
+// markVMError records why a VM could not be recovered
+func markVMError(db *database.Database, logger *logrus.Logger, vm *database.VM, reason string) {
+	vm.Status = "error"
+	vm.StatusReason = reason
+	if err := db.UpdateVM(vm); err != nil {
+		logger.Errorf("Failed to mark VM %s as failed: %v", vm.ID, err)
+		return
+	}
+	logger.Warnf("VM %s marked as error: %s", vm.ID, reason)
+}
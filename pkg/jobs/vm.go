@@ -0,0 +1,108 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/abhaybhargav/firecracker-orchestrator/pkg/firecracker"
+)
+
+// Job types
+const (
+	TypeVMCreate = "vm.create"
+	TypeVMDelete = "vm.delete"
+)
+
+// VMPayload identifies the VM a job acts on
+type VMPayload struct {
+	VMID string `json:"vm_id"`
+	// Claim is what a VM being created took out of its reservation, given
+	// back if the creation job dies
+	Claim *firecracker.ReservationClaim `json:"claim,omitempty"`
+}
+
+// RegisterVMHandlers registers handlers for VM lifecycle jobs
+func RegisterVMHandlers(q *Queue, vmManager *firecracker.Manager, db *database.Database) {
+	q.Register(TypeVMCreate, func(ctx context.Context, job *database.Job) (interface{}, error) {
+		var payload VMPayload
+		if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+
+		vm, err := db.GetVM(payload.VMID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get VM %s: %w", payload.VMID, err)
+		}
+
+		// An earlier attempt may have finished before its worker died
+		if vm.Status != "creating" && vm.Status != "error" {
+			return payload, nil
+		}
+
+		if err := vmManager.CreateVM(vm); err != nil {
+			if job.Attempts >= job.MaxAttempts {
+				vm.Status = "error"
+				vm.StatusReason = "creation failed: " + err.Error()
+				db.UpdateVM(vm)
+			}
+			return nil, err
+		}
+		return payload, nil
+	})
+
+	// Give the reservation back what a VM that can't be created took rather
+	// than keep a failed VM holding it
+	q.OnDead(TypeVMCreate, func(job *database.Job) error {
+		var payload VMPayload
+		if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+			return fmt.Errorf("invalid payload: %w", err)
+		}
+		if payload.Claim == nil {
+			return nil
+		}
+
+		// A retried job that died again has already given its claim back
+		if _, err := db.GetVM(payload.VMID); errors.Is(err, sql.ErrNoRows) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to get VM %s: %w", payload.VMID, err)
+		}
+
+		if err := vmManager.ReturnClaim(payload.Claim); err != nil {
+			return err
+		}
+		return vmManager.DeleteVM(payload.VMID)
+	})
+
+	q.Register(TypeVMDelete, func(ctx context.Context, job *database.Job) (interface{}, error) {
+		var payload VMPayload
+		if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+
+		vm, err := db.GetVM(payload.VMID)
+		// An earlier attempt may have purged the record before its worker died
+		if errors.Is(err, sql.ErrNoRows) {
+			return payload, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get VM %s: %w", payload.VMID, err)
+		}
+
+		if err := vmManager.DeleteVM(vm.ID); err != nil {
+			if job.Attempts >= job.MaxAttempts {
+				vm.Status = "error"
+				vm.StatusReason = "deletion failed: " + err.Error()
+				db.UpdateVM(vm)
+			}
+			return nil, err
+		}
+		return payload, nil
+	})
+
+	q.Register(TypeVMBulk, bulkHandler(vmManager, db))
+}
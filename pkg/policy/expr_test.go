@@ -0,0 +1,114 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func evalExpr(t *testing.T, expression string, vars map[string]interface{}) (interface{}, error) {
+	t.Helper()
+	n, err := parse(expression)
+	if err != nil {
+		t.Fatalf("parse %q: %v", expression, err)
+	}
+	return n.eval(vars)
+}
+
+func TestEval(t *testing.T) {
+	vars := map[string]interface{}{
+		"object": map[string]interface{}{
+			"name":   "web-1",
+			"vcpus":  float64(4),
+			"tags":   []interface{}{"prod", "web"},
+			"labels": map[string]interface{}{"team": "infra"},
+		},
+	}
+
+	tests := []struct {
+		expression string
+		want       interface{}
+	}{
+		{"1 + 2 * 3", float64(7)},
+		{"(1 + 2) * 3", float64(9)},
+		{"-object.vcpus", float64(-4)},
+		{"7 / 2", 3.5},
+		{"7 % 3", float64(1)},
+		{"5.5 % 2", 1.5},
+		{"5 % 0.5", float64(0)},
+		{"-7 % 3", float64(-1)},
+		{"'a' + \"b\"", "ab"},
+		{"[1] + [2]", []interface{}{float64(1), float64(2)}},
+		{"object.vcpus <= 4 && object.name.startsWith('web')", true},
+		{"object.vcpus > 8 || object.name.endsWith('-1')", true},
+		{"!(object.vcpus == 4)", false},
+		{"object.name != 'db'", true},
+		{"'prod' in object.tags", true},
+		{"'team' in object.labels", true},
+		{"'x' in object.missing", false},
+		{"object.labels['team'] == 'infra'", true},
+		{"object.tags[1]", "web"},
+		{"object.tags[5]", nil},
+		{"object.missing.deeper", nil},
+		{"object.missing.contains('x')", false},
+		{"size(object.tags) + size(object.name) + size(object.missing)", float64(7)},
+		{"object.name.contains('b-')", true},
+		{"object.name.matches('^web-[0-9]+$')", true},
+		{"'a' < 'b'", true},
+		{"false && object.missing > 1", false},
+	}
+
+	for _, tt := range tests {
+		got, err := evalExpr(t, tt.expression, vars)
+		if err != nil {
+			t.Errorf("%q: %v", tt.expression, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q = %#v, want %#v", tt.expression, got, tt.want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	vars := map[string]interface{}{"object": map[string]interface{}{"name": "web-1"}}
+
+	for _, expression := range []string{
+		"1 / 0",
+		"1 % 0",
+		"0.5 % 0",
+		"'a' - 1",
+		"!1",
+		"-'a'",
+		"1 && true",
+		"undefined_var",
+		"object.name[0]",
+		"[1, 2]['a']",
+		"[1, 2][0.5]",
+		"1 in 'abc'",
+		"object.name.matches('[')",
+		"object.name.unknown('x')",
+		"size(1)",
+		"nosuch(1)",
+	} {
+		if got, err := evalExpr(t, expression, vars); err == nil {
+			t.Errorf("%q = %#v, want an error", expression, got)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expression := range []string{
+		"",
+		"1 +",
+		"(1 + 2",
+		"[1, 2",
+		"'unterminated",
+		"1 2",
+		"a.",
+		"a @ b",
+	} {
+		if _, err := parse(expression); err == nil {
+			t.Errorf("parse %q succeeded, want an error", expression)
+		}
+	}
+}
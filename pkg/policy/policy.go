@@ -0,0 +1,142 @@
+// Package policy evaluates operator-defined rules against VM and container
+// create and update requests before they are applied.
+package policy
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Operations rules can apply to
+const (
+	OperationCreate = "create"
+	OperationUpdate = "update"
+)
+
+// Rule is one policy rule as written in the policy file. A request is allowed
+// only if Expression evaluates to true.
+type Rule struct {
+	Name       string   `yaml:"name"`
+	Resources  []string `yaml:"resources"`  // vm, container; both when empty
+	Operations []string `yaml:"operations"` // create, update; both when empty
+	Expression string   `yaml:"expression"`
+	Message    string   `yaml:"message"` // returned when the rule is violated
+}
+
+// Violation is a rule a request broke
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Engine holds the compiled rules
+type Engine struct {
+	rules    []Rule
+	compiled []node
+}
+
+// Load reads and compiles the rules in a YAML policy file
+func Load(path string) (*Engine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	var file struct {
+		Rules []Rule `yaml:"rules"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", path, err)
+	}
+
+	engine := &Engine{}
+	for i, rule := range file.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("policy rule %d has no name", i+1)
+		}
+		if rule.Expression == "" {
+			return nil, fmt.Errorf("policy rule %s has no expression", rule.Name)
+		}
+		for _, resource := range rule.Resources {
+			if resource != "vm" && resource != "container" {
+				return nil, fmt.Errorf("policy rule %s: unknown resource %q", rule.Name, resource)
+			}
+		}
+		for _, operation := range rule.Operations {
+			if operation != OperationCreate && operation != OperationUpdate {
+				return nil, fmt.Errorf("policy rule %s: unknown operation %q", rule.Name, operation)
+			}
+		}
+
+		compiled, err := parse(rule.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("policy rule %s: %w", rule.Name, err)
+		}
+		if rule.Message == "" {
+			rule.Message = fmt.Sprintf("violates %s", rule.Name)
+		}
+		engine.rules = append(engine.rules, rule)
+		engine.compiled = append(engine.compiled, compiled)
+	}
+	return engine, nil
+}
+
+// Len returns the number of rules
+func (e *Engine) Len() int {
+	if e == nil {
+		return 0
+	}
+	return len(e.rules)
+}
+
+// Evaluate checks a request against the rules for its resource and operation.
+// object and oldObject (nil on create) are the resource as JSON-decoded maps.
+// A rule that fails to evaluate, for example by comparing a string with a
+// number, counts as violated. A nil engine allows everything.
+func (e *Engine) Evaluate(resource, operation string, object, oldObject map[string]interface{}) []Violation {
+	if e == nil {
+		return nil
+	}
+
+	vars := map[string]interface{}{
+		"resource":  resource,
+		"operation": operation,
+		"object":    object,
+		"oldObject": nil,
+	}
+	if oldObject != nil {
+		vars["oldObject"] = oldObject
+	}
+
+	var violations []Violation
+	for i, rule := range e.rules {
+		if !appliesTo(rule.Resources, resource) || !appliesTo(rule.Operations, operation) {
+			continue
+		}
+
+		result, err := e.compiled[i].eval(vars)
+		if err != nil {
+			violations = append(violations, Violation{Rule: rule.Name, Message: fmt.Sprintf("%s (rule failed to evaluate: %v)", rule.Message, err)})
+			continue
+		}
+		if allowed, ok := result.(bool); !ok || !allowed {
+			violations = append(violations, Violation{Rule: rule.Name, Message: rule.Message})
+		}
+	}
+	return violations
+}
+
+// appliesTo reports whether a rule scoped to values covers value
+func appliesTo(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// fileStore replicates into a local directory, such as a network or bucket mount
+type fileStore struct {
+	dir string
+}
+
+func (s *fileStore) String() string {
+	return "file://" + s.dir
+}
+
+// Put copies the file next to its destination and renames it into place, so
+// the replica is never a partial copy
+func (s *fileStore) Put(ctx context.Context, name, path string) error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create replica directory: %w", err)
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(s.dir, "."+name+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create replica: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write replica: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write replica: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write replica: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, name))
+}
@@ -0,0 +1,144 @@
+package replication
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/config"
+	"github.com/abhaybhargav/firecracker-orchestrator/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+// snapshotName is the object the latest database snapshot is stored as
+const snapshotName = "orchestrator.db"
+
+// Store is somewhere database snapshots are replicated to
+type Store interface {
+	// Put uploads the file at path as the named object, replacing any earlier one
+	Put(ctx context.Context, name, path string) error
+	// String describes the store for logs, without credentials
+	String() string
+}
+
+// NewStore returns the store for a REPLICA_URL: file:///dir or s3://bucket/prefix
+func NewStore(cfg *config.Config) (Store, error) {
+	u, err := url.Parse(cfg.ReplicaURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid replica URL: %w", err)
+	}
+
+	switch u.Scheme {
+	case "file":
+		return &fileStore{dir: u.Path}, nil
+	case "s3":
+		if cfg.S3AccessKey == "" || cfg.S3SecretKey == "" {
+			return nil, fmt.Errorf("s3 replication needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+		endpoint := cfg.S3Endpoint
+		if endpoint == "" {
+			endpoint = "https://s3." + cfg.S3Region + ".amazonaws.com"
+		}
+		return &s3Store{
+			endpoint:     endpoint,
+			bucket:       u.Host,
+			prefix:       u.Path,
+			region:       cfg.S3Region,
+			accessKey:    cfg.S3AccessKey,
+			secretKey:    cfg.S3SecretKey,
+			sessionToken: cfg.S3SessionToken,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported replica URL scheme %q, use file or s3", u.Scheme)
+	}
+}
+
+// Replicator periodically copies the database to a store
+type Replicator struct {
+	db     *database.Database
+	store  Store
+	logger *logrus.Logger
+	tmpDir string
+
+	mu      sync.Mutex
+	lastSum string // checksum of the last snapshot uploaded
+}
+
+// NewReplicator creates a replicator; snapshots are staged in tmpDir
+func NewReplicator(db *database.Database, store Store, tmpDir string, logger *logrus.Logger) *Replicator {
+	return &Replicator{db: db, store: store, tmpDir: tmpDir, logger: logger}
+}
+
+// Run replicates the database every interval until the context is cancelled
+func (r *Replicator) Run(ctx context.Context, interval time.Duration) {
+	r.logger.Infof("Replicating the database to %s every %s", r.store, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Replicate(ctx); err != nil {
+			r.logger.Errorf("Failed to replicate the database to %s: %v", r.store, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Replicate takes a consistent snapshot of the database and uploads it, unless
+// nothing changed since the last upload
+func (r *Replicator) Replicate(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := os.MkdirAll(r.tmpDir, 0700); err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	path := filepath.Join(r.tmpDir, fmt.Sprintf("snapshot-%d.db", time.Now().UnixNano()))
+	defer os.Remove(path)
+
+	if err := r.db.SnapshotTo(path); err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
+	}
+
+	sum, err := fileChecksum(path)
+	if err != nil {
+		return err
+	}
+	if sum == r.lastSum {
+		return nil
+	}
+
+	if err := r.store.Put(ctx, snapshotName, path); err != nil {
+		return err
+	}
+	r.lastSum = sum
+	r.logger.Debugf("Replicated the database to %s (sha256 %s)", r.store, sum)
+	return nil
+}
+
+// fileChecksum returns the hex SHA-256 of a file
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}